@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	spsw "github.com/spiderswarm/spiderswarm/lib"
+)
+
+const defaultMasterAddr = "http://localhost:8080"
+
+func printClientUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] jobs list [--status <status>]")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] jobs submit <yamlFilePath>")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] jobs show <jobUUID>")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] jobs pause <jobUUID>")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] jobs resume <jobUUID>")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] jobs cancel <jobUUID>")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] managers list")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] workers list")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] schedules list")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] schedules runs <scheduleName>")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] workflows validate <yamlFilePath>")
+	fmt.Println("")
+	fmt.Printf("Master address defaults to SPSW_MASTER_ADDR environment variable or %s.\n", defaultMasterAddr)
+}
+
+func printJSON(x interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(x)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func printJobsTable(jobs []*spsw.Job) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "UUID\tWORKFLOW\tVERSION\tSTATUS\tPENDING\tFINISHED\tFAILED\tSCHEDULED\tUPDATED")
+
+	for _, job := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", job.UUID, job.WorkflowName, job.WorkflowVersion,
+			job.Status, job.Stats.NPendingTasks, job.Stats.NFinishedTasks, job.Stats.NFailedTasks,
+			job.Stats.NScheduledTasks, formatTime(job.UpdatedAt))
+	}
+
+	w.Flush()
+}
+
+func printJobDetails(job *spsw.Job) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "UUID:\t%s\n", job.UUID)
+	fmt.Fprintf(w, "Workflow:\t%s %s\n", job.WorkflowName, job.WorkflowVersion)
+	fmt.Fprintf(w, "Status:\t%s\n", job.Status)
+	fmt.Fprintf(w, "Manager:\t%s\n", job.ManagerUUID)
+	fmt.Fprintf(w, "Created:\t%s\n", formatTime(job.CreatedAt))
+	fmt.Fprintf(w, "Updated:\t%s\n", formatTime(job.UpdatedAt))
+	fmt.Fprintf(w, "Pending tasks:\t%d\n", job.Stats.NPendingTasks)
+	fmt.Fprintf(w, "Finished tasks:\t%d\n", job.Stats.NFinishedTasks)
+	fmt.Fprintf(w, "Failed tasks:\t%d\n", job.Stats.NFailedTasks)
+	fmt.Fprintf(w, "Scheduled tasks:\t%d\n", job.Stats.NScheduledTasks)
+
+	if job.StopReason != "" {
+		fmt.Fprintf(w, "Stopped by:\t%s\n", job.StopReason)
+	}
+
+	if job.Workflow != nil {
+		fmt.Fprintf(w, "Task templates:\t%d\n", len(job.Workflow.TaskTemplates))
+	}
+
+	w.Flush()
+}
+
+func printManagersTable(reports []*spsw.ManagerReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "UUID\tJOB\tJOB STATUS\tLAST SEEN")
+
+	for _, report := range reports {
+		jobUUID := report.JobUUID
+		if jobUUID == "" {
+			jobUUID = "-"
+		}
+
+		jobStatus := report.JobStatus
+		if jobStatus == "" {
+			jobStatus = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", report.ManagerUUID, jobUUID, jobStatus, formatTime(report.CreatedAt))
+	}
+
+	w.Flush()
+}
+
+func printWorkersTable(statuses []*spsw.WorkerStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "UUID\tHOST\tLABELS\tSTATE\tTASK\tUPTIME\tFINISHED\tFAILED\tLAST SEEN")
+
+	for _, status := range statuses {
+		heartbeat := status.Heartbeat
+
+		state := "alive"
+		if status.Stale {
+			state = "stale"
+		}
+
+		scheduledTaskUUID := heartbeat.ScheduledTaskUUID
+		if scheduledTaskUUID == "" {
+			scheduledTaskUUID = "-"
+		}
+
+		labels := spsw.FormatLabels(heartbeat.Labels)
+		if labels == "" {
+			labels = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", heartbeat.WorkerUUID, heartbeat.Host, labels,
+			state, scheduledTaskUUID, heartbeat.Uptime.Truncate(time.Second), heartbeat.NFinishedTasks, heartbeat.NFailedTasks,
+			formatTime(status.LastSeenAt))
+	}
+
+	w.Flush()
+}
+
+func printSchedulesTable(statuses []*spsw.JobScheduleStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tWHEN\tOVERLAP\tNEXT RUN\tLAST JOB")
+
+	for _, status := range statuses {
+		when := status.Schedule.Cron
+		if when == "" {
+			when = "every " + status.Schedule.Interval.String()
+		}
+
+		nextRunAt := formatTime(status.NextRunAt)
+		if status.Schedule.Disabled {
+			nextRunAt = "disabled"
+		}
+
+		lastJobUUID := status.LastJobUUID
+		if lastJobUUID == "" {
+			lastJobUUID = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", status.Schedule.Name, when, status.Schedule.GetOverlapPolicy(),
+			nextRunAt, lastJobUUID)
+	}
+
+	w.Flush()
+}
+
+func printScheduledRunsTable(runs []*spsw.ScheduledRun) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "DUE\tOUTCOME\tJOB\tSTARTED\tMESSAGE")
+
+	for _, run := range runs {
+		jobUUID := run.JobUUID
+		if jobUUID == "" {
+			jobUUID = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", formatTime(run.DueAt), run.Outcome, jobUUID,
+			formatTime(run.StartedAt), run.Message)
+	}
+
+	w.Flush()
+}
+
+// runClient implements `spiderswarm client` subcommand and returns exit code.
+func runClient(args []string) int {
+	flags := flag.NewFlagSet("client", flag.ContinueOnError)
+
+	masterAddr := os.Getenv("SPSW_MASTER_ADDR")
+	if masterAddr == "" {
+		masterAddr = defaultMasterAddr
+	}
+
+	flags.StringVar(&masterAddr, "master", masterAddr, "master API address")
+	jsonOutput := flags.Bool("json", false, "print JSON instead of tables")
+	flags.Usage = printClientUsage
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	args = flags.Args()
+
+	if len(args) < 2 {
+		printClientUsage()
+		return 2
+	}
+
+	client := spsw.NewMasterClient(masterAddr)
+
+	switch args[0] + " " + args[1] {
+	case "jobs list":
+		listFlags := flag.NewFlagSet("jobs list", flag.ContinueOnError)
+		status := listFlags.String("status", "", "only list jobs with given status")
+		listFlags.Usage = printClientUsage
+
+		err = listFlags.Parse(args[2:])
+		if err != nil {
+			return 2
+		}
+
+		jobs, err := client.ListJobs(*status)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *jsonOutput {
+			printJSON(jobs)
+		} else {
+			printJobsTable(jobs)
+		}
+	case "jobs submit":
+		if len(args) != 3 {
+			printClientUsage()
+			return 2
+		}
+
+		workflowYAML, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		job, err := client.SubmitWorkflow(workflowYAML)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *jsonOutput {
+			printJSON(job)
+		} else {
+			printJobDetails(job)
+		}
+	case "jobs show", "jobs pause", "jobs resume", "jobs cancel":
+		if len(args) != 3 {
+			printClientUsage()
+			return 2
+		}
+
+		var job *spsw.Job
+
+		if args[1] == "show" {
+			job, err = client.GetJob(args[2])
+		} else {
+			job, err = client.ControlJob(args[2], args[1])
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *jsonOutput {
+			printJSON(job)
+		} else {
+			printJobDetails(job)
+		}
+	case "managers list":
+		reports, err := client.ListManagers()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *jsonOutput {
+			printJSON(reports)
+		} else {
+			printManagersTable(reports)
+		}
+	case "workers list":
+		statuses, err := client.ListWorkers()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *jsonOutput {
+			printJSON(statuses)
+		} else {
+			printWorkersTable(statuses)
+		}
+	case "schedules list":
+		statuses, err := client.ListSchedules()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *jsonOutput {
+			printJSON(statuses)
+		} else {
+			printSchedulesTable(statuses)
+		}
+	case "schedules runs":
+		if len(args) != 3 {
+			printClientUsage()
+			return 2
+		}
+
+		runs, err := client.ListScheduledRuns(args[2])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *jsonOutput {
+			printJSON(runs)
+		} else {
+			printScheduledRunsTable(runs)
+		}
+	case "workflows validate":
+		if len(args) != 3 {
+			printClientUsage()
+			return 2
+		}
+
+		workflowYAML, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		err = client.ValidateWorkflow(workflowYAML)
+
+		if *jsonOutput {
+			result := map[string]interface{}{"Valid": err == nil}
+			if err != nil {
+				result["Error"] = err.Error()
+			}
+
+			printJSON(result)
+		} else if err == nil {
+			fmt.Println("Valid!")
+		} else {
+			fmt.Println(err)
+		}
+
+		if err != nil {
+			return 1
+		}
+	default:
+		printClientUsage()
+		return 2
+	}
+
+	return 0
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	spsw "github.com/spiderswarm/spiderswarm/lib"
 
 	"github.com/davecgh/go-spew/spew"
@@ -32,7 +34,7 @@ func main() {
 
 	spew.Dump(task)
 
-	err := task.Run()
+	err := task.Run(context.Background())
 	if err != nil {
 		spew.Dump(err)
 	} else {
@@ -216,6 +216,7 @@ func getWorkflow() *spsw.Workflow {
 			spsw.TaskTemplate{
 				TaskName: "ScrapeBookPage",
 				Initial:  false,
+				Priority: 2,
 				ActionTemplates: []spsw.ActionTemplate{
 					spsw.ActionTemplate{
 						Name:       "MakeBookURLAbsolute",
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	spsw "github.com/spiderswarm/spiderswarm/lib"
 
 	"github.com/davecgh/go-spew/spew"
@@ -27,7 +29,7 @@ func main() {
 	task.AddDataPipeBetweenActions(httpAction, spsw.HTTPActionOutputBody, titleXpathAction, spsw.XPathActionInputHTMLBytes)
 	task.AddDataPipeBetweenActions(httpAction, spsw.HTTPActionOutputBody, linkXpathAction, spsw.XPathActionInputHTMLBytes)
 
-	err := task.Run()
+	err := task.Run(context.Background())
 	if err != nil {
 		spew.Dump(err)
 		return
@@ -8,7 +8,9 @@ require (
 	github.com/go-redis/redis/v8 v8.11.4
 	github.com/google/uuid v1.3.0
 	github.com/jinzhu/copier v0.3.2
+	github.com/mattn/go-sqlite3 v1.14.8
 	github.com/ohler55/ojg v1.12.11
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/net v0.0.0-20210428140749-89ef3d95e781
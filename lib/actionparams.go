@@ -0,0 +1,153 @@
+package spsw
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ActionParam describes one of ConstructorParams that action takes in its template.
+// Empty ValueType means that value of any type is accepted. Default, if any, is used
+// when optional param is not given.
+type ActionParam struct {
+	Name      string
+	ValueType string
+	Required  bool
+	Default   *Value
+}
+
+// ActionParamsTable lists constructor params of each action. Actions that are not listed
+// here (e.g. ones registered with RegisterAction only) get their ConstructorParams as is.
+var ActionParamsTable = map[string][]ActionParam{
+	"CSVParseAction": []ActionParam{},
+	"FormExtractionAction": []ActionParam{
+		{Name: "formID", ValueType: ValueTypeString, Required: true},
+	},
+	"HTTPAction": []ActionParam{
+		{Name: "baseURL", ValueType: ValueTypeString, Default: NewValueFromString("")},
+		{Name: "method", ValueType: ValueTypeString, Default: NewValueFromString("GET")},
+		{Name: "canFail", ValueType: ValueTypeBool, Default: NewValueFromBool(false)},
+	},
+	"XPathAction": []ActionParam{
+		{Name: "xpath", ValueType: ValueTypeString, Required: true},
+		{Name: "expectMany", ValueType: ValueTypeBool, Default: NewValueFromBool(false)},
+		{Name: "stripWhitespace", ValueType: ValueTypeBool, Default: NewValueFromBool(false)},
+		{Name: "streamResults", ValueType: ValueTypeBool, Default: NewValueFromBool(false)},
+	},
+	"FieldJoinAction": []ActionParam{
+		{Name: "inputNames", ValueType: ValueTypeStrings, Required: true},
+		{Name: "itemName", ValueType: ValueTypeString, Required: true},
+		{Name: "requireFields", ValueType: ValueTypeStrings},
+	},
+	"TaskPromiseAction": []ActionParam{
+		{Name: "taskName", ValueType: ValueTypeString, Required: true},
+		{Name: "inputNames", ValueType: ValueTypeStrings, Default: NewValueFromStrings([]string{})},
+		{Name: "requireFields", ValueType: ValueTypeStrings},
+		{Name: "priority", ValueType: ValueTypeInt},
+	},
+	"UTF8DecodeAction": []ActionParam{},
+	"UTF8EncodeAction": []ActionParam{},
+	"ConstAction": []ActionParam{
+		{Name: "c", Required: true},
+	},
+	"URLJoinAction": []ActionParam{
+		{Name: "baseURL", ValueType: ValueTypeString, Default: NewValueFromString("")},
+	},
+	"StringMapUpdateAction": []ActionParam{
+		{Name: "overrideKey", ValueType: ValueTypeString, Default: NewValueFromString("")},
+		{Name: "itemName", ValueType: ValueTypeString, Default: NewValueFromString("")},
+	},
+	"URLParseAction": []ActionParam{},
+	"StringCutAction": []ActionParam{
+		{Name: "from", ValueType: ValueTypeString, Required: true},
+		{Name: "to", ValueType: ValueTypeString, Required: true},
+	},
+	"JSONPathAction": []ActionParam{
+		{Name: "jsonPath", ValueType: ValueTypeString, Required: true},
+		{Name: "decode", ValueType: ValueTypeBool, Default: NewValueFromBool(false)},
+		{Name: "expectMany", ValueType: ValueTypeBool, Default: NewValueFromBool(false)},
+	},
+	"LengthThresholdAction": []ActionParam{
+		{Name: "threshold", ValueType: ValueTypeInt, Required: true},
+	},
+}
+
+// RegisterActionParams declares constructor params of registered action, so that its
+// ConstructorParams are checked by Workflow.Validate and filled in with defaults.
+func RegisterActionParams(structName string, params []ActionParam) {
+	ActionParamsTable[structName] = params
+}
+
+// ValidateConstructorParams checks ConstructorParams of action template against params
+// declared for its struct: all of them must be known, of expected type and the required
+// ones must be given.
+func ValidateConstructorParams(actionTempl *ActionTemplate) error {
+	return validateConstructorParams(actionTempl.StructName, actionTempl.ConstructorParams)
+}
+
+func validateConstructorParams(structName string, constructorParams map[string]Value) error {
+	params, found := ActionParamsTable[structName]
+	if !found {
+		return nil
+	}
+
+	paramByName := map[string]ActionParam{}
+
+	for _, param := range params {
+		paramByName[param.Name] = param
+	}
+
+	givenNames := []string{}
+
+	for name := range constructorParams {
+		givenNames = append(givenNames, name)
+	}
+
+	sort.Strings(givenNames)
+
+	for _, name := range givenNames {
+		param, known := paramByName[name]
+		if !known {
+			return fmt.Errorf("Unknown constructor param %s for %s", name, structName)
+		}
+
+		valueType := constructorParams[name].ValueType
+
+		if param.ValueType != "" && valueType != param.ValueType {
+			return fmt.Errorf("Constructor param %s for %s should be %s, not %s", name, structName,
+				param.ValueType, valueType)
+		}
+	}
+
+	for _, param := range params {
+		if _, given := constructorParams[param.Name]; !given && param.Required {
+			return fmt.Errorf("Missing required constructor param %s for %s", param.Name, structName)
+		}
+	}
+
+	return nil
+}
+
+// constructorParamsWithDefaults validates ConstructorParams of action template against
+// params of given struct and returns them with defaults filled in for optional params that
+// were not given. Struct name is passed by constructor itself, as templates made in code
+// don't always have StructName set.
+func constructorParamsWithDefaults(structName string, actionTempl *ActionTemplate) (map[string]Value, error) {
+	err := validateConstructorParams(structName, actionTempl.ConstructorParams)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]Value{}
+
+	for name, value := range actionTempl.ConstructorParams {
+		params[name] = value
+	}
+
+	for _, param := range ActionParamsTable[structName] {
+		if _, given := params[param.Name]; !given && param.Default != nil {
+			params[param.Name] = *param.Default
+		}
+	}
+
+	return params, nil
+}
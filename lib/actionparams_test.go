@@ -0,0 +1,92 @@
+package spsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConstructorParams(t *testing.T) {
+	actionTempl := NewActionTemplate("HTTP1", "HTTPAction", map[string]interface{}{
+		"baseURL": "http://example.com",
+		"canFail": true,
+	})
+
+	assert.Nil(t, ValidateConstructorParams(actionTempl))
+
+	actionTempl.ConstructorParams["baseUrl"] = *NewValueFromString("http://example.com")
+
+	err := ValidateConstructorParams(actionTempl)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Unknown constructor param baseUrl for HTTPAction", err.Error())
+
+	delete(actionTempl.ConstructorParams, "baseUrl")
+	actionTempl.ConstructorParams["canFail"] = *NewValueFromString("true")
+
+	err = ValidateConstructorParams(actionTempl)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Constructor param canFail for HTTPAction should be ValueTypeBool, not ValueTypeString", err.Error())
+
+	actionTempl = NewActionTemplate("XPath1", "XPathAction", map[string]interface{}{"expectMany": true})
+
+	err = ValidateConstructorParams(actionTempl)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Missing required constructor param xpath for XPathAction", err.Error())
+
+	// ConstAction takes value of any type.
+	assert.Nil(t, ValidateConstructorParams(NewActionTemplate("Const1", "ConstAction", map[string]interface{}{"c": 4.2})))
+	assert.NotNil(t, ValidateConstructorParams(NewActionTemplate("Const1", "ConstAction", nil)))
+
+	// Actions without declared params are not checked.
+	assert.Nil(t, ValidateConstructorParams(NewActionTemplate("Unknown1", "UnknownAction", map[string]interface{}{"x": 1})))
+}
+
+func TestConstructorParamsWithDefaults(t *testing.T) {
+	actionTempl := NewActionTemplate("HTTP1", "", map[string]interface{}{"canFail": true})
+
+	params, err := constructorParamsWithDefaults("HTTPAction", actionTempl)
+	assert.Nil(t, err)
+
+	expectParams := map[string]Value{
+		"baseURL": *NewValueFromString(""),
+		"method":  *NewValueFromString("GET"),
+		"canFail": *NewValueFromBool(true),
+	}
+
+	assert.Equal(t, expectParams, params)
+	assert.Equal(t, 1, len(actionTempl.ConstructorParams))
+
+	action, err := NewHTTPActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+	assert.Equal(t, "GET", action.(*HTTPAction).Method)
+
+	actionTempl.ConstructorParams["method"] = *NewValueFromInt(1)
+
+	action, err = NewHTTPActionFromTemplate(actionTempl)
+	assert.Nil(t, action)
+	assert.NotNil(t, err)
+}
+
+func TestRegisterActionParams(t *testing.T) {
+	structName := "TestParamsAction"
+
+	RegisterAction(structName, NewTestAction, []string{}, []string{})
+	RegisterActionParams(structName, []ActionParam{
+		{Name: "limit", ValueType: ValueTypeInt, Default: NewValueFromInt(10)},
+	})
+
+	defer func() {
+		delete(ActionConstructorTable, structName)
+		delete(AllowedInputNameTable, structName)
+		delete(AllowedOutputNameTable, structName)
+		delete(ActionParamsTable, structName)
+	}()
+
+	assert.Nil(t, ValidateConstructorParams(NewActionTemplate("Test1", structName, map[string]interface{}{"limit": 5})))
+	assert.NotNil(t, ValidateConstructorParams(NewActionTemplate("Test1", structName, map[string]interface{}{"limt": 5})))
+
+	// Registering action again drops params that were declared for it.
+	RegisterAction(structName, NewTestAction, []string{}, []string{})
+
+	assert.Nil(t, ValidateConstructorParams(NewActionTemplate("Test1", structName, map[string]interface{}{"limt": 5})))
+}
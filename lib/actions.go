@@ -1,12 +1,18 @@
 package spsw
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
 )
 
-// Action is a single stateless operation that is used as building block for Task.
+// Action is a single stateless operation that is used as building block for Task. Run
+// gets the context of the task, which carries task metadata (see TaskMetadataFromContext)
+// and is cancelled if task runs out of time. Actions that wait on something (e.g. network)
+// should give up once it's done.
 type Action interface {
-	Run() error
+	Run(ctx context.Context) error
 	AddInput(name string, dataPipe *DataPipe) error
 	AddOutput(name string, dataPipe *DataPipe) error
 	GetUniqueID() string
@@ -28,7 +34,7 @@ type AbstractAction struct {
 	UUID               string
 }
 
-type InitFunc func(*ActionTemplate) Action
+type InitFunc func(*ActionTemplate) (Action, error)
 
 var ActionConstructorTable = map[string]InitFunc{
 	"CSVParseAction":        NewCSVParseActionFromTemplate,
@@ -69,8 +75,10 @@ var AllowedInputNameTable = map[string][]string{
 		XPathActionInputHTMLBytes,
 		XPathActionInputHTMLStr,
 	},
-	"FieldJoinAction":   []string{},
-	"TaskPromiseAction": []string{},
+	"FieldJoinAction": []string{},
+	"TaskPromiseAction": []string{
+		TaskPromiseActionInputRefrain,
+	},
 	"UTF8DecodeAction": []string{
 		UTF8DecodeActionInputBytes,
 	},
@@ -152,19 +160,169 @@ var AllowedOutputNameTable = map[string][]string{
 	},
 }
 
+// InputValueTypeTable tells which ValueTypes each action input accepts. Ports carrying
+// items or task promises have DataChunkTypeItem or DataChunkTypePromise as their type.
+// Ports that are not listed here (e.g. named inputs of FieldJoinAction) take anything.
+var InputValueTypeTable = map[string]map[string][]string{
+	"CSVParseAction": map[string][]string{
+		CSVParseActionInputCSVBytes: []string{ValueTypeBytes},
+		CSVParseActionInputCSVStr:   []string{ValueTypeString},
+	},
+	"FormExtractionAction": map[string][]string{
+		FormExtractionActionInputHTMLBytes: []string{ValueTypeBytes},
+		FormExtractionActionInputHTMLStr:   []string{ValueTypeString},
+	},
+	"HTTPAction": map[string][]string{
+		HTTPActionInputBaseURL:   []string{ValueTypeString},
+		HTTPActionInputBody:      []string{ValueTypeBytes},
+		HTTPActionInputCookies:   []string{ValueTypeMapStringToString},
+		HTTPActionInputFormData:  []string{ValueTypeMapStringToString, ValueTypeMapStringToStrings},
+		HTTPActionInputHeaders:   []string{ValueTypeHTTPHeaders},
+		HTTPActionInputURLParams: []string{ValueTypeMapStringToString, ValueTypeMapStringToStrings},
+	},
+	"XPathAction": map[string][]string{
+		XPathActionInputHTMLBytes: []string{ValueTypeBytes},
+		XPathActionInputHTMLStr:   []string{ValueTypeString},
+	},
+	"TaskPromiseAction": map[string][]string{
+		TaskPromiseActionInputRefrain: []string{ValueTypeBool},
+	},
+	"UTF8DecodeAction": map[string][]string{
+		UTF8DecodeActionInputBytes: []string{ValueTypeBytes},
+	},
+	"UTF8EncodeAction": map[string][]string{
+		UTF8EncodeActionInputStr: []string{ValueTypeString},
+	},
+	"URLJoinAction": map[string][]string{
+		URLJoinActionInputBaseURL:     []string{ValueTypeString},
+		URLJoinActionInputRelativeURL: []string{ValueTypeString},
+	},
+	"StringMapUpdateAction": map[string][]string{
+		StringMapUpdateActionInputNew:            []string{ValueTypeMapStringToString},
+		StringMapUpdateActionInputOld:            []string{ValueTypeMapStringToString},
+		StringMapUpdateActionInputOverridenValue: []string{ValueTypeString},
+	},
+	"URLParseAction": map[string][]string{
+		URLParseActionInputURL: []string{ValueTypeString},
+	},
+	"StringCutAction": map[string][]string{
+		StringCutActionInputStr: []string{ValueTypeString, ValueTypeStrings},
+	},
+	"JSONPathAction": map[string][]string{
+		JSONPathActionInputJSONBytes: []string{ValueTypeBytes},
+		JSONPathActionInputJSONStr:   []string{ValueTypeString},
+	},
+	"LengthThresholdAction": map[string][]string{
+		LengthThresholdActionInputSlice: []string{ValueTypeStrings, ValueTypeBytes, ValueTypeList,
+			ValueTypeMap, ValueTypeMapStringToString, ValueTypeMapStringToStrings, ValueTypeHTTPHeaders},
+	},
+}
+
+// OutputValueTypeTable tells which ValueTypes each action output may send out. Output
+// of ConstAction is not listed, as it has type of the constant given in the template.
+var OutputValueTypeTable = map[string]map[string][]string{
+	"CSVParseAction": map[string][]string{
+		CSVParseActionOutputMap: []string{ValueTypeMapStringToStrings},
+	},
+	"FormExtractionAction": map[string][]string{
+		FormExtractionActionOutputFormData: []string{ValueTypeMapStringToString},
+	},
+	"HTTPAction": map[string][]string{
+		HTTPActionOutputBody:        []string{ValueTypeBytes},
+		HTTPActionOutputCookies:     []string{ValueTypeMapStringToString},
+		HTTPActionOutputHeaders:     []string{ValueTypeHTTPHeaders},
+		HTTPActionOutputResponseURL: []string{ValueTypeString},
+		HTTPActionOutputStatusCode:  []string{ValueTypeInt},
+	},
+	"XPathAction": map[string][]string{
+		XPathActionOutputStr: []string{ValueTypeString, ValueTypeStrings},
+	},
+	"FieldJoinAction": map[string][]string{
+		FieldJoinActionOutputItem: []string{DataChunkTypeItem},
+		FieldJoinActionOutputMap:  []string{ValueTypeMapStringToString},
+	},
+	"TaskPromiseAction": map[string][]string{
+		TaskPromiseActionOutputPromise: []string{DataChunkTypePromise},
+	},
+	"UTF8DecodeAction": map[string][]string{
+		UTF8DecodeActionOutputStr: []string{ValueTypeString},
+	},
+	"UTF8EncodeAction": map[string][]string{
+		UTF8EncodeActionOutputBytes: []string{ValueTypeBytes},
+	},
+	"URLJoinAction": map[string][]string{
+		URLJoinActionOutputAbsoluteURL: []string{ValueTypeString},
+	},
+	"StringMapUpdateAction": map[string][]string{
+		StringMapUpdateActionOutputItem:    []string{DataChunkTypeItem},
+		StringMapUpdateActionOutputUpdated: []string{ValueTypeMapStringToString},
+	},
+	"URLParseAction": map[string][]string{
+		URLParseActionOutputHost:   []string{ValueTypeString},
+		URLParseActionOutputParams: []string{ValueTypeMapStringToStrings},
+		URLParseActionOutputPath:   []string{ValueTypeString},
+		URLParseActionOutputScheme: []string{ValueTypeString},
+	},
+	"StringCutAction": map[string][]string{
+		StringCutActionOutputStr: []string{ValueTypeString, ValueTypeStrings},
+	},
+	"JSONPathAction": map[string][]string{
+		JSONPathActionOutputStr: []string{ValueTypeString, ValueTypeStrings, ValueTypeInt, ValueTypeFloat,
+			ValueTypeBool, ValueTypeNull, ValueTypeList, ValueTypeMap},
+	},
+	"LengthThresholdAction": map[string][]string{
+		LengthThresholdActionOutputThresholdUnmet: []string{ValueTypeBool},
+	},
+}
+
 func RegisterAction(structName string, initFunc InitFunc, allowedInputNames []string, allowedOutputNames []string) {
 	ActionConstructorTable[structName] = initFunc
 	AllowedInputNameTable[structName] = allowedInputNames
 	AllowedOutputNameTable[structName] = allowedOutputNames
+
+	delete(InputValueTypeTable, structName)
+	delete(OutputValueTypeTable, structName)
+	delete(ActionParamsTable, structName)
 }
 
-func NewActionFromTemplate(actionTempl *ActionTemplate) Action {
+// RegisterTypedAction is RegisterAction for action that declares ValueTypes of its inputs
+// and outputs (see InputValueTypeTable), so that Workflow.Validate can check what is
+// connected to them. Allowed input and output names are the keys of given maps.
+func RegisterTypedAction(structName string, initFunc InitFunc, inputValueTypes map[string][]string,
+	outputValueTypes map[string][]string) {
+	allowedInputNames := []string{}
+
+	for name := range inputValueTypes {
+		allowedInputNames = append(allowedInputNames, name)
+	}
+
+	allowedOutputNames := []string{}
+
+	for name := range outputValueTypes {
+		allowedOutputNames = append(allowedOutputNames, name)
+	}
+
+	sort.Strings(allowedInputNames)
+	sort.Strings(allowedOutputNames)
+
+	RegisterAction(structName, initFunc, allowedInputNames, allowedOutputNames)
+
+	InputValueTypeTable[structName] = inputValueTypes
+	OutputValueTypeTable[structName] = outputValueTypes
+}
+
+func NewActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
 	initFunc := ActionConstructorTable[actionTempl.StructName]
-	if initFunc != nil {
-		return initFunc(actionTempl)
+	if initFunc == nil {
+		return nil, fmt.Errorf("No entry found in ActionConstructorTable for struct name %s", actionTempl.StructName)
 	}
 
-	return nil
+	action, err := initFunc(actionTempl)
+	if err != nil {
+		return nil, fmt.Errorf("Action %s: %v", actionTempl.Name, err)
+	}
+
+	return action, nil
 }
 
 // AddInput adds input data pipe of given name to Inputs map iff name is in AllowedInputNames.
@@ -216,7 +374,7 @@ func (a *AbstractAction) GetPrecedingActions() []Action {
 
 }
 
-func (a *AbstractAction) Run() error {
+func (a *AbstractAction) Run(ctx context.Context) error {
 	// To be implemented by concrete actions.
 	return nil
 }
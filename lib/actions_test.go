@@ -9,7 +9,10 @@ import (
 
 func TestNewActionFromTemplate(t *testing.T) {
 	actionTempl1 := &ActionTemplate{Name: "HTTPAction", StructName: "HTTPAction"}
-	action1, ok1 := NewActionFromTemplate(actionTempl1).(*HTTPAction)
+	gotAction1, err1 := NewActionFromTemplate(actionTempl1)
+	assert.Nil(t, err1)
+
+	action1, ok1 := gotAction1.(*HTTPAction)
 
 	assert.True(t, ok1)
 	assert.NotNil(t, action1)
@@ -29,14 +32,23 @@ func TestNewActionFromTemplate(t *testing.T) {
 			},
 		},
 	}
-	action2, ok2 := NewActionFromTemplate(actionTempl2).(*XPathAction)
+	gotAction2, err2 := NewActionFromTemplate(actionTempl2)
+	assert.Nil(t, err2)
+
+	action2, ok2 := gotAction2.(*XPathAction)
 
 	assert.True(t, ok2)
 	assert.NotNil(t, action2)
 	assert.Equal(t, actionTempl2.Name, action2.Name)
 
-	actionTempl3 := &ActionTemplate{Name: "FieldJoinAction", StructName: "FieldJoinAction"}
-	action3, ok3 := NewActionFromTemplate(actionTempl3).(*FieldJoinAction)
+	actionTempl3 := NewActionTemplate("FieldJoinAction", "FieldJoinAction", map[string]interface{}{
+		"inputNames": []string{"name"},
+		"itemName":   "person",
+	})
+	gotAction3, err3 := NewActionFromTemplate(actionTempl3)
+	assert.Nil(t, err3)
+
+	action3, ok3 := gotAction3.(*FieldJoinAction)
 
 	assert.True(t, ok3)
 	assert.NotNil(t, action3)
@@ -50,9 +62,16 @@ func TestNewActionFromTemplate(t *testing.T) {
 				ValueType:    ValueTypeStrings,
 				StringsValue: []string{"page", "query"},
 			},
+			"taskName": Value{
+				ValueType:   ValueTypeString,
+				StringValue: "ScrapeListPage",
+			},
 		},
 	}
-	action4, ok4 := NewActionFromTemplate(actionTempl4).(*TaskPromiseAction)
+	gotAction4, err4 := NewActionFromTemplate(actionTempl4)
+	assert.Nil(t, err4)
+
+	action4, ok4 := gotAction4.(*TaskPromiseAction)
 
 	assert.True(t, ok4)
 	assert.NotNil(t, action4)
@@ -60,42 +79,63 @@ func TestNewActionFromTemplate(t *testing.T) {
 	assert.Equal(t, []string{"page", "query", TaskPromiseActionInputRefrain}, action4.AllowedInputNames)
 
 	actionTempl5 := &ActionTemplate{Name: "UTF8DecodeAction", StructName: "UTF8DecodeAction"}
-	action5, ok5 := NewActionFromTemplate(actionTempl5).(*UTF8DecodeAction)
+	gotAction5, err5 := NewActionFromTemplate(actionTempl5)
+	assert.Nil(t, err5)
+
+	action5, ok5 := gotAction5.(*UTF8DecodeAction)
 
 	assert.True(t, ok5)
 	assert.NotNil(t, action5)
 	assert.Equal(t, actionTempl5.Name, action5.Name)
 
 	actionTempl6 := &ActionTemplate{Name: "UTF8EncodeAction", StructName: "UTF8EncodeAction"}
-	action6, ok6 := NewActionFromTemplate(actionTempl6).(*UTF8EncodeAction)
+	gotAction6, err6 := NewActionFromTemplate(actionTempl6)
+	assert.Nil(t, err6)
+
+	action6, ok6 := gotAction6.(*UTF8EncodeAction)
 
 	assert.True(t, ok6)
 	assert.NotNil(t, action6)
 	assert.Equal(t, actionTempl6.Name, action6.Name)
 
-	actionTempl7 := &ActionTemplate{Name: "ConstAction", StructName: "ConstAction"}
-	action7, ok7 := NewActionFromTemplate(actionTempl7).(*ConstAction)
+	actionTempl7 := NewActionTemplate("ConstAction", "ConstAction", map[string]interface{}{"c": 42})
+	gotAction7, err7 := NewActionFromTemplate(actionTempl7)
+	assert.Nil(t, err7)
+
+	action7, ok7 := gotAction7.(*ConstAction)
 
 	assert.True(t, ok7)
 	assert.NotNil(t, action7)
 	assert.Equal(t, actionTempl7.Name, action7.Name)
 
 	actionTempl8 := &ActionTemplate{Name: "URLJoinAction", StructName: "URLJoinAction"}
-	action8, ok8 := NewActionFromTemplate(actionTempl8).(*URLJoinAction)
+	gotAction8, err8 := NewActionFromTemplate(actionTempl8)
+	assert.Nil(t, err8)
+
+	action8, ok8 := gotAction8.(*URLJoinAction)
 
 	assert.True(t, ok8)
 	assert.NotNil(t, action8)
 	assert.Equal(t, actionTempl8.Name, action8.Name)
 
-	actionTempl9 := &ActionTemplate{Name: "StringCutAction", StructName: "StringCutAction"}
-	action9, ok9 := NewActionFromTemplate(actionTempl9).(*StringCutAction)
+	actionTempl9 := NewActionTemplate("StringCutAction", "StringCutAction", map[string]interface{}{
+		"from": "<b>",
+		"to":   "</b>",
+	})
+	gotAction9, err9 := NewActionFromTemplate(actionTempl9)
+	assert.Nil(t, err9)
+
+	action9, ok9 := gotAction9.(*StringCutAction)
 
 	assert.True(t, ok9)
 	assert.NotNil(t, action9)
 	assert.Equal(t, actionTempl9.Name, action9.Name)
 
 	actionTempl10 := &ActionTemplate{Name: "StringMapUpdateAction", StructName: "StringMapUpdateAction"}
-	action10, ok10 := NewActionFromTemplate(actionTempl10).(*StringMapUpdateAction)
+	gotAction10, err10 := NewActionFromTemplate(actionTempl10)
+	assert.Nil(t, err10)
+
+	action10, ok10 := gotAction10.(*StringMapUpdateAction)
 
 	assert.True(t, ok10)
 	assert.NotNil(t, action10)
@@ -112,8 +152,8 @@ func TestInputTableSortedness(t *testing.T) {
 	}
 }
 
-func NewTestAction(*ActionTemplate) Action {
-	return &AbstractAction{}
+func NewTestAction(*ActionTemplate) (Action, error) {
+	return &AbstractAction{}, nil
 }
 
 func TestRegisterAction(t *testing.T) {
@@ -127,3 +167,51 @@ func TestRegisterAction(t *testing.T) {
 	assert.Equal(t, allowedInputNames, AllowedInputNameTable[structName])
 	assert.Equal(t, allowedOutputNames, AllowedOutputNameTable[structName])
 }
+
+func TestValueTypeTables(t *testing.T) {
+	for structName, inputValueTypes := range InputValueTypeTable {
+		for inputName, valueTypes := range inputValueTypes {
+			assert.True(t, stringIsInSlice(inputName, AllowedInputNameTable[structName]))
+			assert.NotEmpty(t, valueTypes)
+		}
+	}
+
+	for _, outputValueTypes := range OutputValueTypeTable {
+		for _, valueTypes := range outputValueTypes {
+			assert.NotEmpty(t, valueTypes)
+		}
+	}
+}
+
+func TestRegisterTypedAction(t *testing.T) {
+	structName := "TestTypedAction"
+	inputValueTypes := map[string][]string{
+		"in2": []string{ValueTypeString},
+		"in1": []string{ValueTypeBytes, ValueTypeString},
+	}
+	outputValueTypes := map[string][]string{
+		"out1": []string{ValueTypeInt},
+	}
+
+	RegisterTypedAction(structName, NewTestAction, inputValueTypes, outputValueTypes)
+
+	defer func() {
+		delete(ActionConstructorTable, structName)
+		delete(AllowedInputNameTable, structName)
+		delete(AllowedOutputNameTable, structName)
+		delete(InputValueTypeTable, structName)
+		delete(OutputValueTypeTable, structName)
+	}()
+
+	assert.NotNil(t, ActionConstructorTable[structName])
+	assert.Equal(t, []string{"in1", "in2"}, AllowedInputNameTable[structName])
+	assert.Equal(t, []string{"out1"}, AllowedOutputNameTable[structName])
+	assert.Equal(t, inputValueTypes, InputValueTypeTable[structName])
+	assert.Equal(t, outputValueTypes, OutputValueTypeTable[structName])
+
+	// Registering action again without types drops them.
+	RegisterAction(structName, NewTestAction, []string{"in1"}, []string{"out1"})
+
+	assert.Nil(t, InputValueTypeTable[structName])
+	assert.Nil(t, OutputValueTypeTable[structName])
+}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -29,21 +30,26 @@ func NewConstAction(c *Value) *ConstAction {
 	}
 }
 
-func NewConstActionFromTemplate(actionTempl *ActionTemplate) Action {
-	c := actionTempl.ConstructorParams["c"]
+func NewConstActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("ConstAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
+	c := params["c"]
 
 	action := NewConstAction(&c)
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (ca *ConstAction) String() string {
 	return fmt.Sprintf("<ConstAction %s C: %v>", ca.UUID, ca.C)
 }
 
-func (ca *ConstAction) Run() error {
+func (ca *ConstAction) Run(ctx context.Context) error {
 	if ca.Outputs[ConstActionOutput] == nil {
 		return errors.New("Output not connected")
 	}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -17,7 +18,7 @@ func TestConstActionRun(t *testing.T) {
 	err := action.AddOutput(ConstActionOutput, dataOut)
 	assert.Nil(t, err)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotC, ok := dataOut.Remove().(string)
@@ -30,7 +31,7 @@ func TestConstActionRunErr(t *testing.T) {
 
 	action := NewConstAction(&Value{ValueType: ValueTypeString, StringValue: c})
 
-	err := action.Run()
+	err := action.Run(context.Background())
 	assert.NotNil(t, err) // fails because output is not connected.
 	assert.Equal(t, errors.New("Output not connected"), err)
 }
@@ -2,10 +2,13 @@ package spsw
 
 import (
 	"encoding/csv"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -21,6 +24,17 @@ type CSVExporterBackend struct {
 	csvWritersByJob  map[string]*csv.Writer
 	fileHandlesByJob map[string]*os.File
 	fieldNamesByJob  map[string][]string
+	nItemsByJob      map[string]int
+}
+
+// CSVExportManifest is written next to CSV file once job is finished, so that whoever
+// consumes the export can tell it is complete.
+type CSVExportManifest struct {
+	JobUUID     string
+	CSVFileName string
+	FieldNames  []string
+	NItems      int
+	FinishedAt  time.Time
 }
 
 func NewCSVExporterBackend(outputDirPath string) *CSVExporterBackend {
@@ -33,6 +47,7 @@ func NewCSVExporterBackend(outputDirPath string) *CSVExporterBackend {
 		csvWritersByJob:  map[string]*csv.Writer{},
 		fileHandlesByJob: map[string]*os.File{},
 		fieldNamesByJob:  map[string][]string{},
+		nItemsByJob:      map[string]int{},
 	}
 }
 
@@ -40,9 +55,17 @@ func (ceb *CSVExporterBackend) String() string {
 	return fmt.Sprintf("<CSVExporterBackend OutputDirPath: %s>", ceb.OutputDirPath)
 }
 
-func (ceb *CSVExporterBackend) StartExporting(jobUUID string, fieldNames []string) (*csv.Writer, error) {
+func (ceb *CSVExporterBackend) csvFileName(jobUUID string) string {
 	// XXX: maybe include date/time into filename as well?
-	csvFilePath := ceb.OutputDirPath + "/" + jobUUID + ".csv"
+	return jobUUID + ".csv"
+}
+
+func (ceb *CSVExporterBackend) manifestFilePath(jobUUID string) string {
+	return ceb.OutputDirPath + "/" + jobUUID + ".manifest.json"
+}
+
+func (ceb *CSVExporterBackend) StartExporting(jobUUID string, fieldNames []string) (*csv.Writer, error) {
+	csvFilePath := ceb.OutputDirPath + "/" + ceb.csvFileName(jobUUID)
 
 	csvFileHandle, err := os.OpenFile(csvFilePath, os.O_RDWR|os.O_CREATE, 0755)
 	if err != nil {
@@ -105,6 +128,18 @@ func (ceb *CSVExporterBackend) WriteItem(i *Item) error {
 			} else {
 				rowStr = "false"
 			}
+		} else if value.ValueType == ValueTypeFloat {
+			rowStr = strconv.FormatFloat(value.FloatValue, 'f', -1, 64)
+		} else if value.ValueType == ValueTypeTime {
+			rowStr = value.TimeValue.Format(time.RFC3339Nano)
+		} else if value.ValueType == ValueTypeList || value.ValueType == ValueTypeMap {
+			// Nested values don't fit into a CSV cell, so they are written out as JSON.
+			raw, err := json.Marshal(value.GetUnderlyingValue())
+			if err != nil {
+				return err
+			}
+
+			rowStr = string(raw)
 		}
 
 		row = append(row, rowStr)
@@ -115,23 +150,89 @@ func (ceb *CSVExporterBackend) WriteItem(i *Item) error {
 		return err
 	}
 
+	ceb.nItemsByJob[jobUUID]++
+
 	return nil
 }
 
+func (ceb *CSVExporterBackend) writeManifest(manifest *CSVExportManifest) error {
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	manifestFilePath := ceb.manifestFilePath(manifest.JobUUID)
+	tmpFilePath := manifestFilePath + ".tmp"
+
+	err = ioutil.WriteFile(tmpFilePath, raw, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilePath, manifestFilePath)
+}
+
+// FinishExporting closes CSV file of the job and writes manifest for it. Jobs that did not
+// produce any items get manifest without CSV file.
 func (ceb *CSVExporterBackend) FinishExporting(jobUUID string) error {
+	manifest := &CSVExportManifest{
+		JobUUID:    jobUUID,
+		FieldNames: ceb.fieldNamesByJob[jobUUID],
+		NItems:     ceb.nItemsByJob[jobUUID],
+		FinishedAt: time.Now(),
+	}
+
 	fileHandle := ceb.fileHandlesByJob[jobUUID]
-	if fileHandle == nil {
-		return errors.New("File handle not found in FinishExporting")
+	if fileHandle != nil {
+		err := fileHandle.Sync()
+		if err != nil {
+			log.Error(fmt.Sprintf("Syncing CSV file failed with error: %v", err))
+		}
+
+		err = fileHandle.Close()
+		if err != nil {
+			return err
+		}
+
+		manifest.CSVFileName = ceb.csvFileName(jobUUID)
 	}
 
-	fileHandle.Sync()
-	fileHandle.Close()
+	delete(ceb.csvWritersByJob, jobUUID)
+	delete(ceb.fileHandlesByJob, jobUUID)
+	delete(ceb.fieldNamesByJob, jobUUID)
+	delete(ceb.nItemsByJob, jobUUID)
 
-	ceb.csvWritersByJob[jobUUID] = nil
-	ceb.fileHandlesByJob[jobUUID] = nil
-	ceb.fieldNamesByJob[jobUUID] = nil
+	err := ceb.writeManifest(manifest)
+	if err != nil {
+		return err
+	}
 
-	log.Info(fmt.Sprintf("Finished exporting items for job %s", jobUUID))
+	log.Info(fmt.Sprintf("Finished exporting %d items for job %s", manifest.NItems, jobUUID))
 
 	return nil
 }
+
+// Flush writes out buffered rows of all the jobs that are being exported and syncs their
+// CSV files. Files are kept open, so that export can go on.
+func (ceb *CSVExporterBackend) Flush() error {
+	var firstErr error
+
+	for jobUUID, csvWriter := range ceb.csvWritersByJob {
+		csvWriter.Flush()
+
+		err := csvWriter.Error()
+		if err == nil {
+			err = ceb.fileHandlesByJob[jobUUID].Sync()
+		}
+
+		if err != nil {
+			log.Error(fmt.Sprintf("Flushing CSV file of job %s failed with error: %v", jobUUID, err))
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
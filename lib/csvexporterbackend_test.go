@@ -1,9 +1,11 @@
 package spsw
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -60,4 +62,99 @@ func TestCSVExporterBackendE2E(t *testing.T) {
 	expectedCsvStr := "name,phone\nFaust,555-1212\nMephistopheles,666-0000\n"
 
 	assert.Equal(t, expectedCsvStr, csvStr)
+
+	raw, err := ioutil.ReadFile(dir + "/" + jobUUID + ".manifest.json")
+	assert.Nil(t, err)
+
+	manifest := &CSVExportManifest{}
+	assert.Nil(t, json.Unmarshal(raw, manifest))
+	assert.Equal(t, jobUUID, manifest.JobUUID)
+	assert.Equal(t, jobUUID+".csv", manifest.CSVFileName)
+	assert.Equal(t, fieldNames, manifest.FieldNames)
+	assert.Equal(t, 2, manifest.NItems)
+}
+
+func TestCSVExporterBackendFinishExportingWithoutItems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	jobUUID := "8C3D1A2B-5E4F-4A6B-9C8D-7E6F5A4B3C2D"
+
+	backend := NewCSVExporterBackend(dir)
+
+	assert.Nil(t, backend.FinishExporting(jobUUID))
+
+	raw, err := ioutil.ReadFile(dir + "/" + jobUUID + ".manifest.json")
+	assert.Nil(t, err)
+
+	manifest := &CSVExportManifest{}
+	assert.Nil(t, json.Unmarshal(raw, manifest))
+	assert.Equal(t, "", manifest.CSVFileName)
+	assert.Equal(t, 0, manifest.NItems)
+}
+
+func TestCSVExporterBackendFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	jobUUID := "1F2E3D4C-5B6A-4798-8A9B-0C1D2E3F4A5B"
+
+	backend := NewCSVExporterBackend(dir)
+
+	assert.Nil(t, backend.Flush())
+
+	item := NewItem("person", "testWorkflow", jobUUID, "")
+	item.SetField("name", "Faust")
+
+	assert.Nil(t, backend.WriteItem(item))
+	assert.Nil(t, backend.Flush())
+
+	raw, err := ioutil.ReadFile(dir + "/" + jobUUID + ".csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "name\nFaust\n", string(raw))
+
+	// Export goes on after flushing.
+	assert.Nil(t, backend.WriteItem(item))
+	assert.Nil(t, backend.FinishExporting(jobUUID))
+
+	raw, err = ioutil.ReadFile(dir + "/" + jobUUID + ".csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "name\nFaust\nFaust\n", string(raw))
+}
+
+func TestCSVExporterBackendWriteItemValueTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	jobUUID := "0A9B8C7D-6E5F-4A3B-8C1D-2E3F4A5B6C7D"
+	fieldNames := []string{"price", "discount", "scrapedAt", "sizes", "stock"}
+
+	backend := NewCSVExporterBackend(dir)
+
+	_, err = backend.StartExporting(jobUUID, fieldNames)
+	assert.Nil(t, err)
+
+	item := NewItem("product", "", jobUUID, "")
+	item.SetField("price", 19.5)
+	item.Fields["discount"] = NewNullValue()
+	item.SetField("scrapedAt", time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC))
+	item.SetField("sizes", []interface{}{"S", "M"})
+	item.SetField("stock", map[string]interface{}{"S": 3})
+
+	assert.Nil(t, backend.WriteItem(item))
+	assert.Nil(t, backend.FinishExporting(jobUUID))
+
+	raw, err := ioutil.ReadFile(dir + "/" + jobUUID + ".csv")
+	assert.Nil(t, err)
+
+	expectedCsvStr := "price,discount,scrapedAt,sizes,stock\n" +
+		"19.5,,2020-09-13T12:26:40Z,\"[\"\"S\"\",\"\"M\"\"]\",\"{\"\"S\"\":3}\"\n"
+
+	assert.Equal(t, expectedCsvStr, string(raw))
 }
@@ -2,6 +2,7 @@ package spsw
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
@@ -32,19 +33,24 @@ func NewCSVParseAction() *CSVParseAction {
 	}
 }
 
-func NewCSVParseActionFromTemplate(actionTempl *ActionTemplate) Action {
+func NewCSVParseActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	_, err := constructorParamsWithDefaults("CSVParseAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
 	action := NewCSVParseAction()
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (cpa *CSVParseAction) String() string {
 	return fmt.Sprintf("<CSVParseAction %s Name: %s>", cpa.UUID, cpa.Name)
 }
 
-func (cpa *CSVParseAction) Run() error {
+func (cpa *CSVParseAction) Run(ctx context.Context) error {
 	if cpa.Inputs[CSVParseActionInputCSVBytes] == nil && cpa.Inputs[CSVParseActionInputCSVStr] == nil {
 		return errors.New("No input connected")
 	}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -24,9 +25,15 @@ func TestNewCSVParseActionFromTemplate(t *testing.T) {
 		ConstructorParams: map[string]Value{},
 	}
 
-	action := NewCSVParseActionFromTemplate(actionTempl)
-
+	action, err := NewCSVParseActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
 	assert.NotNil(t, action)
+
+	actionTempl.ConstructorParams["delimiter"] = Value{ValueType: ValueTypeString, StringValue: ";"}
+
+	action, err = NewCSVParseActionFromTemplate(actionTempl)
+	assert.Nil(t, action)
+	assert.NotNil(t, err)
 }
 
 func TestCSVParseActionRun(t *testing.T) {
@@ -48,7 +55,7 @@ func TestCSVParseActionRun(t *testing.T) {
 	action.AddInput(CSVParseActionInputCSVStr, inDP)
 	action.AddOutput(CSVParseActionOutputMap, outDP)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 
 	assert.Nil(t, err)
 	gotMap, ok := outDP.Remove().(map[string][]string)
@@ -72,7 +79,7 @@ func TestCSVParseActionRunError(t *testing.T) {
 	action.AddInput(CSVParseActionInputCSVBytes, inDP)
 	action.AddOutput(CSVParseActionOutputMap, outDP)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 
 	fmt.Println(err)
 
@@ -83,6 +83,14 @@ func NewDataChunk(payload interface{}) (*DataChunk, error) {
 		return NewDataChunkWithType(DataChunkTypeValue, payload), nil
 	}
 
+	// Remaining types (floats, times, generic lists and maps) are only supported as Values.
+	// Bare nil is not accepted, as null has to be sent explicitly with NewNullValue().
+	if payload != nil {
+		if value := NewValue(payload); value != nil {
+			return NewDataChunkWithType(DataChunkTypeValue, value), nil
+		}
+	}
+
 	return nil, errors.New("Unsupported payload type")
 }
 
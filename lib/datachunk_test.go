@@ -74,6 +74,34 @@ func TestNewDataChunkTaskPromise(t *testing.T) {
 	assert.Equal(t, DataChunkTypePromise, chunk.Type)
 }
 
+func TestNewDataChunkFloat(t *testing.T) {
+	chunk, err := NewDataChunk(4.2)
+	assert.Nil(t, err)
+	assert.NotNil(t, chunk)
+	assert.Equal(t, NewValueFromFloat(4.2), chunk.PayloadValue)
+	assert.Equal(t, DataChunkTypeValue, chunk.Type)
+}
+
+func TestNewDataChunkMap(t *testing.T) {
+	m := map[string]interface{}{"price": 4.2, "tags": []interface{}{"new"}}
+
+	chunk, err := NewDataChunk(m)
+	assert.Nil(t, err)
+	assert.NotNil(t, chunk)
+	assert.Equal(t, ValueTypeMap, chunk.PayloadValue.ValueType)
+	assert.Equal(t, m, chunk.PayloadValue.GetUnderlyingValue())
+}
+
+func TestNewDataChunkNil(t *testing.T) {
+	chunk, err := NewDataChunk(nil)
+	assert.Nil(t, chunk)
+	assert.NotNil(t, err)
+
+	chunk, err = NewDataChunk(NewNullValue())
+	assert.Nil(t, err)
+	assert.Equal(t, ValueTypeNull, chunk.PayloadValue.ValueType)
+}
+
 func TestNewDataChunkFail(t *testing.T) {
 	err := errors.New("Unsupported payload type")
 
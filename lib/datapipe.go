@@ -1,22 +1,38 @@
 package spsw
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
+var ErrDataPipeDone = errors.New("Data pipe is done")
+
+// DataPipe passes data chunks from one action to another in FIFO order. Once the action
+// feeding the pipe is done with it, pipe is closed and Done is set. Streamed is set if the
+// pipe carries any number of chunks (possibly none) that action downstream should run on one
+// by one. Queue is guarded by mutex, as actions of a task may run concurrently. It must not
+// be touched directly while task is running.
 type DataPipe struct {
 	Done       bool
+	Streamed   bool
 	Queue      []*DataChunk
 	FromAction Action
 	ToAction   Action
 	UUID       string
+
+	mutex sync.Mutex
 }
 
 func NewDataPipe() *DataPipe {
-	return &DataPipe{false, []*DataChunk{}, nil, nil, uuid.New().String()}
+	return &DataPipe{
+		Done:  false,
+		Queue: []*DataChunk{},
+		UUID:  uuid.New().String(),
+	}
 }
 
 func NewDataPipeBetweenActions(fromAction Action, toAction Action) *DataPipe {
@@ -44,42 +60,125 @@ func (dp *DataPipe) String() string {
 		fromActionName, toActionName)
 }
 
+func (dp *DataPipe) addChunk(chunk *DataChunk) error {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	if dp.Done {
+		return ErrDataPipeDone
+	}
+
+	dp.Queue = append(dp.Queue, chunk)
+
+	return nil
+}
+
+// requeueChunk puts chunk back into the pipe even if it's done, so that it's handed to
+// action again on its next run.
+func (dp *DataPipe) requeueChunk(chunk *DataChunk) {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	dp.Queue = append(dp.Queue, chunk)
+}
+
+// peekChunk returns the chunk that is to be removed next, without removing it.
+func (dp *DataPipe) peekChunk() *DataChunk {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	if len(dp.Queue) == 0 {
+		return nil
+	}
+
+	return dp.Queue[0]
+}
+
 func (dp *DataPipe) Add(x interface{}) error {
 	if chunk, err := NewDataChunk(x); err == nil {
 		log.Debug(fmt.Sprintf("Adding chunk %v to data pipe %v", chunk, dp))
-		dp.Queue = append(dp.Queue, chunk)
+		return dp.addChunk(chunk)
 	} else {
 		return err
 	}
-
-	return nil
 }
 
 func (dp *DataPipe) AddItem(item *Item) error {
 	chunk := NewDataChunkWithType(DataChunkTypeItem, item)
 
-	dp.Queue = append(dp.Queue, chunk)
+	return dp.addChunk(chunk)
+}
 
-	return nil
+// Close tells that no more chunks will be added to the pipe. Chunks that are already in
+// it can still be removed.
+func (dp *DataPipe) Close() {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	dp.Done = true
+}
+
+// IsDone tells if the pipe is closed. Together with Len, it tells if there's anything more
+// to expect from the pipe.
+func (dp *DataPipe) IsDone() bool {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	return dp.Done
+}
+
+// MarkStreamed tells that chunks are streamed through the pipe, so that action downstream is
+// not run at all if none of them come through.
+func (dp *DataPipe) MarkStreamed() {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	dp.Streamed = true
+}
+
+// IsStreamed tells if MarkStreamed was called on the pipe.
+func (dp *DataPipe) IsStreamed() bool {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	return dp.Streamed
 }
 
+// Len returns number of chunks waiting in the pipe.
+func (dp *DataPipe) Len() int {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	return len(dp.Queue)
+}
+
+// Remove takes the oldest chunk off the pipe and returns its payload, or nil if pipe is
+// empty. Null value is returned as *Value, so that it can be told apart from empty pipe.
 func (dp *DataPipe) Remove() interface{} {
+	dp.mutex.Lock()
+
 	if len(dp.Queue) == 0 {
+		dp.mutex.Unlock()
 		return nil
 	}
 
-	lastIdx := len(dp.Queue) - 1
-	lastChunk := dp.Queue[lastIdx]
-	dp.Queue = dp.Queue[:lastIdx]
+	firstChunk := dp.Queue[0]
+	dp.Queue = dp.Queue[1:]
+
+	dp.mutex.Unlock()
+
+	log.Debug(fmt.Sprintf("Removing chunk %v from data pipe %v", firstChunk, dp))
 
-	log.Debug(fmt.Sprintf("Removing chunk %v from data pipe %v", lastChunk, dp))
+	if firstChunk.Type == DataChunkTypeItem {
+		return firstChunk.PayloadItem
+	} else if firstChunk.Type == DataChunkTypePromise {
+		return firstChunk.PayloadPromise
+	} else if firstChunk.Type == DataChunkTypeValue {
+		value := firstChunk.PayloadValue
+		if value.ValueType == ValueTypeNull {
+			return value
+		}
 
-	if lastChunk.Type == DataChunkTypeItem {
-		return lastChunk.PayloadItem
-	} else if lastChunk.Type == DataChunkTypePromise {
-		return lastChunk.PayloadPromise
-	} else if lastChunk.Type == DataChunkTypeValue {
-		value := lastChunk.PayloadValue
 		return value.GetUnderlyingValue()
 	}
 
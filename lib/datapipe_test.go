@@ -2,6 +2,7 @@ package spsw
 
 import (
 	"errors"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -29,3 +30,83 @@ func TestDataPipeAddUnsupported(t *testing.T) {
 	assert.NotNil(t, gotErr)
 	assert.Equal(t, err, gotErr)
 }
+
+func TestDataPipeConcurrentUse(t *testing.T) {
+	dataPipe := NewDataPipe()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				dataPipe.Add("x")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1000, dataPipe.Len())
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				dataPipe.Remove()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 500, dataPipe.Len())
+}
+
+func TestDataPipeFIFO(t *testing.T) {
+	dataPipe := NewDataPipe()
+
+	assert.Nil(t, dataPipe.Add("first"))
+	assert.Nil(t, dataPipe.Add("second"))
+	assert.Nil(t, dataPipe.Add("third"))
+
+	assert.Equal(t, "first", dataPipe.Remove())
+	assert.Equal(t, "second", dataPipe.Remove())
+	assert.Equal(t, "third", dataPipe.Remove())
+	assert.Nil(t, dataPipe.Remove())
+}
+
+func TestDataPipeNull(t *testing.T) {
+	dataPipe := NewDataPipe()
+
+	assert.Nil(t, dataPipe.Add(NewNullValue()))
+	assert.Nil(t, dataPipe.Add("second"))
+
+	// Null is not mistaken for empty pipe.
+	assert.Equal(t, NewNullValue(), dataPipe.Remove())
+	assert.Equal(t, "second", dataPipe.Remove())
+	assert.Nil(t, dataPipe.Remove())
+}
+
+func TestDataPipeClose(t *testing.T) {
+	dataPipe := NewDataPipe()
+
+	assert.Nil(t, dataPipe.Add("first"))
+	assert.False(t, dataPipe.IsDone())
+
+	dataPipe.Close()
+
+	assert.True(t, dataPipe.IsDone())
+	assert.Equal(t, ErrDataPipeDone, dataPipe.Add("second"))
+	assert.Equal(t, ErrDataPipeDone, dataPipe.AddItem(NewItem("item", "", "", "")))
+
+	// What was added before closing can still be taken.
+	assert.Equal(t, 1, dataPipe.Len())
+	assert.Equal(t, "first", dataPipe.Remove())
+}
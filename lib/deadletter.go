@@ -0,0 +1,66 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetter holds a scheduled task that ran out of attempts, along with the
+// last error it failed with, so that it could be inspected and re-driven later.
+type DeadLetter struct {
+	UUID          string
+	ScheduledTask ScheduledTask
+	Error         string
+	CreatedAt     time.Time
+}
+
+func NewDeadLetter(scheduledTask *ScheduledTask, errStr string) *DeadLetter {
+	return &DeadLetter{
+		UUID:          uuid.New().String(),
+		ScheduledTask: *scheduledTask,
+		Error:         errStr,
+		CreatedAt:     time.Now(),
+	}
+}
+
+func NewDeadLetterFromJSON(raw []byte) *DeadLetter {
+	deadLetter := &DeadLetter{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(deadLetter)
+	if err != nil {
+		return nil
+	}
+
+	return deadLetter
+}
+
+func (dl *DeadLetter) String() string {
+	return fmt.Sprintf("<DeadLetter %s ScheduledTask: %v, Error: %s, CreatedAt: %v>", dl.UUID, &dl.ScheduledTask,
+		dl.Error, dl.CreatedAt)
+}
+
+func (dl *DeadLetter) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(dl)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
+
+// NewScheduledTask makes a fresh scheduled task to re-drive the dead one, with
+// attempt counter reset.
+func (dl *DeadLetter) NewScheduledTask() *ScheduledTask {
+	return NewScheduledTask(&dl.ScheduledTask.Promise, &dl.ScheduledTask.Template,
+		dl.ScheduledTask.WorkflowName, dl.ScheduledTask.WorkflowVersion, dl.ScheduledTask.JobUUID)
+}
@@ -0,0 +1,60 @@
+package spsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDeadLetter(t *testing.T) {
+	jobUUID := "0D5F2C3A-8E4B-4F1A-9C6D-7B2E1A3F4C5D"
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", jobUUID)
+
+	deadLetter := NewDeadLetter(scheduledTask, "connection refused")
+
+	assert.NotNil(t, deadLetter)
+	assert.Equal(t, 36, len(deadLetter.UUID))
+	assert.Equal(t, scheduledTask.UUID, deadLetter.ScheduledTask.UUID)
+	assert.Equal(t, "connection refused", deadLetter.Error)
+	assert.False(t, deadLetter.CreatedAt.IsZero())
+}
+
+func TestDeadLetterJSONAndBack(t *testing.T) {
+	jobUUID := "0D5F2C3A-8E4B-4F1A-9C6D-7B2E1A3F4C5D"
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", jobUUID)
+	scheduledTask = scheduledTask.NewAttempt()
+
+	deadLetter := NewDeadLetter(scheduledTask, "connection refused")
+
+	gotDeadLetter := NewDeadLetterFromJSON(deadLetter.EncodeToJSON())
+
+	assert.NotNil(t, gotDeadLetter)
+	assert.Equal(t, deadLetter.UUID, gotDeadLetter.UUID)
+	assert.Equal(t, scheduledTask.UUID, gotDeadLetter.ScheduledTask.UUID)
+	assert.Equal(t, 2, gotDeadLetter.ScheduledTask.Attempt)
+	assert.Equal(t, "connection refused", gotDeadLetter.Error)
+	assert.True(t, deadLetter.CreatedAt.Equal(gotDeadLetter.CreatedAt))
+}
+
+func TestDeadLetterNewScheduledTask(t *testing.T) {
+	jobUUID := "0D5F2C3A-8E4B-4F1A-9C6D-7B2E1A3F4C5D"
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", jobUUID)
+	scheduledTask = scheduledTask.NewAttempt().NewAttempt()
+
+	deadLetter := NewDeadLetter(scheduledTask, "connection refused")
+
+	newScheduledTask := deadLetter.NewScheduledTask()
+
+	assert.NotEqual(t, scheduledTask.UUID, newScheduledTask.UUID)
+	assert.Equal(t, 1, newScheduledTask.Attempt)
+	assert.Equal(t, jobUUID, newScheduledTask.JobUUID)
+	assert.Equal(t, "Task1", newScheduledTask.Template.TaskName)
+	assert.Equal(t, "WF0", newScheduledTask.WorkflowName)
+	assert.Equal(t, "v1", newScheduledTask.WorkflowVersion)
+}
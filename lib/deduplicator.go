@@ -16,6 +16,13 @@ func NewDeduplicator(backendAddr string) *Deduplicator {
 	}
 }
 
+func NewDeduplicatorWithBackend(backend DeduplicatorBackend) *Deduplicator {
+	return &Deduplicator{
+		UUID:    uuid.New().String(),
+		Backend: backend,
+	}
+}
+
 func (d *Deduplicator) IsScheduledTaskDuplicated(scheduledTask *ScheduledTask) bool {
 	return d.Backend.IsScheduledTaskDuplicated(scheduledTask)
 }
@@ -23,3 +30,10 @@ func (d *Deduplicator) IsScheduledTaskDuplicated(scheduledTask *ScheduledTask) b
 func (d *Deduplicator) NoteScheduledTask(scheduledTask *ScheduledTask) error {
 	return d.Backend.NoteScheduledTask(scheduledTask)
 }
+
+// Close releases whatever backend holds on to, e.g. connection to Redis.
+func (d *Deduplicator) Close() {
+	if closer, ok := d.Backend.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
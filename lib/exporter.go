@@ -2,22 +2,53 @@ package spsw
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
+// How long Exporter waits for items still in flight after learning that job is finished.
+const ExporterPendingFinishTimeout = 1 * time.Minute
+const ExporterPendingFinishCheckInterval = 1 * time.Second
+const ExporterJobsExportedBufferSize = 16
+
+type pendingJobFinish struct {
+	jobFinished *JobFinished
+	receivedAt  time.Time
+}
+
+// Exporter passes items to its backends. Once Manager tells that job is finished,
+// Exporter waits for the rest of job's items to arrive and has each backend finalize
+// the export. Finalized jobs are announced on JobsExportedOut, if anyone listens.
+// When told to stop through Done, Exporter flushes its backends, leaving exports of
+// unfinished jobs as they are.
 type Exporter struct {
-	UUID     string
-	Backends []ExporterBackend
-	ItemsIn  chan *Item
+	UUID            string
+	Backends        []ExporterBackend
+	ItemsIn         chan *Item
+	JobsFinishedIn  chan *JobFinished
+	JobsExportedOut chan *JobFinished
+	Done            chan interface{}
+
+	nItemsByJob     map[string]int
+	pendingFinishes map[string]*pendingJobFinish
+	stopOnce        sync.Once
+	stopped         chan struct{}
 }
 
 func NewExporter() *Exporter {
 	return &Exporter{
-		UUID:     uuid.New().String(),
-		Backends: []ExporterBackend{},
-		ItemsIn:  make(chan *Item),
+		UUID:            uuid.New().String(),
+		Backends:        []ExporterBackend{},
+		ItemsIn:         make(chan *Item),
+		JobsFinishedIn:  make(chan *JobFinished),
+		JobsExportedOut: make(chan *JobFinished, ExporterJobsExportedBufferSize),
+		Done:            make(chan interface{}),
+		nItemsByJob:     map[string]int{},
+		pendingFinishes: map[string]*pendingJobFinish{},
+		stopped:         make(chan struct{}),
 	}
 }
 
@@ -25,28 +56,145 @@ func (e *Exporter) String() string {
 	return fmt.Sprintf("<Exporter %s Backends: %v>", e.UUID, e.Backends)
 }
 
+func (e *Exporter) handleItem(item *Item) {
+	// Receive items, pass them to exporter backend(s).
+	log.Info(fmt.Sprintf("Exporter %s got item %v", e.UUID, item))
+
+	for _, i := range item.Splay() {
+		for _, backend := range e.Backends {
+			err := backend.WriteItem(i)
+			if err != nil {
+				log.Error(fmt.Sprintf("WriteItem failed with error: %v", err))
+			}
+		}
+
+		e.nItemsByJob[i.JobUUID]++
+	}
+
+	pending := e.pendingFinishes[item.JobUUID]
+	if pending != nil && e.nItemsByJob[item.JobUUID] >= pending.jobFinished.NItems {
+		e.finishJob(pending.jobFinished)
+	}
+}
+
+func (e *Exporter) handleJobFinished(jobFinished *JobFinished) {
+	log.Info(fmt.Sprintf("Exporter %s got %v", e.UUID, jobFinished))
+
+	if e.nItemsByJob[jobFinished.JobUUID] >= jobFinished.NItems {
+		e.finishJob(jobFinished)
+		return
+	}
+
+	// Items and job finished messages travel separately, so some items may still be on their way.
+	e.pendingFinishes[jobFinished.JobUUID] = &pendingJobFinish{
+		jobFinished: jobFinished,
+		receivedAt:  time.Now(),
+	}
+}
+
+func (e *Exporter) finishJob(jobFinished *JobFinished) {
+	jobUUID := jobFinished.JobUUID
+
+	if e.nItemsByJob[jobUUID] < jobFinished.NItems {
+		log.Warn(fmt.Sprintf("Exporter %s finishing job %s with %d items out of %d", e.UUID, jobUUID,
+			e.nItemsByJob[jobUUID], jobFinished.NItems))
+	}
+
+	for _, backend := range e.Backends {
+		err := backend.FinishExporting(jobUUID)
+		if err != nil {
+			log.Error(fmt.Sprintf("FinishExporting failed with error: %v", err))
+		}
+	}
+
+	delete(e.nItemsByJob, jobUUID)
+	delete(e.pendingFinishes, jobUUID)
+
+	select {
+	case e.JobsExportedOut <- jobFinished:
+	default:
+	}
+}
+
+func (e *Exporter) finishOverdueJobs() {
+	for _, pending := range e.pendingFinishes {
+		if time.Since(pending.receivedAt) >= ExporterPendingFinishTimeout {
+			e.finishJob(pending.jobFinished)
+		}
+	}
+}
+
+func (e *Exporter) flushBackends() {
+	for _, backend := range e.Backends {
+		err := backend.Flush()
+		if err != nil {
+			log.Error(fmt.Sprintf("Flush failed with error: %v", err))
+		}
+	}
+}
+
 func (e *Exporter) Run() error {
 	log.Info(fmt.Sprintf("Starting run loop for exporter %s", e.UUID))
 
-	for item := range e.ItemsIn {
-		if item == nil {
-			continue
-		}
+	defer close(e.stopped)
 
-		// Receive items, pass them to exporter backend(s).
-		log.Info(fmt.Sprintf("Exporter %s got item %v", e.UUID, item))
+	ticker := time.NewTicker(ExporterPendingFinishCheckInterval)
+	defer ticker.Stop()
 
-		for _, i := range item.Splay() {
-			for _, backend := range e.Backends {
-				err := backend.WriteItem(i)
-				if err != nil {
-					log.Error(fmt.Sprintf("WriteItem failed with error: %v", err))
-				}
+	for {
+		select {
+		case item, ok := <-e.ItemsIn:
+			if !ok {
+				return nil
 			}
+
+			if item == nil {
+				continue
+			}
+
+			e.handleItem(item)
+		case jobFinished := <-e.JobsFinishedIn:
+			if jobFinished == nil {
+				continue
+			}
+
+			e.handleJobFinished(jobFinished)
+		case <-ticker.C:
+			e.finishOverdueJobs()
+		case <-e.Done:
+			e.flushBackends()
+			return nil
 		}
 	}
+}
+
+// Stop tells Exporter to stop and waits until its backends are flushed. It must only be
+// called while Run is going.
+func (e *Exporter) Stop() {
+	e.stopOnce.Do(func() {
+		log.Info(fmt.Sprintf("Stopping exporter %s", e.UUID))
+		close(e.Done)
+	})
 
-	return nil
+	<-e.stopped
+}
+
+// WaitForJob blocks until job with given UUID is exported or timeout passes. Returns
+// false on timeout. Only meant for the case where nothing else reads JobsExportedOut.
+func (e *Exporter) WaitForJob(jobUUID string, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case jobFinished := <-e.JobsExportedOut:
+			if jobFinished.JobUUID == jobUUID {
+				return true
+			}
+		case <-timer.C:
+			return false
+		}
+	}
 }
 
 func (e *Exporter) AddBackend(newBackend ExporterBackend) {
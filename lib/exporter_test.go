@@ -1,21 +1,69 @@
 package spsw
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// TestExporterBackend is written to by exporter goroutine, so that test has to read
+// what it got through methods that take the mutex.
 type TestExporterBackend struct {
 	AbstractExporterBackend
-	Items []*Item
+	Items            []*Item
+	FinishedJobUUIDs []string
+	NFlushes         int
+
+	mutex sync.Mutex
+}
+
+func (teb *TestExporterBackend) Flush() error {
+	teb.mutex.Lock()
+	defer teb.mutex.Unlock()
+
+	teb.NFlushes++
+	return nil
 }
 
 func (teb *TestExporterBackend) WriteItem(item *Item) error {
+	teb.mutex.Lock()
+	defer teb.mutex.Unlock()
+
 	teb.Items = append(teb.Items, item)
 	return nil
 }
 
+func (teb *TestExporterBackend) FinishExporting(jobUUID string) error {
+	teb.mutex.Lock()
+	defer teb.mutex.Unlock()
+
+	teb.FinishedJobUUIDs = append(teb.FinishedJobUUIDs, jobUUID)
+	return nil
+}
+
+func (teb *TestExporterBackend) getItems() []*Item {
+	teb.mutex.Lock()
+	defer teb.mutex.Unlock()
+
+	return append([]*Item{}, teb.Items...)
+}
+
+func (teb *TestExporterBackend) getFinishedJobUUIDs() []string {
+	teb.mutex.Lock()
+	defer teb.mutex.Unlock()
+
+	return append([]string{}, teb.FinishedJobUUIDs...)
+}
+
+func (teb *TestExporterBackend) getNFlushes() int {
+	teb.mutex.Lock()
+	defer teb.mutex.Unlock()
+
+	return teb.NFlushes
+}
+
 func TestExporterSimple(t *testing.T) {
 	backend := &TestExporterBackend{
 		Items: []*Item{},
@@ -32,6 +80,59 @@ func TestExporterSimple(t *testing.T) {
 
 	exporter.ItemsIn <- testItem
 
-	assert.Equal(t, 1, len(backend.Items))
-	assert.Equal(t, testItem, backend.Items[0])
+	for i := 0; i < 50 && len(backend.getItems()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	items := backend.getItems()
+	assert.Equal(t, 1, len(items))
+	if len(items) == 1 {
+		assert.Equal(t, testItem, items[0])
+	}
+}
+
+func TestExporterFinishesJobAfterAllItemsArrive(t *testing.T) {
+	backend := &TestExporterBackend{
+		Items: []*Item{},
+	}
+
+	exporter := NewExporter()
+
+	exporter.AddBackend(backend)
+
+	go exporter.Run()
+	defer close(exporter.ItemsIn)
+
+	jobUUID := "4A7E2C1B-3D5F-4E6A-8B9C-0D1E2F3A4B5C"
+
+	exporter.ItemsIn <- NewItem("testItem", "testWorkflow", jobUUID, "")
+
+	// Job finished message overtakes the last item.
+	exporter.JobsFinishedIn <- NewJobFinished(jobUUID, JobStatusFinished, JobStats{}, 2)
+
+	exporter.ItemsIn <- NewItem("testItem", "testWorkflow", jobUUID, "")
+
+	assert.True(t, exporter.WaitForJob(jobUUID, time.Second))
+	assert.Equal(t, 2, len(backend.getItems()))
+	assert.Equal(t, []string{jobUUID}, backend.getFinishedJobUUIDs())
+}
+
+func TestExporterStopFlushesBackends(t *testing.T) {
+	backend := &TestExporterBackend{
+		Items: []*Item{},
+	}
+
+	exporter := NewExporter()
+
+	exporter.AddBackend(backend)
+
+	go exporter.Run()
+
+	exporter.ItemsIn <- NewItem("testItem", "testWorkflow", "4A7E2C1B-3D5F-4E6A-8B9C-0D1E2F3A4B5C", "")
+
+	exporter.Stop()
+
+	assert.Equal(t, 1, len(backend.getItems()))
+	assert.Equal(t, 1, backend.getNFlushes())
+	assert.Equal(t, 0, len(backend.getFinishedJobUUIDs()))
 }
@@ -7,6 +7,8 @@ import (
 type ExporterBackend interface {
 	WriteItem(i *Item) error
 	FinishExporting(jobUUID string) error
+	// Flush makes sure items written so far are on disk, e.g. before the process exits.
+	Flush() error
 }
 
 type AbstractExporterBackend struct {
@@ -24,3 +26,8 @@ func (aeb *AbstractExporterBackend) WriteItem(i *Item) error {
 func (aeb *AbstractExporterBackend) FinishExporting(jobUUID string) error {
 	return errors.New("Not implemented")
 }
+
+// Flush does nothing, as there is nothing to flush unless backend buffers items.
+func (aeb *AbstractExporterBackend) Flush() error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -37,22 +38,24 @@ func NewFieldJoinAction(inputNames []string, jobUUID string, taskUUID string, it
 	}
 }
 
-func NewFieldJoinActionFromTemplate(actionTempl *ActionTemplate) Action {
-	var inputNames []string
-	var itemName string
+func NewFieldJoinActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("FieldJoinAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
 
-	inputNames = actionTempl.ConstructorParams["inputNames"].StringsValue
-	itemName = actionTempl.ConstructorParams["itemName"].StringValue
+	inputNames := params["inputNames"].StringsValue
+	itemName := params["itemName"].StringValue
 
 	action := NewFieldJoinAction(inputNames, "", "", itemName)
 
 	action.Name = actionTempl.Name
 
-	if _, ok := actionTempl.ConstructorParams["requireFields"]; ok {
-		action.RequireFields = actionTempl.ConstructorParams["requireFields"].StringsValue
+	if _, ok := params["requireFields"]; ok {
+		action.RequireFields = params["requireFields"].StringsValue
 	}
 
-	return action
+	return action, nil
 }
 
 func (fja *FieldJoinAction) String() string {
@@ -60,7 +63,7 @@ func (fja *FieldJoinAction) String() string {
 		fja.WorkflowName, fja.ItemName)
 }
 
-func (fja *FieldJoinAction) Run() error {
+func (fja *FieldJoinAction) Run(ctx context.Context) error {
 	if fja.Outputs[FieldJoinActionOutputItem] == nil && fja.Outputs[FieldJoinActionOutputMap] == nil {
 		return errors.New("No output connected")
 	}
@@ -73,7 +76,7 @@ func (fja *FieldJoinAction) Run() error {
 	m := map[string]string{}
 
 	for key, inDP := range fja.Inputs {
-		if len(inDP.Queue) > 0 {
+		if inDP.Len() > 0 {
 			value := inDP.Remove()
 			item.SetField(key, value)
 			s, ok := value.(string)
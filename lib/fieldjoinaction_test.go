@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -25,7 +26,10 @@ func TestNewFieldJoinActionFromTemplate(t *testing.T) {
 		},
 	}
 
-	action, ok := NewFieldJoinActionFromTemplate(actionTempl).(*FieldJoinAction)
+	gotAction, err := NewFieldJoinActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action, ok := gotAction.(*FieldJoinAction)
 	assert.True(t, ok)
 
 	assert.Equal(t, itemName, action.ItemName)
@@ -68,7 +72,7 @@ func TestFieldJoinActionRun(t *testing.T) {
 	err = action.AddOutput(FieldJoinActionOutputMap, mapOut)
 	assert.Nil(t, err)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	expectedItemFields := map[string]*Value{
@@ -103,14 +107,14 @@ func TestFieldJoinActionRunErr(t *testing.T) {
 	action := NewFieldJoinAction([]string{"Name", "Surname", "Phone", "Email"},
 		jobUUID, taskUUID, itemName)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 
 	assert.NotNil(t, err)
 	assert.Equal(t, errors.New("No output connected"), err)
 
 	action.AddOutput(FieldJoinActionOutputItem, NewDataPipe())
 
-	err = action.Run()
+	err = action.Run(context.Background())
 
 	assert.Equal(t, errors.New("No inputs connected"), err)
 }
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"golang.org/x/net/html" // XXX
@@ -42,23 +43,26 @@ func NewFormExtractionAction(formID string) *FormExtractionAction {
 	}
 }
 
-func NewFormExtractionActionFromTemplate(actionTempl *ActionTemplate) Action {
-	var formID string
+func NewFormExtractionActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("FormExtractionAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
 
-	formID = actionTempl.ConstructorParams["formID"].StringValue
+	formID := params["formID"].StringValue
 
 	action := NewFormExtractionAction(formID)
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (fea *FormExtractionAction) String() string {
 	return fmt.Sprintf("<FormExtractionAction %s Name: %s, FormID: %s>", fea.UUID, fea.Name, fea.FormID)
 }
 
-func (fea *FormExtractionAction) Run() error {
+func (fea *FormExtractionAction) Run(ctx context.Context) error {
 	if fea.Inputs[FormExtractionActionInputHTMLStr] == nil && fea.Inputs[FormExtractionActionInputHTMLBytes] == nil {
 		return errors.New("Input not connected")
 	}
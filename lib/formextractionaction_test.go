@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,7 +33,10 @@ func TestNewFormExtractionActionFromTemplate(t *testing.T) {
 		},
 	}
 
-	action := NewFormExtractionActionFromTemplate(actionTempl).(*FormExtractionAction)
+	gotAction, err := NewFormExtractionActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action := gotAction.(*FormExtractionAction)
 
 	assert.NotNil(t, action)
 	assert.Equal(t, "GetForm", action.Name)
@@ -65,7 +69,7 @@ func TestFormExtractionActionRun(t *testing.T) {
 	action.AddInput(FormExtractionActionInputHTMLStr, dataPipeIn)
 	action.AddOutput(FormExtractionActionOutputFormData, dataPipeOut)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 	assert.Nil(t, err)
 
 	formData, ok := dataPipeOut.Remove().(map[string]string)
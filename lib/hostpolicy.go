@@ -0,0 +1,96 @@
+package spsw
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Host policy with this host name applies to all hosts that have no policy of their own.
+const HostPolicyAnyHost = "*"
+
+// HostPolicy tells Manager how gently to treat a single host. At most RequestsPerSecond
+// tasks are sent out for the host per second, with at least CrawlDelay between them, and
+// no more than MaxInFlight tasks are running against it at once. Zero value means no limit.
+type HostPolicy struct {
+	Host              string        `yaml:"Host"`
+	RequestsPerSecond float64       `yaml:"RequestsPerSecond,omitempty"`
+	MaxInFlight       int           `yaml:"MaxInFlight,omitempty"`
+	CrawlDelay        time.Duration `yaml:"CrawlDelay,omitempty"`
+}
+
+func NewHostPolicy(host string, requestsPerSecond float64, maxInFlight int, crawlDelay time.Duration) *HostPolicy {
+	return &HostPolicy{
+		Host:              host,
+		RequestsPerSecond: requestsPerSecond,
+		MaxInFlight:       maxInFlight,
+		CrawlDelay:        crawlDelay,
+	}
+}
+
+func (hp *HostPolicy) String() string {
+	return fmt.Sprintf("<HostPolicy Host: %s, RequestsPerSecond: %v, MaxInFlight: %d, CrawlDelay: %v>", hp.Host,
+		hp.RequestsPerSecond, hp.MaxInFlight, hp.CrawlDelay)
+}
+
+// Matches tells if policy applies to given host. Host names are compared case-insensitively.
+func (hp *HostPolicy) Matches(host string) bool {
+	return hp.Host == HostPolicyAnyHost || strings.EqualFold(hp.Host, host)
+}
+
+// MinInterval returns shortest time allowed between sending out two tasks for the host.
+func (hp *HostPolicy) MinInterval() time.Duration {
+	interval := hp.CrawlDelay
+
+	if hp.RequestsPerSecond > 0 {
+		rateInterval := time.Duration(float64(time.Second) / hp.RequestsPerSecond)
+		if rateInterval > interval {
+			interval = rateInterval
+		}
+	}
+
+	return interval
+}
+
+// findHostPolicy returns policy for given host out of policies, falling back to policy for
+// any host. Tasks of unknown host are not subject to any policy.
+func findHostPolicy(policies []HostPolicy, host string) *HostPolicy {
+	if host == "" {
+		return nil
+	}
+
+	var fallback *HostPolicy
+
+	for i, hp := range policies {
+		if hp.Host == HostPolicyAnyHost {
+			fallback = &policies[i]
+			continue
+		}
+
+		if hp.Matches(host) {
+			return &policies[i]
+		}
+	}
+
+	return fallback
+}
+
+func (hp *HostPolicy) Validate() error {
+	if hp.Host == "" {
+		return fmt.Errorf("Host must not be empty")
+	}
+
+	if hp.RequestsPerSecond < 0 {
+		return fmt.Errorf("RequestsPerSecond must not be negative, got %v", hp.RequestsPerSecond)
+	}
+
+	if hp.MaxInFlight < 0 {
+		return fmt.Errorf("MaxInFlight must not be negative, got %d", hp.MaxInFlight)
+	}
+
+	if hp.CrawlDelay < 0 {
+		return fmt.Errorf("CrawlDelay must not be negative, got %v", hp.CrawlDelay)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestNewHostPolicy(t *testing.T) {
+	hostPolicy := NewHostPolicy("books.toscrape.com", 2.0, 4, time.Second)
+
+	assert.NotNil(t, hostPolicy)
+	assert.Equal(t, "books.toscrape.com", hostPolicy.Host)
+	assert.Equal(t, 2.0, hostPolicy.RequestsPerSecond)
+	assert.Equal(t, 4, hostPolicy.MaxInFlight)
+	assert.Equal(t, time.Second, hostPolicy.CrawlDelay)
+}
+
+func TestHostPolicyMatches(t *testing.T) {
+	hostPolicy := NewHostPolicy("books.toscrape.com", 0, 0, 0)
+
+	assert.True(t, hostPolicy.Matches("books.toscrape.com"))
+	assert.True(t, hostPolicy.Matches("Books.ToScrape.com"))
+	assert.False(t, hostPolicy.Matches("quotes.toscrape.com"))
+
+	anyHostPolicy := NewHostPolicy(HostPolicyAnyHost, 0, 0, 0)
+
+	assert.True(t, anyHostPolicy.Matches("quotes.toscrape.com"))
+}
+
+func TestHostPolicyMinInterval(t *testing.T) {
+	assert.Equal(t, time.Duration(0), NewHostPolicy("a.com", 0, 1, 0).MinInterval())
+	assert.Equal(t, 500*time.Millisecond, NewHostPolicy("a.com", 2.0, 0, 0).MinInterval())
+	assert.Equal(t, time.Second, NewHostPolicy("a.com", 2.0, 0, time.Second).MinInterval())
+	assert.Equal(t, 2*time.Second, NewHostPolicy("a.com", 0.5, 0, time.Second).MinInterval())
+}
+
+func TestFindHostPolicy(t *testing.T) {
+	policies := []HostPolicy{
+		*NewHostPolicy(HostPolicyAnyHost, 10.0, 0, 0),
+		*NewHostPolicy("books.toscrape.com", 1.0, 0, 0),
+	}
+
+	assert.Equal(t, &policies[1], findHostPolicy(policies, "books.toscrape.com"))
+	assert.Equal(t, &policies[0], findHostPolicy(policies, "quotes.toscrape.com"))
+	assert.Nil(t, findHostPolicy(policies, ""))
+	assert.Nil(t, findHostPolicy(policies[1:], "quotes.toscrape.com"))
+}
+
+func TestHostPolicyValidate(t *testing.T) {
+	assert.Nil(t, NewHostPolicy("a.com", 1.0, 2, time.Second).Validate())
+	assert.NotNil(t, NewHostPolicy("", 1.0, 2, time.Second).Validate())
+	assert.NotNil(t, NewHostPolicy("a.com", -1.0, 2, time.Second).Validate())
+	assert.NotNil(t, NewHostPolicy("a.com", 1.0, -2, time.Second).Validate())
+	assert.NotNil(t, NewHostPolicy("a.com", 1.0, 2, -time.Second).Validate())
+}
+
+func TestHostPolicyYAML(t *testing.T) {
+	yamlStr := `Host: books.toscrape.com
+RequestsPerSecond: 0.5
+MaxInFlight: 2
+CrawlDelay: 3s
+`
+
+	hostPolicy := &HostPolicy{}
+
+	err := yaml.Unmarshal([]byte(yamlStr), hostPolicy)
+
+	assert.Nil(t, err)
+	assert.Equal(t, NewHostPolicy("books.toscrape.com", 0.5, 2, 3*time.Second), hostPolicy)
+}
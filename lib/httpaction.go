@@ -2,6 +2,7 @@ package spsw
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -60,31 +61,33 @@ func NewHTTPAction(baseURL string, method string, canFail bool) *HTTPAction {
 	}
 }
 
-func NewHTTPActionFromTemplate(actionTempl *ActionTemplate) Action {
-	var baseURL string
-	var method string
-	var canFail bool
+func NewHTTPActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("HTTPAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
 
-	baseURL = actionTempl.ConstructorParams["baseURL"].StringValue
-	method = actionTempl.ConstructorParams["method"].StringValue
-	canFail = actionTempl.ConstructorParams["canFail"].BoolValue
+	baseURL := params["baseURL"].StringValue
+	method := params["method"].StringValue
+	canFail := params["canFail"].BoolValue
 
 	action := NewHTTPAction(baseURL, method, canFail)
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (ha *HTTPAction) String() string {
 	return fmt.Sprintf("<HTTPAction %s Name: %s CanFail: %v, BaseURL: %s, Method: %s>", ha.UUID, ha.Name, ha.CanFail, ha.BaseURL, ha.Method)
 }
 
-func (ha *HTTPAction) Run() error {
+// Run makes the request, which is aborted if given context is cancelled.
+func (ha *HTTPAction) Run(ctx context.Context) error {
 	var body *bytes.Buffer
 	body = nil
 
-	request, err := http.NewRequest(ha.Method, ha.BaseURL, nil)
+	request, err := http.NewRequestWithContext(ctx, ha.Method, ha.BaseURL, nil)
 	if err != nil {
 		return err
 	}
@@ -1,11 +1,13 @@
 package spsw
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -66,7 +68,10 @@ func TestNewHTTPActionFromTemplate(t *testing.T) {
 		ConstructorParams: constructorParams,
 	}
 
-	action, ok := NewHTTPActionFromTemplate(actionTempl).(*HTTPAction)
+	gotAction, err := NewHTTPActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action, ok := gotAction.(*HTTPAction)
 	assert.True(t, ok)
 
 	assert.NotNil(t, action)
@@ -137,7 +142,7 @@ func TestHTTPActionRunGET(t *testing.T) {
 	err = httpAction.AddOutput(HTTPActionOutputResponseURL, responseURLOut)
 	assert.Nil(t, err)
 
-	err = httpAction.Run()
+	err = httpAction.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotBody, ok1 := bodyOut.Remove().([]byte)
@@ -190,7 +195,7 @@ func TestHTTPActionHandleRedirect(t *testing.T) {
 	err = httpAction.AddOutput(HTTPActionOutputResponseURL, responseURLOut)
 	assert.Nil(t, err)
 
-	err = httpAction.Run()
+	err = httpAction.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotStatus, ok1 := statusOut.Remove().(int)
@@ -218,10 +223,32 @@ func TestHTTPActionRunHEAD(t *testing.T) {
 
 	httpAction := NewHTTPAction(testServer.URL, http.MethodHead, false)
 
-	err := httpAction.Run()
+	err := httpAction.Run(context.Background())
 	assert.Nil(t, err)
 }
 
+func TestHTTPActionRunContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			<-release
+			res.WriteHeader(200)
+		}))
+
+	defer testServer.Close()
+	defer close(release)
+
+	httpAction := NewHTTPAction(testServer.URL, http.MethodGet, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := httpAction.Run(ctx)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "context deadline exceeded")
+}
+
 func TestHTTPActionRunPOST(t *testing.T) {
 	expectedBody := []byte("Test Payload")
 
@@ -246,7 +273,7 @@ func TestHTTPActionRunPOST(t *testing.T) {
 	err := httpAction.AddInput(HTTPActionInputBody, bodyIn)
 	assert.Nil(t, err)
 
-	err = httpAction.Run()
+	err = httpAction.Run(context.Background())
 	assert.Nil(t, err)
 }
 
@@ -277,7 +304,7 @@ func TestHTTPActionRunPOSTWithFormData(t *testing.T) {
 	err := httpAction.AddInput(HTTPActionInputFormData, formDataIn)
 	assert.Nil(t, err)
 
-	err = httpAction.Run()
+	err = httpAction.Run(context.Background())
 	assert.Nil(t, err)
 }
 
@@ -316,7 +343,7 @@ func TestHTTPActionRunPOSTWithMultivaluedFormData(t *testing.T) {
 	err := httpAction.AddInput(HTTPActionInputFormData, formDataIn)
 	assert.Nil(t, err)
 
-	err = httpAction.Run()
+	err = httpAction.Run(context.Background())
 	assert.Nil(t, err)
 }
 
@@ -365,7 +392,7 @@ func TestHTTPActionRunCookies(t *testing.T) {
 	err = httpAction.AddOutput(HTTPActionOutputCookies, cookiesOut)
 	assert.Nil(t, err)
 
-	err = httpAction.Run()
+	err = httpAction.Run(context.Background())
 	assert.Nil(t, err)
 
 	var gotCookies map[string]string
@@ -0,0 +1,47 @@
+package spsw
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type InMemoryDeduplicatorBackend struct {
+	AbstractDeduplicatorBackend
+	UUID string
+
+	mutex  sync.Mutex
+	hashes map[string]map[string]bool
+}
+
+func NewInMemoryDeduplicatorBackend() *InMemoryDeduplicatorBackend {
+	return &InMemoryDeduplicatorBackend{
+		UUID:   uuid.New().String(),
+		hashes: map[string]map[string]bool{},
+	}
+}
+
+func (imdb *InMemoryDeduplicatorBackend) IsScheduledTaskDuplicated(scheduledTask *ScheduledTask) bool {
+	hashStr := fmt.Sprintf("%v", scheduledTask.Hash())
+
+	imdb.mutex.Lock()
+	defer imdb.mutex.Unlock()
+
+	return imdb.hashes[scheduledTask.JobUUID][hashStr]
+}
+
+func (imdb *InMemoryDeduplicatorBackend) NoteScheduledTask(scheduledTask *ScheduledTask) error {
+	hashStr := fmt.Sprintf("%v", scheduledTask.Hash())
+
+	imdb.mutex.Lock()
+	defer imdb.mutex.Unlock()
+
+	if imdb.hashes[scheduledTask.JobUUID] == nil {
+		imdb.hashes[scheduledTask.JobUUID] = map[string]bool{}
+	}
+
+	imdb.hashes[scheduledTask.JobUUID][hashStr] = true
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package spsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryDeduplicatorBackend(t *testing.T) {
+	backend := NewInMemoryDeduplicatorBackend()
+
+	promise := NewTaskPromise("Task1", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", map[string]*DataChunk{})
+	template := NewTaskTemplate("Task1", false)
+
+	scheduledTask := NewScheduledTask(promise, template, "WF0", "v1", promise.JobUUID)
+
+	assert.False(t, backend.IsScheduledTaskDuplicated(scheduledTask))
+
+	err := backend.NoteScheduledTask(scheduledTask)
+	assert.Nil(t, err)
+
+	assert.True(t, backend.IsScheduledTaskDuplicated(scheduledTask))
+
+	otherJobTask := NewScheduledTask(promise, template, "WF0", "v1", "0C9B5F8D-4E38-4C1B-8F5B-0A8A3B2C1D0E")
+	assert.False(t, backend.IsScheduledTaskDuplicated(otherJobTask))
+}
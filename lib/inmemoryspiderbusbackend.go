@@ -0,0 +1,419 @@
+package spsw
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InMemorySpiderBusBackend keeps all SpiderBus traffic within the current process.
+// It is meant for running whole workflows on a single machine without Redis, e.g.
+// in development or in unit tests. All components that need to talk to each other
+// must share the same backend instance.
+type InMemorySpiderBusBackend struct {
+	SpiderBusBackend
+	UUID string
+
+	queues map[string]*inMemoryQueue
+
+	deadLettersMutex sync.Mutex
+	deadLetters      [][]byte
+
+	jobControlsMutex sync.Mutex
+	jobControls      [][]byte
+
+	checkpointsMutex sync.Mutex
+	checkpoints      map[string][]byte
+
+	heartbeatsMutex sync.Mutex
+	heartbeats      map[string][]byte
+}
+
+const InMemorySpiderBusBackendReceiveTimeout = 1 * time.Second
+
+const InMemoryQueueNameItems = "items"
+const InMemoryQueueNameTaskPromises = "task_promises"
+const InMemoryQueueNameScheduledTasks = "scheduled_tasks"
+const InMemoryQueueNameTaskResults = "task_results"
+const InMemoryQueueNameJobs = "jobs"
+const InMemoryQueueNameManagerReports = "manager_reports"
+const InMemoryQueueNameJobsFinished = "jobs_finished"
+
+const InMemorySpiderBusBackendPollInterval = 100 * time.Millisecond
+
+type inMemoryQueueEntry struct {
+	raw            []byte
+	priority       int
+	requiredLabels map[string]string
+}
+
+// inMemoryQueue hands out entries with higher priority first, and in FIFO order otherwise.
+type inMemoryQueue struct {
+	mutex   sync.Mutex
+	entries []inMemoryQueueEntry
+	ready   chan struct{}
+}
+
+func newInMemoryQueue() *inMemoryQueue {
+	return &inMemoryQueue{
+		entries: []inMemoryQueueEntry{},
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+func (q *inMemoryQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *inMemoryQueue) push(raw []byte) {
+	q.pushWithPriority(raw, 0, nil)
+}
+
+func (q *inMemoryQueue) pushWithPriority(raw []byte, priority int, requiredLabels map[string]string) {
+	q.mutex.Lock()
+
+	// Goes behind all the entries of the same or higher priority.
+	idx := len(q.entries)
+	for idx > 0 && q.entries[idx-1].priority < priority {
+		idx--
+	}
+
+	q.entries = append(q.entries, inMemoryQueueEntry{})
+	copy(q.entries[idx+1:], q.entries[idx:])
+	q.entries[idx] = inMemoryQueueEntry{raw: raw, priority: priority, requiredLabels: requiredLabels}
+
+	q.mutex.Unlock()
+
+	q.notify()
+}
+
+// tryPop removes the first entry whose required labels match given labels.
+func (q *inMemoryQueue) tryPop(labels map[string]string) []byte {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, entry := range q.entries {
+		if !LabelsMatch(labels, entry.requiredLabels) {
+			continue
+		}
+
+		q.entries = append(q.entries[:i], q.entries[i+1:]...)
+
+		// Wake up another consumer if there's still something left.
+		if len(q.entries) > 0 {
+			q.notify()
+		}
+
+		return entry.raw
+	}
+
+	return nil
+}
+
+// pop removes the oldest entry that given labels match from the queue, waiting up to
+// timeout for one to appear. Returns nil if there was none. As notification about new
+// entry may be taken by consumer that doesn't match it, queue is also polled every
+// InMemorySpiderBusBackendPollInterval.
+func (q *inMemoryQueue) pop(labels map[string]string, timeout time.Duration) []byte {
+	if raw := q.tryPop(labels); raw != nil {
+		return raw
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	ticker := time.NewTicker(InMemorySpiderBusBackendPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.ready:
+			if raw := q.tryPop(labels); raw != nil {
+				return raw
+			}
+		case <-ticker.C:
+			if raw := q.tryPop(labels); raw != nil {
+				return raw
+			}
+		case <-timer.C:
+			return q.tryPop(labels)
+		}
+	}
+}
+
+func (q *inMemoryQueue) size() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.entries)
+}
+
+func NewInMemorySpiderBusBackend() *InMemorySpiderBusBackend {
+	return &InMemorySpiderBusBackend{
+		UUID: uuid.New().String(),
+		queues: map[string]*inMemoryQueue{
+			InMemoryQueueNameItems:          newInMemoryQueue(),
+			InMemoryQueueNameTaskPromises:   newInMemoryQueue(),
+			InMemoryQueueNameScheduledTasks: newInMemoryQueue(),
+			InMemoryQueueNameTaskResults:    newInMemoryQueue(),
+			InMemoryQueueNameJobs:           newInMemoryQueue(),
+			InMemoryQueueNameManagerReports: newInMemoryQueue(),
+			InMemoryQueueNameJobsFinished:   newInMemoryQueue(),
+		},
+		deadLetters: [][]byte{},
+		jobControls: [][]byte{},
+		checkpoints: map[string][]byte{},
+		heartbeats:  map[string][]byte{},
+	}
+}
+
+// Messages are stored JSON-encoded, same as on Redis, so that sender and receiver
+// never end up sharing pointers.
+
+func (imsbb *InMemorySpiderBusBackend) SendScheduledTask(scheduledTask *ScheduledTask) error {
+	imsbb.queues[InMemoryQueueNameScheduledTasks].pushWithPriority(scheduledTask.EncodeToJSON(), scheduledTask.Priority,
+		scheduledTask.Template.RequiredLabels)
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ReceiveScheduledTask(workerLabels map[string]string) *ScheduledTask {
+	raw := imsbb.queues[InMemoryQueueNameScheduledTasks].pop(workerLabels, InMemorySpiderBusBackendReceiveTimeout)
+	if raw == nil {
+		return nil
+	}
+
+	return NewScheduledTaskFromJSON(raw)
+}
+
+// AckScheduledTask is a no-op, as in-memory queues don't outlive the process that
+// would be redelivering tasks.
+func (imsbb *InMemorySpiderBusBackend) AckScheduledTask(scheduledTaskUUID string) error {
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendTaskPromise(taskPromise *TaskPromise) error {
+	imsbb.queues[InMemoryQueueNameTaskPromises].push(taskPromise.EncodeToJSON())
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ReceiveTaskPromise() *TaskPromise {
+	raw := imsbb.queues[InMemoryQueueNameTaskPromises].pop(nil, InMemorySpiderBusBackendReceiveTimeout)
+	if raw == nil {
+		return nil
+	}
+
+	return NewTaskPromiseFromJSON(raw)
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendItem(item *Item) error {
+	imsbb.queues[InMemoryQueueNameItems].push(item.EncodeToJSON())
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ReceiveItem() *Item {
+	raw := imsbb.queues[InMemoryQueueNameItems].pop(nil, InMemorySpiderBusBackendReceiveTimeout)
+	if raw == nil {
+		return nil
+	}
+
+	return NewItemFromJSON(raw)
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendTaskResult(taskResult *TaskResult) error {
+	imsbb.queues[InMemoryQueueNameTaskResults].push(taskResult.EncodeToJSON())
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ReceiveTaskResult() *TaskResult {
+	raw := imsbb.queues[InMemoryQueueNameTaskResults].pop(nil, InMemorySpiderBusBackendReceiveTimeout)
+	if raw == nil {
+		return nil
+	}
+
+	return NewTaskResultFromJSON(raw)
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendDeadLetter(deadLetter *DeadLetter) error {
+	imsbb.deadLettersMutex.Lock()
+	defer imsbb.deadLettersMutex.Unlock()
+
+	imsbb.deadLetters = append(imsbb.deadLetters, deadLetter.EncodeToJSON())
+
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ListDeadLetters(jobUUID string) ([]*DeadLetter, error) {
+	imsbb.deadLettersMutex.Lock()
+	defer imsbb.deadLettersMutex.Unlock()
+
+	deadLetters := []*DeadLetter{}
+
+	for _, raw := range imsbb.deadLetters {
+		deadLetter := NewDeadLetterFromJSON(raw)
+
+		if jobUUID != "" && deadLetter.ScheduledTask.JobUUID != jobUUID {
+			continue
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) RemoveDeadLetter(deadLetterUUID string) error {
+	imsbb.deadLettersMutex.Lock()
+	defer imsbb.deadLettersMutex.Unlock()
+
+	for i, raw := range imsbb.deadLetters {
+		if NewDeadLetterFromJSON(raw).UUID == deadLetterUUID {
+			imsbb.deadLetters = append(imsbb.deadLetters[:i], imsbb.deadLetters[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Dead letter %s not found", deadLetterUUID)
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendJob(job *Job) error {
+	imsbb.queues[InMemoryQueueNameJobs].push(job.EncodeToJSON())
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ReceiveJob() *Job {
+	raw := imsbb.queues[InMemoryQueueNameJobs].pop(nil, InMemorySpiderBusBackendReceiveTimeout)
+	if raw == nil {
+		return nil
+	}
+
+	return NewJobFromJSON(raw)
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendManagerReport(report *ManagerReport) error {
+	imsbb.queues[InMemoryQueueNameManagerReports].push(report.EncodeToJSON())
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ReceiveManagerReport() *ManagerReport {
+	raw := imsbb.queues[InMemoryQueueNameManagerReports].pop(nil, InMemorySpiderBusBackendReceiveTimeout)
+	if raw == nil {
+		return nil
+	}
+
+	return NewManagerReportFromJSON(raw)
+}
+
+func (imsbb *InMemorySpiderBusBackend) SaveManagerCheckpoint(checkpoint *ManagerCheckpoint) error {
+	imsbb.checkpointsMutex.Lock()
+	defer imsbb.checkpointsMutex.Unlock()
+
+	imsbb.checkpoints[checkpoint.JobUUID] = checkpoint.EncodeToJSON()
+
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) LoadManagerCheckpoint(jobUUID string) (*ManagerCheckpoint, error) {
+	imsbb.checkpointsMutex.Lock()
+	defer imsbb.checkpointsMutex.Unlock()
+
+	raw, found := imsbb.checkpoints[jobUUID]
+	if !found {
+		return nil, ErrManagerCheckpointNotFound
+	}
+
+	return NewManagerCheckpointFromJSON(raw), nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) SaveWorkerHeartbeat(heartbeat *WorkerHeartbeat) error {
+	imsbb.heartbeatsMutex.Lock()
+	defer imsbb.heartbeatsMutex.Unlock()
+
+	imsbb.heartbeats[heartbeat.WorkerUUID] = heartbeat.EncodeToJSON()
+
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ListWorkerHeartbeats() ([]*WorkerHeartbeat, error) {
+	imsbb.heartbeatsMutex.Lock()
+	defer imsbb.heartbeatsMutex.Unlock()
+
+	heartbeats := []*WorkerHeartbeat{}
+
+	for _, raw := range imsbb.heartbeats {
+		heartbeat := NewWorkerHeartbeatFromJSON(raw)
+		if heartbeat != nil {
+			heartbeats = append(heartbeats, heartbeat)
+		}
+	}
+
+	return heartbeats, nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendJobFinished(jobFinished *JobFinished) error {
+	imsbb.queues[InMemoryQueueNameJobsFinished].push(jobFinished.EncodeToJSON())
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) ReceiveJobFinished() *JobFinished {
+	raw := imsbb.queues[InMemoryQueueNameJobsFinished].pop(nil, InMemorySpiderBusBackendReceiveTimeout)
+	if raw == nil {
+		return nil
+	}
+
+	return NewJobFinishedFromJSON(raw)
+}
+
+func (imsbb *InMemorySpiderBusBackend) SendJobControl(jobControl *JobControl) error {
+	imsbb.jobControlsMutex.Lock()
+	defer imsbb.jobControlsMutex.Unlock()
+
+	imsbb.jobControls = append(imsbb.jobControls, jobControl.EncodeToJSON())
+
+	return nil
+}
+
+func (imsbb *InMemorySpiderBusBackend) readJobControls(offset int) ([]*JobControl, int) {
+	imsbb.jobControlsMutex.Lock()
+	defer imsbb.jobControlsMutex.Unlock()
+
+	jobControls := []*JobControl{}
+
+	for i := offset; i < len(imsbb.jobControls); i++ {
+		jobControls = append(jobControls, NewJobControlFromJSON(imsbb.jobControls[i]))
+	}
+
+	return jobControls, len(imsbb.jobControls)
+}
+
+// ReceiveJobControls uses number of job controls seen so far as cursor. It waits up to
+// InMemorySpiderBusBackendReceiveTimeout for new ones to appear.
+func (imsbb *InMemorySpiderBusBackend) ReceiveJobControls(cursor string) ([]*JobControl, string, error) {
+	offset := 0
+
+	if cursor != "" {
+		var err error
+
+		offset, err = strconv.Atoi(cursor)
+		if err != nil {
+			return nil, cursor, fmt.Errorf("Bad job control cursor %s: %v", cursor, err)
+		}
+	}
+
+	deadline := time.Now().Add(InMemorySpiderBusBackendReceiveTimeout)
+
+	for {
+		jobControls, newOffset := imsbb.readJobControls(offset)
+
+		if len(jobControls) > 0 || time.Now().After(deadline) {
+			return jobControls, strconv.Itoa(newOffset), nil
+		}
+
+		time.Sleep(InMemorySpiderBusBackendPollInterval)
+	}
+}
@@ -0,0 +1,298 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewInMemorySpiderBusBackend(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	assert.NotNil(t, backend)
+	assert.NotEqual(t, "", backend.UUID)
+	assert.Equal(t, 7, len(backend.queues))
+}
+
+func TestInMemorySpiderBusBackendScheduledTask(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	promise := NewTaskPromise("Task1", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", map[string]*DataChunk{})
+	template := NewTaskTemplate("Task1", false)
+
+	scheduledTask1 := NewScheduledTask(promise, template, "WF0", "v1", promise.JobUUID)
+	scheduledTask2 := NewScheduledTask(promise, template, "WF0", "v1", promise.JobUUID)
+
+	err := backend.SendScheduledTask(scheduledTask1)
+	assert.Nil(t, err)
+
+	err = backend.SendScheduledTask(scheduledTask2)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, backend.queues[InMemoryQueueNameScheduledTasks].size())
+
+	gotScheduledTask := backend.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, scheduledTask1.UUID, gotScheduledTask.UUID)
+	assert.False(t, scheduledTask1 == gotScheduledTask)
+
+	gotScheduledTask = backend.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, scheduledTask2.UUID, gotScheduledTask.UUID)
+
+	assert.Equal(t, 0, backend.queues[InMemoryQueueNameScheduledTasks].size())
+}
+
+func TestInMemorySpiderBusBackendScheduledTaskPriority(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	promise := NewTaskPromise("Task1", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", map[string]*DataChunk{})
+	listTemplate := NewTaskTemplate("ListPage", false)
+	detailTemplate := NewTaskTemplate("DetailPage", false)
+	detailTemplate.Priority = 3
+
+	listTask1 := NewScheduledTask(promise, listTemplate, "WF0", "v1", promise.JobUUID)
+	listTask2 := NewScheduledTask(promise, listTemplate, "WF0", "v1", promise.JobUUID)
+	detailTask1 := NewScheduledTask(promise, detailTemplate, "WF0", "v1", promise.JobUUID)
+	detailTask2 := NewScheduledTask(promise, detailTemplate, "WF0", "v1", promise.JobUUID)
+
+	for _, scheduledTask := range []*ScheduledTask{listTask1, detailTask1, listTask2, detailTask2} {
+		assert.Nil(t, backend.SendScheduledTask(scheduledTask))
+	}
+
+	for _, expectTask := range []*ScheduledTask{detailTask1, detailTask2, listTask1, listTask2} {
+		gotScheduledTask := backend.ReceiveScheduledTask(nil)
+		assert.NotNil(t, gotScheduledTask)
+		assert.Equal(t, expectTask.UUID, gotScheduledTask.UUID)
+	}
+}
+
+func TestInMemorySpiderBusBackendScheduledTaskLabels(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	promise := NewTaskPromise("Task1", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", map[string]*DataChunk{})
+	anyTemplate := NewTaskTemplate("ListPage", false)
+	euTemplate := NewTaskTemplate("DetailPage", false)
+	euTemplate.RequiredLabels = map[string]string{"region": "eu"}
+	euTemplate.Priority = 3
+
+	euTask := NewScheduledTask(promise, euTemplate, "WF0", "v1", promise.JobUUID)
+	anyTask := NewScheduledTask(promise, anyTemplate, "WF0", "v1", promise.JobUUID)
+
+	assert.Nil(t, backend.SendScheduledTask(euTask))
+	assert.Nil(t, backend.SendScheduledTask(anyTask))
+
+	gotScheduledTask := backend.ReceiveScheduledTask(map[string]string{"region": "us"})
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, anyTask.UUID, gotScheduledTask.UUID)
+
+	queue := backend.queues[InMemoryQueueNameScheduledTasks]
+	assert.Nil(t, queue.pop(nil, 10*time.Millisecond))
+	assert.Nil(t, queue.pop(map[string]string{"region": "us"}, 10*time.Millisecond))
+
+	gotScheduledTask = backend.ReceiveScheduledTask(map[string]string{"region": "eu", "proxy": "residential"})
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, euTask.UUID, gotScheduledTask.UUID)
+}
+
+func TestInMemorySpiderBusBackendTaskPromise(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	promise := NewTaskPromise("Task1", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", map[string]*DataChunk{})
+
+	err := backend.SendTaskPromise(promise)
+	assert.Nil(t, err)
+
+	gotPromise := backend.ReceiveTaskPromise()
+	assert.NotNil(t, gotPromise)
+	assert.Equal(t, promise.UUID, gotPromise.UUID)
+	assert.Equal(t, promise.TaskName, gotPromise.TaskName)
+}
+
+func TestInMemorySpiderBusBackendItem(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	item := NewItem("person", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", "9B0C7B2E-6A0E-4E55-9D5D-FB1D1D5BE1A1")
+	item.SetField("name", "Faust")
+
+	err := backend.SendItem(item)
+	assert.Nil(t, err)
+
+	gotItem := backend.ReceiveItem()
+	assert.NotNil(t, gotItem)
+	assert.Equal(t, item.UUID, gotItem.UUID)
+	assert.Equal(t, "Faust", gotItem.Fields["name"].StringValue)
+}
+
+func TestInMemorySpiderBusBackendTaskResult(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	taskResult := NewTaskResult("A0", "B1", "C2", true, nil)
+
+	err := backend.SendTaskResult(taskResult)
+	assert.Nil(t, err)
+
+	gotTaskResult := backend.ReceiveTaskResult()
+	assert.NotNil(t, gotTaskResult)
+	assert.Equal(t, taskResult.UUID, gotTaskResult.UUID)
+	assert.True(t, gotTaskResult.Succeeded)
+}
+
+func TestInMemorySpiderBusBackendReceiveBlocksUntilSent(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	item := NewItem("person", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", "")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		backend.SendItem(item)
+	}()
+
+	gotItem := backend.ReceiveItem()
+	assert.NotNil(t, gotItem)
+	assert.Equal(t, item.UUID, gotItem.UUID)
+}
+
+func TestInMemorySpiderBusBackendReceiveEmpty(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	queue := backend.queues[InMemoryQueueNameItems]
+
+	assert.Nil(t, queue.pop(nil, 10*time.Millisecond))
+}
+
+func TestInMemorySpiderBusBackendDeadLetters(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	deadLetter1 := NewDeadLetter(NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "job1"),
+		"connection refused")
+	deadLetter2 := NewDeadLetter(NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "job2"),
+		"connection refused")
+
+	assert.Nil(t, backend.SendDeadLetter(deadLetter1))
+	assert.Nil(t, backend.SendDeadLetter(deadLetter2))
+
+	deadLetters, err := backend.ListDeadLetters("")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(deadLetters))
+
+	deadLetters, err = backend.ListDeadLetters("job1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deadLetters))
+	assert.Equal(t, deadLetter1.UUID, deadLetters[0].UUID)
+
+	assert.Nil(t, backend.RemoveDeadLetter(deadLetter1.UUID))
+	assert.NotNil(t, backend.RemoveDeadLetter(deadLetter1.UUID))
+
+	deadLetters, err = backend.ListDeadLetters("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deadLetters))
+	assert.Equal(t, deadLetter2.UUID, deadLetters[0].UUID)
+}
+
+func TestInMemorySpiderBusBackendJobsAndReports(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	job := NewJob(&Workflow{Name: "WF0", Version: "v1"})
+	report := NewManagerReport("manager1", job.UUID, JobStatusRunning, JobStats{NPendingTasks: 1})
+
+	assert.Nil(t, backend.SendJob(job))
+	assert.Nil(t, backend.SendManagerReport(report))
+
+	gotJob := backend.ReceiveJob()
+	assert.NotNil(t, gotJob)
+	assert.Equal(t, job.UUID, gotJob.UUID)
+
+	gotReport := backend.ReceiveManagerReport()
+	assert.NotNil(t, gotReport)
+	assert.Equal(t, report.UUID, gotReport.UUID)
+
+	jobFinished := NewJobFinished(job.UUID, JobStatusFinished, JobStats{NFinishedTasks: 1}, 3)
+
+	assert.Nil(t, backend.SendJobFinished(jobFinished))
+
+	gotJobFinished := backend.ReceiveJobFinished()
+	assert.NotNil(t, gotJobFinished)
+	assert.Equal(t, jobFinished.UUID, gotJobFinished.UUID)
+	assert.Equal(t, 3, gotJobFinished.NItems)
+
+	_, err := backend.LoadManagerCheckpoint(job.UUID)
+	assert.Equal(t, ErrManagerCheckpointNotFound, err)
+
+	managerJob := NewManagerJob(job.Workflow, job.UUID)
+
+	assert.Nil(t, backend.SaveManagerCheckpoint(NewManagerCheckpoint("manager1", managerJob)))
+
+	managerJob.NFinishedTasks = 2
+	checkpoint := NewManagerCheckpoint("manager1", managerJob)
+
+	assert.Nil(t, backend.SaveManagerCheckpoint(checkpoint))
+
+	// Only the latest checkpoint is kept.
+	gotCheckpoint, err := backend.LoadManagerCheckpoint(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, checkpoint.UUID, gotCheckpoint.UUID)
+	assert.Equal(t, 2, gotCheckpoint.NFinishedTasks)
+}
+
+func TestInMemorySpiderBusBackendJobControls(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	jobControls, cursor, err := backend.ReceiveJobControls("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(jobControls))
+
+	jobControl1 := NewJobControl("job1", JobControlActionCancel)
+	jobControl2 := NewJobControl("job2", JobControlActionCancel)
+
+	assert.Nil(t, backend.SendJobControl(jobControl1))
+
+	jobControls, cursor, err = backend.ReceiveJobControls(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobControls))
+	assert.Equal(t, jobControl1.UUID, jobControls[0].UUID)
+
+	assert.Nil(t, backend.SendJobControl(jobControl2))
+
+	jobControls, _, err = backend.ReceiveJobControls(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobControls))
+	assert.Equal(t, jobControl2.UUID, jobControls[0].UUID)
+
+	// Every consumer gets to see all job controls.
+	jobControls, _, err = backend.ReceiveJobControls("")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(jobControls))
+
+	_, _, err = backend.ReceiveJobControls("bad")
+	assert.NotNil(t, err)
+}
+
+func TestInMemorySpiderBusBackendWorkerHeartbeats(t *testing.T) {
+	backend := NewInMemorySpiderBusBackend()
+
+	heartbeats, err := backend.ListWorkerHeartbeats()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(heartbeats))
+
+	assert.Nil(t, backend.SaveWorkerHeartbeat(NewWorkerHeartbeat("worker1", "host1")))
+	assert.Nil(t, backend.SaveWorkerHeartbeat(NewWorkerHeartbeat("worker2", "host1")))
+
+	heartbeat := NewWorkerHeartbeat("worker1", "host1")
+	heartbeat.NFinishedTasks = 3
+	assert.Nil(t, backend.SaveWorkerHeartbeat(heartbeat))
+
+	// Only the latest heartbeat of each worker is kept.
+	heartbeats, err = backend.ListWorkerHeartbeats()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(heartbeats))
+
+	for _, gotHeartbeat := range heartbeats {
+		if gotHeartbeat.WorkerUUID == "worker1" {
+			assert.Equal(t, heartbeat.UUID, gotHeartbeat.UUID)
+			assert.Equal(t, 3, gotHeartbeat.NFinishedTasks)
+		}
+	}
+}
@@ -138,22 +138,15 @@ func (i *Item) Splay() []*Item {
 	return items
 }
 
+// SetField sets field to given value, which can be anything NewValue supports. Values
+// of unsupported types are ignored.
 func (i *Item) SetField(name string, value interface{}) {
-	if s, okStr := value.(string); okStr {
-		i.Fields[name] = NewValueFromString(s)
+	v := NewValue(value)
+	if v == nil {
+		return
 	}
 
-	if in, okInt := value.(int); okInt {
-		i.Fields[name] = NewValueFromInt(in)
-	}
-
-	if str, okStr2 := value.([]string); okStr2 {
-		i.Fields[name] = NewValueFromStrings(str)
-	}
-
-	if b, okBool := value.(bool); okBool {
-		i.Fields[name] = NewValueFromBool(b)
-	}
+	i.Fields[name] = v
 }
 
 func (i *Item) EncodeToJSON() []byte {
@@ -129,8 +129,11 @@ func TestItemSetField(t *testing.T) {
 	item.SetField("testStrings", []string{"1", "2"})
 	item.SetField("testInt", 42)
 	item.SetField("testBool", false)
+	item.SetField("testFloat", 9.99)
+	item.SetField("testMap", map[string]interface{}{"a": nil})
+	item.SetField("testUnsupported", struct{}{})
 
-	assert.Equal(t, 4, len(item.Fields))
+	assert.Equal(t, 6, len(item.Fields))
 
 	expectedFields := map[string]*Value{
 		"testStr": &Value{
@@ -149,6 +152,16 @@ func TestItemSetField(t *testing.T) {
 			ValueType: ValueTypeBool,
 			BoolValue: false,
 		},
+		"testFloat": &Value{
+			ValueType:  ValueTypeFloat,
+			FloatValue: 9.99,
+		},
+		"testMap": &Value{
+			ValueType: ValueTypeMap,
+			MapValue: map[string]*Value{
+				"a": &Value{ValueType: ValueTypeNull},
+			},
+		},
 	}
 
 	assert.Equal(t, expectedFields, item.Fields)
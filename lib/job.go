@@ -0,0 +1,91 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job statuses are exposed through Master API, hence short lowercase values.
+const JobStatusQueued = "queued"
+const JobStatusRunning = "running"
+const JobStatusPaused = "paused"
+const JobStatusFinished = "finished"
+const JobStatusFailed = "failed"
+const JobStatusCancelled = "cancelled"
+
+type JobStats struct {
+	NPendingTasks   int
+	NFinishedTasks  int
+	NFailedTasks    int
+	NScheduledTasks int
+}
+
+// Job is a single run of scraping workflow, as tracked by Master. Jobs started by
+// JobScheduler carry name of the schedule.
+type Job struct {
+	UUID            string
+	WorkflowName    string
+	WorkflowVersion string
+	Workflow        *Workflow `json:",omitempty"`
+	ScheduleName    string    `json:",omitempty"`
+	Status          string
+	ManagerUUID     string
+	Stats           JobStats
+	StopReason      string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func NewJob(workflow *Workflow) *Job {
+	now := time.Now()
+
+	return &Job{
+		UUID:            uuid.New().String(),
+		WorkflowName:    workflow.Name,
+		WorkflowVersion: workflow.Version,
+		Workflow:        workflow,
+		Status:          JobStatusQueued,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
+
+func NewJobFromJSON(raw []byte) *Job {
+	job := &Job{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(job)
+	if err != nil {
+		return nil
+	}
+
+	return job
+}
+
+func (j *Job) String() string {
+	return fmt.Sprintf("<Job %s WorkflowName: %s, WorkflowVersion: %s, Status: %s, ManagerUUID: %s, Stats: %+v>",
+		j.UUID, j.WorkflowName, j.WorkflowVersion, j.Status, j.ManagerUUID, j.Stats)
+}
+
+func (j *Job) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(j)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
+
+// IsDone tells if job has reached one of the final statuses.
+func (j *Job) IsDone() bool {
+	return j.Status == JobStatusFinished || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
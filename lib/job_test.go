@@ -0,0 +1,51 @@
+package spsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJob(t *testing.T) {
+	workflow := &Workflow{Name: "WF0", Version: "v1"}
+
+	job := NewJob(workflow)
+
+	assert.NotNil(t, job)
+	assert.Equal(t, 36, len(job.UUID))
+	assert.Equal(t, "WF0", job.WorkflowName)
+	assert.Equal(t, "v1", job.WorkflowVersion)
+	assert.Equal(t, workflow, job.Workflow)
+	assert.Equal(t, JobStatusQueued, job.Status)
+	assert.False(t, job.CreatedAt.IsZero())
+}
+
+func TestJobJSONAndBack(t *testing.T) {
+	workflow := &Workflow{Name: "WF0", Version: "v1",
+		TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+
+	job := NewJob(workflow)
+	job.Stats.NScheduledTasks = 3
+
+	gotJob := NewJobFromJSON(job.EncodeToJSON())
+
+	assert.NotNil(t, gotJob)
+	assert.Equal(t, job.UUID, gotJob.UUID)
+	assert.Equal(t, job.Status, gotJob.Status)
+	assert.Equal(t, job.Stats, gotJob.Stats)
+	assert.Equal(t, "Task1", gotJob.Workflow.TaskTemplates[0].TaskName)
+}
+
+func TestJobIsDone(t *testing.T) {
+	job := NewJob(&Workflow{})
+
+	for _, status := range []string{JobStatusQueued, JobStatusRunning} {
+		job.Status = status
+		assert.False(t, job.IsDone())
+	}
+
+	for _, status := range []string{JobStatusFinished, JobStatusFailed, JobStatusCancelled} {
+		job.Status = status
+		assert.True(t, job.IsDone())
+	}
+}
@@ -0,0 +1,106 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const JobControlActionCancel = "JobControlActionCancel"
+const JobControlActionPause = "JobControlActionPause"
+const JobControlActionResume = "JobControlActionResume"
+
+// JobControlRetention is how long job controls are heeded after being sent. Older ones
+// (e.g. replayed from the start of the bus when component starts) are ignored, and so
+// are jobs they were remembered for.
+const JobControlRetention = 24 * time.Hour
+
+// JobControlActionsByName maps short action names, as used in API paths and on command
+// line, to job control actions.
+var JobControlActionsByName = map[string]string{
+	"cancel": JobControlActionCancel,
+	"pause":  JobControlActionPause,
+	"resume": JobControlActionResume,
+}
+
+// JobControl tells components what to do with a running job. Unlike other SpiderBus
+// messages, job controls are broadcast: every consumer gets to see every one of them.
+type JobControl struct {
+	UUID      string
+	JobUUID   string
+	Action    string
+	CreatedAt time.Time
+}
+
+func NewJobControl(jobUUID string, action string) *JobControl {
+	return &JobControl{
+		UUID:      uuid.New().String(),
+		JobUUID:   jobUUID,
+		Action:    action,
+		CreatedAt: time.Now(),
+	}
+}
+
+func NewJobControlFromJSON(raw []byte) *JobControl {
+	jobControl := &JobControl{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(jobControl)
+	if err != nil {
+		return nil
+	}
+
+	return jobControl
+}
+
+func (jc *JobControl) String() string {
+	return fmt.Sprintf("<JobControl %s JobUUID: %s, Action: %s>", jc.UUID, jc.JobUUID, jc.Action)
+}
+
+func (jc *JobControl) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(jc)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
+
+// jobUUIDSet remembers job UUIDs along with time they were added at, so that they can be
+// forgotten once JobControlRetention runs out.
+type jobUUIDSet map[string]time.Time
+
+func (s jobUUIDSet) add(jobUUID string, addedAt time.Time) {
+	s[jobUUID] = addedAt
+}
+
+func (s jobUUIDSet) contains(jobUUID string) bool {
+	_, found := s[jobUUID]
+	return found
+}
+
+func (s jobUUIDSet) remove(jobUUID string) {
+	delete(s, jobUUID)
+}
+
+// prune forgets job UUIDs that were added more than JobControlRetention ago.
+func (s jobUUIDSet) prune() {
+	for jobUUID, addedAt := range s {
+		if time.Since(addedAt) > JobControlRetention {
+			delete(s, jobUUID)
+		}
+	}
+}
+
+// isStale tells if job control was sent too long ago to be heeded.
+func (jc *JobControl) isStale() bool {
+	return time.Since(jc.CreatedAt) > JobControlRetention
+}
@@ -0,0 +1,49 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJobControl(t *testing.T) {
+	jobControl := NewJobControl("job1", JobControlActionCancel)
+
+	assert.NotNil(t, jobControl)
+	assert.Equal(t, 36, len(jobControl.UUID))
+	assert.Equal(t, "job1", jobControl.JobUUID)
+	assert.Equal(t, JobControlActionCancel, jobControl.Action)
+}
+
+func TestJobControlActionsByName(t *testing.T) {
+	assert.Equal(t, JobControlActionCancel, JobControlActionsByName["cancel"])
+	assert.Equal(t, JobControlActionPause, JobControlActionsByName["pause"])
+	assert.Equal(t, JobControlActionResume, JobControlActionsByName["resume"])
+}
+
+func TestJobControlJSONAndBack(t *testing.T) {
+	jobControl := NewJobControl("job1", JobControlActionCancel)
+
+	gotJobControl := NewJobControlFromJSON(jobControl.EncodeToJSON())
+
+	assert.NotNil(t, gotJobControl)
+	assert.Equal(t, jobControl.UUID, gotJobControl.UUID)
+	assert.Equal(t, "job1", gotJobControl.JobUUID)
+	assert.Equal(t, JobControlActionCancel, gotJobControl.Action)
+}
+
+func TestJobUUIDSetPrune(t *testing.T) {
+	s := jobUUIDSet{}
+
+	s.add("job1", time.Now())
+	s.add("job2", time.Now().Add(-2*JobControlRetention))
+
+	s.prune()
+
+	assert.True(t, s.contains("job1"))
+	assert.False(t, s.contains("job2"))
+
+	s.remove("job1")
+	assert.False(t, s.contains("job1"))
+}
@@ -0,0 +1,67 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobFinished is sent by Manager once a job has no more pending tasks, so that Exporter
+// can finalize its output. NItems is the number of items Manager sent out for the job,
+// letting Exporter wait for items still in flight.
+type JobFinished struct {
+	UUID            string
+	JobUUID         string
+	WorkflowName    string
+	WorkflowVersion string
+	JobStatus       string
+	Stats           JobStats
+	NItems          int
+	StopReason      string
+	CreatedAt       time.Time
+}
+
+func NewJobFinished(jobUUID string, jobStatus string, stats JobStats, nItems int) *JobFinished {
+	return &JobFinished{
+		UUID:      uuid.New().String(),
+		JobUUID:   jobUUID,
+		JobStatus: jobStatus,
+		Stats:     stats,
+		NItems:    nItems,
+		CreatedAt: time.Now(),
+	}
+}
+
+func NewJobFinishedFromJSON(raw []byte) *JobFinished {
+	jobFinished := &JobFinished{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(jobFinished)
+	if err != nil {
+		return nil
+	}
+
+	return jobFinished
+}
+
+func (jf *JobFinished) String() string {
+	return fmt.Sprintf("<JobFinished %s JobUUID: %s, JobStatus: %s, Stats: %+v, NItems: %d, StopReason: %s>", jf.UUID,
+		jf.JobUUID, jf.JobStatus, jf.Stats, jf.NItems, jf.StopReason)
+}
+
+func (jf *JobFinished) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(jf)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
@@ -0,0 +1,33 @@
+package spsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJobFinished(t *testing.T) {
+	stats := JobStats{NFinishedTasks: 2, NFailedTasks: 1, NScheduledTasks: 3}
+
+	jobFinished := NewJobFinished("job1", JobStatusFinished, stats, 5)
+
+	assert.NotNil(t, jobFinished)
+	assert.Equal(t, 36, len(jobFinished.UUID))
+	assert.Equal(t, "job1", jobFinished.JobUUID)
+	assert.Equal(t, JobStatusFinished, jobFinished.JobStatus)
+	assert.Equal(t, stats, jobFinished.Stats)
+	assert.Equal(t, 5, jobFinished.NItems)
+}
+
+func TestJobFinishedJSONAndBack(t *testing.T) {
+	jobFinished := NewJobFinished("job1", JobStatusCancelled, JobStats{NFinishedTasks: 1}, 1)
+	jobFinished.WorkflowName = "WF0"
+
+	gotJobFinished := NewJobFinishedFromJSON(jobFinished.EncodeToJSON())
+
+	assert.NotNil(t, gotJobFinished)
+	assert.Equal(t, jobFinished.UUID, gotJobFinished.UUID)
+	assert.Equal(t, "WF0", gotJobFinished.WorkflowName)
+	assert.Equal(t, JobStatusCancelled, gotJobFinished.JobStatus)
+	assert.Equal(t, 1, gotJobFinished.NItems)
+}
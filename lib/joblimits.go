@@ -0,0 +1,69 @@
+package spsw
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stop reasons tell which limit ended the job. They are exposed through Master API along
+// with job status.
+const JobStopReasonMaxDepth = "max_depth"
+const JobStopReasonMaxScheduledTasks = "max_scheduled_tasks"
+const JobStopReasonMaxItems = "max_items"
+const JobStopReasonMaxDuration = "max_duration"
+
+// JobLimits keep crawls from running away. Tasks deeper than MaxDepth (initial task being
+// at depth 0) are not scheduled. Once job has scheduled MaxScheduledTasks tasks, produced
+// MaxItems items or has been running for MaxDuration, Manager stops scheduling new tasks
+// for it. Zero value means no limit.
+type JobLimits struct {
+	MaxDepth          int           `yaml:"MaxDepth,omitempty"`
+	MaxScheduledTasks int           `yaml:"MaxScheduledTasks,omitempty"`
+	MaxItems          int           `yaml:"MaxItems,omitempty"`
+	MaxDuration       time.Duration `yaml:"MaxDuration,omitempty"`
+}
+
+func NewJobLimits() *JobLimits {
+	return &JobLimits{}
+}
+
+func (jl *JobLimits) String() string {
+	return fmt.Sprintf("<JobLimits MaxDepth: %d, MaxScheduledTasks: %d, MaxItems: %d, MaxDuration: %v>",
+		jl.MaxDepth, jl.MaxScheduledTasks, jl.MaxItems, jl.MaxDuration)
+}
+
+func (jl *JobLimits) IsDepthExceeded(depth int) bool {
+	return jl.MaxDepth > 0 && depth > jl.MaxDepth
+}
+
+func (jl *JobLimits) IsScheduledTasksReached(nScheduledTasks int) bool {
+	return jl.MaxScheduledTasks > 0 && nScheduledTasks >= jl.MaxScheduledTasks
+}
+
+func (jl *JobLimits) IsItemsReached(nItems int) bool {
+	return jl.MaxItems > 0 && nItems >= jl.MaxItems
+}
+
+func (jl *JobLimits) IsDurationExceeded(startedAt time.Time) bool {
+	return jl.MaxDuration > 0 && !startedAt.IsZero() && time.Since(startedAt) >= jl.MaxDuration
+}
+
+func (jl *JobLimits) Validate() error {
+	if jl.MaxDepth < 0 {
+		return fmt.Errorf("MaxDepth must not be negative, got %d", jl.MaxDepth)
+	}
+
+	if jl.MaxScheduledTasks < 0 {
+		return fmt.Errorf("MaxScheduledTasks must not be negative, got %d", jl.MaxScheduledTasks)
+	}
+
+	if jl.MaxItems < 0 {
+		return fmt.Errorf("MaxItems must not be negative, got %d", jl.MaxItems)
+	}
+
+	if jl.MaxDuration < 0 {
+		return fmt.Errorf("MaxDuration must not be negative, got %v", jl.MaxDuration)
+	}
+
+	return nil
+}
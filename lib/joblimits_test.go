@@ -0,0 +1,59 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestNewJobLimits(t *testing.T) {
+	limits := NewJobLimits()
+
+	assert.NotNil(t, limits)
+	assert.False(t, limits.IsDepthExceeded(1000))
+	assert.False(t, limits.IsScheduledTasksReached(1000))
+	assert.False(t, limits.IsItemsReached(1000))
+	assert.False(t, limits.IsDurationExceeded(time.Now().Add(-24*time.Hour)))
+}
+
+func TestJobLimits(t *testing.T) {
+	limits := &JobLimits{MaxDepth: 2, MaxScheduledTasks: 10, MaxItems: 5, MaxDuration: time.Minute}
+
+	assert.False(t, limits.IsDepthExceeded(2))
+	assert.True(t, limits.IsDepthExceeded(3))
+
+	assert.False(t, limits.IsScheduledTasksReached(9))
+	assert.True(t, limits.IsScheduledTasksReached(10))
+
+	assert.False(t, limits.IsItemsReached(4))
+	assert.True(t, limits.IsItemsReached(5))
+
+	assert.False(t, limits.IsDurationExceeded(time.Time{}))
+	assert.False(t, limits.IsDurationExceeded(time.Now()))
+	assert.True(t, limits.IsDurationExceeded(time.Now().Add(-2*time.Minute)))
+}
+
+func TestJobLimitsValidate(t *testing.T) {
+	assert.Nil(t, NewJobLimits().Validate())
+	assert.Nil(t, (&JobLimits{MaxDepth: 2, MaxScheduledTasks: 10, MaxItems: 5, MaxDuration: time.Minute}).Validate())
+	assert.NotNil(t, (&JobLimits{MaxDepth: -1}).Validate())
+	assert.NotNil(t, (&JobLimits{MaxScheduledTasks: -1}).Validate())
+	assert.NotNil(t, (&JobLimits{MaxItems: -1}).Validate())
+	assert.NotNil(t, (&JobLimits{MaxDuration: -time.Second}).Validate())
+}
+
+func TestJobLimitsYAML(t *testing.T) {
+	yamlStr := `MaxDepth: 50
+MaxItems: 1000
+MaxDuration: 2h
+`
+
+	limits := &JobLimits{}
+
+	err := yaml.Unmarshal([]byte(yamlStr), limits)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &JobLimits{MaxDepth: 50, MaxItems: 1000, MaxDuration: 2 * time.Hour}, limits)
+}
@@ -0,0 +1,182 @@
+package spsw
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Overlap policies tell JobScheduler what to do when schedule is due while its previous
+// run is still going: skip the new run, queue it until previous one is done, or cancel
+// previous run and start the new one right away.
+const ScheduleOverlapPolicySkip = "skip"
+const ScheduleOverlapPolicyQueue = "queue"
+const ScheduleOverlapPolicyCancel = "cancel"
+
+var ScheduleOverlapPolicies = []string{
+	ScheduleOverlapPolicySkip,
+	ScheduleOverlapPolicyQueue,
+	ScheduleOverlapPolicyCancel,
+}
+
+// JobSchedule makes Master start jobs for a workflow on cron expression (standard 5 field
+// format, or descriptors like @daily) or at fixed interval. Workflow is either read from
+// YAML file at WorkflowPath on each run, or loaded from Master's store by name and version.
+type JobSchedule struct {
+	Name            string        `yaml:"Name"`
+	WorkflowPath    string        `yaml:"WorkflowPath,omitempty"`
+	WorkflowName    string        `yaml:"WorkflowName,omitempty"`
+	WorkflowVersion string        `yaml:"WorkflowVersion,omitempty"`
+	Cron            string        `yaml:"Cron,omitempty"`
+	Interval        time.Duration `yaml:"Interval,omitempty"`
+	OverlapPolicy   string        `yaml:"OverlapPolicy,omitempty"`
+	Disabled        bool          `yaml:"Disabled,omitempty"`
+}
+
+type jobSchedulesFile struct {
+	Schedules []*JobSchedule `yaml:"Schedules"`
+}
+
+func NewJobSchedule(name string, workflowName string, workflowVersion string) *JobSchedule {
+	return &JobSchedule{
+		Name:            name,
+		WorkflowName:    workflowName,
+		WorkflowVersion: workflowVersion,
+		OverlapPolicy:   ScheduleOverlapPolicySkip,
+	}
+}
+
+// NewJobSchedulesFromYAML parses schedules file, which lists schedules under top-level
+// Schedules key.
+func NewJobSchedulesFromYAML(raw []byte) ([]*JobSchedule, error) {
+	schedulesFile := &jobSchedulesFile{}
+
+	err := yaml.Unmarshal(raw, schedulesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+
+	for _, schedule := range schedulesFile.Schedules {
+		err = schedule.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("Schedule %s: %v", schedule.Name, err)
+		}
+
+		if names[schedule.Name] {
+			return nil, fmt.Errorf("Schedule %s is defined more than once", schedule.Name)
+		}
+
+		names[schedule.Name] = true
+	}
+
+	return schedulesFile.Schedules, nil
+}
+
+func (js *JobSchedule) String() string {
+	return fmt.Sprintf("<JobSchedule %s Workflow: %s%s %s, Cron: %s, Interval: %v, OverlapPolicy: %s>", js.Name,
+		js.WorkflowPath, js.WorkflowName, js.WorkflowVersion, js.Cron, js.Interval, js.GetOverlapPolicy())
+}
+
+// GetOverlapPolicy returns overlap policy of the schedule, skipping overlapping runs by default.
+func (js *JobSchedule) GetOverlapPolicy() string {
+	if js.OverlapPolicy == "" {
+		return ScheduleOverlapPolicySkip
+	}
+
+	return js.OverlapPolicy
+}
+
+// NextRunAfter returns the first time schedule is due strictly after given time.
+func (js *JobSchedule) NextRunAfter(t time.Time) (time.Time, error) {
+	if js.Cron == "" {
+		return t.Add(js.Interval), nil
+	}
+
+	cronSchedule, err := cron.ParseStandard(js.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := cronSchedule.Next(t)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("Cron expression %s never fires", js.Cron)
+	}
+
+	return next, nil
+}
+
+// LoadWorkflow reads workflow from WorkflowPath, or from given store if schedule refers
+// to stored workflow.
+func (js *JobSchedule) LoadWorkflow(store *MasterStore) (*Workflow, error) {
+	if js.WorkflowPath != "" {
+		raw, err := ioutil.ReadFile(js.WorkflowPath)
+		if err != nil {
+			return nil, err
+		}
+
+		workflow := &Workflow{}
+
+		err = yaml.Unmarshal(raw, workflow)
+		if err != nil {
+			return nil, err
+		}
+
+		return workflow, nil
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("Schedule %s refers to stored workflow, but master has no store", js.Name)
+	}
+
+	return store.LoadWorkflow(js.WorkflowName, js.WorkflowVersion)
+}
+
+func (js *JobSchedule) Validate() error {
+	if js.Name == "" {
+		return fmt.Errorf("Name must not be empty")
+	}
+
+	if js.WorkflowPath == "" && (js.WorkflowName == "" || js.WorkflowVersion == "") {
+		return fmt.Errorf("Either WorkflowPath or both WorkflowName and WorkflowVersion must be set")
+	}
+
+	if js.WorkflowPath != "" && (js.WorkflowName != "" || js.WorkflowVersion != "") {
+		return fmt.Errorf("WorkflowPath cannot be combined with WorkflowName and WorkflowVersion")
+	}
+
+	if (js.Cron == "") == (js.Interval == 0) {
+		return fmt.Errorf("Exactly one of Cron and Interval must be set")
+	}
+
+	if js.Interval < 0 {
+		return fmt.Errorf("Interval must not be negative, got %v", js.Interval)
+	}
+
+	if js.Cron != "" {
+		_, err := cron.ParseStandard(js.Cron)
+		if err != nil {
+			return fmt.Errorf("Bad Cron expression %s: %v", js.Cron, err)
+		}
+	}
+
+	if js.OverlapPolicy != "" {
+		known := false
+
+		for _, policy := range ScheduleOverlapPolicies {
+			if js.OverlapPolicy == policy {
+				known = true
+			}
+		}
+
+		if !known {
+			return fmt.Errorf("Unknown OverlapPolicy %s", js.OverlapPolicy)
+		}
+	}
+
+	return nil
+}
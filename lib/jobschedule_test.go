@@ -0,0 +1,184 @@
+package spsw
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestNewJobSchedule(t *testing.T) {
+	schedule := NewJobSchedule("daily", "WF0", "v1")
+
+	assert.NotNil(t, schedule)
+	assert.Equal(t, "daily", schedule.Name)
+	assert.Equal(t, "WF0", schedule.WorkflowName)
+	assert.Equal(t, "v1", schedule.WorkflowVersion)
+	assert.Equal(t, ScheduleOverlapPolicySkip, schedule.OverlapPolicy)
+}
+
+func TestJobScheduleGetOverlapPolicy(t *testing.T) {
+	schedule := &JobSchedule{}
+	assert.Equal(t, ScheduleOverlapPolicySkip, schedule.GetOverlapPolicy())
+
+	schedule.OverlapPolicy = ScheduleOverlapPolicyCancel
+	assert.Equal(t, ScheduleOverlapPolicyCancel, schedule.GetOverlapPolicy())
+}
+
+func TestJobScheduleNextRunAfter(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	schedule := NewJobSchedule("hourly", "WF0", "v1")
+	schedule.Interval = time.Hour
+
+	next, err := schedule.NextRunAfter(now)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(time.Hour), next)
+
+	schedule = NewJobSchedule("nightly", "WF0", "v1")
+	schedule.Cron = "30 3 * * *"
+
+	next, err = schedule.NextRunAfter(now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2021, 3, 5, 3, 30, 0, 0, time.UTC), next)
+
+	schedule.Cron = "@hourly"
+
+	next, err = schedule.NextRunAfter(now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2021, 3, 4, 6, 0, 0, 0, time.UTC), next)
+
+	schedule.Cron = "not a cron expression"
+
+	_, err = schedule.NextRunAfter(now)
+	assert.NotNil(t, err)
+}
+
+func TestJobScheduleValidate(t *testing.T) {
+	newSchedule := func() *JobSchedule {
+		schedule := NewJobSchedule("daily", "WF0", "v1")
+		schedule.Cron = "@daily"
+		return schedule
+	}
+
+	assert.Nil(t, newSchedule().Validate())
+
+	schedule := newSchedule()
+	schedule.Name = ""
+	assert.NotNil(t, schedule.Validate())
+
+	schedule = newSchedule()
+	schedule.WorkflowVersion = ""
+	assert.NotNil(t, schedule.Validate())
+
+	schedule = newSchedule()
+	schedule.WorkflowPath = "workflow.yaml"
+	assert.NotNil(t, schedule.Validate())
+
+	schedule.WorkflowName = ""
+	schedule.WorkflowVersion = ""
+	assert.Nil(t, schedule.Validate())
+
+	schedule = newSchedule()
+	schedule.Interval = time.Hour
+	assert.NotNil(t, schedule.Validate())
+
+	schedule.Cron = ""
+	assert.Nil(t, schedule.Validate())
+
+	schedule.Interval = -time.Hour
+	assert.NotNil(t, schedule.Validate())
+
+	schedule = newSchedule()
+	schedule.Cron = ""
+	assert.NotNil(t, schedule.Validate())
+
+	schedule.Cron = "61 * * * *"
+	assert.NotNil(t, schedule.Validate())
+
+	schedule = newSchedule()
+	schedule.OverlapPolicy = "wait"
+	assert.NotNil(t, schedule.Validate())
+
+	for _, policy := range ScheduleOverlapPolicies {
+		schedule.OverlapPolicy = policy
+		assert.Nil(t, schedule.Validate())
+	}
+}
+
+func TestNewJobSchedulesFromYAML(t *testing.T) {
+	raw := []byte(`
+Schedules:
+- Name: books-nightly
+  WorkflowName: books
+  WorkflowVersion: v1
+  Cron: "0 3 * * *"
+  OverlapPolicy: cancel
+- Name: quotes-hourly
+  WorkflowPath: quotes.yaml
+  Interval: 1h
+  OverlapPolicy: queue
+  Disabled: true
+`)
+
+	schedules, err := NewJobSchedulesFromYAML(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(schedules))
+
+	assert.Equal(t, "books-nightly", schedules[0].Name)
+	assert.Equal(t, "0 3 * * *", schedules[0].Cron)
+	assert.Equal(t, ScheduleOverlapPolicyCancel, schedules[0].OverlapPolicy)
+	assert.False(t, schedules[0].Disabled)
+
+	assert.Equal(t, "quotes.yaml", schedules[1].WorkflowPath)
+	assert.Equal(t, time.Hour, schedules[1].Interval)
+	assert.Equal(t, ScheduleOverlapPolicyQueue, schedules[1].OverlapPolicy)
+	assert.True(t, schedules[1].Disabled)
+
+	_, err = NewJobSchedulesFromYAML([]byte("Schedules:\n- Name: broken\n  WorkflowPath: x.yaml\n"))
+	assert.NotNil(t, err)
+
+	_, err = NewJobSchedulesFromYAML([]byte("Schedules:\n- Name: a\n  WorkflowPath: x.yaml\n  Interval: 1h\n" +
+		"- Name: a\n  WorkflowPath: y.yaml\n  Interval: 2h\n"))
+	assert.NotNil(t, err)
+}
+
+func TestJobScheduleLoadWorkflow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	yamlBytes, err := yaml.Marshal(newTestMasterWorkflow())
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(dir+"/workflow.yaml", yamlBytes, 0644))
+
+	schedule := &JobSchedule{Name: "fromFile", WorkflowPath: dir + "/workflow.yaml", Interval: time.Hour}
+
+	workflow, err := schedule.LoadWorkflow(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "testWorkflow", workflow.Name)
+
+	schedule = NewJobSchedule("fromStore", "testWorkflow", "v0.0.1")
+
+	_, err = schedule.LoadWorkflow(nil)
+	assert.NotNil(t, err)
+
+	backend, err := NewJSONFileMasterStoreBackend(dir + "/store")
+	assert.Nil(t, err)
+
+	store := NewMasterStore()
+	store.AddBackend(backend)
+
+	_, err = schedule.LoadWorkflow(store)
+	assert.Equal(t, ErrWorkflowNotFound, err)
+
+	assert.Nil(t, store.SaveWorkflow(newTestMasterWorkflow()))
+
+	workflow, err = schedule.LoadWorkflow(store)
+	assert.Nil(t, err)
+	assert.Equal(t, "testWorkflow", workflow.Name)
+}
@@ -0,0 +1,384 @@
+package spsw
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrScheduleNotFound = errors.New("Schedule not found")
+
+const JobSchedulerTickInterval = time.Second
+
+// Without Master store, JobScheduler keeps only this many latest runs in memory.
+const JobSchedulerMaxRunsInMemory = 1000
+
+// JobScheduleStatus tells when schedule is due next and what came out of its last run.
+type JobScheduleStatus struct {
+	Schedule    *JobSchedule
+	NextRunAt   time.Time
+	LastJobUUID string
+	QueuedRun   *ScheduledRun `json:",omitempty"`
+}
+
+type jobScheduleState struct {
+	schedule    *JobSchedule
+	nextRunAt   time.Time
+	lastJobUUID string
+	queuedRun   *ScheduledRun
+}
+
+// JobScheduler starts jobs through Master on their schedules. Missed runs (e.g. while
+// Master was down) are not made up for, except that interval schedule runs right away if
+// its last job was started more than an interval ago. Every time schedule is due,
+// ScheduledRun is recorded in Master's store (or kept in memory if there's no store).
+type JobScheduler struct {
+	UUID   string
+	Master *Master
+
+	mutex       sync.Mutex
+	states      map[string]*jobScheduleState
+	stateNames  []string
+	runs        []*ScheduledRun
+	initialized bool
+}
+
+func NewJobScheduler(master *Master, schedules []*JobSchedule) (*JobScheduler, error) {
+	js := &JobScheduler{
+		UUID:       uuid.New().String(),
+		Master:     master,
+		states:     map[string]*jobScheduleState{},
+		stateNames: []string{},
+		runs:       []*ScheduledRun{},
+	}
+
+	for _, schedule := range schedules {
+		err := schedule.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("Schedule %s: %v", schedule.Name, err)
+		}
+
+		if _, found := js.states[schedule.Name]; found {
+			return nil, fmt.Errorf("Schedule %s is defined more than once", schedule.Name)
+		}
+
+		js.states[schedule.Name] = &jobScheduleState{schedule: schedule}
+		js.stateNames = append(js.stateNames, schedule.Name)
+	}
+
+	return js, nil
+}
+
+func (js *JobScheduler) String() string {
+	return fmt.Sprintf("<JobScheduler %s Schedules: %d>", js.UUID, len(js.stateNames))
+}
+
+// initialize figures out when each schedule is first due, taking jobs already started
+// for it into account. Last jobs are looked up by caller, so that Master is not asked
+// while holding the mutex.
+func (js *JobScheduler) initialize(now time.Time, lastJobs map[string]*Job) {
+	for _, name := range js.stateNames {
+		state := js.states[name]
+
+		from := now
+
+		lastJob := lastJobs[name]
+		if lastJob != nil {
+			state.lastJobUUID = lastJob.UUID
+
+			if state.schedule.Cron == "" {
+				from = lastJob.CreatedAt
+			}
+		}
+
+		nextRunAt, err := state.schedule.NextRunAfter(from)
+		if err != nil {
+			log.Error(fmt.Sprintf("JobScheduler %s failed to compute next run of schedule %s: %v", js.UUID, name,
+				err))
+			continue
+		}
+
+		if nextRunAt.Before(now) {
+			nextRunAt = now
+		}
+
+		state.nextRunAt = nextRunAt
+	}
+
+	js.initialized = true
+}
+
+func (js *JobScheduler) saveRun(run *ScheduledRun) {
+	log.Info(fmt.Sprintf("JobScheduler %s recording run %v", js.UUID, run))
+
+	if js.Master.Store != nil {
+		err := js.Master.Store.SaveScheduledRun(run)
+		if err != nil {
+			log.Error(fmt.Sprintf("JobScheduler %s failed to save run %s in store: %v", js.UUID, run.UUID, err))
+		}
+
+		return
+	}
+
+	for _, knownRun := range js.runs {
+		if knownRun == run {
+			return
+		}
+	}
+
+	js.runs = append(js.runs, run)
+
+	if len(js.runs) > JobSchedulerMaxRunsInMemory {
+		js.runs = js.runs[len(js.runs)-JobSchedulerMaxRunsInMemory:]
+	}
+}
+
+// runToStart is run that is to get a job, along with job of the same schedule that is to
+// be cancelled first, if any.
+type runToStart struct {
+	state         *jobScheduleState
+	run           *ScheduledRun
+	cancelJobUUID string
+}
+
+// startRun cancels previous job if needed and creates new one for the run. Master may block
+// while handing jobs out, so it must be called without holding the mutex.
+func (js *JobScheduler) startRun(toStart *runToStart, now time.Time) {
+	state := toStart.state
+	run := toStart.run
+
+	if toStart.cancelJobUUID != "" {
+		_, err := js.Master.CancelJob(toStart.cancelJobUUID)
+		if err != nil && err != ErrJobAlreadyDone {
+			js.mutex.Lock()
+			defer js.mutex.Unlock()
+
+			run.Outcome = ScheduledRunOutcomeFailed
+			run.Message = fmt.Sprintf("Cancelling previous job %s failed: %v", toStart.cancelJobUUID, err)
+			js.saveRun(run)
+			return
+		}
+	}
+
+	var job *Job
+
+	workflow, err := state.schedule.LoadWorkflow(js.Master.Store)
+	if err == nil {
+		job, err = js.Master.createJob(workflow, state.schedule.Name)
+	}
+
+	js.mutex.Lock()
+	defer js.mutex.Unlock()
+
+	run.CancelledJobUUID = toStart.cancelJobUUID
+
+	if err != nil {
+		run.Outcome = ScheduledRunOutcomeFailed
+		run.Message = err.Error()
+	} else {
+		state.lastJobUUID = job.UUID
+		run.JobUUID = job.UUID
+		run.Outcome = ScheduledRunOutcomeStarted
+		run.StartedAt = now
+	}
+
+	js.saveRun(run)
+}
+
+// runningJob returns job previously started for the schedule if it's not done yet. It asks
+// Master, so it must be called without holding the mutex.
+func (js *JobScheduler) runningJob(state *jobScheduleState) *Job {
+	js.mutex.Lock()
+	lastJobUUID := state.lastJobUUID
+	js.mutex.Unlock()
+
+	if lastJobUUID == "" {
+		return nil
+	}
+
+	job, err := js.Master.GetJob(lastJobUUID)
+	if err != nil || job.IsDone() {
+		return nil
+	}
+
+	return job
+}
+
+// handleDueRun applies overlap policy of the schedule to the run that is due, given job
+// of the schedule that is still running, if any. Returns the run if it's to be started.
+func (js *JobScheduler) handleDueRun(state *jobScheduleState, run *ScheduledRun, runningJob *Job) *runToStart {
+	if runningJob == nil {
+		return &runToStart{state: state, run: run}
+	}
+
+	switch state.schedule.GetOverlapPolicy() {
+	case ScheduleOverlapPolicySkip:
+		run.Outcome = ScheduledRunOutcomeSkipped
+		run.Message = fmt.Sprintf("Previous job %s is %s", runningJob.UUID, runningJob.Status)
+		js.saveRun(run)
+	case ScheduleOverlapPolicyQueue:
+		if state.queuedRun != nil {
+			run.Outcome = ScheduledRunOutcomeSkipped
+			run.Message = fmt.Sprintf("Run %s is already queued", state.queuedRun.UUID)
+		} else {
+			run.Outcome = ScheduledRunOutcomeQueued
+			run.Message = fmt.Sprintf("Waiting for previous job %s", runningJob.UUID)
+			state.queuedRun = run
+		}
+
+		js.saveRun(run)
+	case ScheduleOverlapPolicyCancel:
+		return &runToStart{state: state, run: run, cancelJobUUID: runningJob.UUID}
+	}
+
+	return nil
+}
+
+// takeQueuedRun returns queued run of the schedule if its previous job is done, i.e. there
+// is no running job.
+func (js *JobScheduler) takeQueuedRun(state *jobScheduleState, runningJob *Job) *runToStart {
+	js.mutex.Lock()
+	defer js.mutex.Unlock()
+
+	if state.schedule.Disabled || state.nextRunAt.IsZero() {
+		return nil
+	}
+
+	if state.queuedRun == nil || runningJob != nil {
+		return nil
+	}
+
+	run := state.queuedRun
+	state.queuedRun = nil
+
+	return &runToStart{state: state, run: run}
+}
+
+// takeDueRun records run of the schedule if it's due by given time and returns it if it's
+// to be started, given job of the schedule that is still running, if any.
+func (js *JobScheduler) takeDueRun(state *jobScheduleState, now time.Time, runningJob *Job) *runToStart {
+	js.mutex.Lock()
+	defer js.mutex.Unlock()
+
+	if state.schedule.Disabled || state.nextRunAt.IsZero() || now.Before(state.nextRunAt) {
+		return nil
+	}
+
+	run := NewScheduledRun(state.schedule.Name, state.nextRunAt)
+
+	nextRunAt, err := state.schedule.NextRunAfter(now)
+	if err != nil {
+		log.Error(fmt.Sprintf("JobScheduler %s failed to compute next run of schedule %s: %v", js.UUID,
+			state.schedule.Name, err))
+	}
+
+	state.nextRunAt = nextRunAt
+
+	return js.handleDueRun(state, run, runningJob)
+}
+
+// tick starts queued runs whose previous job is done, and handles schedules that are due
+// by given time. Master is asked about jobs and jobs are started without holding the
+// mutex, so that schedules can be listed while Master is busy.
+func (js *JobScheduler) tick(now time.Time) {
+	js.mutex.Lock()
+	initialized := js.initialized
+	js.mutex.Unlock()
+
+	if !initialized {
+		lastJobs := map[string]*Job{}
+		for _, name := range js.stateNames {
+			lastJobs[name] = js.Master.lastScheduledJob(name)
+		}
+
+		js.mutex.Lock()
+		js.initialize(now, lastJobs)
+		js.mutex.Unlock()
+	}
+
+	for _, name := range js.stateNames {
+		state := js.states[name]
+
+		runningJob := js.runningJob(state)
+
+		if toStart := js.takeQueuedRun(state, runningJob); toStart != nil {
+			js.startRun(toStart, now)
+			runningJob = js.runningJob(state)
+		}
+
+		if toStart := js.takeDueRun(state, now, runningJob); toStart != nil {
+			js.startRun(toStart, now)
+		}
+	}
+}
+
+// ListSchedules returns status of each schedule, in order they were given.
+func (js *JobScheduler) ListSchedules() []*JobScheduleStatus {
+	js.mutex.Lock()
+	defer js.mutex.Unlock()
+
+	statuses := []*JobScheduleStatus{}
+
+	for _, name := range js.stateNames {
+		state := js.states[name]
+
+		status := &JobScheduleStatus{
+			Schedule:    state.schedule,
+			NextRunAt:   state.nextRunAt,
+			LastJobUUID: state.lastJobUUID,
+		}
+
+		if state.queuedRun != nil {
+			queuedRun := *state.queuedRun
+			status.QueuedRun = &queuedRun
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// ListRuns returns history of runs of given schedule, in order they were due.
+func (js *JobScheduler) ListRuns(scheduleName string) ([]*ScheduledRun, error) {
+	js.mutex.Lock()
+	defer js.mutex.Unlock()
+
+	if _, found := js.states[scheduleName]; !found {
+		return nil, ErrScheduleNotFound
+	}
+
+	if js.Master.Store != nil {
+		return js.Master.Store.ListScheduledRuns(scheduleName)
+	}
+
+	runs := []*ScheduledRun{}
+
+	for _, run := range js.runs {
+		if run.ScheduleName == scheduleName {
+			runCopy := *run
+			runs = append(runs, &runCopy)
+		}
+	}
+
+	return runs, nil
+}
+
+func (js *JobScheduler) Run() error {
+	log.Info(fmt.Sprintf("Starting runloop for job scheduler %s", js.UUID))
+
+	ticker := time.NewTicker(JobSchedulerTickInterval)
+	defer ticker.Stop()
+
+	js.tick(time.Now())
+
+	for now := range ticker.C {
+		js.tick(now)
+	}
+
+	return nil
+}
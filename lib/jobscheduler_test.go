@@ -0,0 +1,345 @@
+package spsw
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestJobScheduler makes scheduler with a single hourly schedule of given overlap
+// policy, running workflow stored in Master's store.
+func newTestJobScheduler(t *testing.T, dir string, overlapPolicy string) (*Master, *JobScheduler) {
+	backend, err := NewJSONFileMasterStoreBackend(dir)
+	assert.Nil(t, err)
+
+	store := NewMasterStore()
+	store.AddBackend(backend)
+	assert.Nil(t, store.SaveWorkflow(newTestMasterWorkflow()))
+
+	master, err := NewMasterWithStore(store)
+	assert.Nil(t, err)
+	drainMaster(master)
+
+	schedule := NewJobSchedule("hourly", "testWorkflow", "v0.0.1")
+	schedule.Interval = time.Hour
+	schedule.OverlapPolicy = overlapPolicy
+
+	scheduler, err := NewJobScheduler(master, []*JobSchedule{schedule})
+	assert.Nil(t, err)
+
+	return master, scheduler
+}
+
+func finishTestJob(master *Master, jobUUID string) {
+	master.handleManagerReport(NewManagerReport("manager1", jobUUID, JobStatusFinished, JobStats{}))
+}
+
+func TestNewJobScheduler(t *testing.T) {
+	master := NewMaster()
+
+	schedule := NewJobSchedule("hourly", "WF0", "v1")
+	schedule.Interval = time.Hour
+
+	scheduler, err := NewJobScheduler(master, []*JobSchedule{schedule})
+	assert.Nil(t, err)
+	assert.NotNil(t, scheduler)
+	assert.Equal(t, 36, len(scheduler.UUID))
+	assert.Equal(t, master, scheduler.Master)
+	assert.Equal(t, 1, len(scheduler.ListSchedules()))
+
+	_, err = NewJobScheduler(master, []*JobSchedule{schedule, schedule})
+	assert.NotNil(t, err)
+
+	_, err = NewJobScheduler(master, []*JobSchedule{NewJobSchedule("never", "WF0", "v1")})
+	assert.NotNil(t, err)
+}
+
+func TestJobSchedulerOverlapSkip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	master, scheduler := newTestJobScheduler(t, dir, ScheduleOverlapPolicySkip)
+
+	start := time.Now()
+
+	scheduler.tick(start)
+	assert.Equal(t, 0, len(master.ListJobs("")))
+	assert.Equal(t, start.Add(time.Hour), scheduler.ListSchedules()[0].NextRunAt)
+
+	scheduler.tick(start.Add(time.Hour))
+
+	jobs := master.ListJobs("")
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, "hourly", jobs[0].ScheduleName)
+
+	scheduler.tick(start.Add(2 * time.Hour))
+	assert.Equal(t, 1, len(master.ListJobs("")))
+
+	finishTestJob(master, jobs[0].UUID)
+
+	scheduler.tick(start.Add(3 * time.Hour))
+	assert.Equal(t, 2, len(master.ListJobs("")))
+
+	runs, err := scheduler.ListRuns("hourly")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(runs))
+	assert.Equal(t, ScheduledRunOutcomeStarted, runs[0].Outcome)
+	assert.Equal(t, jobs[0].UUID, runs[0].JobUUID)
+	assert.Equal(t, ScheduledRunOutcomeSkipped, runs[1].Outcome)
+	assert.Equal(t, "", runs[1].JobUUID)
+	assert.Equal(t, ScheduledRunOutcomeStarted, runs[2].Outcome)
+
+	_, err = scheduler.ListRuns("no-such-schedule")
+	assert.Equal(t, ErrScheduleNotFound, err)
+}
+
+func TestJobSchedulerOverlapQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	master, scheduler := newTestJobScheduler(t, dir, ScheduleOverlapPolicyQueue)
+
+	start := time.Now()
+
+	scheduler.tick(start)
+	scheduler.tick(start.Add(time.Hour))
+	scheduler.tick(start.Add(2 * time.Hour))
+	scheduler.tick(start.Add(3 * time.Hour))
+
+	jobs := master.ListJobs("")
+	assert.Equal(t, 1, len(jobs))
+	assert.NotNil(t, scheduler.ListSchedules()[0].QueuedRun)
+
+	finishTestJob(master, jobs[0].UUID)
+
+	scheduler.tick(start.Add(3*time.Hour + time.Second))
+
+	jobs = master.ListJobs("")
+	assert.Equal(t, 2, len(jobs))
+	assert.Nil(t, scheduler.ListSchedules()[0].QueuedRun)
+	assert.Equal(t, jobs[1].UUID, scheduler.ListSchedules()[0].LastJobUUID)
+
+	runs, err := scheduler.ListRuns("hourly")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(runs))
+	assert.Equal(t, ScheduledRunOutcomeStarted, runs[0].Outcome)
+	assert.Equal(t, ScheduledRunOutcomeStarted, runs[1].Outcome)
+	assert.Equal(t, jobs[1].UUID, runs[1].JobUUID)
+	assert.True(t, start.Add(3*time.Hour+time.Second).Equal(runs[1].StartedAt))
+	assert.Equal(t, ScheduledRunOutcomeSkipped, runs[2].Outcome)
+}
+
+func TestJobSchedulerOverlapCancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	master, scheduler := newTestJobScheduler(t, dir, ScheduleOverlapPolicyCancel)
+
+	start := time.Now()
+
+	scheduler.tick(start)
+	scheduler.tick(start.Add(time.Hour))
+	scheduler.tick(start.Add(2 * time.Hour))
+
+	jobs := master.ListJobs("")
+	assert.Equal(t, 2, len(jobs))
+	assert.Equal(t, JobStatusCancelled, jobs[0].Status)
+	assert.Equal(t, JobStatusQueued, jobs[1].Status)
+
+	runs, err := scheduler.ListRuns("hourly")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(runs))
+	assert.Equal(t, ScheduledRunOutcomeStarted, runs[1].Outcome)
+	assert.Equal(t, jobs[1].UUID, runs[1].JobUUID)
+	assert.Equal(t, jobs[0].UUID, runs[1].CancelledJobUUID)
+}
+
+func TestJobSchedulerFailedRun(t *testing.T) {
+	master := NewMaster()
+	drainMaster(master)
+
+	schedule := &JobSchedule{Name: "broken", WorkflowPath: "/no/such/workflow.yaml", Interval: time.Hour}
+
+	scheduler, err := NewJobScheduler(master, []*JobSchedule{schedule})
+	assert.Nil(t, err)
+
+	start := time.Now()
+
+	scheduler.tick(start)
+	scheduler.tick(start.Add(time.Hour))
+
+	assert.Equal(t, 0, len(master.ListJobs("")))
+
+	// Without store, history is kept in memory.
+	runs, err := scheduler.ListRuns("broken")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(runs))
+	assert.Equal(t, ScheduledRunOutcomeFailed, runs[0].Outcome)
+	assert.NotEqual(t, "", runs[0].Message)
+}
+
+func TestJobSchedulerDisabledSchedule(t *testing.T) {
+	master := NewMaster()
+
+	schedule := &JobSchedule{Name: "disabled", WorkflowPath: "/no/such/workflow.yaml", Interval: time.Hour,
+		Disabled: true}
+
+	scheduler, err := NewJobScheduler(master, []*JobSchedule{schedule})
+	assert.Nil(t, err)
+
+	start := time.Now()
+
+	scheduler.tick(start)
+	scheduler.tick(start.Add(time.Hour))
+
+	runs, err := scheduler.ListRuns("disabled")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(runs))
+}
+
+func TestJobSchedulerPicksUpLastJob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	master, scheduler := newTestJobScheduler(t, dir, ScheduleOverlapPolicySkip)
+
+	start := time.Now()
+
+	scheduler.tick(start)
+	scheduler.tick(start.Add(time.Hour))
+
+	jobs := master.ListJobs("")
+	assert.Equal(t, 1, len(jobs))
+
+	// After restart, interval is counted from the time last job was created, and
+	// overlap policy still applies to it.
+	master, scheduler = newTestJobScheduler(t, dir, ScheduleOverlapPolicySkip)
+
+	scheduler.tick(time.Now())
+
+	status := scheduler.ListSchedules()[0]
+	assert.Equal(t, jobs[0].UUID, status.LastJobUUID)
+	assert.Equal(t, jobs[0].CreatedAt.Add(time.Hour).UnixNano(), status.NextRunAt.UnixNano())
+
+	scheduler.tick(jobs[0].CreatedAt.Add(time.Hour))
+
+	assert.Equal(t, 1, len(master.ListJobs("")))
+
+	runs, err := scheduler.ListRuns("hourly")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(runs))
+	assert.Equal(t, ScheduledRunOutcomeSkipped, runs[1].Outcome)
+}
+
+func TestJobSchedulerListsSchedulesWhileStartingJob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewJSONFileMasterStoreBackend(dir)
+	assert.Nil(t, err)
+
+	store := NewMasterStore()
+	store.AddBackend(backend)
+	assert.Nil(t, store.SaveWorkflow(newTestMasterWorkflow()))
+
+	// Nobody takes jobs from Master until test does so.
+	master, err := NewMasterWithStore(store)
+	assert.Nil(t, err)
+
+	schedule := NewJobSchedule("hourly", "testWorkflow", "v0.0.1")
+	schedule.Interval = time.Hour
+
+	scheduler, err := NewJobScheduler(master, []*JobSchedule{schedule})
+	assert.Nil(t, err)
+
+	start := time.Now()
+	scheduler.tick(start)
+
+	ticked := make(chan struct{})
+
+	go func() {
+		scheduler.tick(start.Add(time.Hour))
+		close(ticked)
+	}()
+
+	listed := make(chan []*JobScheduleStatus)
+
+	go func() {
+		// Giving tick time to get stuck handing job over.
+		time.Sleep(50 * time.Millisecond)
+		listed <- scheduler.ListSchedules()
+	}()
+
+	select {
+	case statuses := <-listed:
+		assert.Equal(t, 1, len(statuses))
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listing schedules was blocked by job being started")
+	}
+
+	job := <-master.JobsOut
+	<-ticked
+
+	status := scheduler.ListSchedules()[0]
+	assert.Equal(t, job.UUID, status.LastJobUUID)
+}
+
+func TestJobSchedulerListsSchedulesWhileMasterIsBusy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	master, scheduler := newTestJobScheduler(t, dir, ScheduleOverlapPolicySkip)
+
+	start := time.Now()
+
+	scheduler.tick(start)
+	scheduler.tick(start.Add(time.Hour))
+	assert.Equal(t, 1, len(master.ListJobs("")))
+
+	// Master is kept busy, so that tick gets stuck asking it about previous job.
+	master.mutex.Lock()
+
+	ticked := make(chan struct{})
+
+	go func() {
+		scheduler.tick(start.Add(2 * time.Hour))
+		close(ticked)
+	}()
+
+	listed := make(chan []*JobScheduleStatus)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		listed <- scheduler.ListSchedules()
+	}()
+
+	select {
+	case statuses := <-listed:
+		assert.Equal(t, 1, len(statuses))
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listing schedules was blocked by Master being busy")
+	}
+
+	master.mutex.Unlock()
+	<-ticked
+
+	runs, err := scheduler.ListRuns("hourly")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(runs))
+	assert.Equal(t, ScheduledRunOutcomeSkipped, runs[1].Outcome)
+}
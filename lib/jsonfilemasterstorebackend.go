@@ -0,0 +1,230 @@
+package spsw
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// JSONFileMasterStoreBackend keeps each workflow and job in its own JSON file under
+// given directory:
+//
+// * <dirPath>/workflows/<name>@<version>.json
+// * <dirPath>/jobs/<jobUUID>.json
+// * <dirPath>/scheduledruns/<runUUID>.json
+type JSONFileMasterStoreBackend struct {
+	AbstractMasterStoreBackend
+	UUID string
+
+	DirPath string
+
+	mutex sync.Mutex
+}
+
+func NewJSONFileMasterStoreBackend(dirPath string) (*JSONFileMasterStoreBackend, error) {
+	for _, subdir := range []string{"workflows", "jobs", "scheduledruns"} {
+		err := os.MkdirAll(filepath.Join(dirPath, subdir), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &JSONFileMasterStoreBackend{
+		UUID:    uuid.New().String(),
+		DirPath: dirPath,
+	}, nil
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) String() string {
+	return fmt.Sprintf("<JSONFileMasterStoreBackend %s DirPath: %s>", jfmsb.UUID, jfmsb.DirPath)
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) workflowFilePath(name string, version string) string {
+	fileName := fmt.Sprintf("%s@%s.json", url.PathEscape(name), url.PathEscape(version))
+	return filepath.Join(jfmsb.DirPath, "workflows", fileName)
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) jobFilePath(jobUUID string) string {
+	return filepath.Join(jfmsb.DirPath, "jobs", url.PathEscape(jobUUID)+".json")
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) scheduledRunFilePath(runUUID string) string {
+	return filepath.Join(jfmsb.DirPath, "scheduledruns", url.PathEscape(runUUID)+".json")
+}
+
+// writeFile replaces file contents atomically, so that readers never see a half-written file.
+func (jfmsb *JSONFileMasterStoreBackend) writeFile(filePath string, raw []byte) error {
+	tmpFilePath := filePath + ".tmp"
+
+	err := ioutil.WriteFile(tmpFilePath, raw, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilePath, filePath)
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) SaveWorkflow(workflow *Workflow) error {
+	raw, err := json.Marshal(workflow)
+	if err != nil {
+		return err
+	}
+
+	jfmsb.mutex.Lock()
+	defer jfmsb.mutex.Unlock()
+
+	return jfmsb.writeFile(jfmsb.workflowFilePath(workflow.Name, workflow.Version), raw)
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) LoadWorkflow(name string, version string) (*Workflow, error) {
+	jfmsb.mutex.Lock()
+	raw, err := ioutil.ReadFile(jfmsb.workflowFilePath(name, version))
+	jfmsb.mutex.Unlock()
+
+	if os.IsNotExist(err) {
+		return nil, ErrWorkflowNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	workflow := &Workflow{}
+
+	err = json.Unmarshal(raw, workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	return workflow, nil
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) CreateJob(job *Job) error {
+	jfmsb.mutex.Lock()
+	defer jfmsb.mutex.Unlock()
+
+	filePath := jfmsb.jobFilePath(job.UUID)
+
+	if _, err := os.Stat(filePath); err == nil {
+		return fmt.Errorf("Job %s already exists", job.UUID)
+	}
+
+	return jfmsb.writeFile(filePath, encodeJobRecord(job))
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) UpdateJob(job *Job) error {
+	jfmsb.mutex.Lock()
+	defer jfmsb.mutex.Unlock()
+
+	filePath := jfmsb.jobFilePath(job.UUID)
+
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return ErrJobNotFound
+	}
+
+	return jfmsb.writeFile(filePath, encodeJobRecord(job))
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) readJobFile(filePath string) (*Job, error) {
+	raw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	job := NewJobFromJSON(raw)
+	if job == nil {
+		return nil, fmt.Errorf("Bad job file %s", filePath)
+	}
+
+	return job, nil
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) GetJob(jobUUID string) (*Job, error) {
+	jfmsb.mutex.Lock()
+	defer jfmsb.mutex.Unlock()
+
+	job, err := jfmsb.readJobFile(jfmsb.jobFilePath(jobUUID))
+	if os.IsNotExist(err) {
+		return nil, ErrJobNotFound
+	}
+
+	return job, err
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) ListJobs(filter *JobFilter) ([]*Job, error) {
+	jfmsb.mutex.Lock()
+	defer jfmsb.mutex.Unlock()
+
+	filePaths, err := filepath.Glob(filepath.Join(jfmsb.DirPath, "jobs", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []*Job{}
+
+	for _, filePath := range filePaths {
+		job, err := jfmsb.readJobFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		if filter.Matches(job) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	return jobs, nil
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) SaveScheduledRun(run *ScheduledRun) error {
+	jfmsb.mutex.Lock()
+	defer jfmsb.mutex.Unlock()
+
+	return jfmsb.writeFile(jfmsb.scheduledRunFilePath(run.UUID), run.EncodeToJSON())
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) ListScheduledRuns(scheduleName string) ([]*ScheduledRun, error) {
+	jfmsb.mutex.Lock()
+	defer jfmsb.mutex.Unlock()
+
+	filePaths, err := filepath.Glob(filepath.Join(jfmsb.DirPath, "scheduledruns", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*ScheduledRun{}
+
+	for _, filePath := range filePaths {
+		raw, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		run := NewScheduledRunFromJSON(raw)
+		if run == nil {
+			return nil, fmt.Errorf("Bad scheduled run file %s", filePath)
+		}
+
+		if scheduleName == "" || run.ScheduleName == scheduleName {
+			runs = append(runs, run)
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].DueAt.Before(runs[j].DueAt)
+	})
+
+	return runs, nil
+}
+
+func (jfmsb *JSONFileMasterStoreBackend) Close() {
+}
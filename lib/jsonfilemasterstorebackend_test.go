@@ -0,0 +1,44 @@
+package spsw
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJSONFileMasterStoreBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewJSONFileMasterStoreBackend(dir + "/master")
+	assert.Nil(t, err)
+	assert.NotNil(t, backend)
+
+	_, err = os.Stat(dir + "/master/jobs")
+	assert.Nil(t, err)
+
+	_, err = os.Stat(dir + "/master/workflows")
+	assert.Nil(t, err)
+}
+
+func TestJSONFileMasterStoreBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewJSONFileMasterStoreBackend(dir)
+	assert.Nil(t, err)
+
+	testMasterStoreBackend(t, backend)
+}
+
+func TestJSONFileMasterStoreBackendWorkflowFileName(t *testing.T) {
+	backend := &JSONFileMasterStoreBackend{DirPath: "/tmp/master"}
+
+	assert.Equal(t, "/tmp/master/workflows/books%2Fscraper@v1.json", backend.workflowFilePath("books/scraper", "v1"))
+}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -40,25 +41,47 @@ func NewJSONPathAction(jsonPath string, decode bool, expectMany bool) *JSONPathA
 	}
 }
 
-func NewJSONPathActionFromTemplate(actionTempl *ActionTemplate) Action {
-	jsonPath := actionTempl.ConstructorParams["jsonPath"].StringValue
-	decode := actionTempl.ConstructorParams["decode"].BoolValue
-	expectMany := actionTempl.ConstructorParams["expectMany"].BoolValue
+func NewJSONPathActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("JSONPathAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonPath := params["jsonPath"].StringValue
+	decode := params["decode"].BoolValue
+	expectMany := params["expectMany"].BoolValue
 
 	action := NewJSONPathAction(jsonPath, decode, expectMany)
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
-func (jpa *JSONPathAction) outputResult(resultStr string) {
+func (jpa *JSONPathAction) outputResult(result interface{}) {
 	for _, outDP := range jpa.Outputs[JSONPathActionOutputStr] {
-		outDP.Add(resultStr)
+		outDP.Add(result)
 	}
 }
 
-func (jpa *JSONPathAction) Run() error {
+// decodedStrings returns results as []string if all of them are strings, which is what
+// JSONPathAction has always sent out for e.g. lists of links.
+func decodedStrings(results []interface{}) ([]string, bool) {
+	resultStrings := []string{}
+
+	for _, x := range results {
+		s, okStr := x.(string)
+		if !okStr {
+			return nil, false
+		}
+
+		resultStrings = append(resultStrings, s)
+	}
+
+	return resultStrings, true
+}
+
+func (jpa *JSONPathAction) Run(ctx context.Context) error {
 	if jpa.Inputs[JSONPathActionInputJSONStr] == nil && jpa.Inputs[JSONPathActionInputJSONBytes] == nil {
 		return errors.New("Input not connected")
 	}
@@ -70,9 +93,18 @@ func (jpa *JSONPathAction) Run() error {
 	var jsonStr string
 
 	if jpa.Inputs[JSONPathActionInputJSONStr] != nil {
-		jsonStr = jpa.Inputs[JSONPathActionInputJSONStr].Remove().(string)
+		var okStr bool
+
+		jsonStr, okStr = jpa.Inputs[JSONPathActionInputJSONStr].Remove().(string)
+		if !okStr {
+			return errors.New("Failed to get JSON string")
+		}
 	} else {
-		jsonBytes := jpa.Inputs[JSONPathActionInputJSONBytes].Remove().([]byte)
+		jsonBytes, okBytes := jpa.Inputs[JSONPathActionInputJSONBytes].Remove().([]byte)
+		if !okBytes {
+			return errors.New("Failed to get JSON bytes")
+		}
+
 		jsonStr = string(jsonBytes)
 	}
 
@@ -102,35 +134,24 @@ func (jpa *JSONPathAction) Run() error {
 	}
 
 	if !jpa.Decode {
-		jsonStr2 := oj.JSON(result)
-
-		for _, outDP := range jpa.Outputs[JSONPathActionOutputStr] {
-			outDP.Add(jsonStr2)
-		}
-
-		jpa.outputResult(jsonStr2)
-
+		jpa.outputResult(oj.JSON(result))
 		return nil
 	}
 
-	if resultStr, okStr := result.(string); okStr {
-		jpa.outputResult(resultStr)
-	} else {
-		if jpa.ExpectMany {
-			resultStrings := []string{}
-
-			for _, x := range results {
-				resultStrings = append(resultStrings, fmt.Sprintf("%v", x))
-			}
-
-			for _, outDP := range jpa.Outputs[JSONPathActionOutputStr] {
-				outDP.Add(resultStrings)
-			}
-		} else {
-			resultStr := fmt.Sprintf("%v", result)
-			jpa.outputResult(resultStr)
+	if jpa.ExpectMany {
+		if resultStrings, okStrings := decodedStrings(results); okStrings {
+			jpa.outputResult(resultStrings)
+			return nil
 		}
 	}
 
+	// Numbers, booleans, nulls, objects and arrays are sent out as Values of matching type.
+	value := NewValue(result)
+	if value == nil {
+		return fmt.Errorf("Unsupported JSON value: %v", result)
+	}
+
+	jpa.outputResult(value)
+
 	return nil
 }
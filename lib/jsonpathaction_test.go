@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -53,7 +54,10 @@ func TestNewJSONPathActionFromTemplate(t *testing.T) {
 		ConstructorParams: constructorParams,
 	}
 
-	action, ok := NewJSONPathActionFromTemplate(actionTempl).(*JSONPathAction)
+	gotAction, err := NewJSONPathActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action, ok := gotAction.(*JSONPathAction)
 	assert.True(t, ok)
 
 	assert.Equal(t, jsonPath, action.JSONPath)
@@ -75,7 +79,7 @@ func TestJSONPathActionRunBasic(t *testing.T) {
 	action.AddInput(JSONPathActionInputJSONStr, dataPipeIn)
 	action.AddOutput(JSONPathActionOutputStr, dataPipeOut)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 	assert.Nil(t, err)
 
 	resultStr, ok := dataPipeOut.Remove().(string)
@@ -95,7 +99,7 @@ func TestJSONPathActionRunExpectMany(t *testing.T) {
 	action.AddInput(JSONPathActionInputJSONBytes, dataPipeIn)
 	action.AddOutput(JSONPathActionOutputStr, dataPipeOut)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 	assert.Nil(t, err)
 
 	expectResults := []string{"0123-4567-8888", "0123-4567-8910"}
@@ -108,7 +112,7 @@ func TestJSONPathActionRunExpectMany(t *testing.T) {
 	action.Decode = false
 	dataPipeIn.Add([]byte(testJSONStr))
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	result, ok2 := dataPipeOut.Remove().(string)
@@ -118,3 +122,34 @@ func TestJSONPathActionRunExpectMany(t *testing.T) {
 
 	assert.Equal(t, expectJSONStr, result)
 }
+
+func TestJSONPathActionRunDecodeTypes(t *testing.T) {
+	table := []struct {
+		JSONPath   string
+		ExpectMany bool
+		Expected   interface{}
+	}{
+		{"$.age", false, 17},
+		{"$.address", false, map[string]interface{}{"streetAddress": "1428 Elm Street", "city": "Springwood, OH"}},
+		{"$.phoneNumbers[*].type", true, []string{"iPhone", "home"}},
+		{"$.phoneNumbers[0]", true, []interface{}{map[string]interface{}{"type": "iPhone", "number": "0123-4567-8888"}}},
+	}
+
+	for _, entry := range table {
+		dataPipeIn := NewDataPipe()
+		dataPipeOut := NewDataPipe()
+
+		dataPipeIn.Add(testJSONStr)
+
+		action := NewJSONPathAction(entry.JSONPath, true, entry.ExpectMany)
+
+		action.AddInput(JSONPathActionInputJSONStr, dataPipeIn)
+		action.AddOutput(JSONPathActionOutputStr, dataPipeOut)
+
+		err := action.Run(context.Background())
+		assert.Nil(t, err)
+
+		assert.Equal(t, 1, dataPipeOut.Len())
+		assert.Equal(t, entry.Expected, dataPipeOut.Remove())
+	}
+}
@@ -0,0 +1,106 @@
+package spsw
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var labelPartRegexp = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+
+// Labels describe what a worker is capable of (e.g. region=eu, proxy=residential) and
+// tasks can require some of them through TaskTemplate.RequiredLabels. Both keys and values
+// are kept simple, so that label sets can be written as comma-separated key=value pairs
+// on command line and used in queue names.
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelPartRegexp.MatchString(key) {
+			return fmt.Errorf("Bad label key %q: only letters, digits and _./- are allowed", key)
+		}
+
+		if !labelPartRegexp.MatchString(value) {
+			return fmt.Errorf("Bad value %q of label %s: only letters, digits and _./- are allowed", value, key)
+		}
+	}
+
+	return nil
+}
+
+// ParseLabels parses comma-separated key=value pairs, e.g. region=eu,proxy=residential.
+// Empty string means no labels.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	if strings.TrimSpace(s) == "" {
+		return labels, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Bad label %q: expected key=value", pair)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if _, found := labels[key]; found {
+			return nil, fmt.Errorf("Label %s is given more than once", key)
+		}
+
+		labels[key] = value
+	}
+
+	err := ValidateLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// FormatLabels is the inverse of ParseLabels. Pairs are sorted by key, so that the same
+// set of labels always comes out the same. This is what SpiderBus backends use as label
+// selector of a scheduled task.
+func FormatLabels(labels map[string]string) string {
+	keys := []string{}
+
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := []string{}
+
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// LabelsMatch tells if worker having given labels may run task requiring given labels,
+// i.e. if worker has all the required labels with the same values. Task requiring no
+// labels can run anywhere.
+func LabelsMatch(workerLabels map[string]string, requiredLabels map[string]string) bool {
+	for key, value := range requiredLabels {
+		workerValue, found := workerLabels[key]
+		if !found || workerValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// labelSelectorMatches is LabelsMatch for required labels formatted by FormatLabels.
+func labelSelectorMatches(workerLabels map[string]string, selector string) bool {
+	requiredLabels, err := ParseLabels(selector)
+	if err != nil {
+		return false
+	}
+
+	return LabelsMatch(workerLabels, requiredLabels)
+}
@@ -0,0 +1,55 @@
+package spsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("region=eu,proxy=residential")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"region": "eu", "proxy": "residential"}, labels)
+
+	labels, err = ParseLabels(" region = eu , memory=large ")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"region": "eu", "memory": "large"}, labels)
+
+	labels, err = ParseLabels("")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{}, labels)
+
+	for _, bad := range []string{"region", "region=eu,region=us", "=eu", "region=", "region=eu,", "region=e u",
+		"region=eu=1"} {
+		_, err = ParseLabels(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "", FormatLabels(nil))
+	assert.Equal(t, "proxy=residential,region=eu",
+		FormatLabels(map[string]string{"region": "eu", "proxy": "residential"}))
+
+	labels, err := ParseLabels(FormatLabels(map[string]string{"region": "eu", "proxy": "residential"}))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"region": "eu", "proxy": "residential"}, labels)
+}
+
+func TestLabelsMatch(t *testing.T) {
+	workerLabels := map[string]string{"region": "eu", "proxy": "residential"}
+
+	assert.True(t, LabelsMatch(workerLabels, nil))
+	assert.True(t, LabelsMatch(workerLabels, map[string]string{"region": "eu"}))
+	assert.True(t, LabelsMatch(workerLabels, map[string]string{"region": "eu", "proxy": "residential"}))
+	assert.False(t, LabelsMatch(workerLabels, map[string]string{"region": "us"}))
+	assert.False(t, LabelsMatch(workerLabels, map[string]string{"region": "eu", "memory": "large"}))
+
+	assert.True(t, LabelsMatch(nil, nil))
+	assert.False(t, LabelsMatch(nil, map[string]string{"region": "eu"}))
+
+	assert.True(t, labelSelectorMatches(workerLabels, ""))
+	assert.True(t, labelSelectorMatches(workerLabels, "proxy=residential,region=eu"))
+	assert.False(t, labelSelectorMatches(workerLabels, "region=us"))
+	assert.False(t, labelSelectorMatches(nil, "region=eu"))
+}
@@ -0,0 +1,59 @@
+package spsw
+
+import (
+	"context"
+	"fmt"
+)
+
+// LegacyAction is what Action looked like before Run got context. Custom actions written
+// against it keep working when wrapped with NewLegacyActionAdapter or registered with
+// RegisterLegacyAction.
+type LegacyAction interface {
+	Run() error
+	AddInput(name string, dataPipe *DataPipe) error
+	AddOutput(name string, dataPipe *DataPipe) error
+	GetUniqueID() string
+	GetName() string
+	GetPrecedingActions() []Action
+	IsFailureAllowed() bool
+}
+
+type LegacyInitFunc func(*ActionTemplate) LegacyAction
+
+// LegacyActionAdapter makes LegacyAction conform to Action. As legacy action cannot be
+// cancelled, context is only checked before running it.
+type LegacyActionAdapter struct {
+	LegacyAction
+}
+
+func NewLegacyActionAdapter(action LegacyAction) *LegacyActionAdapter {
+	return &LegacyActionAdapter{
+		LegacyAction: action,
+	}
+}
+
+func (laa *LegacyActionAdapter) String() string {
+	return fmt.Sprintf("<LegacyActionAdapter %v>", laa.LegacyAction)
+}
+
+func (laa *LegacyActionAdapter) Run(ctx context.Context) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	return laa.LegacyAction.Run()
+}
+
+// RegisterLegacyAction is RegisterAction for actions whose Run takes no context.
+func RegisterLegacyAction(structName string, initFunc LegacyInitFunc, allowedInputNames []string,
+	allowedOutputNames []string) {
+	RegisterAction(structName, func(actionTempl *ActionTemplate) (Action, error) {
+		action := initFunc(actionTempl)
+		if action == nil {
+			return nil, fmt.Errorf("Failed to create %s", structName)
+		}
+
+		return NewLegacyActionAdapter(action), nil
+	}, allowedInputNames, allowedOutputNames)
+}
@@ -0,0 +1,84 @@
+package spsw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testLegacyActionOutput = "testLegacyActionOutput"
+
+type testLegacyAction struct {
+	AbstractAction
+	NRuns int
+}
+
+func newTestLegacyAction() *testLegacyAction {
+	return &testLegacyAction{
+		AbstractAction: AbstractAction{
+			AllowedOutputNames: []string{testLegacyActionOutput},
+			Inputs:             map[string]*DataPipe{},
+			Outputs:            map[string][]*DataPipe{},
+			UUID:               "testLegacyAction",
+		},
+	}
+}
+
+func (tla *testLegacyAction) Run() error {
+	tla.NRuns++
+
+	for _, outDP := range tla.Outputs[testLegacyActionOutput] {
+		outDP.Add("legacy")
+	}
+
+	return nil
+}
+
+func TestLegacyActionAdapter(t *testing.T) {
+	legacyAction := newTestLegacyAction()
+
+	task := NewTask("Task1", "WF0", "job1")
+
+	action := NewLegacyActionAdapter(legacyAction)
+
+	task.AddAction(action)
+
+	outDP := NewDataPipe()
+	task.AddOutput("out", action, testLegacyActionOutput, outDP)
+
+	err := task.Run(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, legacyAction.NRuns)
+	assert.Equal(t, "legacy", outDP.Remove())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = action.Run(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, legacyAction.NRuns)
+}
+
+func TestRegisterLegacyAction(t *testing.T) {
+	RegisterLegacyAction("testLegacyAction", func(actionTempl *ActionTemplate) LegacyAction {
+		action := newTestLegacyAction()
+		action.Name = actionTempl.Name
+		return action
+	}, []string{}, []string{testLegacyActionOutput})
+
+	defer func() {
+		delete(ActionConstructorTable, "testLegacyAction")
+		delete(AllowedInputNameTable, "testLegacyAction")
+		delete(AllowedOutputNameTable, "testLegacyAction")
+	}()
+
+	action, err := NewActionFromTemplate(NewActionTemplate("legacy1", "testLegacyAction", map[string]interface{}{}))
+	assert.Nil(t, err)
+
+	adapter, ok := action.(*LegacyActionAdapter)
+	assert.True(t, ok)
+	assert.Equal(t, "legacy1", adapter.GetName())
+	assert.Nil(t, action.Run(context.Background()))
+	assert.Equal(t, 1, adapter.LegacyAction.(*testLegacyAction).NRuns)
+}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -32,21 +33,26 @@ func NewLengthThresholdAction(threshold int) *LengthThresholdAction {
 	}
 }
 
-func NewLengthThresholdActionFromTemplate(actionTempl *ActionTemplate) Action {
-	threshold := actionTempl.ConstructorParams["threshold"].IntValue
+func NewLengthThresholdActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("LengthThresholdAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := params["threshold"].IntValue
 
 	action := NewLengthThresholdAction(threshold)
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (lta *LengthThresholdAction) String() string {
 	return fmt.Sprintf("<LengthThresholdAction %s Name: %s, Threshold: %d>", lta.UUID, lta.Name, lta.Threshold)
 }
 
-func (lta *LengthThresholdAction) Run() error {
+func (lta *LengthThresholdAction) Run(ctx context.Context) error {
 	if lta.Inputs[LengthThresholdActionInputSlice] == nil {
 		return errors.New("Input not connected")
 	}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,7 +21,10 @@ func TestNewLengthThresholdActionFromTemplate(t *testing.T) {
 		},
 	}
 
-	action := NewLengthThresholdActionFromTemplate(actionTempl).(*LengthThresholdAction)
+	gotAction, err := NewLengthThresholdActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action := gotAction.(*LengthThresholdAction)
 
 	assert.NotNil(t, action)
 	assert.Equal(t, threshold, action.Threshold)
@@ -40,7 +44,7 @@ func TestLengthThresholdActionRun(t *testing.T) {
 
 	dpIn.Add([]string{"a"})
 
-	err := action.Run()
+	err := action.Run(context.Background())
 	assert.Nil(t, err)
 
 	result, ok := dpOut.Remove().(bool)
@@ -49,7 +53,7 @@ func TestLengthThresholdActionRun(t *testing.T) {
 
 	dpIn.Add([]string{"a", "b"})
 
-	action.Run()
+	action.Run(context.Background())
 
 	result, ok = dpOut.Remove().(bool)
 	assert.True(t, ok)
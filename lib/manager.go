@@ -2,38 +2,79 @@ package spsw
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
+const ManagerDelayedTasksCheckInterval = 100 * time.Millisecond
+const ManagerReportInterval = 5 * time.Second
+const ManagerReportsBufferSize = 16
+const ManagerJobsFinishedBufferSize = 16
+const ManagerCheckpointInterval = 10 * time.Second
+const ManagerCheckpointsBufferSize = 16
+
+// Manager schedules tasks for any number of scraping jobs at once. Each job is tracked
+// as ManagerJob and task results are routed to it by JobUUID.
 type Manager struct {
-	UUID              string
-	TaskPromisesIn    chan *TaskPromise
-	TaskResultsIn     chan *TaskResult
-	ScheduledTasksOut chan *ScheduledTask
-	ItemsOut          chan *Item
-	CurrentWorkflow   *Workflow // TODO: support multiple scraping jobs running concurrently
-	JobUUID           string
-	NPendingTasks     int
-	NFinishedTasks    int
-	NFailedTasks      int
-	NScheduledTasks   int
-	Deduplicator      *Deduplicator
+	UUID               string
+	TaskPromisesIn     chan *TaskPromise
+	TaskResultsIn      chan *TaskResult
+	ScheduledTasksOut  chan *ScheduledTask
+	ItemsOut           chan *Item
+	DeadLettersOut     chan *DeadLetter
+	JobsIn             chan *Job
+	JobControlsIn      chan *JobControl
+	ManagerReportsOut  chan *ManagerReport
+	JobsFinishedOut    chan *JobFinished
+	CheckpointsOut     chan *ManagerCheckpoint
+	WorkerHeartbeatsIn chan *WorkerHeartbeat
+	Done               chan interface{}
+	Deduplicator       *Deduplicator
+
+	// Workers keeps track of worker heartbeats, so that tasks of workers that went
+	// away can be sent out again.
+	Workers *WorkerRegistry
+
+	// How long to wait for results of tasks that were in flight according to checkpoint
+	// before sending them out again. Resumed job is also kept open for that long, in case
+	// results of tasks scheduled after the checkpoint come in.
+	ResumedTaskTimeout time.Duration
+
+	jobsMutex         sync.Mutex
+	jobs              map[string]*ManagerJob
+	cancelledJobUUIDs jobUUIDSet
+	pausedJobUUIDs    jobUUIDSet
+	finishedJobUUIDs  jobUUIDSet
+	stopOnce          sync.Once
+	stopped           chan struct{}
 }
 
 func NewManager(deduplicator *Deduplicator) *Manager {
 	return &Manager{
-		UUID:              uuid.New().String(),
-		TaskPromisesIn:    make(chan *TaskPromise),
-		TaskResultsIn:     make(chan *TaskResult),
-		ScheduledTasksOut: make(chan *ScheduledTask),
-		ItemsOut:          make(chan *Item),
-		NPendingTasks:     0,
-		NFinishedTasks:    0,
-		NFailedTasks:      0,
-		NScheduledTasks:   0,
-		Deduplicator:      deduplicator,
+		UUID:               uuid.New().String(),
+		TaskPromisesIn:     make(chan *TaskPromise),
+		TaskResultsIn:      make(chan *TaskResult),
+		ScheduledTasksOut:  make(chan *ScheduledTask),
+		ItemsOut:           make(chan *Item),
+		DeadLettersOut:     make(chan *DeadLetter),
+		JobsIn:             make(chan *Job),
+		JobControlsIn:      make(chan *JobControl),
+		ManagerReportsOut:  make(chan *ManagerReport, ManagerReportsBufferSize),
+		JobsFinishedOut:    make(chan *JobFinished, ManagerJobsFinishedBufferSize),
+		CheckpointsOut:     make(chan *ManagerCheckpoint, ManagerCheckpointsBufferSize),
+		WorkerHeartbeatsIn: make(chan *WorkerHeartbeat),
+		Done:               make(chan interface{}),
+		Deduplicator:       deduplicator,
+		Workers:            NewWorkerRegistry(),
+		ResumedTaskTimeout: SpiderBusDefaultVisibilityTimeout,
+		jobs:               map[string]*ManagerJob{},
+		cancelledJobUUIDs:  jobUUIDSet{},
+		pausedJobUUIDs:     jobUUIDSet{},
+		finishedJobUUIDs:   jobUUIDSet{},
+		stopped:            make(chan struct{}),
 	}
 }
 
@@ -41,35 +82,356 @@ func (m *Manager) String() string {
 	return fmt.Sprintf("<Manager %s>", m.UUID)
 }
 
-func (m *Manager) StartScrapingJob(w *Workflow) {
-	m.JobUUID = uuid.New().String()
-	m.CurrentWorkflow = w
+// StartScrapingJob adds new scraping job for given workflow. Job gets started once
+// Manager runloop picks it up.
+func (m *Manager) StartScrapingJob(w *Workflow) *ManagerJob {
+	return m.ContinueScrapingJob(w, uuid.New().String())
+}
+
+// ContinueScrapingJob makes Manager work on already existing scraping job, e.g. for
+// re-driving its dead letters.
+func (m *Manager) ContinueScrapingJob(w *Workflow, jobUUID string) *ManagerJob {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+
+	job, found := m.jobs[jobUUID]
+	if !found {
+		job = NewManagerJob(w, jobUUID)
+		m.jobs[jobUUID] = job
+	}
+
+	return job
+}
+
+// StartJob makes Manager work on job received from Master.
+func (m *Manager) StartJob(job *Job) *ManagerJob {
+	return m.ContinueScrapingJob(job.Workflow, job.UUID)
+}
+
+// ResumeJob picks up job from checkpoint made by this or some other Manager. Tasks that
+// were in flight stay so, and their results are taken off the bus as usual.
+func (m *Manager) ResumeJob(checkpoint *ManagerCheckpoint) (*ManagerJob, error) {
+	if checkpoint.IsDone() {
+		return nil, ErrManagerCheckpointJobDone
+	}
+
+	job := checkpoint.NewManagerJob()
+	job.adoptUntil = job.resumedAt.Add(m.ResumedTaskTimeout)
+
+	log.Info(fmt.Sprintf("Manager %s resuming job %v from checkpoint %v", m.UUID, job, checkpoint))
+
+	m.jobsMutex.Lock()
+	m.jobs[job.UUID] = job
+	m.jobsMutex.Unlock()
+
+	return job, nil
+}
+
+// GetJob returns job with given UUID if Manager is still working on it, nil otherwise.
+func (m *Manager) GetJob(jobUUID string) *ManagerJob {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+
+	return m.jobs[jobUUID]
+}
+
+// ListJobs returns jobs Manager is working on.
+func (m *Manager) ListJobs() []*ManagerJob {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+
+	jobs := []*ManagerJob{}
+
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+func (m *Manager) removeJob(jobUUID string) {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+
+	delete(m.jobs, jobUUID)
+}
+
+func (m *Manager) sendJobReport(job *ManagerJob) {
+	report := NewManagerReport(m.UUID, job.UUID, job.Status(), job.Stats())
+	report.WorkflowName = job.Workflow.Name
+	report.WorkflowVersion = job.Workflow.Version
+	report.StopReason = job.StopReason
+
+	m.ManagerReportsOut <- report
+}
+
+// sendReports tells Master what Manager is up to, with a report per job. If there are
+// no jobs, report only serves to register the manager.
+func (m *Manager) sendReports() {
+	jobs := m.ListJobs()
+
+	if len(jobs) == 0 {
+		m.ManagerReportsOut <- NewManagerReport(m.UUID, "", "", JobStats{})
+		return
+	}
+
+	for _, job := range jobs {
+		m.sendJobReport(job)
+	}
+}
+
+func (m *Manager) sendCheckpoint(job *ManagerJob) {
+	m.CheckpointsOut <- NewManagerCheckpoint(m.UUID, job)
+}
+
+func (m *Manager) sendCheckpoints() {
+	for _, job := range m.ListJobs() {
+		if job.started {
+			m.sendCheckpoint(job)
+		}
+	}
 }
 
-func (m *Manager) createScheduledTaskFromPromise(promise *TaskPromise, jobUUID string) *ScheduledTask {
-	taskTempl := m.CurrentWorkflow.FindTaskTemplate(promise.TaskName)
+// handleJobControl pauses, resumes or cancels the job. Controls are remembered until the
+// job is finished or JobControlRetention runs out, so that jobs that arrive later (e.g.
+// queued on the bus) start out paused or get skipped. Controls of jobs that this manager
+// has finished, and stale controls, are ignored.
+func (m *Manager) handleJobControl(jobControl *JobControl) {
+	if jobControl == nil || jobControl.isStale() || m.finishedJobUUIDs.contains(jobControl.JobUUID) {
+		return
+	}
+
+	job := m.GetJob(jobControl.JobUUID)
+
+	switch jobControl.Action {
+	case JobControlActionCancel:
+		m.cancelledJobUUIDs.add(jobControl.JobUUID, jobControl.CreatedAt)
+
+		if job == nil || job.Cancelled {
+			return
+		}
+
+		log.Warn(fmt.Sprintf("Manager %s cancelling job %s", m.UUID, job.UUID))
+
+		job.cancel()
+	case JobControlActionPause:
+		m.pausedJobUUIDs.add(jobControl.JobUUID, jobControl.CreatedAt)
+
+		if job == nil || job.Paused || job.Cancelled {
+			return
+		}
+
+		log.Warn(fmt.Sprintf("Manager %s pausing job %s", m.UUID, job.UUID))
+
+		job.Paused = true
+		m.sendJobReport(job)
+	case JobControlActionResume:
+		m.pausedJobUUIDs.remove(jobControl.JobUUID)
+
+		if job == nil || !job.Paused || job.Cancelled {
+			return
+		}
+
+		log.Info(fmt.Sprintf("Manager %s resuming job %s", m.UUID, job.UUID))
+
+		for _, scheduledTask := range job.resume() {
+			m.sendScheduledTask(job, scheduledTask)
+		}
+
+		m.sendJobReport(job)
+	default:
+		log.Warn(fmt.Sprintf("Manager %s ignoring unknown job control %v", m.UUID, jobControl))
+	}
+}
+
+func (m *Manager) createScheduledTaskFromPromise(job *ManagerJob, promise *TaskPromise) *ScheduledTask {
+	taskTempl := job.Workflow.FindTaskTemplate(promise.TaskName)
 	if taskTempl == nil {
 		return nil
 	}
 
-	scheduledTask := NewScheduledTask(promise, taskTempl,
-		m.CurrentWorkflow.Name, m.CurrentWorkflow.Version, jobUUID)
+	scheduledTask := NewScheduledTask(promise, taskTempl, job.Workflow.Name, job.Workflow.Version, job.UUID)
 
 	return scheduledTask
 }
 
-func (m *Manager) logPendingTasks() {
-	log.Info(fmt.Sprintf("Manager %s tasks: %d pending, %d finished out of %d scheduled", m.UUID, m.NPendingTasks,
-		m.NFinishedTasks, m.NScheduledTasks))
+func (m *Manager) logPendingTasks(job *ManagerJob) {
+	log.Info(fmt.Sprintf("Manager %s job %s tasks: %d pending, %d finished, %d retried, %d failed out of %d scheduled",
+		m.UUID, job.UUID, job.NPendingTasks, job.NFinishedTasks, job.NRetriedTasks, job.NFailedTasks,
+		job.NScheduledTasks))
+}
+
+// sendScheduledTask hands scheduled task over to workers as soon as politeness limits for
+// its host allow. If the job is paused, task is held back until the job is resumed.
+func (m *Manager) sendScheduledTask(job *ManagerJob, scheduledTask *ScheduledTask) {
+	if job.Paused {
+		job.heldTasks = append(job.heldTasks, scheduledTask)
+		return
+	}
+
+	job.scheduler.Enqueue(scheduledTask)
+	m.releaseScheduledTasks(job)
+}
+
+// releaseScheduledTasks sends out tasks of the job that no longer wait for their host.
+func (m *Manager) releaseScheduledTasks(job *ManagerJob) {
+	if job.Paused || job.Cancelled {
+		return
+	}
+
+	for _, scheduledTask := range job.scheduler.TakeReady() {
+		job.inFlightTasks[scheduledTask.UUID] = scheduledTask
+		m.ScheduledTasksOut <- scheduledTask
+	}
+}
+
+func (m *Manager) releaseQueuedTasks() {
+	for _, job := range m.ListJobs() {
+		m.releaseScheduledTasks(job)
+	}
 }
 
-func (m *Manager) handleTaskPromise(promise *TaskPromise) {
+func (m *Manager) releaseDelayedTasks() {
+	for _, job := range m.ListJobs() {
+		for _, scheduledTask := range job.takeDueDelayedTasks() {
+			log.Info(fmt.Sprintf("Releasing delayed scheduled task %v", scheduledTask))
+			m.sendScheduledTask(job, scheduledTask)
+		}
+	}
+}
+
+func (m *Manager) resendStaleResumedTasks() {
+	for _, job := range m.ListJobs() {
+		for _, scheduledTask := range job.takeStaleResumedTasks(m.ResumedTaskTimeout) {
+			log.Warn(fmt.Sprintf("No result for resumed scheduled task %s, sending it again", scheduledTask.UUID))
+			m.sendScheduledTask(job, scheduledTask)
+		}
+	}
+}
+
+// rescheduleTasksOfStaleWorkers sends out again tasks that were running on workers which
+// stopped sending heartbeats, rather than waiting for the bus to redeliver them.
+func (m *Manager) rescheduleTasksOfStaleWorkers() {
+	for _, heartbeat := range m.Workers.TakeNewlyStaleWorkers() {
+		if heartbeat.ScheduledTaskUUID == "" {
+			continue
+		}
+
+		job := m.GetJob(heartbeat.JobUUID)
+		if job == nil {
+			continue
+		}
+
+		scheduledTask, found := job.inFlightTasks[heartbeat.ScheduledTaskUUID]
+		if !found {
+			continue
+		}
+
+		log.Warn(fmt.Sprintf("Worker %s on %s went silent while running scheduled task %s, sending it again",
+			heartbeat.WorkerUUID, heartbeat.Host, scheduledTask.UUID))
+
+		delete(job.inFlightTasks, scheduledTask.UUID)
+		job.scheduler.TaskDone(scheduledTask.UUID)
+
+		m.sendScheduledTask(job, scheduledTask)
+	}
+}
+
+// RedriveDeadLetters puts scheduled tasks from given dead letters back into work. Jobs of
+// these dead letters must have been added with ContinueScrapingJob beforehand. Tasks are
+// sent out once Manager runloop starts.
+func (m *Manager) RedriveDeadLetters(deadLetters []*DeadLetter) {
+	for _, deadLetter := range deadLetters {
+		job := m.GetJob(deadLetter.ScheduledTask.JobUUID)
+		if job == nil {
+			log.Error(fmt.Sprintf("Cannot re-drive dead letter %s of unknown job %s", deadLetter.UUID,
+				deadLetter.ScheduledTask.JobUUID))
+			continue
+		}
+
+		scheduledTask := deadLetter.NewScheduledTask()
+
+		log.Info(fmt.Sprintf("Re-driving dead letter %s as scheduled task %v", deadLetter.UUID, scheduledTask))
+
+		job.deadLetterUUIDsByTaskUUID[scheduledTask.UUID] = deadLetter.UUID
+		job.delayScheduledTask(scheduledTask, 0)
+		job.NPendingTasks++
+		job.NScheduledTasks++
+	}
+}
+
+func (m *Manager) handleFailedTask(job *ManagerJob, scheduledTask *ScheduledTask, errStr string) {
+	retryPolicy := scheduledTask.Template.RetryPolicy
+
+	deadLetterUUID, redriven := job.deadLetterUUIDsByTaskUUID[scheduledTask.UUID]
+	delete(job.deadLetterUUIDsByTaskUUID, scheduledTask.UUID)
+
+	if retryPolicy != nil && retryPolicy.ShouldRetry(scheduledTask.Attempt, errStr) && !job.stopped {
+		newAttempt := scheduledTask.NewAttempt()
+		backoff := retryPolicy.BackoffBeforeAttempt(newAttempt.Attempt)
+
+		if redriven {
+			job.deadLetterUUIDsByTaskUUID[newAttempt.UUID] = deadLetterUUID
+		}
+
+		log.Info(fmt.Sprintf("Retrying scheduled task %s in %v as %v", scheduledTask.UUID, backoff, newAttempt))
+
+		job.delayScheduledTask(newAttempt, backoff)
+		job.NPendingTasks++
+		job.NRetriedTasks++
+		return
+	}
+
+	job.NFailedTasks++
+
+	if redriven {
+		// Dead letter it was re-driven from is still there, so there's no need for another one.
+		log.Warn(fmt.Sprintf("Re-driven scheduled task %s failed again, keeping dead letter %s", scheduledTask.UUID,
+			deadLetterUUID))
+		return
+	}
+
+	deadLetter := NewDeadLetter(scheduledTask, errStr)
+
+	log.Warn(fmt.Sprintf("Scheduled task %s ran out of attempts, sending dead letter %v", scheduledTask.UUID,
+		deadLetter))
+
+	m.DeadLettersOut <- deadLetter
+}
+
+// handleTaskPromise schedules tasks for promise made by parent scheduled task, as long as
+// job limits allow.
+func (m *Manager) handleTaskPromise(job *ManagerJob, promise *TaskPromise, parent *ScheduledTask) {
 	if promise == nil {
 		return
 	}
 
+	if job.stopped {
+		log.Info(fmt.Sprintf("Dropping promise %s of job %s stopped by %s", promise.UUID, job.UUID, job.StopReason))
+		return
+	}
+
+	limits := job.Workflow.GetLimits()
+
+	promise.Depth = parent.Depth + 1
+	promise.ParentTaskUUID = parent.UUID
+
+	if limits.IsDepthExceeded(promise.Depth) {
+		log.Info(fmt.Sprintf("Dropping promise %s at depth %d beyond max depth %d", promise.UUID, promise.Depth,
+			limits.MaxDepth))
+		job.noteLimitHit(JobStopReasonMaxDepth)
+		return
+	}
+
 	for _, p := range promise.Splay() {
-		newScheduledTask := m.createScheduledTaskFromPromise(p, m.JobUUID)
+		if limits.IsScheduledTasksReached(job.NScheduledTasks) {
+			log.Warn(fmt.Sprintf("Manager %s job %s reached max scheduled tasks %d, stopping", m.UUID, job.UUID,
+				limits.MaxScheduledTasks))
+			job.stop(JobStopReasonMaxScheduledTasks)
+			return
+		}
+
+		newScheduledTask := m.createScheduledTaskFromPromise(job, p)
 		if newScheduledTask == nil {
 			continue
 		}
@@ -80,18 +442,57 @@ func (m *Manager) handleTaskPromise(promise *TaskPromise) {
 		}
 
 		log.Info(fmt.Sprintf("Created scheduled task %v", newScheduledTask))
-		m.ScheduledTasksOut <- newScheduledTask
-		m.NPendingTasks++
-		m.NScheduledTasks++
-		m.logPendingTasks()
+		m.sendScheduledTask(job, newScheduledTask)
+		job.NPendingTasks++
+		job.NScheduledTasks++
+		m.logPendingTasks(job)
 
 		m.Deduplicator.NoteScheduledTask(newScheduledTask)
 	}
 }
 
-func (m *Manager) handleItem(item *Item) {
+func (m *Manager) handleItem(job *ManagerJob, item *Item) {
+	limits := job.Workflow.GetLimits()
+
 	for _, i := range item.Splay() {
+		if limits.IsItemsReached(job.NItems) {
+			log.Info(fmt.Sprintf("Dropping item %s of job %s beyond max items %d", i.UUID, job.UUID, limits.MaxItems))
+			continue
+		}
+
 		m.ItemsOut <- i
+		job.NItems++
+
+		if limits.IsItemsReached(job.NItems) {
+			log.Warn(fmt.Sprintf("Manager %s job %s reached max items %d, stopping", m.UUID, job.UUID,
+				limits.MaxItems))
+			job.stop(JobStopReasonMaxItems)
+		}
+	}
+}
+
+// stopOverdueJobs stops jobs that have been running for longer than their limits allow.
+// Tasks that were not sent out yet are dropped, ones in flight are let finish.
+func (m *Manager) stopOverdueJobs() {
+	for _, job := range m.ListJobs() {
+		if !job.started || job.stopped || job.Cancelled {
+			continue
+		}
+
+		limits := job.Workflow.GetLimits()
+		if !limits.IsDurationExceeded(job.startedAt) {
+			continue
+		}
+
+		log.Warn(fmt.Sprintf("Manager %s job %s ran for max duration %v, stopping", m.UUID, job.UUID,
+			limits.MaxDuration))
+
+		job.stop(JobStopReasonMaxDuration)
+
+		n := job.dropUnsentTasks()
+		if n > 0 {
+			log.Warn(fmt.Sprintf("Manager %s dropped %d unsent tasks of job %s", m.UUID, n, job.UUID))
+		}
 	}
 }
 
@@ -100,63 +501,245 @@ func (m *Manager) processTaskResult(taskResult *TaskResult) {
 		return
 	}
 
-	m.NPendingTasks--
+	job := m.GetJob(taskResult.JobUUID)
+	if job == nil {
+		// Result for job that is done (e.g. cancelled) or is run by some other manager.
+		log.Warn(fmt.Sprintf("Ignoring task result %s for unknown job %s", taskResult.UUID, taskResult.JobUUID))
+		return
+	}
+
+	scheduledTask, found := job.inFlightTasks[taskResult.ScheduledTaskUUID]
+	if !found && job.adoptScheduledTask(taskResult.ScheduledTask) {
+		log.Info(fmt.Sprintf("Manager %s adopting scheduled task %s of resumed job %s", m.UUID,
+			taskResult.ScheduledTaskUUID, job.UUID))
+
+		scheduledTask, found = taskResult.ScheduledTask, true
+	}
+
+	if !found {
+		// Result for redelivered task that was already handled, or for task this manager does not know.
+		log.Warn(fmt.Sprintf("Ignoring task result %s for unknown scheduled task %s", taskResult.UUID,
+			taskResult.ScheduledTaskUUID))
+		return
+	}
+
+	delete(job.inFlightTasks, taskResult.ScheduledTaskUUID)
+	job.scheduler.TaskDone(taskResult.ScheduledTaskUUID)
+
+	job.NPendingTasks--
 
 	if !taskResult.Succeeded {
 		log.Error(fmt.Sprintf("Task %s failed with error: %v", taskResult.TaskUUID, taskResult.Error))
+		m.handleFailedTask(job, scheduledTask, taskResult.Error)
 		return
 	}
 
-	m.NFinishedTasks++
+	job.NFinishedTasks++
+
+	if deadLetterUUID, redriven := job.deadLetterUUIDsByTaskUUID[scheduledTask.UUID]; redriven {
+		delete(job.deadLetterUUIDsByTaskUUID, scheduledTask.UUID)
+		job.RedrivenDeadLetterUUIDs = append(job.RedrivenDeadLetterUUIDs, deadLetterUUID)
+	}
 
 	for _, chunks := range taskResult.OutputDataChunks {
 		for _, chunk := range chunks {
 			if chunk.Type == DataChunkTypePromise {
-				m.handleTaskPromise(chunk.PayloadPromise)
+				m.handleTaskPromise(job, chunk.PayloadPromise, scheduledTask)
 			} else if chunk.Type == DataChunkTypeItem {
-				m.handleItem(chunk.PayloadItem)
+				m.handleItem(job, chunk.PayloadItem)
 			}
 		}
 	}
+
+	m.logPendingTasks(job)
 }
 
-func (m *Manager) Run() error {
-	log.Info(fmt.Sprintf("Starting runloop for manager %s", m.UUID))
+// launchJob schedules initial task of the job, unless job already has pending tasks
+// (e.g. from dead letters being re-driven).
+func (m *Manager) launchJob(job *ManagerJob) {
+	log.Info(fmt.Sprintf("Manager %s launching job %v", m.UUID, job))
+
+	job.started = true
+	job.startedAt = time.Now()
+
+	for _, taskTempl := range job.Workflow.TaskTemplates {
+		if job.NPendingTasks > 0 {
+			break
+		}
 
-	for _, taskTempl := range m.CurrentWorkflow.TaskTemplates {
 		if !taskTempl.Initial {
 			continue
 		}
 
-		newPromise := NewTaskPromise(taskTempl.TaskName,
-			m.CurrentWorkflow.Name, m.JobUUID, map[string]*DataChunk{})
+		newPromise := NewTaskPromise(taskTempl.TaskName, job.Workflow.Name, job.UUID, map[string]*DataChunk{})
 		log.Info(fmt.Sprintf("Fulfilling promise %v", newPromise))
 
-		scheduledTask := NewScheduledTask(newPromise, &taskTempl,
-			m.CurrentWorkflow.Name, m.CurrentWorkflow.Version, m.JobUUID)
+		taskTempl := taskTempl
+		scheduledTask := NewScheduledTask(newPromise, &taskTempl, job.Workflow.Name, job.Workflow.Version, job.UUID)
 
 		log.Info(fmt.Sprintf("Created scheduled task %v", scheduledTask))
 
-		m.ScheduledTasksOut <- scheduledTask
-		m.NPendingTasks++
-		m.NScheduledTasks++
+		m.sendScheduledTask(job, scheduledTask)
+		job.NPendingTasks++
+		job.NScheduledTasks++
 
 		m.Deduplicator.NoteScheduledTask(scheduledTask)
 
-		m.logPendingTasks()
+		m.logPendingTasks(job)
 
 		break
 	}
 
-	for taskResult := range m.TaskResultsIn {
-		m.processTaskResult(taskResult)
+	m.sendJobReport(job)
+	m.sendCheckpoint(job)
+}
 
-		if m.NPendingTasks == 0 {
-			break
+func (m *Manager) launchNewJobs() {
+	for _, job := range m.ListJobs() {
+		if !job.started {
+			m.launchJob(job)
+		}
+	}
+}
+
+// finishDoneJobs sends final report for each job that is done, lets exporters know the
+// job is finished and stops tracking it.
+func (m *Manager) finishDoneJobs() {
+	for _, job := range m.ListJobs() {
+		if !job.IsDone() {
+			continue
 		}
 
-		m.logPendingTasks()
+		log.Info(fmt.Sprintf("Manager %s done with job %v", m.UUID, job))
+
+		m.sendJobReport(job)
+
+		jobFinished := NewJobFinished(job.UUID, job.Status(), job.Stats(), job.NItems)
+		jobFinished.WorkflowName = job.Workflow.Name
+		jobFinished.WorkflowVersion = job.Workflow.Version
+		jobFinished.StopReason = job.StopReason
+
+		m.JobsFinishedOut <- jobFinished
+
+		m.sendCheckpoint(job)
+		m.removeJob(job.UUID)
+
+		m.cancelledJobUUIDs.remove(job.UUID)
+		m.pausedJobUUIDs.remove(job.UUID)
+		m.finishedJobUUIDs.add(job.UUID, time.Now())
+	}
+}
+
+// forgetStaleJobControls drops job controls (and finished jobs) remembered for longer
+// than JobControlRetention.
+func (m *Manager) forgetStaleJobControls() {
+	m.cancelledJobUUIDs.prune()
+	m.pausedJobUUIDs.prune()
+	m.finishedJobUUIDs.prune()
+}
+
+func (m *Manager) acceptJob(job *Job) {
+	if job == nil || job.Workflow == nil {
+		return
 	}
 
+	if m.cancelledJobUUIDs.contains(job.UUID) {
+		log.Info(fmt.Sprintf("Manager %s skipping cancelled job %s", m.UUID, job.UUID))
+		return
+	}
+
+	log.Info(fmt.Sprintf("Manager %s accepting job %v", m.UUID, job))
+
+	managerJob := m.StartJob(job)
+	managerJob.Paused = m.pausedJobUUIDs.contains(job.UUID)
+
+	m.launchNewJobs()
+}
+
+// runLoop drives all the jobs. If keepServing is false, it returns once there are no
+// jobs left. When told to stop, it checkpoints jobs that are not done yet, so that
+// another manager can resume them.
+func (m *Manager) runLoop(keepServing bool) {
+	defer close(m.stopped)
+
+	ticker := time.NewTicker(ManagerDelayedTasksCheckInterval)
+	defer ticker.Stop()
+
+	reportTicker := time.NewTicker(ManagerReportInterval)
+	defer reportTicker.Stop()
+
+	checkpointTicker := time.NewTicker(ManagerCheckpointInterval)
+	defer checkpointTicker.Stop()
+
+	m.launchNewJobs()
+
+	if len(m.ListJobs()) == 0 {
+		m.sendReports()
+	}
+
+	m.finishDoneJobs()
+
+	for keepServing || len(m.ListJobs()) > 0 {
+		select {
+		case job := <-m.JobsIn:
+			m.acceptJob(job)
+		case taskResult := <-m.TaskResultsIn:
+			m.processTaskResult(taskResult)
+		case <-m.TaskPromisesIn:
+			// Promises are taken from task results, these are only drained so that
+			// adapter does not get stuck handing them over.
+		case jobControl := <-m.JobControlsIn:
+			m.handleJobControl(jobControl)
+		case heartbeat := <-m.WorkerHeartbeatsIn:
+			m.Workers.Update(heartbeat)
+		case <-ticker.C:
+			// Jobs may also be added with StartScrapingJob while runloop is going.
+			m.launchNewJobs()
+			m.releaseDelayedTasks()
+			m.resendStaleResumedTasks()
+			m.rescheduleTasksOfStaleWorkers()
+			m.stopOverdueJobs()
+			m.releaseQueuedTasks()
+		case <-reportTicker.C:
+			m.sendReports()
+			m.forgetStaleJobControls()
+		case <-checkpointTicker.C:
+			m.sendCheckpoints()
+		case <-m.Done:
+			m.finishDoneJobs()
+			m.sendCheckpoints()
+			m.sendReports()
+			return
+		}
+
+		m.finishDoneJobs()
+	}
+}
+
+// Run works on jobs that were added to the Manager until all of them are done.
+func (m *Manager) Run() error {
+	log.Info(fmt.Sprintf("Starting runloop for manager %s", m.UUID))
+
+	m.runLoop(false)
+
 	return nil
 }
+
+// Stop tells Manager to stop working on its jobs and waits until their checkpoints and
+// reports are handed over. It must only be called while Run or Serve is going.
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() {
+		log.Info(fmt.Sprintf("Stopping manager %s", m.UUID))
+		close(m.Done)
+	})
+
+	<-m.stopped
+}
+
+// Serve keeps Manager waiting for jobs from Master and working on them, along with
+// whatever jobs are already running.
+func (m *Manager) Serve() {
+	log.Info(fmt.Sprintf("Manager %s waiting for jobs", m.UUID))
+
+	m.runLoop(true)
+}
@@ -1,7 +1,9 @@
 package spsw
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,7 +14,7 @@ func TestNewManager(t *testing.T) {
 	assert.NotNil(t, manager)
 	assert.NotNil(t, manager.TaskPromisesIn)
 	assert.NotNil(t, manager.ScheduledTasksOut)
-	assert.Nil(t, manager.CurrentWorkflow)
+	assert.Equal(t, 0, len(manager.ListJobs()))
 }
 
 func TestManagerStartScrapingJob(t *testing.T) {
@@ -20,7 +22,807 @@ func TestManagerStartScrapingJob(t *testing.T) {
 
 	workflow := &Workflow{}
 
-	manager.StartScrapingJob(workflow)
+	job1 := manager.StartScrapingJob(workflow)
+	job2 := manager.StartScrapingJob(workflow)
 
-	assert.Equal(t, workflow, manager.CurrentWorkflow)
+	assert.NotEqual(t, job1.UUID, job2.UUID)
+	assert.Equal(t, workflow, job1.Workflow)
+	assert.Equal(t, job1, manager.GetJob(job1.UUID))
+	assert.Equal(t, 2, len(manager.ListJobs()))
+	assert.Equal(t, JobStatusQueued, job1.Status())
+}
+
+func TestManagerRetriesFailedTasks(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	taskTempl := NewTaskTemplate("Task1", true)
+	taskTempl.RetryPolicy = NewRetryPolicy(2, 10*time.Millisecond)
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*taskTempl}}
+
+	job := manager.StartScrapingJob(workflow)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, 1, scheduledTask.Attempt)
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", scheduledTask.UUID, false,
+		errors.New("connection refused"))
+
+	retriedTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, 2, retriedTask.Attempt)
+	assert.NotEqual(t, scheduledTask.UUID, retriedTask.UUID)
+
+	// Results for tasks that were already handled are ignored.
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", scheduledTask.UUID, true, nil)
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", retriedTask.UUID, false,
+		errors.New("connection refused"))
+
+	deadLetter := <-manager.DeadLettersOut
+	assert.Equal(t, retriedTask.UUID, deadLetter.ScheduledTask.UUID)
+	assert.Equal(t, "connection refused", deadLetter.Error)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 0, job.NPendingTasks)
+	assert.Equal(t, 0, job.NFinishedTasks)
+	assert.Equal(t, 1, job.NRetriedTasks)
+	assert.Equal(t, 1, job.NFailedTasks)
+	assert.Equal(t, JobStatusFailed, job.Status())
+	assert.Nil(t, manager.GetJob(job.UUID))
+}
+
+func TestManagerDoesNotRetryNonRetryableErrors(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	taskTempl := NewTaskTemplate("Task1", true)
+	taskTempl.RetryPolicy = NewRetryPolicy(3, 0)
+	taskTempl.RetryPolicy.RetryableErrors = []string{"timeout"}
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*taskTempl}}
+
+	job := manager.StartScrapingJob(workflow)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", scheduledTask.UUID, false,
+		errors.New("HTTP 404 Not Found"))
+
+	deadLetter := <-manager.DeadLettersOut
+	assert.Equal(t, scheduledTask.UUID, deadLetter.ScheduledTask.UUID)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 0, job.NRetriedTasks)
+	assert.Equal(t, 1, job.NFailedTasks)
+}
+
+func TestManagerRedriveDeadLetters(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	jobUUID := "0D5F2C3A-8E4B-4F1A-9C6D-7B2E1A3F4C5D"
+
+	workflow := &Workflow{Name: "WF0", Version: "v1",
+		TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true), *NewTaskTemplate("Task2", false)}}
+
+	promise := NewTaskPromise("Task2", "WF0", jobUUID, map[string]*DataChunk{})
+	deadScheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task2", false), "WF0", "v1", jobUUID)
+
+	job := manager.ContinueScrapingJob(workflow, jobUUID)
+	deadLetter := NewDeadLetter(deadScheduledTask, "connection refused")
+	manager.RedriveDeadLetters([]*DeadLetter{deadLetter})
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	// Initial task is not scheduled again, only the re-driven one.
+	scheduledTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, "Task2", scheduledTask.Template.TaskName)
+	assert.Equal(t, jobUUID, scheduledTask.JobUUID)
+	assert.Equal(t, 1, scheduledTask.Attempt)
+
+	manager.TaskResultsIn <- NewTaskResult(jobUUID, "", scheduledTask.UUID, true, nil)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 1, job.NFinishedTasks)
+	assert.Equal(t, 0, job.NFailedTasks)
+	assert.Equal(t, []string{deadLetter.UUID}, job.RedrivenDeadLetterUUIDs)
+}
+
+func TestManagerRedriveDeadLettersFailingAgain(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	jobUUID := "0D5F2C3A-8E4B-4F1A-9C6D-7B2E1A3F4C5D"
+
+	taskTempl := NewTaskTemplate("Task2", false)
+	taskTempl.RetryPolicy = &RetryPolicy{MaxAttempts: 2}
+
+	workflow := &Workflow{Name: "WF0", Version: "v1",
+		TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true), *taskTempl}}
+
+	promise := NewTaskPromise("Task2", "WF0", jobUUID, map[string]*DataChunk{})
+	deadScheduledTask := NewScheduledTask(promise, taskTempl, "WF0", "v1", jobUUID)
+
+	job := manager.ContinueScrapingJob(workflow, jobUUID)
+	manager.RedriveDeadLetters([]*DeadLetter{NewDeadLetter(deadScheduledTask, "connection refused")})
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		scheduledTask := <-manager.ScheduledTasksOut
+		assert.Equal(t, attempt, scheduledTask.Attempt)
+
+		manager.TaskResultsIn <- NewTaskResult(jobUUID, "", scheduledTask.UUID, false, errors.New("connection refused"))
+	}
+
+	// Dead letter task was re-driven from is kept, so no new one is sent.
+	select {
+	case deadLetter := <-manager.DeadLettersOut:
+		t.Fatalf("Unexpected dead letter %v", deadLetter)
+	case err := <-done:
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, 0, job.NFinishedTasks)
+	assert.Equal(t, 1, job.NFailedTasks)
+	assert.Equal(t, 1, job.NRetriedTasks)
+	assert.Equal(t, 0, len(job.RedrivenDeadLetterUUIDs))
+}
+
+func TestManagerStartJob(t *testing.T) {
+	manager := NewManager(nil)
+
+	workflow := &Workflow{Name: "WF0", Version: "v1"}
+	job := NewJob(workflow)
+
+	managerJob := manager.StartJob(job)
+
+	assert.Equal(t, job.UUID, managerJob.UUID)
+	assert.Equal(t, workflow, managerJob.Workflow)
+	assert.Equal(t, 0, managerJob.NFinishedTasks)
+	assert.Equal(t, managerJob, manager.StartJob(job))
+}
+
+func TestManagerCancelJob(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+
+	job := manager.StartJob(NewJob(workflow))
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+
+	report := <-manager.ManagerReportsOut
+	assert.Equal(t, job.UUID, report.JobUUID)
+	assert.Equal(t, "WF0", report.WorkflowName)
+
+	manager.JobControlsIn <- NewJobControl(job.UUID, JobControlActionCancel)
+
+	assert.Nil(t, <-done)
+
+	report = <-manager.ManagerReportsOut
+	assert.Equal(t, JobStatusCancelled, report.JobStatus)
+	assert.Equal(t, 0, report.Stats.NPendingTasks)
+	assert.Equal(t, 1, report.Stats.NScheduledTasks)
+
+	// Results of tasks that were running when job was cancelled are ignored.
+	manager.processTaskResult(NewTaskResult(job.UUID, "", scheduledTask.UUID, true, nil))
+	assert.Equal(t, 0, job.NFinishedTasks)
+}
+
+func TestManagerPauseAndResumeJob(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	workflow := &Workflow{Name: "WF0", Version: "v1",
+		TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true), *NewTaskTemplate("Task2", false)}}
+
+	job := manager.StartScrapingJob(workflow)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+	<-manager.ManagerReportsOut
+
+	manager.JobControlsIn <- NewJobControl(job.UUID, JobControlActionPause)
+
+	report := <-manager.ManagerReportsOut
+	assert.Equal(t, JobStatusPaused, report.JobStatus)
+
+	taskResult := NewTaskResult(job.UUID, "", scheduledTask.UUID, true, nil)
+	taskResult.AddOutputTaskPromise("promises", NewTaskPromise("Task2", "WF0", job.UUID, map[string]*DataChunk{}))
+
+	manager.TaskResultsIn <- taskResult
+
+	// New task is held back while job is paused.
+	select {
+	case <-manager.ScheduledTasksOut:
+		t.Fatal("Scheduled task was sent out for paused job")
+	case <-time.After(2 * ManagerDelayedTasksCheckInterval):
+	}
+
+	manager.JobControlsIn <- NewJobControl(job.UUID, JobControlActionResume)
+
+	heldTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, "Task2", heldTask.Template.TaskName)
+
+	report = <-manager.ManagerReportsOut
+	assert.Equal(t, JobStatusRunning, report.JobStatus)
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", heldTask.UUID, true, nil)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 2, job.NFinishedTasks)
+	assert.False(t, job.Paused)
+}
+
+func TestManagerAcceptsPausedJob(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+	job := NewJob(workflow)
+
+	// Job was paused before this manager got it.
+	manager.handleJobControl(NewJobControl(job.UUID, JobControlActionPause))
+	manager.acceptJob(job)
+
+	managerJob := manager.GetJob(job.UUID)
+	assert.NotNil(t, managerJob)
+	assert.True(t, managerJob.Paused)
+	assert.Equal(t, JobStatusPaused, managerJob.Status())
+	assert.Equal(t, 1, managerJob.NPendingTasks)
+	assert.Equal(t, 1, len(managerJob.heldTasks))
+
+	// Cancelled jobs are not taken at all.
+	cancelledJob := NewJob(workflow)
+
+	manager.handleJobControl(NewJobControl(cancelledJob.UUID, JobControlActionCancel))
+	manager.acceptJob(cancelledJob)
+
+	assert.Nil(t, manager.GetJob(cancelledJob.UUID))
+}
+
+func TestManagerForgetsJobControlsOfFinishedJobs(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+	job := NewJob(workflow)
+
+	manager.handleJobControl(NewJobControl(job.UUID, JobControlActionPause))
+	manager.acceptJob(job)
+
+	manager.handleJobControl(NewJobControl(job.UUID, JobControlActionCancel))
+	manager.finishDoneJobs()
+
+	assert.Nil(t, manager.GetJob(job.UUID))
+	assert.False(t, manager.pausedJobUUIDs.contains(job.UUID))
+	assert.False(t, manager.cancelledJobUUIDs.contains(job.UUID))
+
+	// Controls that come for the job after it's finished (e.g. replayed from the bus) are ignored.
+	manager.handleJobControl(NewJobControl(job.UUID, JobControlActionCancel))
+	assert.False(t, manager.cancelledJobUUIDs.contains(job.UUID))
+
+	// So are the stale ones.
+	staleJobControl := NewJobControl("job2", JobControlActionCancel)
+	staleJobControl.CreatedAt = time.Now().Add(-2 * JobControlRetention)
+
+	manager.handleJobControl(staleJobControl)
+	assert.False(t, manager.cancelledJobUUIDs.contains("job2"))
+
+	// Controls of jobs that never turned up are forgotten once retention runs out.
+	manager.handleJobControl(NewJobControl("job3", JobControlActionPause))
+	manager.pausedJobUUIDs.add("job3", time.Now().Add(-2*JobControlRetention))
+	manager.finishedJobUUIDs.add(job.UUID, time.Now().Add(-2*JobControlRetention))
+
+	manager.forgetStaleJobControls()
+
+	assert.False(t, manager.pausedJobUUIDs.contains("job3"))
+	assert.False(t, manager.finishedJobUUIDs.contains(job.UUID))
+}
+
+func TestManagerResumeJob(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+	manager.ResumedTaskTimeout = 2 * ManagerDelayedTasksCheckInterval
+
+	checkpointedJob := newTestCheckpointedJob()
+
+	var inFlightTask *ScheduledTask
+	for _, scheduledTask := range checkpointedJob.inFlightTasks {
+		inFlightTask = scheduledTask
+	}
+
+	delayedTask := checkpointedJob.delayedTasks[0].scheduledTask
+
+	checkpoint := NewManagerCheckpointFromJSON(NewManagerCheckpoint("manager0", checkpointedJob).EncodeToJSON())
+
+	job, err := manager.ResumeJob(checkpoint)
+	assert.Nil(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	// Initial task is not scheduled again, delayed task is released right away.
+	scheduledTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, delayedTask.UUID, scheduledTask.UUID)
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", delayedTask.UUID, true, nil)
+
+	// Task that was in flight gets sent again as its result did not turn up.
+	scheduledTask = <-manager.ScheduledTasksOut
+	assert.Equal(t, inFlightTask.UUID, scheduledTask.UUID)
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", inFlightTask.UUID, true, nil)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 3, job.NFinishedTasks)
+	assert.Equal(t, 0, job.NPendingTasks)
+
+	var lastCheckpoint *ManagerCheckpoint
+	for len(manager.CheckpointsOut) > 0 {
+		lastCheckpoint = <-manager.CheckpointsOut
+	}
+
+	assert.NotNil(t, lastCheckpoint)
+	assert.Equal(t, manager.UUID, lastCheckpoint.ManagerUUID)
+	assert.True(t, lastCheckpoint.IsDone())
+
+	_, err = manager.ResumeJob(lastCheckpoint)
+	assert.Equal(t, ErrManagerCheckpointJobDone, err)
+}
+
+func TestManagerResumeJobAdoptsTasksScheduledAfterCheckpoint(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+	manager.ResumedTaskTimeout = 2 * ManagerDelayedTasksCheckInterval
+
+	checkpointedJob := newTestCheckpointedJob()
+	checkpoint := NewManagerCheckpointFromJSON(NewManagerCheckpoint("manager0", checkpointedJob).EncodeToJSON())
+
+	// Previous manager sends out another task before going away without a new checkpoint.
+	urlChunk, _ := NewDataChunk("https://a.com/late")
+	latePromise := NewTaskPromise("Task2", "WF0", checkpointedJob.UUID, map[string]*DataChunk{"url": urlChunk})
+	lateTask := NewScheduledTask(latePromise, &checkpointedJob.Workflow.TaskTemplates[1], "WF0", "v1",
+		checkpointedJob.UUID)
+	lateTask.Depth = 1
+
+	job, err := manager.ResumeJob(checkpoint)
+	assert.Nil(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	delayedTask := <-manager.ScheduledTasksOut
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", delayedTask.UUID, true, nil)
+
+	item := NewItem("person", "WF0", job.UUID, "")
+
+	childURLChunk, _ := NewDataChunk("https://a.com/child")
+	lateResult := NewTaskResult(job.UUID, "", lateTask.UUID, true, nil)
+	lateResult.ScheduledTask = lateTask
+	lateResult.AddOutputItem("items", item)
+	lateResult.AddOutputTaskPromise("promises", NewTaskPromise("Task2", "WF0", job.UUID,
+		map[string]*DataChunk{"url": childURLChunk}))
+
+	manager.TaskResultsIn <- lateResult
+
+	gotItem := <-manager.ItemsOut
+	assert.Equal(t, item.UUID, gotItem.UUID)
+
+	childTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, lateTask.UUID, childTask.ParentTaskUUID)
+	assert.Equal(t, 2, childTask.Depth)
+
+	// Redelivered result of adopted task is not handled twice.
+	manager.TaskResultsIn <- lateResult
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", childTask.UUID, true, nil)
+
+	inFlightTask := <-manager.ScheduledTasksOut
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", inFlightTask.UUID, true, nil)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 5, job.NFinishedTasks)
+	assert.Equal(t, 5, job.NScheduledTasks)
+	assert.Equal(t, 5, job.NItems)
+	assert.Equal(t, 0, job.NPendingTasks)
+}
+
+func TestManagerRunsConcurrentJobs(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	workflow1 := &Workflow{Name: "WF1", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+	workflow2 := &Workflow{Name: "WF2", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task2", true)}}
+
+	job1 := manager.StartScrapingJob(workflow1)
+	job2 := manager.StartScrapingJob(workflow2)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTasks := map[string]*ScheduledTask{}
+
+	for i := 0; i < 2; i++ {
+		scheduledTask := <-manager.ScheduledTasksOut
+		scheduledTasks[scheduledTask.JobUUID] = scheduledTask
+	}
+
+	assert.Equal(t, "Task1", scheduledTasks[job1.UUID].Template.TaskName)
+	assert.Equal(t, "WF1", scheduledTasks[job1.UUID].WorkflowName)
+	assert.Equal(t, "Task2", scheduledTasks[job2.UUID].Template.TaskName)
+	assert.Equal(t, "WF2", scheduledTasks[job2.UUID].WorkflowName)
+
+	// Result of one job's task does not count towards the other job.
+	manager.TaskResultsIn <- NewTaskResult(job2.UUID, "", scheduledTasks[job1.UUID].UUID, true, nil)
+
+	manager.TaskResultsIn <- NewTaskResult(job1.UUID, "", scheduledTasks[job1.UUID].UUID, true, nil)
+
+	// Second job is still going after the first one is done.
+	select {
+	case <-done:
+		t.Fatal("Manager stopped before all jobs were done")
+	case <-time.After(2 * ManagerDelayedTasksCheckInterval):
+	}
+
+	assert.Nil(t, manager.GetJob(job1.UUID))
+	assert.Equal(t, job2, manager.GetJob(job2.UUID))
+
+	manager.TaskResultsIn <- NewTaskResult(job2.UUID, "", scheduledTasks[job2.UUID].UUID, false,
+		errors.New("connection refused"))
+
+	<-manager.DeadLettersOut
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 1, job1.NFinishedTasks)
+	assert.Equal(t, 0, job1.NFailedTasks)
+	assert.Equal(t, JobStatusFinished, job1.Status())
+	assert.Equal(t, 0, job2.NFinishedTasks)
+	assert.Equal(t, 1, job2.NFailedTasks)
+	assert.Equal(t, JobStatusFailed, job2.Status())
+
+	jobFinished := <-manager.JobsFinishedOut
+	assert.Equal(t, job1.UUID, jobFinished.JobUUID)
+	assert.Equal(t, JobStatusFinished, jobFinished.JobStatus)
+	assert.Equal(t, "WF1", jobFinished.WorkflowName)
+
+	jobFinished = <-manager.JobsFinishedOut
+	assert.Equal(t, job2.UUID, jobFinished.JobUUID)
+	assert.Equal(t, JobStatusFailed, jobFinished.JobStatus)
+}
+
+func TestManagerCountsItemsOfJob(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+
+	job := manager.StartScrapingJob(workflow)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+
+	item := NewItem("person", "WF0", job.UUID, "")
+	item.SetField("name", "Faust")
+
+	taskResult := NewTaskResult(job.UUID, "", scheduledTask.UUID, true, nil)
+	taskResult.AddOutputItem("items", item)
+
+	manager.TaskResultsIn <- taskResult
+
+	gotItem := <-manager.ItemsOut
+	assert.Equal(t, item.UUID, gotItem.UUID)
+
+	assert.Nil(t, <-done)
+
+	jobFinished := <-manager.JobsFinishedOut
+	assert.Equal(t, job.UUID, jobFinished.JobUUID)
+	assert.Equal(t, 1, jobFinished.NItems)
+	assert.Equal(t, 1, jobFinished.Stats.NFinishedTasks)
+}
+
+func TestManagerEnforcesHostPolicies(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	pageTaskTempl := NewTaskTemplate("GetPage", false)
+	pageTaskTempl.AddActionTemplate(NewActionTemplate("HTTP1", "HTTPAction", map[string]interface{}{
+		"method": "GET",
+	}))
+	pageTaskTempl.ConnectInputToActionTemplate("url", "HTTP1", HTTPActionInputBaseURL)
+
+	workflow := &Workflow{
+		Name:          "WF0",
+		Version:       "v1",
+		TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true), *pageTaskTempl},
+		HostPolicies:  []HostPolicy{*NewHostPolicy("a.com", 0, 1, 0)},
+	}
+
+	job := manager.StartScrapingJob(workflow)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	initialTask := <-manager.ScheduledTasksOut
+
+	taskResult := NewTaskResult(job.UUID, "", initialTask.UUID, true, nil)
+	for _, urlStr := range []string{"https://a.com/1", "https://a.com/2"} {
+		urlChunk, _ := NewDataChunk(urlStr)
+		taskResult.AddOutputTaskPromise("promises", NewTaskPromise("GetPage", "WF0", job.UUID,
+			map[string]*DataChunk{"url": urlChunk}))
+	}
+
+	manager.TaskResultsIn <- taskResult
+
+	pageTask1 := <-manager.ScheduledTasksOut
+	assert.Equal(t, "a.com", pageTask1.TargetHost())
+
+	select {
+	case <-manager.ScheduledTasksOut:
+		assert.Fail(t, "Second task for the host was sent out while first one was in flight")
+	case <-time.After(3 * ManagerDelayedTasksCheckInterval):
+	}
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", pageTask1.UUID, true, nil)
+
+	pageTask2 := <-manager.ScheduledTasksOut
+	assert.NotEqual(t, pageTask1.UUID, pageTask2.UUID)
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", pageTask2.UUID, true, nil)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 3, job.NFinishedTasks)
+}
+
+// newTestLimitedWorkflow makes workflow where Task1 is the initial task and promises
+// Task2, which may keep promising itself.
+func newTestLimitedWorkflow(limits *JobLimits) *Workflow {
+	return &Workflow{
+		Name:          "WF0",
+		Version:       "v1",
+		TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true), *NewTaskTemplate("Task2", false)},
+		Limits:        limits,
+	}
+}
+
+func newTestPromiseResult(job *ManagerJob, scheduledTask *ScheduledTask, pages ...string) *TaskResult {
+	taskResult := NewTaskResult(job.UUID, "", scheduledTask.UUID, true, nil)
+
+	for _, page := range pages {
+		pageChunk, _ := NewDataChunk(page)
+		taskResult.AddOutputTaskPromise("promises", NewTaskPromise("Task2", "WF0", job.UUID,
+			map[string]*DataChunk{"page": pageChunk}))
+	}
+
+	return taskResult
+}
+
+func TestManagerEnforcesMaxDepth(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	job := manager.StartScrapingJob(newTestLimitedWorkflow(&JobLimits{MaxDepth: 1}))
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	initialTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, 0, initialTask.Depth)
+
+	manager.TaskResultsIn <- newTestPromiseResult(job, initialTask, "1")
+
+	childTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, 1, childTask.Depth)
+	assert.Equal(t, initialTask.UUID, childTask.ParentTaskUUID)
+
+	// Grandchild would be at depth 2, so it's not scheduled and the job is done.
+	manager.TaskResultsIn <- newTestPromiseResult(job, childTask, "2")
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 2, job.NScheduledTasks)
+	assert.Equal(t, JobStatusFinished, job.Status())
+	assert.Equal(t, JobStopReasonMaxDepth, job.StopReason)
+
+	jobFinished := <-manager.JobsFinishedOut
+	assert.Equal(t, JobStopReasonMaxDepth, jobFinished.StopReason)
+}
+
+func TestManagerEnforcesMaxScheduledTasks(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	job := manager.StartScrapingJob(newTestLimitedWorkflow(&JobLimits{MaxScheduledTasks: 3}))
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	initialTask := <-manager.ScheduledTasksOut
+
+	manager.TaskResultsIn <- newTestPromiseResult(job, initialTask, "1", "2", "3", "4")
+
+	childTask1 := <-manager.ScheduledTasksOut
+	childTask2 := <-manager.ScheduledTasksOut
+
+	// Further promises are dropped once the job is stopped.
+	manager.TaskResultsIn <- newTestPromiseResult(job, childTask1, "5")
+	manager.TaskResultsIn <- newTestPromiseResult(job, childTask2)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 3, job.NScheduledTasks)
+	assert.Equal(t, 3, job.NFinishedTasks)
+	assert.Equal(t, JobStopReasonMaxScheduledTasks, job.StopReason)
+}
+
+func TestManagerEnforcesMaxItems(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	job := manager.StartScrapingJob(newTestLimitedWorkflow(&JobLimits{MaxItems: 2}))
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	initialTask := <-manager.ScheduledTasksOut
+
+	taskResult := newTestPromiseResult(job, initialTask)
+	for i := 0; i < 3; i++ {
+		taskResult.AddOutputItem("items", NewItem("person", "WF0", job.UUID, ""))
+	}
+
+	manager.TaskResultsIn <- taskResult
+
+	<-manager.ItemsOut
+	<-manager.ItemsOut
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 2, job.NItems)
+	assert.Equal(t, 1, job.NScheduledTasks)
+	assert.Equal(t, JobStopReasonMaxItems, job.StopReason)
+}
+
+func TestManagerEnforcesMaxDuration(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	taskTempl := NewTaskTemplate("Task1", true)
+	taskTempl.RetryPolicy = NewRetryPolicy(3, time.Hour)
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*taskTempl},
+		Limits: &JobLimits{MaxDuration: 200 * time.Millisecond}}
+
+	job := manager.StartScrapingJob(workflow)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+
+	// Retry is put off for an hour, but the job is stopped long before that.
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", scheduledTask.UUID, false, errors.New("timeout"))
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 0, job.NPendingTasks)
+	assert.Equal(t, 1, job.NRetriedTasks)
+	assert.Equal(t, JobStopReasonMaxDuration, job.StopReason)
+}
+
+func TestManagerReschedulesTasksOfStaleWorkers(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+	manager.Workers.StaleAfter = 200 * time.Millisecond
+
+	job := manager.StartScrapingJob(newTestLimitedWorkflow(nil))
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+
+	heartbeat := NewWorkerHeartbeat("worker1", "host1")
+	heartbeat.ScheduledTaskUUID = scheduledTask.UUID
+	heartbeat.JobUUID = job.UUID
+
+	manager.WorkerHeartbeatsIn <- heartbeat
+
+	// Worker goes silent, so the task is sent out again.
+	resentTask := <-manager.ScheduledTasksOut
+	assert.Equal(t, scheduledTask.UUID, resentTask.UUID)
+
+	manager.TaskResultsIn <- NewTaskResult(job.UUID, "", resentTask.UUID, true, nil)
+
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, 1, job.NScheduledTasks)
+	assert.Equal(t, 1, job.NFinishedTasks)
+	assert.Equal(t, 0, job.NPendingTasks)
+}
+
+func TestManagerStopCheckpointsUnfinishedJobs(t *testing.T) {
+	manager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+
+	job := manager.StartScrapingJob(workflow)
+
+	done := make(chan error)
+	go func() {
+		done <- manager.Run()
+	}()
+
+	scheduledTask := <-manager.ScheduledTasksOut
+
+	manager.Stop()
+
+	assert.Nil(t, <-done)
+
+	var lastCheckpoint *ManagerCheckpoint
+	for len(manager.CheckpointsOut) > 0 {
+		lastCheckpoint = <-manager.CheckpointsOut
+	}
+
+	assert.NotNil(t, lastCheckpoint)
+	assert.Equal(t, job.UUID, lastCheckpoint.JobUUID)
+	assert.False(t, lastCheckpoint.IsDone())
+
+	// Another manager picks up where this one stopped.
+	otherManager := NewManager(NewDeduplicatorWithBackend(NewInMemoryDeduplicatorBackend()))
+	otherManager.ResumedTaskTimeout = ManagerDelayedTasksCheckInterval
+
+	_, err := otherManager.ResumeJob(lastCheckpoint)
+	assert.Nil(t, err)
+
+	go func() {
+		done <- otherManager.Run()
+	}()
+
+	resentTask := <-otherManager.ScheduledTasksOut
+	assert.Equal(t, scheduledTask.UUID, resentTask.UUID)
+
+	otherManager.TaskResultsIn <- NewTaskResult(job.UUID, "", resentTask.UUID, true, nil)
+
+	assert.Nil(t, <-done)
 }
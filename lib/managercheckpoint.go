@@ -0,0 +1,151 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrManagerCheckpointNotFound = errors.New("Manager checkpoint not found")
+var ErrManagerCheckpointJobDone = errors.New("Job was already done at checkpoint")
+
+// ManagerCheckpoint is a snapshot of ManagerJob that Manager periodically puts on the bus,
+// so that the job can be resumed by another Manager if this one goes away. Scheduled
+// tasks are kept by where they were at the time: sent out to workers, waiting for retry
+// backoff or host politeness limits, or held back while job was paused.
+type ManagerCheckpoint struct {
+	UUID            string
+	ManagerUUID     string
+	JobUUID         string
+	Workflow        *Workflow
+	NPendingTasks   int
+	NFinishedTasks  int
+	NFailedTasks    int
+	NRetriedTasks   int
+	NScheduledTasks int
+	NItems          int
+	Paused          bool
+	Cancelled       bool
+	Stopped         bool
+	StopReason      string
+	StartedAt       time.Time
+	InFlightTasks   []*ScheduledTask
+	DelayedTasks    []*ScheduledTask
+	HeldTasks       []*ScheduledTask
+	CreatedAt       time.Time
+}
+
+func NewManagerCheckpoint(managerUUID string, job *ManagerJob) *ManagerCheckpoint {
+	checkpoint := &ManagerCheckpoint{
+		UUID:            uuid.New().String(),
+		ManagerUUID:     managerUUID,
+		JobUUID:         job.UUID,
+		Workflow:        job.Workflow,
+		NPendingTasks:   job.NPendingTasks,
+		NFinishedTasks:  job.NFinishedTasks,
+		NFailedTasks:    job.NFailedTasks,
+		NRetriedTasks:   job.NRetriedTasks,
+		NScheduledTasks: job.NScheduledTasks,
+		NItems:          job.NItems,
+		Paused:          job.Paused,
+		Cancelled:       job.Cancelled,
+		Stopped:         job.stopped,
+		StopReason:      job.StopReason,
+		StartedAt:       job.startedAt,
+		InFlightTasks:   []*ScheduledTask{},
+		DelayedTasks:    []*ScheduledTask{},
+		HeldTasks:       []*ScheduledTask{},
+		CreatedAt:       time.Now(),
+	}
+
+	for _, scheduledTask := range job.inFlightTasks {
+		checkpoint.InFlightTasks = append(checkpoint.InFlightTasks, scheduledTask)
+	}
+
+	for _, dt := range job.delayedTasks {
+		checkpoint.DelayedTasks = append(checkpoint.DelayedTasks, dt.scheduledTask)
+	}
+
+	checkpoint.DelayedTasks = append(checkpoint.DelayedTasks, job.scheduler.QueuedTasks()...)
+
+	checkpoint.HeldTasks = append(checkpoint.HeldTasks, job.heldTasks...)
+
+	return checkpoint
+}
+
+func NewManagerCheckpointFromJSON(raw []byte) *ManagerCheckpoint {
+	checkpoint := &ManagerCheckpoint{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(checkpoint)
+	if err != nil {
+		return nil
+	}
+
+	return checkpoint
+}
+
+func (mc *ManagerCheckpoint) String() string {
+	return fmt.Sprintf("<ManagerCheckpoint %s ManagerUUID: %s, JobUUID: %s, NPendingTasks: %d, NFinishedTasks: %d, "+
+		"NFailedTasks: %d, InFlightTasks: %d, DelayedTasks: %d, HeldTasks: %d, CreatedAt: %v>", mc.UUID,
+		mc.ManagerUUID, mc.JobUUID, mc.NPendingTasks, mc.NFinishedTasks, mc.NFailedTasks, len(mc.InFlightTasks),
+		len(mc.DelayedTasks), len(mc.HeldTasks), mc.CreatedAt)
+}
+
+func (mc *ManagerCheckpoint) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(mc)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
+
+// IsDone tells if there was nothing left to do for the job when checkpoint was made.
+func (mc *ManagerCheckpoint) IsDone() bool {
+	return len(mc.InFlightTasks) == 0 && len(mc.DelayedTasks) == 0 && len(mc.HeldTasks) == 0
+}
+
+// NewManagerJob rebuilds the job from checkpoint. Delayed tasks are released right away,
+// as their backoff has most likely passed while job was not running anyway. Pending
+// task count is recomputed from the tasks themselves.
+func (mc *ManagerCheckpoint) NewManagerJob() *ManagerJob {
+	job := NewManagerJob(mc.Workflow, mc.JobUUID)
+
+	job.NFinishedTasks = mc.NFinishedTasks
+	job.NFailedTasks = mc.NFailedTasks
+	job.NRetriedTasks = mc.NRetriedTasks
+	job.NScheduledTasks = mc.NScheduledTasks
+	job.NItems = mc.NItems
+	job.Paused = mc.Paused
+	job.Cancelled = mc.Cancelled
+	job.stopped = mc.Stopped
+	job.StopReason = mc.StopReason
+	job.started = true
+	job.startedAt = mc.StartedAt
+
+	for _, scheduledTask := range mc.InFlightTasks {
+		job.inFlightTasks[scheduledTask.UUID] = scheduledTask
+		job.resumedTaskUUIDs[scheduledTask.UUID] = true
+	}
+
+	for _, scheduledTask := range mc.DelayedTasks {
+		job.delayScheduledTask(scheduledTask, 0)
+	}
+
+	job.heldTasks = append(job.heldTasks, mc.HeldTasks...)
+
+	job.NPendingTasks = len(job.inFlightTasks) + len(job.delayedTasks) + len(job.heldTasks)
+	job.resumedAt = time.Now()
+
+	return job
+}
@@ -0,0 +1,126 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCheckpointedJob() *ManagerJob {
+	workflow := &Workflow{Name: "WF0", Version: "v1",
+		TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true), *NewTaskTemplate("Task2", false)}}
+
+	job := NewManagerJob(workflow, "job1")
+	job.started = true
+
+	promise := NewTaskPromise("Task2", "WF0", "job1", map[string]*DataChunk{})
+
+	inFlightTask := NewScheduledTask(promise, &workflow.TaskTemplates[1], "WF0", "v1", "job1")
+	delayedTask := NewScheduledTask(promise, &workflow.TaskTemplates[1], "WF0", "v1", "job1")
+
+	job.inFlightTasks[inFlightTask.UUID] = inFlightTask
+	job.delayScheduledTask(delayedTask, 0)
+	job.NPendingTasks = 2
+	job.NFinishedTasks = 1
+	job.NRetriedTasks = 1
+	job.NScheduledTasks = 3
+	job.NItems = 4
+
+	return job
+}
+
+func TestNewManagerCheckpoint(t *testing.T) {
+	job := newTestCheckpointedJob()
+
+	checkpoint := NewManagerCheckpoint("manager1", job)
+
+	assert.NotNil(t, checkpoint)
+	assert.Equal(t, 36, len(checkpoint.UUID))
+	assert.Equal(t, "manager1", checkpoint.ManagerUUID)
+	assert.Equal(t, "job1", checkpoint.JobUUID)
+	assert.Equal(t, job.Workflow, checkpoint.Workflow)
+	assert.Equal(t, 2, checkpoint.NPendingTasks)
+	assert.Equal(t, 4, checkpoint.NItems)
+	assert.Equal(t, 1, len(checkpoint.InFlightTasks))
+	assert.Equal(t, 1, len(checkpoint.DelayedTasks))
+	assert.Equal(t, 0, len(checkpoint.HeldTasks))
+	assert.False(t, checkpoint.IsDone())
+}
+
+func TestNewManagerCheckpointKeepsQueuedTasks(t *testing.T) {
+	job := newTestCheckpointedJob()
+	job.Workflow.HostPolicies = []HostPolicy{*NewHostPolicy("a.com", 0, 1, 0)}
+	job.scheduler = NewPolitenessScheduler(job.Workflow.HostPolicies)
+
+	task1 := newTestScheduledTaskForURL("https://a.com/1")
+	task2 := newTestScheduledTaskForURL("https://a.com/2")
+
+	job.scheduler.Enqueue(task1)
+	job.scheduler.Enqueue(task2)
+	job.scheduler.TakeReady()
+
+	checkpoint := NewManagerCheckpoint("manager1", job)
+
+	assert.Equal(t, 2, len(checkpoint.DelayedTasks))
+	assert.Equal(t, task2, checkpoint.DelayedTasks[1])
+}
+
+func TestManagerCheckpointJSONAndBack(t *testing.T) {
+	checkpoint := NewManagerCheckpoint("manager1", newTestCheckpointedJob())
+
+	gotCheckpoint := NewManagerCheckpointFromJSON(checkpoint.EncodeToJSON())
+
+	assert.NotNil(t, gotCheckpoint)
+	assert.Equal(t, checkpoint.UUID, gotCheckpoint.UUID)
+	assert.Equal(t, "WF0", gotCheckpoint.Workflow.Name)
+	assert.Equal(t, 2, len(gotCheckpoint.Workflow.TaskTemplates))
+	assert.Equal(t, checkpoint.InFlightTasks[0].UUID, gotCheckpoint.InFlightTasks[0].UUID)
+	assert.Equal(t, checkpoint.DelayedTasks[0].UUID, gotCheckpoint.DelayedTasks[0].UUID)
+}
+
+func TestManagerCheckpointNewManagerJob(t *testing.T) {
+	originalJob := newTestCheckpointedJob()
+	originalJob.NPendingTasks = 5
+
+	checkpoint := NewManagerCheckpointFromJSON(NewManagerCheckpoint("manager1", originalJob).EncodeToJSON())
+
+	job := checkpoint.NewManagerJob()
+
+	assert.Equal(t, "job1", job.UUID)
+	assert.Equal(t, "WF0", job.Workflow.Name)
+	assert.True(t, job.started)
+	assert.Equal(t, JobStatusRunning, job.Status())
+
+	// Pending task count comes from the tasks checkpoint has.
+	assert.Equal(t, 2, job.NPendingTasks)
+	assert.Equal(t, 1, job.NFinishedTasks)
+	assert.Equal(t, 1, job.NRetriedTasks)
+	assert.Equal(t, 3, job.NScheduledTasks)
+	assert.Equal(t, 4, job.NItems)
+
+	assert.Equal(t, 1, len(job.inFlightTasks))
+	assert.Equal(t, 1, len(job.resumedTaskUUIDs))
+	assert.Equal(t, 1, len(job.takeDueDelayedTasks()))
+}
+
+func TestManagerCheckpointKeepsStopReason(t *testing.T) {
+	job := newTestCheckpointedJob()
+	job.startedAt = time.Now().Add(-time.Minute)
+	job.stop(JobStopReasonMaxItems)
+
+	checkpoint := NewManagerCheckpointFromJSON(NewManagerCheckpoint("manager1", job).EncodeToJSON())
+
+	resumedJob := checkpoint.NewManagerJob()
+
+	assert.True(t, resumedJob.stopped)
+	assert.Equal(t, JobStopReasonMaxItems, resumedJob.StopReason)
+	assert.True(t, job.startedAt.Equal(resumedJob.startedAt))
+}
+
+func TestManagerCheckpointIsDone(t *testing.T) {
+	job := NewManagerJob(&Workflow{Name: "WF0"}, "job1")
+	job.NFinishedTasks = 3
+
+	assert.True(t, NewManagerCheckpoint("manager1", job).IsDone())
+}
@@ -0,0 +1,249 @@
+package spsw
+
+import (
+	"fmt"
+	"time"
+)
+
+type delayedScheduledTask struct {
+	scheduledTask *ScheduledTask
+	notBefore     time.Time
+}
+
+// ManagerJob is a single scraping job that Manager works on, with its own workflow and
+// counters. Manager may be tracking many of them at once.
+type ManagerJob struct {
+	UUID            string
+	Workflow        *Workflow
+	NPendingTasks   int
+	NFinishedTasks  int
+	NFailedTasks    int
+	NRetriedTasks   int
+	NScheduledTasks int
+	NItems          int
+	Paused          bool
+	Cancelled       bool
+
+	// StopReason tells which of the job limits was hit, if any.
+	StopReason string
+
+	// RedrivenDeadLetterUUIDs lists dead letters whose re-driven tasks have finished successfully.
+	RedrivenDeadLetterUUIDs []string
+
+	started       bool
+	startedAt     time.Time
+	stopped       bool
+	inFlightTasks map[string]*ScheduledTask
+	delayedTasks  []*delayedScheduledTask
+	heldTasks     []*ScheduledTask
+	scheduler     *PolitenessScheduler
+
+	// Tasks that were in flight according to checkpoint the job was resumed from.
+	resumedAt        time.Time
+	resumedTaskUUIDs map[string]bool
+
+	// Tasks scheduled by previous manager after the checkpoint, taken over when their
+	// results came in. Resumed job is not done before adoptUntil, as such results may
+	// still be on their way.
+	adoptUntil       time.Time
+	adoptedTaskUUIDs map[string]bool
+
+	// Dead letter UUIDs by UUIDs of scheduled tasks (or their retries) re-driven from them.
+	deadLetterUUIDsByTaskUUID map[string]string
+}
+
+func NewManagerJob(workflow *Workflow, jobUUID string) *ManagerJob {
+	hostPolicies := []HostPolicy{}
+	if workflow != nil {
+		hostPolicies = workflow.HostPolicies
+	}
+
+	return &ManagerJob{
+		UUID:          jobUUID,
+		Workflow:      workflow,
+		inFlightTasks: map[string]*ScheduledTask{},
+		delayedTasks:  []*delayedScheduledTask{},
+		heldTasks:     []*ScheduledTask{},
+		scheduler:     NewPolitenessScheduler(hostPolicies),
+
+		resumedTaskUUIDs: map[string]bool{},
+		adoptedTaskUUIDs: map[string]bool{},
+
+		deadLetterUUIDsByTaskUUID: map[string]string{},
+	}
+}
+
+func (mj *ManagerJob) String() string {
+	return fmt.Sprintf("<ManagerJob %s Workflow: %s %s, NPendingTasks: %d, NFinishedTasks: %d, NRetriedTasks: %d, "+
+		"NFailedTasks: %d, NScheduledTasks: %d, Paused: %v, Cancelled: %v, StopReason: %s>", mj.UUID,
+		mj.Workflow.Name, mj.Workflow.Version, mj.NPendingTasks, mj.NFinishedTasks, mj.NRetriedTasks,
+		mj.NFailedTasks, mj.NScheduledTasks, mj.Paused, mj.Cancelled, mj.StopReason)
+}
+
+// IsDone tells if job has been started and has no more pending tasks.
+func (mj *ManagerJob) IsDone() bool {
+	return mj.started && mj.NPendingTasks == 0 && (mj.Cancelled || !mj.awaitingAdoption())
+}
+
+func (mj *ManagerJob) awaitingAdoption() bool {
+	return time.Now().Before(mj.adoptUntil)
+}
+
+func (mj *ManagerJob) Status() string {
+	if mj.Cancelled {
+		return JobStatusCancelled
+	}
+
+	if mj.Paused {
+		return JobStatusPaused
+	}
+
+	if !mj.started {
+		return JobStatusQueued
+	}
+
+	if mj.NPendingTasks > 0 || mj.awaitingAdoption() {
+		return JobStatusRunning
+	}
+
+	if mj.NFinishedTasks == 0 && mj.NFailedTasks > 0 {
+		return JobStatusFailed
+	}
+
+	return JobStatusFinished
+}
+
+func (mj *ManagerJob) Stats() JobStats {
+	return JobStats{
+		NPendingTasks:   mj.NPendingTasks,
+		NFinishedTasks:  mj.NFinishedTasks,
+		NFailedTasks:    mj.NFailedTasks,
+		NScheduledTasks: mj.NScheduledTasks,
+	}
+}
+
+func (mj *ManagerJob) cancel() {
+	// Tasks that are already running are left alone, but their results will be ignored.
+	mj.Cancelled = true
+	mj.NPendingTasks = 0
+	mj.inFlightTasks = map[string]*ScheduledTask{}
+	mj.delayedTasks = []*delayedScheduledTask{}
+	mj.heldTasks = []*ScheduledTask{}
+	mj.scheduler.Reset()
+}
+
+// noteLimitHit records that given limit kept some tasks from being scheduled, unless job
+// already has a stop reason.
+func (mj *ManagerJob) noteLimitHit(reason string) {
+	if mj.StopReason == "" {
+		mj.StopReason = reason
+	}
+}
+
+// stop makes job schedule no more tasks because of given limit. Tasks that are already
+// scheduled are left alone.
+func (mj *ManagerJob) stop(reason string) {
+	if mj.stopped {
+		return
+	}
+
+	mj.stopped = true
+	mj.StopReason = reason
+}
+
+// dropUnsentTasks forgets scheduled tasks that were not sent out to workers yet and returns
+// how many there were.
+func (mj *ManagerJob) dropUnsentTasks() int {
+	n := len(mj.delayedTasks) + len(mj.heldTasks) + mj.scheduler.Len()
+
+	mj.delayedTasks = []*delayedScheduledTask{}
+	mj.heldTasks = []*ScheduledTask{}
+	mj.scheduler.Reset()
+
+	mj.NPendingTasks -= n
+
+	return n
+}
+
+// resume unpauses the job and returns scheduled tasks that were held back meanwhile.
+func (mj *ManagerJob) resume() []*ScheduledTask {
+	heldTasks := mj.heldTasks
+
+	mj.Paused = false
+	mj.heldTasks = []*ScheduledTask{}
+
+	return heldTasks
+}
+
+func (mj *ManagerJob) delayScheduledTask(scheduledTask *ScheduledTask, delay time.Duration) {
+	mj.delayedTasks = append(mj.delayedTasks, &delayedScheduledTask{
+		scheduledTask: scheduledTask,
+		notBefore:     time.Now().Add(delay),
+	})
+}
+
+// takeStaleResumedTasks returns tasks that were in flight when job was resumed from
+// checkpoint and still have no result after timeout. Their results may have been received
+// by previous manager after the checkpoint was made, so they need to be sent out again.
+func (mj *ManagerJob) takeStaleResumedTasks(timeout time.Duration) []*ScheduledTask {
+	if len(mj.resumedTaskUUIDs) == 0 || time.Since(mj.resumedAt) < timeout {
+		return nil
+	}
+
+	stale := []*ScheduledTask{}
+
+	for scheduledTaskUUID := range mj.resumedTaskUUIDs {
+		scheduledTask, found := mj.inFlightTasks[scheduledTaskUUID]
+		if !found {
+			continue
+		}
+
+		delete(mj.inFlightTasks, scheduledTaskUUID)
+		stale = append(stale, scheduledTask)
+	}
+
+	mj.resumedTaskUUIDs = map[string]bool{}
+
+	return stale
+}
+
+// adoptScheduledTask takes over task that previous manager scheduled after making the
+// checkpoint job was resumed from, so that its result is handled rather than lost. It
+// returns false if job was not resumed or the task was already adopted.
+func (mj *ManagerJob) adoptScheduledTask(scheduledTask *ScheduledTask) bool {
+	if mj.resumedAt.IsZero() || mj.Cancelled || scheduledTask == nil || scheduledTask.JobUUID != mj.UUID {
+		return false
+	}
+
+	if mj.adoptedTaskUUIDs[scheduledTask.UUID] {
+		return false
+	}
+
+	mj.adoptedTaskUUIDs[scheduledTask.UUID] = true
+	mj.inFlightTasks[scheduledTask.UUID] = scheduledTask
+	mj.NScheduledTasks++
+	mj.NPendingTasks++
+
+	return true
+}
+
+// takeDueDelayedTasks removes delayed tasks that are due by now and returns them.
+func (mj *ManagerJob) takeDueDelayedTasks() []*ScheduledTask {
+	now := time.Now()
+
+	due := []*ScheduledTask{}
+	stillDelayed := []*delayedScheduledTask{}
+
+	for _, dt := range mj.delayedTasks {
+		if now.Before(dt.notBefore) {
+			stillDelayed = append(stillDelayed, dt)
+			continue
+		}
+
+		due = append(due, dt.scheduledTask)
+	}
+
+	mj.delayedTasks = stillDelayed
+
+	return due
+}
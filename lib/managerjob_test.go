@@ -0,0 +1,115 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewManagerJob(t *testing.T) {
+	workflow := &Workflow{Name: "WF0", Version: "v1"}
+
+	job := NewManagerJob(workflow, "job1")
+
+	assert.Equal(t, "job1", job.UUID)
+	assert.Equal(t, workflow, job.Workflow)
+	assert.False(t, job.IsDone())
+	assert.Equal(t, JobStatusQueued, job.Status())
+}
+
+func TestManagerJobStatus(t *testing.T) {
+	job := NewManagerJob(&Workflow{}, "job1")
+	job.started = true
+	job.NPendingTasks = 1
+
+	assert.Equal(t, JobStatusRunning, job.Status())
+	assert.False(t, job.IsDone())
+
+	job.NPendingTasks = 0
+	job.NFailedTasks = 1
+
+	assert.Equal(t, JobStatusFailed, job.Status())
+	assert.True(t, job.IsDone())
+
+	job.NFinishedTasks = 1
+
+	assert.Equal(t, JobStatusFinished, job.Status())
+	assert.Equal(t, JobStats{NFinishedTasks: 1, NFailedTasks: 1}, job.Stats())
+
+	job.Paused = true
+
+	assert.Equal(t, JobStatusPaused, job.Status())
+
+	job.cancel()
+
+	assert.Equal(t, JobStatusCancelled, job.Status())
+}
+
+func TestManagerJobResume(t *testing.T) {
+	job := NewManagerJob(&Workflow{}, "job1")
+	job.Paused = true
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	heldTask := NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", "job1")
+
+	job.heldTasks = append(job.heldTasks, heldTask)
+
+	assert.Equal(t, []*ScheduledTask{heldTask}, job.resume())
+	assert.False(t, job.Paused)
+	assert.Equal(t, 0, len(job.heldTasks))
+}
+
+func TestManagerJobTakeDueDelayedTasks(t *testing.T) {
+	job := NewManagerJob(&Workflow{}, "job1")
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	dueTask := NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", "job1")
+	laterTask := NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", "job1")
+
+	job.delayScheduledTask(dueTask, 0)
+	job.delayScheduledTask(laterTask, time.Hour)
+
+	assert.Equal(t, []*ScheduledTask{dueTask}, job.takeDueDelayedTasks())
+	assert.Equal(t, 1, len(job.delayedTasks))
+	assert.Equal(t, 0, len(job.takeDueDelayedTasks()))
+}
+
+func TestManagerJobStop(t *testing.T) {
+	job := NewManagerJob(&Workflow{}, "job1")
+
+	job.noteLimitHit(JobStopReasonMaxDepth)
+	assert.Equal(t, JobStopReasonMaxDepth, job.StopReason)
+	assert.False(t, job.stopped)
+
+	job.stop(JobStopReasonMaxItems)
+	assert.Equal(t, JobStopReasonMaxItems, job.StopReason)
+	assert.True(t, job.stopped)
+
+	job.stop(JobStopReasonMaxDuration)
+	job.noteLimitHit(JobStopReasonMaxDepth)
+	assert.Equal(t, JobStopReasonMaxItems, job.StopReason)
+}
+
+func TestManagerJobDropUnsentTasks(t *testing.T) {
+	job := NewManagerJob(&Workflow{}, "job1")
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	newTask := func() *ScheduledTask {
+		return NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", "job1")
+	}
+
+	inFlightTask := newTask()
+	job.inFlightTasks[inFlightTask.UUID] = inFlightTask
+	job.delayScheduledTask(newTask(), time.Hour)
+	job.heldTasks = append(job.heldTasks, newTask())
+	job.scheduler.Enqueue(newTask())
+	job.NPendingTasks = 4
+
+	assert.Equal(t, 3, job.dropUnsentTasks())
+	assert.Equal(t, 1, job.NPendingTasks)
+	assert.Equal(t, 1, len(job.inFlightTasks))
+	assert.Equal(t, 0, len(job.delayedTasks))
+	assert.Equal(t, 0, len(job.heldTasks))
+	assert.Equal(t, 0, job.scheduler.Len())
+}
@@ -0,0 +1,66 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ManagerReport is periodically sent by Manager to Master. Report with empty JobUUID
+// means the manager is idle and merely registers itself.
+type ManagerReport struct {
+	UUID            string
+	ManagerUUID     string
+	JobUUID         string
+	WorkflowName    string
+	WorkflowVersion string
+	JobStatus       string
+	Stats           JobStats
+	StopReason      string
+	CreatedAt       time.Time
+}
+
+func NewManagerReport(managerUUID string, jobUUID string, jobStatus string, stats JobStats) *ManagerReport {
+	return &ManagerReport{
+		UUID:        uuid.New().String(),
+		ManagerUUID: managerUUID,
+		JobUUID:     jobUUID,
+		JobStatus:   jobStatus,
+		Stats:       stats,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func NewManagerReportFromJSON(raw []byte) *ManagerReport {
+	report := &ManagerReport{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(report)
+	if err != nil {
+		return nil
+	}
+
+	return report
+}
+
+func (mr *ManagerReport) String() string {
+	return fmt.Sprintf("<ManagerReport %s ManagerUUID: %s, JobUUID: %s, JobStatus: %s, Stats: %+v>", mr.UUID,
+		mr.ManagerUUID, mr.JobUUID, mr.JobStatus, mr.Stats)
+}
+
+func (mr *ManagerReport) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(mr)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
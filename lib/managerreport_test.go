@@ -0,0 +1,34 @@
+package spsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewManagerReport(t *testing.T) {
+	stats := JobStats{NPendingTasks: 1, NFinishedTasks: 2, NFailedTasks: 3, NScheduledTasks: 6}
+
+	report := NewManagerReport("manager1", "job1", JobStatusRunning, stats)
+
+	assert.NotNil(t, report)
+	assert.Equal(t, 36, len(report.UUID))
+	assert.Equal(t, "manager1", report.ManagerUUID)
+	assert.Equal(t, "job1", report.JobUUID)
+	assert.Equal(t, JobStatusRunning, report.JobStatus)
+	assert.Equal(t, stats, report.Stats)
+}
+
+func TestManagerReportJSONAndBack(t *testing.T) {
+	stats := JobStats{NPendingTasks: 1, NFinishedTasks: 2, NFailedTasks: 3, NScheduledTasks: 6}
+
+	report := NewManagerReport("manager1", "job1", JobStatusRunning, stats)
+	report.WorkflowName = "WF0"
+
+	gotReport := NewManagerReportFromJSON(report.EncodeToJSON())
+
+	assert.NotNil(t, gotReport)
+	assert.Equal(t, report.UUID, gotReport.UUID)
+	assert.Equal(t, "WF0", gotReport.WorkflowName)
+	assert.Equal(t, stats, gotReport.Stats)
+}
@@ -1,27 +1,531 @@
 package spsw
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var ErrJobNotFound = errors.New("Job not found")
+var ErrJobAlreadyDone = errors.New("Job is already done")
+
+// InvalidWorkflowError is returned when job is to be created from workflow that fails
+// validation.
+type InvalidWorkflowError struct {
+	Err error
+}
+
+func (e *InvalidWorkflowError) Error() string {
+	return e.Err.Error()
+}
+
+const MasterAPIPathPrefix = "/api/"
+
+// Master keeps track of scraping jobs and managers running them. Jobs are handed out
+// to managers through SpiderBus, and managers report their progress back the same way.
+// Master exposes HTTP/JSON API for job management:
+//
+// * POST /api/jobs - create job from workflow YAML in request body.
+// * GET /api/jobs[?status=<status>] - list jobs, optionally with given status only.
+// * GET /api/jobs/<jobUUID> - get job with workflow and statistics.
+// * POST /api/jobs/<jobUUID>/pause - hold back new tasks of the job.
+// * POST /api/jobs/<jobUUID>/resume - resume paused job.
+// * POST /api/jobs/<jobUUID>/cancel - cancel job.
+// * GET /api/managers - list managers that have registered.
+// * GET /api/workers - list workers that have sent heartbeats, flagging ones gone silent.
+// * POST /api/workflows/validate - check workflow YAML in request body without creating job.
+// * GET /api/schedules - list job schedules with their next run times.
+// * GET /api/schedules/<name>/runs - list history of scheduled runs.
+//
+// If Store is set, jobs and their workflows are persisted there, so that job history
+// survives restarts. If Scheduler is set, it starts jobs on their schedules.
 type Master struct {
-	UUID string
+	UUID               string
+	JobsOut            chan *Job
+	JobControlsOut     chan *JobControl
+	ManagerReportsIn   chan *ManagerReport
+	WorkerHeartbeatsIn chan *WorkerHeartbeat
+	Workers            *WorkerRegistry
+	Store              *MasterStore
+	Scheduler          *JobScheduler
+
+	mutex    sync.Mutex
+	jobs     map[string]*Job
+	jobUUIDs []string
+	managers map[string]*ManagerReport
+}
+
+func NewMaster() *Master {
+	return &Master{
+		UUID:               uuid.New().String(),
+		JobsOut:            make(chan *Job),
+		JobControlsOut:     make(chan *JobControl),
+		ManagerReportsIn:   make(chan *ManagerReport),
+		WorkerHeartbeatsIn: make(chan *WorkerHeartbeat),
+		Workers:            NewWorkerRegistry(),
+		jobs:               map[string]*Job{},
+		jobUUIDs:           []string{},
+		managers:           map[string]*ManagerReport{},
+	}
+}
+
+// NewMasterWithStore makes Master that persists jobs in given store, picking up jobs
+// that are already there.
+func NewMasterWithStore(store *MasterStore) (*Master, error) {
+	m := NewMaster()
+	m.Store = store
+
+	jobs, err := store.ListJobs(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		m.jobs[job.UUID] = job
+		m.jobUUIDs = append(m.jobUUIDs, job.UUID)
+	}
+
+	log.Info(fmt.Sprintf("Master %s loaded %d jobs from store %v", m.UUID, len(jobs), store))
+
+	return m, nil
+}
+
+func (m *Master) String() string {
+	return fmt.Sprintf("<Master %s>", m.UUID)
+}
+
+func (m *Master) CreateJob(workflow *Workflow) (*Job, error) {
+	return m.createJob(workflow, "")
+}
+
+func (m *Master) createJob(workflow *Workflow, scheduleName string) (*Job, error) {
+	_, err := workflow.Validate()
+	if err != nil {
+		return nil, &InvalidWorkflowError{Err: err}
+	}
+
+	job := NewJob(workflow)
+	job.ScheduleName = scheduleName
+
+	if m.Store != nil {
+		err = m.Store.CreateJob(job)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.mutex.Lock()
+	m.jobs[job.UUID] = job
+	m.jobUUIDs = append(m.jobUUIDs, job.UUID)
+	jobCopy := *job
+	jobOut := *job
+	m.mutex.Unlock()
+
+	log.Info(fmt.Sprintf("Master %s created job %v", m.UUID, &jobCopy))
+
+	// Job kept by Master is updated by manager reports, so whoever takes it off JobsOut
+	// gets a copy of its own.
+	m.JobsOut <- &jobOut
+
+	return &jobCopy, nil
+}
+
+// ListJobs returns jobs in order of creation, optionally with given status only. Listed
+// jobs come without their workflows.
+func (m *Master) ListJobs(status string) []*Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	jobs := []*Job{}
+
+	for _, jobUUID := range m.jobUUIDs {
+		job := *m.jobs[jobUUID]
+
+		if status != "" && job.Status != status {
+			continue
+		}
+
+		job.Workflow = nil
+		jobs = append(jobs, &job)
+	}
+
+	return jobs
+}
+
+func (m *Master) GetJob(jobUUID string) (*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, found := m.jobs[jobUUID]
+	if !found {
+		return nil, ErrJobNotFound
+	}
+
+	jobCopy := *job
+
+	// Jobs loaded from store come without workflows.
+	if jobCopy.Workflow == nil && m.Store != nil {
+		workflow, err := m.Store.LoadWorkflow(jobCopy.WorkflowName, jobCopy.WorkflowVersion)
+		if err == nil {
+			jobCopy.Workflow = workflow
+		}
+	}
+
+	return &jobCopy, nil
+}
+
+// lastScheduledJob returns the most recent job started for given schedule, if any.
+func (m *Master) lastScheduledJob(scheduleName string) *Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := len(m.jobUUIDs) - 1; i >= 0; i-- {
+		job := m.jobs[m.jobUUIDs[i]]
+
+		if job.ScheduleName == scheduleName {
+			jobCopy := *job
+			return &jobCopy
+		}
+	}
+
+	return nil
+}
+
+// controlJob broadcasts job control to managers (and workers), updating job status right
+// away so that API reflects it before managers report back.
+func (m *Master) controlJob(jobUUID string, action string) (*Job, error) {
+	m.mutex.Lock()
+
+	job, found := m.jobs[jobUUID]
+	if !found {
+		m.mutex.Unlock()
+		return nil, ErrJobNotFound
+	}
+
+	if job.IsDone() {
+		m.mutex.Unlock()
+		return nil, ErrJobAlreadyDone
+	}
+
+	switch action {
+	case JobControlActionCancel:
+		job.Status = JobStatusCancelled
+	case JobControlActionPause:
+		job.Status = JobStatusPaused
+	case JobControlActionResume:
+		if job.Status == JobStatusPaused && job.ManagerUUID != "" {
+			job.Status = JobStatusRunning
+		} else if job.Status == JobStatusPaused {
+			job.Status = JobStatusQueued
+		}
+	}
+
+	job.UpdatedAt = time.Now()
+	m.updateStoredJob(job)
+	jobCopy := *job
+
+	m.mutex.Unlock()
+
+	log.Info(fmt.Sprintf("Master %s sending %s for job %s", m.UUID, action, jobUUID))
+
+	m.JobControlsOut <- NewJobControl(jobUUID, action)
+
+	return &jobCopy, nil
+}
+
+func (m *Master) CancelJob(jobUUID string) (*Job, error) {
+	return m.controlJob(jobUUID, JobControlActionCancel)
+}
+
+func (m *Master) PauseJob(jobUUID string) (*Job, error) {
+	return m.controlJob(jobUUID, JobControlActionPause)
+}
+
+func (m *Master) ResumeJob(jobUUID string) (*Job, error) {
+	return m.controlJob(jobUUID, JobControlActionResume)
+}
+
+// ListManagers returns the latest report from each manager that has registered.
+func (m *Master) ListManagers() []*ManagerReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	reports := []*ManagerReport{}
+
+	for _, report := range m.managers {
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+func (m *Master) updateStoredJob(job *Job) {
+	if m.Store == nil {
+		return
+	}
+
+	err := m.Store.UpdateJob(job)
+	if err != nil {
+		log.Error(fmt.Sprintf("Master %s failed to update job %s in store: %v", m.UUID, job.UUID, err))
+	}
 }
 
-// TODO: implement REST API for workflow/job management
-// * Create a job from workflow.
-// * List running/finished/failed/all jobs.
-// * Cancel a running job.
-// * Get job with workflow and statistics.
+func (m *Master) handleManagerReport(report *ManagerReport) {
+	if report == nil {
+		return
+	}
+
+	m.mutex.Lock()
+
+	m.managers[report.ManagerUUID] = report
+
+	if report.JobUUID == "" {
+		m.mutex.Unlock()
+		return
+	}
+
+	job, found := m.jobs[report.JobUUID]
+	if !found {
+		// Job was started by the manager itself, not through Master API.
+		job = &Job{
+			UUID:            report.JobUUID,
+			WorkflowName:    report.WorkflowName,
+			WorkflowVersion: report.WorkflowVersion,
+			CreatedAt:       report.CreatedAt,
+		}
+
+		m.jobs[job.UUID] = job
+		m.jobUUIDs = append(m.jobUUIDs, job.UUID)
+	}
+
+	job.ManagerUUID = report.ManagerUUID
+	job.Stats = report.Stats
+	job.StopReason = report.StopReason
+	job.UpdatedAt = report.CreatedAt
+
+	if job.Status != JobStatusCancelled {
+		job.Status = report.JobStatus
+	}
+
+	// Store is written without holding the mutex, so that API is not held up by it.
+	jobCopy := *job
+
+	m.mutex.Unlock()
+
+	if !found && m.Store != nil {
+		err := m.Store.CreateJob(&jobCopy)
+		if err != nil {
+			log.Error(fmt.Sprintf("Master %s failed to save job %s in store: %v", m.UUID, jobCopy.UUID, err))
+		}
+
+		return
+	}
+
+	m.updateStoredJob(&jobCopy)
+}
 
 func (m *Master) Run() error {
-	//TODO: implement this
+	log.Info(fmt.Sprintf("Starting runloop for master %s", m.UUID))
 
 	for {
-		// select across channels from managers
-		// Tell managers about new tasks.
-		// Keep state with statistics.
-		// Make initial task promises when launching Workflow.
-		// Get items from manager(s) and pass them to exporter.
+		select {
+		case report, ok := <-m.ManagerReportsIn:
+			if !ok {
+				return nil
+			}
 
-		break
+			m.handleManagerReport(report)
+		case heartbeat := <-m.WorkerHeartbeatsIn:
+			m.Workers.Update(heartbeat)
+		}
 	}
+}
 
-	return nil
+func writeJSONResponse(w http.ResponseWriter, statusCode int, x interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(x)
+	if err != nil {
+		log.Error(fmt.Sprintf("Writing JSON response failed with error: %v", err))
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSONResponse(w, statusCode, map[string]string{"Error": err.Error()})
+}
+
+func readWorkflowFromRequest(r *http.Request) (*Workflow, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML, so workflow may be given either way.
+	workflow := &Workflow{}
+
+	err = yaml.Unmarshal(body, workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	return workflow, nil
+}
+
+func (m *Master) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	workflow, err := readWorkflowFromRequest(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := m.CreateJob(workflow)
+	if _, invalid := err.(*InvalidWorkflowError); invalid {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, job)
+}
+
+func (m *Master) handleValidateWorkflow(w http.ResponseWriter, r *http.Request) {
+	workflow, err := readWorkflowFromRequest(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_, err = workflow.Validate()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"Valid": true})
+}
+
+func (m *Master) handleJobRequest(w http.ResponseWriter, r *http.Request, jobUUID string, action string) {
+	var job *Job
+	var err error
+
+	jobControlAction, isJobControl := JobControlActionsByName[action]
+
+	if action == "" && r.Method == http.MethodGet {
+		job, err = m.GetJob(jobUUID)
+	} else if isJobControl && r.Method == http.MethodPost {
+		job, err = m.controlJob(jobUUID, jobControlAction)
+	} else {
+		writeJSONError(w, http.StatusNotFound, errors.New("Not found"))
+		return
+	}
+
+	if err == ErrJobNotFound {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	} else if err == ErrJobAlreadyDone {
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, job)
+}
+
+func (m *Master) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	if m.Scheduler == nil {
+		writeJSONResponse(w, http.StatusOK, []*JobScheduleStatus{})
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, m.Scheduler.ListSchedules())
+}
+
+func (m *Master) handleListScheduledRuns(w http.ResponseWriter, r *http.Request, scheduleName string) {
+	if m.Scheduler == nil {
+		writeJSONError(w, http.StatusNotFound, ErrScheduleNotFound)
+		return
+	}
+
+	runs, err := m.Scheduler.ListRuns(scheduleName)
+	if err == ErrScheduleNotFound {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, runs)
+}
+
+func (m *Master) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debug(fmt.Sprintf("Master %s got request %s %s", m.UUID, r.Method, r.URL.Path))
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, MasterAPIPathPrefix), "/")
+	parts := strings.Split(path, "/")
+
+	if !strings.HasPrefix(r.URL.Path, MasterAPIPathPrefix) || len(parts) > 3 {
+		writeJSONError(w, http.StatusNotFound, errors.New("Not found"))
+		return
+	}
+
+	switch parts[0] {
+	case "jobs":
+		if len(parts) == 1 && r.Method == http.MethodGet {
+			writeJSONResponse(w, http.StatusOK, m.ListJobs(r.URL.Query().Get("status")))
+		} else if len(parts) == 1 && r.Method == http.MethodPost {
+			m.handleCreateJob(w, r)
+		} else if len(parts) == 2 {
+			m.handleJobRequest(w, r, parts[1], "")
+		} else if len(parts) == 3 {
+			m.handleJobRequest(w, r, parts[1], parts[2])
+		} else {
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("Method not allowed"))
+		}
+	case "managers":
+		if len(parts) == 1 && r.Method == http.MethodGet {
+			writeJSONResponse(w, http.StatusOK, m.ListManagers())
+		} else {
+			writeJSONError(w, http.StatusNotFound, errors.New("Not found"))
+		}
+	case "workers":
+		if len(parts) == 1 && r.Method == http.MethodGet {
+			writeJSONResponse(w, http.StatusOK, m.Workers.ListWorkers())
+		} else {
+			writeJSONError(w, http.StatusNotFound, errors.New("Not found"))
+		}
+	case "workflows":
+		if len(parts) == 2 && parts[1] == "validate" && r.Method == http.MethodPost {
+			m.handleValidateWorkflow(w, r)
+		} else {
+			writeJSONError(w, http.StatusNotFound, errors.New("Not found"))
+		}
+	case "schedules":
+		if len(parts) == 1 && r.Method == http.MethodGet {
+			m.handleListSchedules(w, r)
+		} else if len(parts) == 3 && parts[2] == "runs" && r.Method == http.MethodGet {
+			m.handleListScheduledRuns(w, r, parts[1])
+		} else {
+			writeJSONError(w, http.StatusNotFound, errors.New("Not found"))
+		}
+	default:
+		writeJSONError(w, http.StatusNotFound, errors.New("Not found"))
+	}
 }
@@ -0,0 +1,411 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func newTestMasterWorkflow() *Workflow {
+	taskTempl := NewTaskTemplate("Launch", true)
+
+	taskTempl.AddActionTemplate(NewActionTemplate("ConstName", "ConstAction",
+		map[string]interface{}{"c": "Faust"}))
+	taskTempl.AddActionTemplate(NewActionTemplate("JoinFields", "FieldJoinAction",
+		map[string]interface{}{"inputNames": []string{"name"}, "itemName": "person"}))
+
+	taskTempl.ConnectActionTemplates("ConstName", ConstActionOutput, "JoinFields", "name")
+	taskTempl.ConnectOutputToActionTemplate("JoinFields", FieldJoinActionOutputItem, "items")
+
+	workflow := NewWorkflow("testWorkflow", "v0.0.1")
+	workflow.AddTaskTemplate(taskTempl)
+
+	return workflow
+}
+
+// drainMaster consumes whatever Master sends out, as SpiderBusAdapter would.
+func drainMaster(master *Master) {
+	go func() {
+		for {
+			select {
+			case <-master.JobsOut:
+			case <-master.JobControlsOut:
+			}
+		}
+	}()
+}
+
+func TestNewMaster(t *testing.T) {
+	master := NewMaster()
+
+	assert.NotNil(t, master)
+	assert.Equal(t, 36, len(master.UUID))
+	assert.NotNil(t, master.JobsOut)
+	assert.NotNil(t, master.JobControlsOut)
+	assert.NotNil(t, master.ManagerReportsIn)
+	assert.Equal(t, 0, len(master.ListJobs("")))
+}
+
+func TestMasterCreateJob(t *testing.T) {
+	master := NewMaster()
+
+	go func() {
+		job, err := master.CreateJob(newTestMasterWorkflow())
+		assert.Nil(t, err)
+		assert.Equal(t, JobStatusQueued, job.Status)
+	}()
+
+	job := <-master.JobsOut
+	assert.Equal(t, "testWorkflow", job.Workflow.Name)
+
+	// Job handed out is not changed by reports coming in for it later.
+	master.handleManagerReport(NewManagerReport("manager1", job.UUID, JobStatusRunning, JobStats{}))
+	assert.Equal(t, JobStatusQueued, job.Status)
+	assert.Equal(t, "", job.ManagerUUID)
+
+	gotJob, err := master.GetJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.UUID, gotJob.UUID)
+	assert.NotNil(t, gotJob.Workflow)
+
+	jobs := master.ListJobs("")
+	assert.Equal(t, 1, len(jobs))
+	assert.Nil(t, jobs[0].Workflow)
+
+	_, err = master.GetJob("no-such-job")
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+func TestMasterCreateJobInvalidWorkflow(t *testing.T) {
+	master := NewMaster()
+
+	workflow := newTestMasterWorkflow()
+	workflow.TaskTemplates[0].ActionTemplates[0].StructName = "NoSuchAction"
+
+	job, err := master.CreateJob(workflow)
+	assert.Nil(t, job)
+	assert.IsType(t, &InvalidWorkflowError{}, err)
+	assert.Equal(t, 0, len(master.ListJobs("")))
+}
+
+func TestMasterCancelJob(t *testing.T) {
+	master := NewMaster()
+	drainMaster(master)
+
+	job, err := master.CreateJob(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	cancelledJob, err := master.CancelJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusCancelled, cancelledJob.Status)
+
+	_, err = master.CancelJob(job.UUID)
+	assert.Equal(t, ErrJobAlreadyDone, err)
+
+	_, err = master.CancelJob("no-such-job")
+	assert.Equal(t, ErrJobNotFound, err)
+
+	// Cancelled job stays cancelled even if manager reports otherwise.
+	master.handleManagerReport(NewManagerReport("manager1", job.UUID, JobStatusRunning, JobStats{NPendingTasks: 1}))
+
+	gotJob, _ := master.GetJob(job.UUID)
+	assert.Equal(t, JobStatusCancelled, gotJob.Status)
+	assert.Equal(t, "manager1", gotJob.ManagerUUID)
+}
+
+func TestMasterPauseAndResumeJob(t *testing.T) {
+	master := NewMaster()
+	drainMaster(master)
+
+	job, err := master.CreateJob(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	pausedJob, err := master.PauseJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusPaused, pausedJob.Status)
+
+	// Job that no manager has picked up yet goes back to the queue.
+	resumedJob, err := master.ResumeJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusQueued, resumedJob.Status)
+
+	master.handleManagerReport(NewManagerReport("manager1", job.UUID, JobStatusRunning, JobStats{NPendingTasks: 1}))
+
+	_, err = master.PauseJob(job.UUID)
+	assert.Nil(t, err)
+
+	resumedJob, err = master.ResumeJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusRunning, resumedJob.Status)
+
+	_, err = master.PauseJob("no-such-job")
+	assert.Equal(t, ErrJobNotFound, err)
+
+	master.handleManagerReport(NewManagerReport("manager1", job.UUID, JobStatusFinished, JobStats{}))
+
+	_, err = master.PauseJob(job.UUID)
+	assert.Equal(t, ErrJobAlreadyDone, err)
+}
+
+func TestMasterHandleManagerReport(t *testing.T) {
+	master := NewMaster()
+	drainMaster(master)
+
+	job, _ := master.CreateJob(newTestMasterWorkflow())
+
+	master.handleManagerReport(NewManagerReport("manager1", "", "", JobStats{}))
+	assert.Equal(t, 1, len(master.ListManagers()))
+
+	stats := JobStats{NPendingTasks: 0, NFinishedTasks: 3, NFailedTasks: 1, NScheduledTasks: 4}
+	finalReport := NewManagerReport("manager1", job.UUID, JobStatusFinished, stats)
+	finalReport.StopReason = JobStopReasonMaxItems
+	master.handleManagerReport(finalReport)
+
+	gotJob, _ := master.GetJob(job.UUID)
+	assert.Equal(t, JobStatusFinished, gotJob.Status)
+	assert.Equal(t, stats, gotJob.Stats)
+	assert.Equal(t, "manager1", gotJob.ManagerUUID)
+	assert.Equal(t, JobStopReasonMaxItems, gotJob.StopReason)
+
+	// Jobs that managers were started with directly show up as well.
+	report := NewManagerReport("manager2", "job2", JobStatusRunning, JobStats{NPendingTasks: 1})
+	report.WorkflowName = "otherWorkflow"
+	master.handleManagerReport(report)
+
+	assert.Equal(t, 2, len(master.ListManagers()))
+	assert.Equal(t, 2, len(master.ListJobs("")))
+
+	runningJobs := master.ListJobs(JobStatusRunning)
+	assert.Equal(t, 1, len(runningJobs))
+	assert.Equal(t, "job2", runningJobs[0].UUID)
+	assert.Equal(t, "otherWorkflow", runningJobs[0].WorkflowName)
+}
+
+func TestMasterHTTPAPI(t *testing.T) {
+	master := NewMaster()
+	drainMaster(master)
+
+	yamlBytes, err := yaml.Marshal(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", bytes.NewReader(yamlBytes))
+	rec := httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	job := &Job{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), job))
+	assert.Equal(t, JobStatusQueued, job.Status)
+	assert.Equal(t, "testWorkflow", job.WorkflowName)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs", bytes.NewReader([]byte("Name: [")))
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	invalidWorkflow := newTestMasterWorkflow()
+	invalidWorkflow.TaskTemplates[0].ActionTemplates[0].StructName = "NoSuchAction"
+
+	invalidYAMLBytes, err := yaml.Marshal(invalidWorkflow)
+	assert.Nil(t, err)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs", bytes.NewReader(invalidYAMLBytes))
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/jobs?status=queued", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	jobs := []*Job{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &jobs))
+	assert.Equal(t, 1, len(jobs))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.UUID, nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	gotJob := &Job{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), gotJob))
+	assert.Equal(t, job.UUID, gotJob.UUID)
+	assert.Equal(t, "Launch", gotJob.Workflow.TaskTemplates[0].TaskName)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.UUID+"/pause", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	gotJob = &Job{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), gotJob))
+	assert.Equal(t, JobStatusPaused, gotJob.Status)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.UUID+"/resume", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.UUID+"/explode", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.UUID+"/cancel", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.UUID+"/cancel", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/jobs/no-such-job", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/managers", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/workflows/validate", bytes.NewReader(yamlBytes))
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/nothing", nil)
+	rec = httptest.NewRecorder()
+	master.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestMasterWithStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteMasterStoreBackend(dir + "/master.db")
+	assert.Nil(t, err)
+
+	store := NewMasterStore()
+	store.AddBackend(backend)
+
+	defer store.Close()
+
+	master, err := NewMasterWithStore(store)
+	assert.Nil(t, err)
+	drainMaster(master)
+
+	job, err := master.CreateJob(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	stats := JobStats{NFinishedTasks: 1, NScheduledTasks: 1}
+	master.handleManagerReport(NewManagerReport("manager1", job.UUID, JobStatusFinished, stats))
+	master.handleManagerReport(NewManagerReport("manager1", "job2", JobStatusRunning, JobStats{NPendingTasks: 1}))
+
+	// Job history survives Master restart.
+	master, err = NewMasterWithStore(store)
+	assert.Nil(t, err)
+
+	jobs := master.ListJobs("")
+	assert.Equal(t, 2, len(jobs))
+
+	gotJob, err := master.GetJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusFinished, gotJob.Status)
+	assert.Equal(t, stats, gotJob.Stats)
+	assert.Equal(t, "testWorkflow", gotJob.Workflow.Name)
+	assert.Equal(t, "Faust", gotJob.Workflow.TaskTemplates[0].ActionTemplates[0].ConstructorParams["c"].StringValue)
+
+	gotJob, err = master.GetJob("job2")
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusRunning, gotJob.Status)
+	assert.Nil(t, gotJob.Workflow)
+}
+
+// blockingMasterStoreBackend holds up job updates until released.
+type blockingMasterStoreBackend struct {
+	MasterStoreBackend
+	updating chan struct{}
+	release  chan struct{}
+}
+
+func (bmsb *blockingMasterStoreBackend) UpdateJob(job *Job) error {
+	bmsb.updating <- struct{}{}
+	<-bmsb.release
+
+	return bmsb.MasterStoreBackend.UpdateJob(job)
+}
+
+func TestMasterHandleManagerReportDoesNotHoldMutexForStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	jsonBackend, err := NewJSONFileMasterStoreBackend(dir)
+	assert.Nil(t, err)
+
+	backend := &blockingMasterStoreBackend{
+		MasterStoreBackend: jsonBackend,
+		updating:           make(chan struct{}),
+		release:            make(chan struct{}),
+	}
+
+	store := NewMasterStore()
+	store.AddBackend(backend)
+
+	master, err := NewMasterWithStore(store)
+	assert.Nil(t, err)
+	drainMaster(master)
+
+	job, err := master.CreateJob(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	reported := make(chan struct{})
+	go func() {
+		master.handleManagerReport(NewManagerReport("manager1", job.UUID, JobStatusRunning, JobStats{}))
+		close(reported)
+	}()
+
+	<-backend.updating
+
+	// Job is already updated in memory while store is still being written to.
+	gotJob, err := master.GetJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusRunning, gotJob.Status)
+	assert.Equal(t, 1, len(master.ListManagers()))
+
+	close(backend.release)
+	<-reported
+
+	gotJob, err = jsonBackend.GetJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusRunning, gotJob.Status)
+}
@@ -0,0 +1,193 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MasterClientDefaultTimeout = 30 * time.Second
+
+// MasterClient talks to Master HTTP/JSON API.
+type MasterClient struct {
+	UUID       string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewMasterClient(baseURL string) *MasterClient {
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+
+	return &MasterClient{
+		UUID:       uuid.New().String(),
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: MasterClientDefaultTimeout},
+	}
+}
+
+func (mc *MasterClient) String() string {
+	return fmt.Sprintf("<MasterClient %s BaseURL: %s>", mc.UUID, mc.BaseURL)
+}
+
+// doRequest sends request to Master and decodes JSON response into x. Error responses
+// are turned into Go errors with message from Master.
+func (mc *MasterClient) doRequest(method string, path string, body io.Reader, x interface{}) error {
+	req, err := http.NewRequest(method, mc.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := mc.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		errResp := map[string]string{}
+
+		if json.Unmarshal(respBody, &errResp) == nil && errResp["Error"] != "" {
+			return fmt.Errorf("%s (HTTP %d)", errResp["Error"], resp.StatusCode)
+		}
+
+		return fmt.Errorf("Master responded with HTTP %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(respBody, x)
+}
+
+func (mc *MasterClient) ListJobs(status string) ([]*Job, error) {
+	path := MasterAPIPathPrefix + "jobs"
+
+	if status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+
+	jobs := []*Job{}
+
+	err := mc.doRequest(http.MethodGet, path, nil, &jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// SubmitWorkflow creates new job from workflow given as YAML (or JSON).
+func (mc *MasterClient) SubmitWorkflow(workflowYAML []byte) (*Job, error) {
+	job := &Job{}
+
+	err := mc.doRequest(http.MethodPost, MasterAPIPathPrefix+"jobs", bytes.NewReader(workflowYAML), job)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (mc *MasterClient) GetJob(jobUUID string) (*Job, error) {
+	job := &Job{}
+
+	err := mc.doRequest(http.MethodGet, MasterAPIPathPrefix+"jobs/"+url.PathEscape(jobUUID), nil, job)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ControlJob has Master pause, resume or cancel the job. Action is given by short name
+// from JobControlActionsByName.
+func (mc *MasterClient) ControlJob(jobUUID string, actionName string) (*Job, error) {
+	job := &Job{}
+
+	err := mc.doRequest(http.MethodPost, MasterAPIPathPrefix+"jobs/"+url.PathEscape(jobUUID)+"/"+
+		url.PathEscape(actionName), nil, job)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (mc *MasterClient) CancelJob(jobUUID string) (*Job, error) {
+	return mc.ControlJob(jobUUID, "cancel")
+}
+
+func (mc *MasterClient) PauseJob(jobUUID string) (*Job, error) {
+	return mc.ControlJob(jobUUID, "pause")
+}
+
+func (mc *MasterClient) ResumeJob(jobUUID string) (*Job, error) {
+	return mc.ControlJob(jobUUID, "resume")
+}
+
+func (mc *MasterClient) ListManagers() ([]*ManagerReport, error) {
+	reports := []*ManagerReport{}
+
+	err := mc.doRequest(http.MethodGet, MasterAPIPathPrefix+"managers", nil, &reports)
+	if err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func (mc *MasterClient) ListWorkers() ([]*WorkerStatus, error) {
+	statuses := []*WorkerStatus{}
+
+	err := mc.doRequest(http.MethodGet, MasterAPIPathPrefix+"workers", nil, &statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// ValidateWorkflow has Master check given workflow YAML without creating a job. Returns
+// nil if workflow is valid.
+func (mc *MasterClient) ValidateWorkflow(workflowYAML []byte) error {
+	resp := map[string]interface{}{}
+
+	return mc.doRequest(http.MethodPost, MasterAPIPathPrefix+"workflows/validate", bytes.NewReader(workflowYAML),
+		&resp)
+}
+
+func (mc *MasterClient) ListSchedules() ([]*JobScheduleStatus, error) {
+	statuses := []*JobScheduleStatus{}
+
+	err := mc.doRequest(http.MethodGet, MasterAPIPathPrefix+"schedules", nil, &statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+func (mc *MasterClient) ListScheduledRuns(scheduleName string) ([]*ScheduledRun, error) {
+	runs := []*ScheduledRun{}
+
+	err := mc.doRequest(http.MethodGet, MasterAPIPathPrefix+"schedules/"+url.PathEscape(scheduleName)+"/runs", nil,
+		&runs)
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,149 @@
+package spsw
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestNewMasterClient(t *testing.T) {
+	client := NewMasterClient("localhost:8080/")
+
+	assert.NotNil(t, client)
+	assert.Equal(t, "http://localhost:8080", client.BaseURL)
+	assert.NotNil(t, client.HTTPClient)
+
+	client = NewMasterClient("https://master.example.com")
+	assert.Equal(t, "https://master.example.com", client.BaseURL)
+}
+
+func TestMasterClient(t *testing.T) {
+	master := NewMaster()
+	drainMaster(master)
+
+	server := httptest.NewServer(master)
+	defer server.Close()
+
+	client := NewMasterClient(server.URL)
+
+	workflowYAML, err := yaml.Marshal(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	assert.Nil(t, client.ValidateWorkflow(workflowYAML))
+	assert.NotNil(t, client.ValidateWorkflow([]byte("Name: [")))
+
+	job, err := client.SubmitWorkflow(workflowYAML)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusQueued, job.Status)
+
+	jobs, err := client.ListJobs("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, job.UUID, jobs[0].UUID)
+
+	jobs, err = client.ListJobs(JobStatusRunning)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(jobs))
+
+	gotJob, err := client.GetJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, "testWorkflow", gotJob.Workflow.Name)
+
+	_, err = client.GetJob("no-such-job")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrJobNotFound.Error())
+
+	pausedJob, err := client.PauseJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusPaused, pausedJob.Status)
+
+	resumedJob, err := client.ResumeJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusQueued, resumedJob.Status)
+
+	cancelledJob, err := client.CancelJob(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusCancelled, cancelledJob.Status)
+
+	_, err = client.CancelJob(job.UUID)
+	assert.NotNil(t, err)
+
+	master.handleManagerReport(NewManagerReport("manager1", "", "", JobStats{}))
+
+	reports, err := client.ListManagers()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(reports))
+	assert.Equal(t, "manager1", reports[0].ManagerUUID)
+}
+
+func TestMasterClientSchedules(t *testing.T) {
+	master := NewMaster()
+	drainMaster(master)
+
+	server := httptest.NewServer(master)
+	defer server.Close()
+
+	client := NewMasterClient(server.URL)
+
+	statuses, err := client.ListSchedules()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(statuses))
+
+	schedule := &JobSchedule{Name: "hourly", WorkflowPath: "/no/such/workflow.yaml", Interval: time.Hour}
+
+	master.Scheduler, err = NewJobScheduler(master, []*JobSchedule{schedule})
+	assert.Nil(t, err)
+
+	start := time.Now()
+	master.Scheduler.tick(start)
+	master.Scheduler.tick(start.Add(time.Hour))
+
+	statuses, err = client.ListSchedules()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(statuses))
+	assert.Equal(t, "hourly", statuses[0].Schedule.Name)
+	assert.Equal(t, time.Hour, statuses[0].Schedule.Interval)
+	assert.True(t, start.Add(2*time.Hour).Equal(statuses[0].NextRunAt))
+
+	runs, err := client.ListScheduledRuns("hourly")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(runs))
+	assert.Equal(t, ScheduledRunOutcomeFailed, runs[0].Outcome)
+
+	_, err = client.ListScheduledRuns("no-such-schedule")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrScheduleNotFound.Error())
+}
+
+func TestMasterClientListWorkers(t *testing.T) {
+	master := NewMaster()
+
+	server := httptest.NewServer(master)
+	defer server.Close()
+
+	client := NewMasterClient(server.URL)
+
+	statuses, err := client.ListWorkers()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(statuses))
+
+	go master.Run()
+
+	heartbeat := NewWorkerHeartbeat("worker1", "host1")
+	heartbeat.ScheduledTaskUUID = "task1"
+
+	master.WorkerHeartbeatsIn <- heartbeat
+	master.ManagerReportsIn <- NewManagerReport("manager1", "", "", JobStats{})
+
+	statuses, err = client.ListWorkers()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(statuses))
+	assert.Equal(t, "worker1", statuses[0].Heartbeat.WorkerUUID)
+	assert.Equal(t, "task1", statuses[0].Heartbeat.ScheduledTaskUUID)
+	assert.False(t, statuses[0].Stale)
+
+	close(master.ManagerReportsIn)
+}
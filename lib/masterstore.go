@@ -1,6 +1,146 @@
 package spsw
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// MasterStore writes to all of its backends, but reads from the first one only.
 type MasterStore struct {
 	UUID     string
 	Backends []MasterStoreBackend
 }
+
+func NewMasterStore() *MasterStore {
+	return &MasterStore{
+		UUID:     uuid.New().String(),
+		Backends: []MasterStoreBackend{},
+	}
+}
+
+func (ms *MasterStore) String() string {
+	return fmt.Sprintf("<MasterStore %s Backends: %v>", ms.UUID, ms.Backends)
+}
+
+func (ms *MasterStore) AddBackend(newBackend MasterStoreBackend) {
+	ms.Backends = append(ms.Backends, newBackend)
+}
+
+func (ms *MasterStore) primaryBackend() (MasterStoreBackend, error) {
+	if len(ms.Backends) == 0 {
+		return nil, errors.New("MasterStore has no backends")
+	}
+
+	return ms.Backends[0], nil
+}
+
+func (ms *MasterStore) forEachBackend(f func(backend MasterStoreBackend) error) error {
+	var firstErr error
+
+	for _, backend := range ms.Backends {
+		err := f(backend)
+		if err != nil {
+			log.Error(fmt.Sprintf("MasterStore %s backend %v failed with error: %v", ms.UUID, backend, err))
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func (ms *MasterStore) SaveWorkflow(workflow *Workflow) error {
+	return ms.forEachBackend(func(backend MasterStoreBackend) error {
+		return backend.SaveWorkflow(workflow)
+	})
+}
+
+func (ms *MasterStore) LoadWorkflow(name string, version string) (*Workflow, error) {
+	backend, err := ms.primaryBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.LoadWorkflow(name, version)
+}
+
+// CreateJob saves job record along with its workflow, if it has one.
+func (ms *MasterStore) CreateJob(job *Job) error {
+	if job.Workflow != nil {
+		err := ms.SaveWorkflow(job.Workflow)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ms.forEachBackend(func(backend MasterStoreBackend) error {
+		return backend.CreateJob(job)
+	})
+}
+
+func (ms *MasterStore) UpdateJob(job *Job) error {
+	return ms.forEachBackend(func(backend MasterStoreBackend) error {
+		return backend.UpdateJob(job)
+	})
+}
+
+// GetJob returns job record along with its workflow, if one was saved.
+func (ms *MasterStore) GetJob(jobUUID string) (*Job, error) {
+	backend, err := ms.primaryBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := backend.GetJob(jobUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	workflow, err := backend.LoadWorkflow(job.WorkflowName, job.WorkflowVersion)
+	if err == nil {
+		job.Workflow = workflow
+	} else if err != ErrWorkflowNotFound {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ListJobs returns job records without workflows, in order of creation.
+func (ms *MasterStore) ListJobs(filter *JobFilter) ([]*Job, error) {
+	backend, err := ms.primaryBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.ListJobs(filter)
+}
+
+// SaveScheduledRun creates or replaces record of scheduled run.
+func (ms *MasterStore) SaveScheduledRun(run *ScheduledRun) error {
+	return ms.forEachBackend(func(backend MasterStoreBackend) error {
+		return backend.SaveScheduledRun(run)
+	})
+}
+
+// ListScheduledRuns returns runs of given schedule (or of all schedules if name is empty),
+// in order they were due.
+func (ms *MasterStore) ListScheduledRuns(scheduleName string) ([]*ScheduledRun, error) {
+	backend, err := ms.primaryBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.ListScheduledRuns(scheduleName)
+}
+
+func (ms *MasterStore) Close() {
+	for _, backend := range ms.Backends {
+		backend.Close()
+	}
+}
@@ -0,0 +1,62 @@
+package spsw
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMasterStore(t *testing.T) {
+	store := NewMasterStore()
+
+	assert.NotNil(t, store)
+	assert.Equal(t, 36, len(store.UUID))
+	assert.Equal(t, 0, len(store.Backends))
+
+	_, err := store.ListJobs(nil)
+	assert.NotNil(t, err)
+}
+
+func TestMasterStoreWritesToAllBackends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	sqliteBackend, err := NewSQLiteMasterStoreBackend(dir + "/master.db")
+	assert.Nil(t, err)
+
+	jsonFileBackend, err := NewJSONFileMasterStoreBackend(dir + "/master")
+	assert.Nil(t, err)
+
+	store := NewMasterStore()
+	store.AddBackend(sqliteBackend)
+	store.AddBackend(jsonFileBackend)
+
+	defer store.Close()
+
+	workflow := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+	job := NewJob(workflow)
+
+	assert.Nil(t, store.CreateJob(job))
+
+	job.Status = JobStatusRunning
+	assert.Nil(t, store.UpdateJob(job))
+
+	for _, backend := range []MasterStoreBackend{sqliteBackend, jsonFileBackend} {
+		gotJob, err := backend.GetJob(job.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, JobStatusRunning, gotJob.Status)
+
+		gotWorkflow, err := backend.LoadWorkflow("WF0", "v1")
+		assert.Nil(t, err)
+		assert.Equal(t, "Task1", gotWorkflow.TaskTemplates[0].TaskName)
+	}
+
+	gotJob, err := store.GetJob(job.UUID)
+	assert.Nil(t, err)
+	assert.NotNil(t, gotJob.Workflow)
+	assert.Equal(t, "Task1", gotJob.Workflow.TaskTemplates[0].TaskName)
+}
@@ -1,9 +1,88 @@
 package spsw
 
+import (
+	"errors"
+)
+
+var ErrWorkflowNotFound = errors.New("Workflow not found")
+
+// JobFilter selects jobs by status and workflow. Empty fields match everything.
+type JobFilter struct {
+	Status          string
+	WorkflowName    string
+	WorkflowVersion string
+}
+
+func (jf *JobFilter) Matches(job *Job) bool {
+	if jf == nil {
+		return true
+	}
+
+	if jf.Status != "" && jf.Status != job.Status {
+		return false
+	}
+
+	if jf.WorkflowName != "" && jf.WorkflowName != job.WorkflowName {
+		return false
+	}
+
+	if jf.WorkflowVersion != "" && jf.WorkflowVersion != job.WorkflowVersion {
+		return false
+	}
+
+	return true
+}
+
+// MasterStoreBackend persists what Master knows about workflows, jobs and scheduled runs.
+// Workflows are keyed by name and version; jobs refer to them the same way and are stored
+// without the workflow itself.
 type MasterStoreBackend interface {
-	// TODO:
+	SaveWorkflow(workflow *Workflow) error
+	LoadWorkflow(name string, version string) (*Workflow, error)
+	CreateJob(job *Job) error
+	UpdateJob(job *Job) error
+	GetJob(jobUUID string) (*Job, error)
+	ListJobs(filter *JobFilter) ([]*Job, error)
+	SaveScheduledRun(run *ScheduledRun) error
+	ListScheduledRuns(scheduleName string) ([]*ScheduledRun, error)
+	Close()
 }
 
 type AbstractMasterStoreBackend struct {
 	MasterStoreBackend
 }
+
+func (amsb *AbstractMasterStoreBackend) SaveWorkflow(workflow *Workflow) error {
+	return errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) LoadWorkflow(name string, version string) (*Workflow, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) CreateJob(job *Job) error {
+	return errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) UpdateJob(job *Job) error {
+	return errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) GetJob(jobUUID string) (*Job, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) ListJobs(filter *JobFilter) ([]*Job, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) SaveScheduledRun(run *ScheduledRun) error {
+	return errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) ListScheduledRuns(scheduleName string) ([]*ScheduledRun, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (amsb *AbstractMasterStoreBackend) Close() {
+}
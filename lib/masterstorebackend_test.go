@@ -0,0 +1,122 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobFilterMatches(t *testing.T) {
+	job := &Job{Status: JobStatusRunning, WorkflowName: "WF0", WorkflowVersion: "v1"}
+
+	var nilFilter *JobFilter
+
+	assert.True(t, nilFilter.Matches(job))
+	assert.True(t, (&JobFilter{}).Matches(job))
+	assert.True(t, (&JobFilter{Status: JobStatusRunning, WorkflowName: "WF0", WorkflowVersion: "v1"}).Matches(job))
+	assert.False(t, (&JobFilter{Status: JobStatusFinished}).Matches(job))
+	assert.False(t, (&JobFilter{WorkflowName: "WF1"}).Matches(job))
+	assert.False(t, (&JobFilter{WorkflowVersion: "v2"}).Matches(job))
+}
+
+// testMasterStoreBackend checks behaviour every MasterStoreBackend implementation must have.
+func testMasterStoreBackend(t *testing.T, backend MasterStoreBackend) {
+	workflow1 := &Workflow{Name: "WF0", Version: "v1", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task1", true)}}
+	workflow2 := &Workflow{Name: "WF0", Version: "v2", TaskTemplates: []TaskTemplate{*NewTaskTemplate("Task2", true)}}
+
+	_, err := backend.LoadWorkflow("WF0", "v1")
+	assert.Equal(t, ErrWorkflowNotFound, err)
+
+	assert.Nil(t, backend.SaveWorkflow(workflow1))
+	assert.Nil(t, backend.SaveWorkflow(workflow2))
+
+	gotWorkflow, err := backend.LoadWorkflow("WF0", "v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Task1", gotWorkflow.TaskTemplates[0].TaskName)
+
+	gotWorkflow, err = backend.LoadWorkflow("WF0", "v2")
+	assert.Nil(t, err)
+	assert.Equal(t, "Task2", gotWorkflow.TaskTemplates[0].TaskName)
+
+	job1 := NewJob(workflow1)
+	job2 := NewJob(workflow2)
+	job2.CreatedAt = job1.CreatedAt.Add(time.Second)
+
+	_, err = backend.GetJob(job1.UUID)
+	assert.Equal(t, ErrJobNotFound, err)
+
+	assert.NotNil(t, backend.UpdateJob(job1))
+
+	assert.Nil(t, backend.CreateJob(job1))
+	assert.Nil(t, backend.CreateJob(job2))
+	assert.NotNil(t, backend.CreateJob(job1))
+
+	gotJob, err := backend.GetJob(job1.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, job1.UUID, gotJob.UUID)
+	assert.Equal(t, JobStatusQueued, gotJob.Status)
+	assert.Nil(t, gotJob.Workflow)
+
+	job1.Status = JobStatusFinished
+	job1.ManagerUUID = "manager1"
+	job1.Stats = JobStats{NFinishedTasks: 3, NScheduledTasks: 3}
+	assert.Nil(t, backend.UpdateJob(job1))
+
+	gotJob, err = backend.GetJob(job1.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusFinished, gotJob.Status)
+	assert.Equal(t, "manager1", gotJob.ManagerUUID)
+	assert.Equal(t, job1.Stats, gotJob.Stats)
+
+	jobs, err := backend.ListJobs(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(jobs))
+	assert.Equal(t, job1.UUID, jobs[0].UUID)
+	assert.Equal(t, job2.UUID, jobs[1].UUID)
+
+	jobs, err = backend.ListJobs(&JobFilter{Status: JobStatusQueued})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, job2.UUID, jobs[0].UUID)
+
+	jobs, err = backend.ListJobs(&JobFilter{WorkflowName: "WF0", WorkflowVersion: "v1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, job1.UUID, jobs[0].UUID)
+
+	jobs, err = backend.ListJobs(&JobFilter{WorkflowName: "WF1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(jobs))
+
+	runs, err := backend.ListScheduledRuns("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(runs))
+
+	run1 := NewScheduledRun("daily", time.Date(2021, 1, 2, 3, 0, 0, 0, time.UTC))
+	run1.Outcome = ScheduledRunOutcomeQueued
+	run2 := NewScheduledRun("daily", run1.DueAt.Add(-24*time.Hour))
+	run2.Outcome = ScheduledRunOutcomeStarted
+	run3 := NewScheduledRun("hourly", run1.DueAt)
+	run3.Outcome = ScheduledRunOutcomeSkipped
+
+	assert.Nil(t, backend.SaveScheduledRun(run1))
+	assert.Nil(t, backend.SaveScheduledRun(run2))
+	assert.Nil(t, backend.SaveScheduledRun(run3))
+
+	run1.Outcome = ScheduledRunOutcomeStarted
+	run1.JobUUID = job1.UUID
+	assert.Nil(t, backend.SaveScheduledRun(run1))
+
+	runs, err = backend.ListScheduledRuns("daily")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(runs))
+	assert.Equal(t, run2.UUID, runs[0].UUID)
+	assert.Equal(t, run1.UUID, runs[1].UUID)
+	assert.Equal(t, ScheduledRunOutcomeStarted, runs[1].Outcome)
+	assert.Equal(t, job1.UUID, runs[1].JobUUID)
+
+	runs, err = backend.ListScheduledRuns("")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(runs))
+}
@@ -0,0 +1,155 @@
+package spsw
+
+import (
+	"fmt"
+	"time"
+)
+
+type politenessHostState struct {
+	policy         *HostPolicy
+	queue          []*ScheduledTask
+	nInFlight      int
+	lastReleasedAt time.Time
+}
+
+// PolitenessScheduler decides when scheduled tasks can be sent out to workers, so that
+// no host gets more requests than its HostPolicy allows. Tasks over the limit wait in
+// per-host queue, by priority and then in the order they were scheduled. Tasks for hosts
+// without policy (or with unknown host) are released right away.
+//
+// Tasks are released when Manager checks for them, so with rate limit above 1 / (check
+// interval) host effectively gets one task per check.
+type PolitenessScheduler struct {
+	Policies []HostPolicy
+
+	hosts          map[string]*politenessHostState
+	hostsOrder     []string
+	hostByTaskUUID map[string]string
+}
+
+func NewPolitenessScheduler(policies []HostPolicy) *PolitenessScheduler {
+	return &PolitenessScheduler{
+		Policies:       policies,
+		hosts:          map[string]*politenessHostState{},
+		hostsOrder:     []string{},
+		hostByTaskUUID: map[string]string{},
+	}
+}
+
+func (ps *PolitenessScheduler) String() string {
+	return fmt.Sprintf("<PolitenessScheduler Policies: %v, Hosts: %d, Queued: %d>", ps.Policies, len(ps.hosts),
+		ps.Len())
+}
+
+func (ps *PolitenessScheduler) hostState(host string) *politenessHostState {
+	state, found := ps.hosts[host]
+	if !found {
+		state = &politenessHostState{
+			policy: findHostPolicy(ps.Policies, host),
+			queue:  []*ScheduledTask{},
+		}
+
+		ps.hosts[host] = state
+		ps.hostsOrder = append(ps.hostsOrder, host)
+	}
+
+	return state
+}
+
+// Enqueue puts scheduled task in line for its host, behind tasks of the same or higher
+// priority.
+func (ps *PolitenessScheduler) Enqueue(scheduledTask *ScheduledTask) {
+	state := ps.hostState(scheduledTask.TargetHost())
+
+	idx := len(state.queue)
+	for idx > 0 && state.queue[idx-1].Priority < scheduledTask.Priority {
+		idx--
+	}
+
+	state.queue = append(state.queue, nil)
+	copy(state.queue[idx+1:], state.queue[idx:])
+	state.queue[idx] = scheduledTask
+}
+
+// TakeReady removes tasks that can be sent out by now from their queues and returns them.
+// Returned tasks count as in flight for their host until TaskDone is called.
+func (ps *PolitenessScheduler) TakeReady() []*ScheduledTask {
+	return ps.takeReadyAt(time.Now())
+}
+
+func (ps *PolitenessScheduler) takeReadyAt(now time.Time) []*ScheduledTask {
+	ready := []*ScheduledTask{}
+
+	for _, host := range ps.hostsOrder {
+		state := ps.hosts[host]
+
+		for len(state.queue) > 0 {
+			if state.policy != nil {
+				if state.policy.MaxInFlight > 0 && state.nInFlight >= state.policy.MaxInFlight {
+					break
+				}
+
+				minInterval := state.policy.MinInterval()
+				if minInterval > 0 && !state.lastReleasedAt.IsZero() && now.Sub(state.lastReleasedAt) < minInterval {
+					break
+				}
+			}
+
+			scheduledTask := state.queue[0]
+			state.queue = state.queue[1:]
+
+			if state.policy != nil {
+				state.nInFlight++
+				state.lastReleasedAt = now
+				ps.hostByTaskUUID[scheduledTask.UUID] = host
+			}
+
+			ready = append(ready, scheduledTask)
+		}
+	}
+
+	return ready
+}
+
+// TaskDone frees the slot taken by scheduled task with given UUID.
+func (ps *PolitenessScheduler) TaskDone(scheduledTaskUUID string) {
+	host, found := ps.hostByTaskUUID[scheduledTaskUUID]
+	if !found {
+		return
+	}
+
+	delete(ps.hostByTaskUUID, scheduledTaskUUID)
+
+	state := ps.hosts[host]
+	if state != nil && state.nInFlight > 0 {
+		state.nInFlight--
+	}
+}
+
+// QueuedTasks returns tasks that are still waiting for their turn.
+func (ps *PolitenessScheduler) QueuedTasks() []*ScheduledTask {
+	queued := []*ScheduledTask{}
+
+	for _, host := range ps.hostsOrder {
+		queued = append(queued, ps.hosts[host].queue...)
+	}
+
+	return queued
+}
+
+func (ps *PolitenessScheduler) Len() int {
+	n := 0
+
+	for _, state := range ps.hosts {
+		n += len(state.queue)
+	}
+
+	return n
+}
+
+// Reset drops all the queued tasks and forgets tasks in flight.
+func (ps *PolitenessScheduler) Reset() {
+	ps.hosts = map[string]*politenessHostState{}
+	ps.hostsOrder = []string{}
+	ps.hostByTaskUUID = map[string]string{}
+}
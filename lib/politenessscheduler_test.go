@@ -0,0 +1,130 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestScheduledTaskForURL(urlStr string) *ScheduledTask {
+	taskTempl := NewTaskTemplate("Task1", false)
+	taskTempl.AddActionTemplate(NewActionTemplate("HTTP1", "HTTPAction", map[string]interface{}{
+		"baseURL": urlStr,
+		"method":  "GET",
+	}))
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+
+	return NewScheduledTask(promise, taskTempl, "WF0", "v1", "job1")
+}
+
+func TestNewPolitenessScheduler(t *testing.T) {
+	policies := []HostPolicy{*NewHostPolicy("a.com", 1.0, 0, 0)}
+
+	scheduler := NewPolitenessScheduler(policies)
+
+	assert.NotNil(t, scheduler)
+	assert.Equal(t, policies, scheduler.Policies)
+	assert.Equal(t, 0, scheduler.Len())
+}
+
+func TestPolitenessSchedulerReleasesUnlimitedHostsRightAway(t *testing.T) {
+	scheduler := NewPolitenessScheduler([]HostPolicy{*NewHostPolicy("a.com", 1.0, 1, 0)})
+
+	task1 := newTestScheduledTaskForURL("https://b.com/1")
+	task2 := newTestScheduledTaskForURL("https://b.com/2")
+	task3 := NewScheduledTask(NewTaskPromise("Task2", "WF0", "job1", map[string]*DataChunk{}),
+		NewTaskTemplate("Task2", false), "WF0", "v1", "job1")
+
+	scheduler.Enqueue(task1)
+	scheduler.Enqueue(task2)
+	scheduler.Enqueue(task3)
+
+	assert.Equal(t, []*ScheduledTask{task1, task2, task3}, scheduler.TakeReady())
+	assert.Equal(t, 0, scheduler.Len())
+}
+
+func TestPolitenessSchedulerMaxInFlight(t *testing.T) {
+	scheduler := NewPolitenessScheduler([]HostPolicy{*NewHostPolicy("a.com", 0, 2, 0)})
+
+	tasks := []*ScheduledTask{}
+	for i := 0; i < 3; i++ {
+		task := newTestScheduledTaskForURL("https://a.com/")
+		tasks = append(tasks, task)
+		scheduler.Enqueue(task)
+	}
+
+	assert.Equal(t, tasks[:2], scheduler.TakeReady())
+	assert.Equal(t, []*ScheduledTask{}, scheduler.TakeReady())
+	assert.Equal(t, 1, scheduler.Len())
+	assert.Equal(t, tasks[2:], scheduler.QueuedTasks())
+
+	scheduler.TaskDone("unknown")
+	assert.Equal(t, []*ScheduledTask{}, scheduler.TakeReady())
+
+	scheduler.TaskDone(tasks[0].UUID)
+	assert.Equal(t, tasks[2:], scheduler.TakeReady())
+	assert.Equal(t, 0, scheduler.Len())
+}
+
+func TestPolitenessSchedulerRateLimit(t *testing.T) {
+	scheduler := NewPolitenessScheduler([]HostPolicy{
+		*NewHostPolicy("a.com", 2.0, 0, 0),
+		*NewHostPolicy("b.com", 0, 0, time.Second),
+	})
+
+	taskA1 := newTestScheduledTaskForURL("https://a.com/1")
+	taskA2 := newTestScheduledTaskForURL("https://a.com/2")
+	taskB1 := newTestScheduledTaskForURL("https://b.com/1")
+	taskB2 := newTestScheduledTaskForURL("https://b.com/2")
+
+	scheduler.Enqueue(taskA1)
+	scheduler.Enqueue(taskA2)
+	scheduler.Enqueue(taskB1)
+	scheduler.Enqueue(taskB2)
+
+	now := time.Now()
+
+	assert.Equal(t, []*ScheduledTask{taskA1, taskB1}, scheduler.takeReadyAt(now))
+	assert.Equal(t, []*ScheduledTask{}, scheduler.takeReadyAt(now.Add(400*time.Millisecond)))
+	assert.Equal(t, []*ScheduledTask{taskA2}, scheduler.takeReadyAt(now.Add(500*time.Millisecond)))
+	assert.Equal(t, []*ScheduledTask{taskB2}, scheduler.takeReadyAt(now.Add(time.Second)))
+	assert.Equal(t, 0, scheduler.Len())
+}
+
+func TestPolitenessSchedulerQueuesByPriority(t *testing.T) {
+	scheduler := NewPolitenessScheduler([]HostPolicy{*NewHostPolicy("a.com", 0, 1, 0)})
+
+	task1 := newTestScheduledTaskForURL("https://a.com/1")
+	task2 := newTestScheduledTaskForURL("https://a.com/2")
+	task3 := newTestScheduledTaskForURL("https://a.com/3")
+	task3.Priority = 2
+	task4 := newTestScheduledTaskForURL("https://a.com/4")
+	task4.Priority = 1
+
+	scheduler.Enqueue(task1)
+	scheduler.Enqueue(task2)
+	scheduler.Enqueue(task3)
+	scheduler.Enqueue(task4)
+
+	assert.Equal(t, []*ScheduledTask{task3, task4, task1, task2}, scheduler.QueuedTasks())
+}
+
+func TestPolitenessSchedulerReset(t *testing.T) {
+	scheduler := NewPolitenessScheduler([]HostPolicy{*NewHostPolicy("a.com", 0, 1, 0)})
+
+	task1 := newTestScheduledTaskForURL("https://a.com/1")
+	task2 := newTestScheduledTaskForURL("https://a.com/2")
+
+	scheduler.Enqueue(task1)
+	scheduler.Enqueue(task2)
+	scheduler.TakeReady()
+
+	scheduler.Reset()
+
+	assert.Equal(t, 0, scheduler.Len())
+
+	scheduler.Enqueue(task2)
+	assert.Equal(t, []*ScheduledTask{task2}, scheduler.TakeReady())
+}
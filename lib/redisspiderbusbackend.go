@@ -3,27 +3,106 @@ package spsw
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
 )
 
 type RedisSpiderBusBackend struct {
 	SpiderBusBackend
 	UUID string
 
+	// Scheduled tasks are acknowledged only once their results are sent. Ones
+	// that remain unacknowledged for longer than VisibilityTimeout are handed to
+	// another consumer, up to MaxDeliveries times. After that the task is
+	// considered failed.
+	VisibilityTimeout time.Duration
+	MaxDeliveries     int64
+
 	ctx         context.Context
 	serverAddr  string
 	redisClient *redis.Client
 	consumerId  string
+
+	unackedMutex        sync.Mutex
+	unackedMsgIDsByUUID map[string]redisStreamMessageID
+	reclaimOnce         sync.Once
+	reclaimed           chan redisStreamMessage
+
+	groupsMutex sync.Mutex
+	knownGroups map[string]bool
+}
+
+type redisStreamMessageID struct {
+	stream string
+	id     string
+}
+
+type redisStreamMessage struct {
+	stream string
+	msg    redis.XMessage
 }
 
 const RedisStreamNameItems = "items"
 const RedisStreamNameTaskPromises = "task_promises"
 const RedisStreamNameScheduledTasks = "scheduled_tasks"
 const RedisStreamNameTaskResults = "task_results"
+const RedisStreamNameDeadLetters = "dead_letters"
+const RedisStreamNameJobs = "jobs"
+const RedisStreamNameManagerReports = "manager_reports"
+const RedisStreamNameJobsFinished = "jobs_finished"
+const RedisStreamNameJobControls = "job_controls"
+
+// Checkpoints are kept in a hash, keyed by job UUID, as only the latest one matters.
+const RedisKeyManagerCheckpoints = "manager_checkpoints"
+
+// Worker heartbeats are kept in a hash as well, keyed by worker UUID.
+const RedisKeyWorkerHeartbeats = "worker_heartbeats"
+
+// Label selectors of scheduled tasks ever sent are kept in a set, so that workers know
+// which scheduled task streams to read besides the ones for tasks not requiring labels.
+const RedisKeyScheduledTaskLabelSelectors = "scheduled_task_label_selectors"
+
+const RedisSpiderBusBackendReceiveTimeout = 1 * time.Second
+const RedisSpiderBusBackendPollInterval = 100 * time.Millisecond
+
+// redisScheduledTasksStreamName returns name of the stream for scheduled tasks of given
+// priority and label selector. Each priority gets its own stream, with tasks of the lowest
+// priority going to the same stream as before priorities were introduced. Likewise, tasks
+// requiring labels get streams of their own.
+func redisScheduledTasksStreamName(priority int, labelSelector string) string {
+	stream := RedisStreamNameScheduledTasks
+
+	if labelSelector != "" {
+		stream = fmt.Sprintf("%s[%s]", stream, labelSelector)
+	}
+
+	if priority == TaskPriorityLowest {
+		return stream
+	}
+
+	return fmt.Sprintf("%s_p%d", stream, priority)
+}
+
+// redisScheduledTasksStreamNames returns names of scheduled task streams for given label
+// selectors, from highest priority to lowest.
+func redisScheduledTasksStreamNames(labelSelectors []string) []string {
+	streams := []string{}
+
+	for priority := TaskPriorityHighest; priority >= TaskPriorityLowest; priority-- {
+		for _, labelSelector := range labelSelectors {
+			streams = append(streams, redisScheduledTasksStreamName(priority, labelSelector))
+		}
+	}
+
+	return streams
+}
 
 func NewRedisSpiderBusBackend(serverAddr string, password string) *RedisSpiderBusBackend {
 	redisClient := redis.NewClient(&redis.Options{
@@ -38,23 +117,86 @@ func NewRedisSpiderBusBackend(serverAddr string, password string) *RedisSpiderBu
 
 	redisClient.XGroupCreateMkStream(ctx, RedisStreamNameItems, RedisStreamNameItems, "$")
 	redisClient.XGroupCreateMkStream(ctx, RedisStreamNameTaskPromises, RedisStreamNameTaskPromises, "$")
-	redisClient.XGroupCreateMkStream(ctx, RedisStreamNameScheduledTasks, RedisStreamNameScheduledTasks, "$")
+	for _, stream := range redisScheduledTasksStreamNames([]string{""}) {
+		redisClient.XGroupCreateMkStream(ctx, stream, stream, "$")
+	}
+
 	redisClient.XGroupCreateMkStream(ctx, RedisStreamNameTaskResults, RedisStreamNameTaskResults, "$")
+	redisClient.XGroupCreateMkStream(ctx, RedisStreamNameJobs, RedisStreamNameJobs, "$")
+	redisClient.XGroupCreateMkStream(ctx, RedisStreamNameManagerReports, RedisStreamNameManagerReports, "$")
+	redisClient.XGroupCreateMkStream(ctx, RedisStreamNameJobsFinished, RedisStreamNameJobsFinished, "$")
 
 	return &RedisSpiderBusBackend{
-		UUID:        consumerId,
-		ctx:         ctx,
-		serverAddr:  serverAddr,
-		redisClient: redisClient,
-		consumerId:  consumerId,
+		UUID:                consumerId,
+		VisibilityTimeout:   SpiderBusDefaultVisibilityTimeout,
+		MaxDeliveries:       SpiderBusDefaultMaxDeliveries,
+		ctx:                 ctx,
+		serverAddr:          serverAddr,
+		redisClient:         redisClient,
+		consumerId:          consumerId,
+		unackedMsgIDsByUUID: map[string]redisStreamMessageID{},
+		reclaimed:           make(chan redisStreamMessage, 1),
+		knownGroups:         map[string]bool{},
+	}
+}
+
+// ensureScheduledTasksGroups creates consumer groups for streams of scheduled tasks requiring
+// given labels, unless that has been done already. As these streams come and go with
+// workflows, groups are made to start from the beginning of the stream, so that tasks
+// sent before group was created are not missed.
+func (rsbb *RedisSpiderBusBackend) ensureScheduledTasksGroups(labelSelector string) {
+	rsbb.groupsMutex.Lock()
+	defer rsbb.groupsMutex.Unlock()
+
+	if labelSelector == "" || rsbb.knownGroups[labelSelector] {
+		return
+	}
+
+	for _, stream := range redisScheduledTasksStreamNames([]string{labelSelector}) {
+		// Fails with BUSYGROUP if group already exists, which is fine.
+		rsbb.redisClient.XGroupCreateMkStream(rsbb.ctx, stream, stream, "0")
 	}
+
+	rsbb.knownGroups[labelSelector] = true
+}
+
+// scheduledTasksStreamNames returns names of streams with scheduled tasks that worker
+// having given labels may run, from highest priority to lowest.
+func (rsbb *RedisSpiderBusBackend) scheduledTasksStreamNames(workerLabels map[string]string) []string {
+	labelSelectors := []string{""}
+
+	knownSelectors, err := rsbb.redisClient.SMembers(rsbb.ctx, RedisKeyScheduledTaskLabelSelectors).Result()
+	if err != nil {
+		log.Error(fmt.Sprintf("Listing scheduled task label selectors failed with error: %v", err))
+	}
+
+	sort.Strings(knownSelectors)
+
+	for _, labelSelector := range knownSelectors {
+		if labelSelector != "" && labelSelectorMatches(workerLabels, labelSelector) {
+			rsbb.ensureScheduledTasksGroups(labelSelector)
+			labelSelectors = append(labelSelectors, labelSelector)
+		}
+	}
+
+	return redisScheduledTasksStreamNames(labelSelectors)
 }
 
 func (rsbb *RedisSpiderBusBackend) SendScheduledTask(scheduledTask *ScheduledTask) error {
 	raw := scheduledTask.EncodeToJSON()
 
+	labelSelector := scheduledTask.LabelSelector()
+	if labelSelector != "" {
+		rsbb.ensureScheduledTasksGroups(labelSelector)
+
+		err := rsbb.redisClient.SAdd(rsbb.ctx, RedisKeyScheduledTaskLabelSelectors, labelSelector).Err()
+		if err != nil {
+			return err
+		}
+	}
+
 	resp := rsbb.redisClient.XAdd(rsbb.ctx, &redis.XAddArgs{
-		Stream: RedisStreamNameScheduledTasks,
+		Stream: redisScheduledTasksStreamName(clampTaskPriority(scheduledTask.Priority), labelSelector),
 		ID:     "*",
 		Values: map[string]interface{}{
 			"raw": string(raw),
@@ -71,13 +213,19 @@ func (rsbb *RedisSpiderBusBackend) SendScheduledTask(scheduledTask *ScheduledTas
 	return nil
 }
 
-func (rsbb *RedisSpiderBusBackend) readRawMessageFromStream(stream string) ([]byte, error) {
+func (rsbb *RedisSpiderBusBackend) readMessageFromStream(stream string) (*redis.XMessage, error) {
+	return rsbb.readMessageFromStreamWithBlock(stream, RedisSpiderBusBackendReceiveTimeout)
+}
+
+// readMessageFromStreamWithBlock waits up to block for a message. Negative block means
+// not waiting at all.
+func (rsbb *RedisSpiderBusBackend) readMessageFromStreamWithBlock(stream string, block time.Duration) (*redis.XMessage, error) {
 	resp := rsbb.redisClient.XReadGroup(rsbb.ctx, &redis.XReadGroupArgs{
 		Group:    stream,
 		Consumer: rsbb.consumerId,
 		Streams:  []string{stream, ">"},
 		Count:    1,
-		Block:    1 * time.Second,
+		Block:    block,
 		NoAck:    false,
 	})
 
@@ -87,27 +235,213 @@ func (rsbb *RedisSpiderBusBackend) readRawMessageFromStream(stream string) ([]by
 	}
 
 	if len(s) == 1 && len(s[0].Messages) == 1 {
-		msg := s[0].Messages[0]
-		if raw, ok := msg.Values["raw"].(string); ok {
-			rsbb.redisClient.XAck(rsbb.ctx, stream, rsbb.consumerId, msg.ID)
-			return []byte(raw), nil
-		}
+		return &s[0].Messages[0], nil
 	}
 
 	return nil, errors.New("Unknown error")
 }
 
-func (rsbb *RedisSpiderBusBackend) ReceiveScheduledTask() *ScheduledTask {
-	raw, err := rsbb.readRawMessageFromStream(RedisStreamNameScheduledTasks)
+func (rsbb *RedisSpiderBusBackend) readRawMessageFromStream(stream string) ([]byte, error) {
+	msg, err := rsbb.readMessageFromStream(stream)
 	if err != nil {
+		return nil, err
+	}
+
+	// Consumer group is named after the stream.
+	rsbb.redisClient.XAck(rsbb.ctx, stream, stream, msg.ID)
+
+	if raw, ok := msg.Values["raw"].(string); ok {
+		return []byte(raw), nil
+	}
+
+	return nil, errors.New("Unknown error")
+}
+
+// readScheduledTaskMessage takes message from the scheduled task stream of highest priority
+// that has any, waiting up to RedisSpiderBusBackendReceiveTimeout for one to appear. Only
+// streams of tasks that worker having given labels may run are read.
+func (rsbb *RedisSpiderBusBackend) readScheduledTaskMessage(workerLabels map[string]string) (string, *redis.XMessage) {
+	deadline := time.Now().Add(RedisSpiderBusBackendReceiveTimeout)
+	streams := rsbb.scheduledTasksStreamNames(workerLabels)
+
+	for {
+		for _, stream := range streams {
+			msg, err := rsbb.readMessageFromStreamWithBlock(stream, -1)
+			if err == nil {
+				return stream, msg
+			} else if err != redis.Nil {
+				log.Error(fmt.Sprintf("Reading from stream %s failed with error: %v", stream, err))
+				return "", nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", nil
+		}
+
+		time.Sleep(RedisSpiderBusBackendPollInterval)
+	}
+}
+
+// ReceiveScheduledTask also starts reclaiming tasks that other consumers failed to
+// acknowledge, from streams that labels given on the first call match. Hence, backend
+// instance should only be used for receiving tasks on behalf of workers with the same labels.
+func (rsbb *RedisSpiderBusBackend) ReceiveScheduledTask(workerLabels map[string]string) *ScheduledTask {
+	rsbb.reclaimOnce.Do(func() {
+		go rsbb.runReclaimLoop(workerLabels)
+	})
+
+	var stream string
+	var msg *redis.XMessage
+
+	select {
+	case reclaimedMsg := <-rsbb.reclaimed:
+		stream = reclaimedMsg.stream
+		msg = &reclaimedMsg.msg
+	default:
+		stream, msg = rsbb.readScheduledTaskMessage(workerLabels)
+		if msg == nil {
+			return nil
+		}
+	}
+
+	raw, ok := msg.Values["raw"].(string)
+	if !ok {
+		rsbb.redisClient.XAck(rsbb.ctx, stream, stream, msg.ID)
 		return nil
 	}
 
-	scheduledTask := NewScheduledTaskFromJSON(raw)
+	scheduledTask := NewScheduledTaskFromJSON([]byte(raw))
+	if scheduledTask == nil {
+		rsbb.redisClient.XAck(rsbb.ctx, stream, stream, msg.ID)
+		return nil
+	}
+
+	rsbb.unackedMutex.Lock()
+	rsbb.unackedMsgIDsByUUID[scheduledTask.UUID] = redisStreamMessageID{stream: stream, id: msg.ID}
+	rsbb.unackedMutex.Unlock()
 
 	return scheduledTask
 }
 
+// AckScheduledTask acknowledges scheduled task that was received from this backend,
+// so that it will not be delivered to anyone else.
+func (rsbb *RedisSpiderBusBackend) AckScheduledTask(scheduledTaskUUID string) error {
+	rsbb.unackedMutex.Lock()
+	msgID, ok := rsbb.unackedMsgIDsByUUID[scheduledTaskUUID]
+	delete(rsbb.unackedMsgIDsByUUID, scheduledTaskUUID)
+	rsbb.unackedMutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("No unacknowledged scheduled task with UUID %s", scheduledTaskUUID)
+	}
+
+	return rsbb.redisClient.XAck(rsbb.ctx, msgID.stream, msgID.stream, msgID.id).Err()
+}
+
+func (rsbb *RedisSpiderBusBackend) runReclaimLoop(workerLabels map[string]string) {
+	interval := rsbb.VisibilityTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, stream := range rsbb.scheduledTasksStreamNames(workerLabels) {
+			err := rsbb.reclaimIdleScheduledTasks(stream)
+			if err == redis.ErrClosed {
+				return
+			} else if err != nil {
+				log.Error(fmt.Sprintf("Reclaiming scheduled tasks from %s failed with error: %v", stream, err))
+			}
+		}
+	}
+}
+
+// reclaimIdleScheduledTasks looks for scheduled tasks in the stream that were delivered, but not
+// acknowledged within VisibilityTimeout (e.g. because the worker crashed) and claims
+// them for this consumer. Tasks that have been delivered MaxDeliveries times already
+// are acknowledged and reported as failed instead.
+func (rsbb *RedisSpiderBusBackend) reclaimIdleScheduledTasks(stream string) error {
+	pending, err := rsbb.redisClient.XPendingExt(rsbb.ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  stream,
+		Idle:   rsbb.VisibilityTimeout,
+		Start:  "-",
+		End:    "+",
+		Count:  10,
+	}).Result()
+
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pending {
+		if p.RetryCount >= rsbb.MaxDeliveries {
+			err = rsbb.giveUpOnScheduledTask(stream, p.ID, p.RetryCount)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// Only claim as much as we are able to take on right away.
+		if len(rsbb.reclaimed) == cap(rsbb.reclaimed) {
+			continue
+		}
+
+		msgs, err := rsbb.redisClient.XClaim(rsbb.ctx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    stream,
+			Consumer: rsbb.consumerId,
+			MinIdle:  rsbb.VisibilityTimeout,
+			Messages: []string{p.ID},
+		}).Result()
+
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			log.Warn(fmt.Sprintf("Reclaimed scheduled task message %s idle for %v (delivery %d)", msg.ID,
+				p.Idle, p.RetryCount+1))
+			rsbb.reclaimed <- redisStreamMessage{stream: stream, msg: msg}
+		}
+	}
+
+	return nil
+}
+
+func (rsbb *RedisSpiderBusBackend) giveUpOnScheduledTask(stream string, msgID string, nDeliveries int64) error {
+	msgs, err := rsbb.redisClient.XRangeN(rsbb.ctx, stream, msgID, msgID, 1).Result()
+	if err != nil {
+		return err
+	}
+
+	if len(msgs) == 1 {
+		raw, _ := msgs[0].Values["raw"].(string)
+
+		scheduledTask := NewScheduledTaskFromJSON([]byte(raw))
+		if scheduledTask != nil {
+			log.Error(fmt.Sprintf("Giving up on scheduled task %s after %d deliveries", scheduledTask.UUID,
+				nDeliveries))
+
+			taskResult := NewTaskResult(scheduledTask.JobUUID, "", scheduledTask.UUID, false,
+				fmt.Errorf("Scheduled task was delivered %d times without being completed", nDeliveries))
+
+			err = rsbb.SendTaskResult(taskResult)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return rsbb.redisClient.XAck(rsbb.ctx, stream, stream, msgID).Err()
+}
+
 func (rsbb *RedisSpiderBusBackend) SendTaskPromise(taskPromise *TaskPromise) error {
 	raw := taskPromise.EncodeToJSON()
 
@@ -197,10 +531,233 @@ func (rsbb *RedisSpiderBusBackend) ReceiveTaskResult() *TaskResult {
 	return taskResult
 }
 
+func (rsbb *RedisSpiderBusBackend) SendDeadLetter(deadLetter *DeadLetter) error {
+	raw := deadLetter.EncodeToJSON()
+
+	err := rsbb.redisClient.XAdd(rsbb.ctx, &redis.XAddArgs{
+		Stream: RedisStreamNameDeadLetters,
+		ID:     "*",
+		Values: map[string]interface{}{
+			"uuid": deadLetter.UUID,
+			"raw":  string(raw),
+		},
+	}).Err()
+
+	if err != nil {
+		spew.Dump(err)
+	}
+
+	return err
+}
+
+// Dead letters are not consumed through consumer group, but read directly with XRANGE
+// so that they stay in the stream until explicitly removed.
+
+func (rsbb *RedisSpiderBusBackend) ListDeadLetters(jobUUID string) ([]*DeadLetter, error) {
+	msgs, err := rsbb.redisClient.XRange(rsbb.ctx, RedisStreamNameDeadLetters, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := []*DeadLetter{}
+
+	for _, msg := range msgs {
+		raw, _ := msg.Values["raw"].(string)
+
+		deadLetter := NewDeadLetterFromJSON([]byte(raw))
+		if deadLetter == nil {
+			continue
+		}
+
+		if jobUUID != "" && deadLetter.ScheduledTask.JobUUID != jobUUID {
+			continue
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, nil
+}
+
+func (rsbb *RedisSpiderBusBackend) RemoveDeadLetter(deadLetterUUID string) error {
+	msgs, err := rsbb.redisClient.XRange(rsbb.ctx, RedisStreamNameDeadLetters, "-", "+").Result()
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		if msg.Values["uuid"] == deadLetterUUID {
+			return rsbb.redisClient.XDel(rsbb.ctx, RedisStreamNameDeadLetters, msg.ID).Err()
+		}
+	}
+
+	return fmt.Errorf("Dead letter %s not found", deadLetterUUID)
+}
+
+func (rsbb *RedisSpiderBusBackend) writeRawMessageToStream(stream string, raw []byte) error {
+	err := rsbb.redisClient.XAdd(rsbb.ctx, &redis.XAddArgs{
+		Stream: stream,
+		ID:     "*",
+		Values: map[string]interface{}{
+			"raw": string(raw),
+		},
+	}).Err()
+
+	if err != nil {
+		log.Error(fmt.Sprintf("Adding message to stream %s failed with error: %v", stream, err))
+	}
+
+	return err
+}
+
+func (rsbb *RedisSpiderBusBackend) SendJob(job *Job) error {
+	return rsbb.writeRawMessageToStream(RedisStreamNameJobs, job.EncodeToJSON())
+}
+
+func (rsbb *RedisSpiderBusBackend) ReceiveJob() *Job {
+	raw, err := rsbb.readRawMessageFromStream(RedisStreamNameJobs)
+	if err != nil {
+		return nil
+	}
+
+	return NewJobFromJSON(raw)
+}
+
+func (rsbb *RedisSpiderBusBackend) SendManagerReport(report *ManagerReport) error {
+	return rsbb.writeRawMessageToStream(RedisStreamNameManagerReports, report.EncodeToJSON())
+}
+
+func (rsbb *RedisSpiderBusBackend) ReceiveManagerReport() *ManagerReport {
+	raw, err := rsbb.readRawMessageFromStream(RedisStreamNameManagerReports)
+	if err != nil {
+		return nil
+	}
+
+	return NewManagerReportFromJSON(raw)
+}
+
+func (rsbb *RedisSpiderBusBackend) SaveManagerCheckpoint(checkpoint *ManagerCheckpoint) error {
+	return rsbb.redisClient.HSet(rsbb.ctx, RedisKeyManagerCheckpoints, checkpoint.JobUUID,
+		checkpoint.EncodeToJSON()).Err()
+}
+
+func (rsbb *RedisSpiderBusBackend) LoadManagerCheckpoint(jobUUID string) (*ManagerCheckpoint, error) {
+	raw, err := rsbb.redisClient.HGet(rsbb.ctx, RedisKeyManagerCheckpoints, jobUUID).Bytes()
+	if err == redis.Nil {
+		return nil, ErrManagerCheckpointNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return NewManagerCheckpointFromJSON(raw), nil
+}
+
+func (rsbb *RedisSpiderBusBackend) SaveWorkerHeartbeat(heartbeat *WorkerHeartbeat) error {
+	return rsbb.redisClient.HSet(rsbb.ctx, RedisKeyWorkerHeartbeats, heartbeat.WorkerUUID,
+		heartbeat.EncodeToJSON()).Err()
+}
+
+func (rsbb *RedisSpiderBusBackend) ListWorkerHeartbeats() ([]*WorkerHeartbeat, error) {
+	raws, err := rsbb.redisClient.HGetAll(rsbb.ctx, RedisKeyWorkerHeartbeats).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeats := []*WorkerHeartbeat{}
+
+	for _, raw := range raws {
+		heartbeat := NewWorkerHeartbeatFromJSON([]byte(raw))
+		if heartbeat != nil {
+			heartbeats = append(heartbeats, heartbeat)
+		}
+	}
+
+	return heartbeats, nil
+}
+
+func (rsbb *RedisSpiderBusBackend) SendJobFinished(jobFinished *JobFinished) error {
+	return rsbb.writeRawMessageToStream(RedisStreamNameJobsFinished, jobFinished.EncodeToJSON())
+}
+
+func (rsbb *RedisSpiderBusBackend) ReceiveJobFinished() *JobFinished {
+	raw, err := rsbb.readRawMessageFromStream(RedisStreamNameJobsFinished)
+	if err != nil {
+		return nil
+	}
+
+	return NewJobFinishedFromJSON(raw)
+}
+
+func (rsbb *RedisSpiderBusBackend) SendJobControl(jobControl *JobControl) error {
+	return rsbb.writeRawMessageToStream(RedisStreamNameJobControls, jobControl.EncodeToJSON())
+}
+
+// ReceiveJobControls reads job control stream without consumer group, so that every
+// consumer sees all messages. Stream message ID serves as cursor.
+func (rsbb *RedisSpiderBusBackend) ReceiveJobControls(cursor string) ([]*JobControl, string, error) {
+	if cursor == "" {
+		cursor = "0"
+	}
+
+	streams, err := rsbb.redisClient.XRead(rsbb.ctx, &redis.XReadArgs{
+		Streams: []string{RedisStreamNameJobControls, cursor},
+		Block:   1 * time.Second,
+	}).Result()
+
+	if err == redis.Nil {
+		return []*JobControl{}, cursor, nil
+	} else if err != nil {
+		return nil, cursor, err
+	}
+
+	jobControls := []*JobControl{}
+
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			cursor = msg.ID
+
+			raw, _ := msg.Values["raw"].(string)
+
+			jobControl := NewJobControlFromJSON([]byte(raw))
+			if jobControl != nil {
+				jobControls = append(jobControls, jobControl)
+			}
+		}
+	}
+
+	return jobControls, cursor, nil
+}
+
 func (rsbb *RedisSpiderBusBackend) Close() {
-	for _, stream := range []string{RedisStreamNameItems, RedisStreamNameScheduledTasks, RedisStreamNameTaskPromises} {
-		rsbb.redisClient.XGroupDelConsumer(rsbb.ctx, stream, rsbb.consumerId, rsbb.consumerId)
-		rsbb.redisClient.XGroupDestroy(rsbb.ctx, stream, rsbb.consumerId)
+	labelSelectors := []string{""}
+
+	rsbb.groupsMutex.Lock()
+	for labelSelector := range rsbb.knownGroups {
+		labelSelectors = append(labelSelectors, labelSelector)
+	}
+	rsbb.groupsMutex.Unlock()
+
+	streams := append([]string{RedisStreamNameItems, RedisStreamNameTaskPromises},
+		redisScheduledTasksStreamNames(labelSelectors)...)
+
+	for _, stream := range streams {
+		// Deleting consumer would also drop messages it has been delivered, but not acknowledged
+		// (e.g. scheduled tasks that did not finish before shutdown), making them impossible to
+		// reclaim. Such consumer is therefore left in the group.
+		pending, err := rsbb.redisClient.XPendingExt(rsbb.ctx, &redis.XPendingExtArgs{
+			Stream:   stream,
+			Group:    stream,
+			Start:    "-",
+			End:      "+",
+			Count:    1,
+			Consumer: rsbb.consumerId,
+		}).Result()
+
+		if err != nil || len(pending) > 0 {
+			continue
+		}
+
+		rsbb.redisClient.XGroupDelConsumer(rsbb.ctx, stream, stream, rsbb.consumerId)
 	}
 
 	rsbb.redisClient.Close()
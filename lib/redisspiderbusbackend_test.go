@@ -15,3 +15,38 @@ func TestNewRedisSpiderBusBackend(t *testing.T) {
 	assert.Equal(t, "127.0.0.1:6379", rsbb.serverAddr)
 	assert.Equal(t, context.Background(), rsbb.ctx)
 }
+
+func TestRedisSpiderBusBackendDefaults(t *testing.T) {
+	rsbb := NewRedisSpiderBusBackend("127.0.0.1:6379", "")
+
+	assert.Equal(t, SpiderBusDefaultVisibilityTimeout, rsbb.VisibilityTimeout)
+	assert.Equal(t, int64(SpiderBusDefaultMaxDeliveries), rsbb.MaxDeliveries)
+	assert.NotNil(t, rsbb.unackedMsgIDsByUUID)
+}
+
+func TestRedisSpiderBusBackendAckUnknownScheduledTask(t *testing.T) {
+	rsbb := NewRedisSpiderBusBackend("127.0.0.1:6379", "")
+
+	err := rsbb.AckScheduledTask("F4E1C4C5-2B1B-4C56-9E0C-8E8A7D6B5C4A")
+	assert.NotNil(t, err)
+}
+
+func TestRedisScheduledTasksStreamNames(t *testing.T) {
+	assert.Equal(t, RedisStreamNameScheduledTasks, redisScheduledTasksStreamName(TaskPriorityLowest, ""))
+	assert.Equal(t, "scheduled_tasks_p3", redisScheduledTasksStreamName(3, ""))
+	assert.Equal(t, "scheduled_tasks[region=eu]", redisScheduledTasksStreamName(TaskPriorityLowest, "region=eu"))
+	assert.Equal(t, "scheduled_tasks[region=eu]_p3", redisScheduledTasksStreamName(3, "region=eu"))
+
+	streams := redisScheduledTasksStreamNames([]string{""})
+
+	assert.Equal(t, TaskPriorityHighest-TaskPriorityLowest+1, len(streams))
+	assert.Equal(t, redisScheduledTasksStreamName(TaskPriorityHighest, ""), streams[0])
+	assert.Equal(t, RedisStreamNameScheduledTasks, streams[len(streams)-1])
+
+	streams = redisScheduledTasksStreamNames([]string{"", "region=eu"})
+
+	assert.Equal(t, 2*(TaskPriorityHighest-TaskPriorityLowest+1), len(streams))
+	assert.Equal(t, redisScheduledTasksStreamName(TaskPriorityHighest, ""), streams[0])
+	assert.Equal(t, redisScheduledTasksStreamName(TaskPriorityHighest, "region=eu"), streams[1])
+	assert.Equal(t, "scheduled_tasks[region=eu]", streams[len(streams)-1])
+}
@@ -0,0 +1,96 @@
+package spsw
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy tells Manager what to do when a task from given TaskTemplate fails.
+// Tasks are attempted up to MaxAttempts times in total, waiting Backoff before the
+// first retry and multiplying the delay by BackoffMultiplier for each subsequent one.
+// If RetryableErrors is non-empty, only errors matching one of these regular
+// expressions are retried.
+type RetryPolicy struct {
+	MaxAttempts       int           `yaml:"MaxAttempts"`
+	Backoff           time.Duration `yaml:"Backoff,omitempty"`
+	BackoffMultiplier float64       `yaml:"BackoffMultiplier,omitempty"`
+	RetryableErrors   []string      `yaml:"RetryableErrors,omitempty"`
+}
+
+func NewRetryPolicy(maxAttempts int, backoff time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       maxAttempts,
+		Backoff:           backoff,
+		BackoffMultiplier: 1.0,
+		RetryableErrors:   []string{},
+	}
+}
+
+func (rp *RetryPolicy) String() string {
+	return fmt.Sprintf("<RetryPolicy MaxAttempts: %d, Backoff: %v, BackoffMultiplier: %v, RetryableErrors: %v>",
+		rp.MaxAttempts, rp.Backoff, rp.BackoffMultiplier, rp.RetryableErrors)
+}
+
+func (rp *RetryPolicy) IsRetryable(errStr string) bool {
+	if len(rp.RetryableErrors) == 0 {
+		return true
+	}
+
+	for _, pattern := range rp.RetryableErrors {
+		matched, err := regexp.MatchString(pattern, errStr)
+		if err != nil {
+			log.Error(fmt.Sprintf("Bad regular expression %s in RetryableErrors: %v", pattern, err))
+			continue
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ShouldRetry decides if task that failed on given (1-based) attempt should be tried again.
+func (rp *RetryPolicy) ShouldRetry(attempt int, errStr string) bool {
+	return attempt < rp.MaxAttempts && rp.IsRetryable(errStr)
+}
+
+// BackoffBeforeAttempt returns delay to wait before making given (1-based) attempt.
+func (rp *RetryPolicy) BackoffBeforeAttempt(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+
+	backoff := float64(rp.Backoff)
+
+	if rp.BackoffMultiplier > 0 {
+		for i := 2; i < attempt; i++ {
+			backoff *= rp.BackoffMultiplier
+		}
+	}
+
+	return time.Duration(backoff)
+}
+
+func (rp *RetryPolicy) Validate() error {
+	if rp.MaxAttempts < 1 {
+		return fmt.Errorf("MaxAttempts must be at least 1, got %d", rp.MaxAttempts)
+	}
+
+	if rp.Backoff < 0 {
+		return fmt.Errorf("Backoff must not be negative, got %v", rp.Backoff)
+	}
+
+	for _, pattern := range rp.RetryableErrors {
+		_, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("Bad regular expression %s in RetryableErrors: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestNewRetryPolicy(t *testing.T) {
+	retryPolicy := NewRetryPolicy(3, time.Second)
+
+	assert.NotNil(t, retryPolicy)
+	assert.Equal(t, 3, retryPolicy.MaxAttempts)
+	assert.Equal(t, time.Second, retryPolicy.Backoff)
+	assert.Equal(t, 1.0, retryPolicy.BackoffMultiplier)
+	assert.Equal(t, []string{}, retryPolicy.RetryableErrors)
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	retryPolicy := NewRetryPolicy(3, time.Second)
+
+	assert.True(t, retryPolicy.ShouldRetry(1, "connection refused"))
+	assert.True(t, retryPolicy.ShouldRetry(2, "connection refused"))
+	assert.False(t, retryPolicy.ShouldRetry(3, "connection refused"))
+
+	retryPolicy.RetryableErrors = []string{"timeout", "^HTTP 5[0-9]{2}"}
+
+	assert.True(t, retryPolicy.ShouldRetry(1, "i/o timeout"))
+	assert.True(t, retryPolicy.ShouldRetry(1, "HTTP 503 Service Unavailable"))
+	assert.False(t, retryPolicy.ShouldRetry(1, "HTTP 404 Not Found"))
+}
+
+func TestRetryPolicyBackoffBeforeAttempt(t *testing.T) {
+	retryPolicy := NewRetryPolicy(5, time.Second)
+	retryPolicy.BackoffMultiplier = 2.0
+
+	assert.Equal(t, time.Duration(0), retryPolicy.BackoffBeforeAttempt(1))
+	assert.Equal(t, time.Second, retryPolicy.BackoffBeforeAttempt(2))
+	assert.Equal(t, 2*time.Second, retryPolicy.BackoffBeforeAttempt(3))
+	assert.Equal(t, 4*time.Second, retryPolicy.BackoffBeforeAttempt(4))
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	assert.Nil(t, NewRetryPolicy(1, 0).Validate())
+	assert.NotNil(t, NewRetryPolicy(0, 0).Validate())
+	assert.NotNil(t, NewRetryPolicy(3, -time.Second).Validate())
+
+	retryPolicy := NewRetryPolicy(3, time.Second)
+	retryPolicy.RetryableErrors = []string{"("}
+
+	assert.NotNil(t, retryPolicy.Validate())
+}
+
+func TestRetryPolicyFromYAML(t *testing.T) {
+	yamlStr := "MaxAttempts: 4\nBackoff: 2s\nBackoffMultiplier: 1.5\nRetryableErrors:\n- timeout\n"
+
+	retryPolicy := &RetryPolicy{}
+
+	err := yaml.Unmarshal([]byte(yamlStr), retryPolicy)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 4, retryPolicy.MaxAttempts)
+	assert.Equal(t, 2*time.Second, retryPolicy.Backoff)
+	assert.Equal(t, 1.5, retryPolicy.BackoffMultiplier)
+	assert.Equal(t, []string{"timeout"}, retryPolicy.RetryableErrors)
+}
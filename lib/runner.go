@@ -1,16 +1,68 @@
 package spsw
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// BackendAddrInMemory selects in-process backends instead of Redis. Only useful
+// when all the components run within a single process (e.g. singlenode mode).
+const BackendAddrInMemory = "mem://"
+
+// BackendAddrSQLitePrefix selects SQLite database file as a backend, e.g.
+// sqlite:///var/lib/spiderswarm/bus.db
+const BackendAddrSQLitePrefix = "sqlite://"
+
+// MasterStoreAddrJSONFilePrefix selects directory of JSON files for keeping Master's
+// jobs and workflows, e.g. file:///var/lib/spiderswarm/master
+const MasterStoreAddrJSONFilePrefix = "file://"
+
+// Kubernetes gives pods 30 seconds to exit after SIGTERM by default, and we want to be done
+// before being killed.
+const RunnerDefaultShutdownTimeout = 25 * time.Second
+
 type Runner struct {
 	BackendAddr string
+
+	// Where Master keeps jobs and workflows: sqlite://<dbFilePath> or file://<dirPath>.
+	// Nothing is persisted if empty.
+	MasterStoreAddr string
+
+	// YAML file with job schedules for Master to run. No jobs are scheduled if empty.
+	SchedulesFilePath string
+
+	// Labels of workers, telling which scheduled tasks they may run.
+	WorkerLabels map[string]string
+
+	// Override SpiderBus defaults for scheduled task redelivery if non-zero.
+	VisibilityTimeout time.Duration
+	MaxDeliveries     int64
+
+	mutex                sync.Mutex
+	inMemoryBusBackend   *InMemorySpiderBusBackend
+	inMemoryDedupBackend *InMemoryDeduplicatorBackend
+
+	runningMutex sync.Mutex
+	running      []*runnerNode
+	httpServers  []*http.Server
+}
+
+// runnerNode is something Runner has started, along with what it uses to talk to the rest
+// of the cluster, so that it can all be shut down. Stop is nil for nodes that don't need
+// to be stopped on their own.
+type runnerNode struct {
+	stop         func()
+	adapter      *SpiderBusAdapter
+	deduplicator *Deduplicator
 }
 
 func NewRunner(backendAddr string) *Runner {
@@ -45,18 +97,198 @@ func (r *Runner) initLogging() {
 	}
 }
 
+func (r *Runner) track(stop func(), adapter *SpiderBusAdapter, deduplicator *Deduplicator) {
+	r.runningMutex.Lock()
+	defer r.runningMutex.Unlock()
+
+	r.running = append(r.running, &runnerNode{stop: stop, adapter: adapter, deduplicator: deduplicator})
+}
+
+// waitUntil runs given functions concurrently and waits for them to return, but no longer
+// than until deadline. Returns false if deadline was hit.
+func waitUntil(deadline time.Time, fns []func()) bool {
+	var wg sync.WaitGroup
+
+	for _, fn := range fns {
+		wg.Add(1)
+
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Shutdown stops everything the runner has started, within given timeout. First, adapters
+// stop taking messages off the bus, so that no new work is picked up, and master stops
+// serving HTTP. Then workers finish tasks they're running, managers checkpoint their jobs
+// and exporters flush their backends. After that, adapters put whatever they were handed
+// onto the bus (task results, items, checkpoints and so on), acknowledging scheduled tasks
+// that are done. Finally, connections to Redis and database files are closed.
+//
+// Returns false if timeout passed before all of that was done. Scheduled tasks left
+// unacknowledged are delivered again after visibility timeout.
+func (r *Runner) Shutdown(timeout time.Duration) bool {
+	r.runningMutex.Lock()
+	nodes := r.running
+	httpServers := r.httpServers
+	r.running = nil
+	r.httpServers = nil
+	r.runningMutex.Unlock()
+
+	log.Info(fmt.Sprintf("Shutting down %d nodes within %v", len(nodes), timeout))
+
+	deadline := time.Now().Add(timeout)
+
+	stopReceiving := []func(){}
+	stops := []func(){}
+	stopAdapters := []func(){}
+
+	for _, node := range nodes {
+		if node.adapter != nil {
+			stopReceiving = append(stopReceiving, node.adapter.StopReceiving)
+			stopAdapters = append(stopAdapters, node.adapter.Stop)
+		}
+
+		if node.stop != nil {
+			stops = append(stops, node.stop)
+		}
+	}
+
+	finished := waitUntil(deadline, stopReceiving)
+
+	for _, server := range httpServers {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+
+		err := server.Shutdown(ctx)
+		if err != nil {
+			log.Error(fmt.Sprintf("Shutting down HTTP server on %s failed with error: %v", server.Addr, err))
+			finished = false
+		}
+
+		cancel()
+	}
+
+	finished = waitUntil(deadline, stops) && finished
+	finished = waitUntil(deadline, stopAdapters) && finished
+
+	if !finished {
+		log.Warn(fmt.Sprintf("Shutdown did not finish within %v, unacknowledged tasks will be delivered again",
+			timeout))
+	}
+
+	for _, node := range nodes {
+		if node.adapter != nil {
+			node.adapter.Bus.Close()
+		}
+
+		if node.deduplicator != nil {
+			node.deduplicator.Close()
+		}
+	}
+
+	return finished
+}
+
+func (r *Runner) isInMemory() bool {
+	return strings.HasPrefix(r.BackendAddr, BackendAddrInMemory)
+}
+
+func (r *Runner) isSQLite() bool {
+	return strings.HasPrefix(r.BackendAddr, BackendAddrSQLitePrefix)
+}
+
+func (r *Runner) sqliteDBPath() string {
+	return strings.TrimPrefix(r.BackendAddr, BackendAddrSQLitePrefix)
+}
+
 func (r *Runner) setupSpiderBus() *SpiderBus {
-	spiderBusBackend := NewRedisSpiderBusBackend(r.BackendAddr, "")
 	spiderBus := NewSpiderBus()
-	spiderBus.Backend = spiderBusBackend
+
+	if r.isSQLite() {
+		spiderBusBackend, err := NewSQLiteSpiderBusBackend(r.sqliteDBPath())
+		if err != nil {
+			panic(err)
+		}
+
+		if r.VisibilityTimeout != 0 {
+			spiderBusBackend.VisibilityTimeout = r.VisibilityTimeout
+		}
+
+		if r.MaxDeliveries != 0 {
+			spiderBusBackend.MaxDeliveries = r.MaxDeliveries
+		}
+
+		spiderBus.Backend = spiderBusBackend
+	} else if r.isInMemory() {
+		r.mutex.Lock()
+		if r.inMemoryBusBackend == nil {
+			r.inMemoryBusBackend = NewInMemorySpiderBusBackend()
+		}
+		spiderBus.Backend = r.inMemoryBusBackend
+		r.mutex.Unlock()
+	} else {
+		spiderBusBackend := NewRedisSpiderBusBackend(r.BackendAddr, "")
+
+		if r.VisibilityTimeout != 0 {
+			spiderBusBackend.VisibilityTimeout = r.VisibilityTimeout
+		}
+
+		if r.MaxDeliveries != 0 {
+			spiderBusBackend.MaxDeliveries = r.MaxDeliveries
+		}
+
+		spiderBus.Backend = spiderBusBackend
+	}
 
 	return spiderBus
 }
 
+func (r *Runner) setupDeduplicator() *Deduplicator {
+	if r.isSQLite() {
+		deduplicatorBackend, err := NewSQLiteDeduplicatorBackend(r.sqliteDBPath())
+		if err != nil {
+			panic(err)
+		}
+
+		return NewDeduplicatorWithBackend(deduplicatorBackend)
+	}
+
+	if r.isInMemory() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		if r.inMemoryDedupBackend == nil {
+			r.inMemoryDedupBackend = NewInMemoryDeduplicatorBackend()
+		}
+
+		return NewDeduplicatorWithBackend(r.inMemoryDedupBackend)
+	}
+
+	return NewDeduplicator(r.BackendAddr)
+}
+
 func (r *Runner) RunManager(workflow *Workflow) *Manager {
 	r.initLogging()
 
-	deduplicator := NewDeduplicator(r.BackendAddr)
+	deduplicator := r.setupDeduplicator()
 
 	manager := NewManager(deduplicator)
 
@@ -72,11 +304,162 @@ func (r *Runner) RunManager(workflow *Workflow) *Manager {
 	if workflow != nil {
 		log.Info(fmt.Sprintf("Starting Manager %v", manager))
 		go manager.Run()
+
+		r.track(manager.Stop, managerAdapter, deduplicator)
+	} else {
+		r.track(nil, managerAdapter, deduplicator)
+	}
+
+	return manager
+}
+
+// ResumeManager runs Manager that continues given job from its latest checkpoint, e.g.
+// after previous manager has crashed.
+func (r *Runner) ResumeManager(jobUUID string) (*Manager, error) {
+	r.initLogging()
+
+	spiderBus := r.setupSpiderBus()
+
+	checkpoint, err := spiderBus.LoadManagerCheckpoint(jobUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	deduplicator := r.setupDeduplicator()
+
+	manager := NewManager(deduplicator)
+
+	if r.VisibilityTimeout != 0 {
+		manager.ResumedTaskTimeout = r.VisibilityTimeout
 	}
 
+	_, err = manager.ResumeJob(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	managerAdapter := NewSpiderBusAdapterForManager(spiderBus, manager)
+	managerAdapter.Start()
+
+	log.Info(fmt.Sprintf("Starting Manager %v to resume job %s", manager, jobUUID))
+	go manager.Run()
+
+	r.track(manager.Stop, managerAdapter, deduplicator)
+
+	return manager, nil
+}
+
+// ServeManager runs Manager that takes jobs submitted to Master.
+func (r *Runner) ServeManager() *Manager {
+	r.initLogging()
+
+	deduplicator := r.setupDeduplicator()
+
+	manager := NewManager(deduplicator)
+
+	spiderBus := r.setupSpiderBus()
+
+	managerAdapter := NewSpiderBusAdapterForManager(spiderBus, manager)
+	// Only managers waiting for jobs should be taking them off the bus.
+	managerAdapter.JobsOut = manager.JobsIn
+	managerAdapter.Start()
+
+	log.Info(fmt.Sprintf("Starting Manager %v", manager))
+	go manager.Serve()
+
+	r.track(manager.Stop, managerAdapter, deduplicator)
+
 	return manager
 }
 
+func (r *Runner) setupMasterStore() (*MasterStore, error) {
+	var backend MasterStoreBackend
+	var err error
+
+	if strings.HasPrefix(r.MasterStoreAddr, BackendAddrSQLitePrefix) {
+		backend, err = NewSQLiteMasterStoreBackend(strings.TrimPrefix(r.MasterStoreAddr, BackendAddrSQLitePrefix))
+	} else if strings.HasPrefix(r.MasterStoreAddr, MasterStoreAddrJSONFilePrefix) {
+		backend, err = NewJSONFileMasterStoreBackend(strings.TrimPrefix(r.MasterStoreAddr,
+			MasterStoreAddrJSONFilePrefix))
+	} else {
+		return nil, fmt.Errorf("Unsupported master store address: %s", r.MasterStoreAddr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewMasterStore()
+	store.AddBackend(backend)
+
+	return store, nil
+}
+
+func (r *Runner) RunMaster(listenAddr string) *Master {
+	r.initLogging()
+
+	master := NewMaster()
+
+	if r.MasterStoreAddr != "" {
+		store, err := r.setupMasterStore()
+		if err != nil {
+			panic(err)
+		}
+
+		master, err = NewMasterWithStore(store)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	spiderBus := r.setupSpiderBus()
+
+	masterAdapter := NewSpiderBusAdapterForMaster(spiderBus, master)
+	masterAdapter.Start()
+
+	if r.SchedulesFilePath != "" {
+		raw, err := ioutil.ReadFile(r.SchedulesFilePath)
+		if err != nil {
+			panic(err)
+		}
+
+		schedules, err := NewJobSchedulesFromYAML(raw)
+		if err != nil {
+			panic(err)
+		}
+
+		master.Scheduler, err = NewJobScheduler(master, schedules)
+		if err != nil {
+			panic(err)
+		}
+
+		log.Info(fmt.Sprintf("Starting JobScheduler %v", master.Scheduler))
+		go master.Scheduler.Run()
+	}
+
+	log.Info(fmt.Sprintf("Starting Master %v", master))
+	go master.Run()
+
+	server := &http.Server{Addr: listenAddr, Handler: master}
+
+	r.track(nil, masterAdapter, nil)
+
+	r.runningMutex.Lock()
+	r.httpServers = append(r.httpServers, server)
+	r.runningMutex.Unlock()
+
+	go func() {
+		log.Info(fmt.Sprintf("Master %s listening on %s", master.UUID, listenAddr))
+
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(fmt.Sprintf("Master %s failed to serve HTTP: %v", master.UUID, err))
+		}
+	}()
+
+	return master
+}
+
 func (r *Runner) RunExporter(outputDirPath string) *Exporter {
 	r.initLogging()
 
@@ -94,6 +477,8 @@ func (r *Runner) RunExporter(outputDirPath string) *Exporter {
 	log.Info(fmt.Sprintf("Starting Exporter %v", exporter))
 	go exporter.Run()
 
+	r.track(exporter.Stop, exporterAdapter, nil)
+
 	return exporter
 }
 
@@ -105,18 +490,24 @@ func (r *Runner) RunWorkers(n int) []*Worker {
 
 	for i := 0; i < n; i++ {
 		worker := NewWorker()
+
+		for key, value := range r.WorkerLabels {
+			worker.Labels[key] = value
+		}
+
 		workers = append(workers, worker)
 	}
 
 	for _, worker := range workers {
-		go func(worker *Worker) {
-			spiderBus := r.setupSpiderBus()
+		spiderBus := r.setupSpiderBus()
 
-			adapter := NewSpiderBusAdapterForWorker(spiderBus, worker)
-			adapter.Start()
-			log.Info(fmt.Sprintf("Starting Worker %v", worker))
-			worker.Run()
-		}(worker)
+		adapter := NewSpiderBusAdapterForWorker(spiderBus, worker)
+		adapter.Start()
+
+		log.Info(fmt.Sprintf("Starting Worker %v", worker))
+		go worker.Run()
+
+		r.track(worker.Stop, adapter, nil)
 	}
 
 	return workers
@@ -124,9 +515,78 @@ func (r *Runner) RunWorkers(n int) []*Worker {
 
 func (r *Runner) RunSingleNode(nWorkers int, outputDirPath string, workflow *Workflow) {
 	r.RunWorkers(nWorkers)
-	r.RunExporter(outputDirPath)
+	exporter := r.RunExporter(outputDirPath)
 	manager := r.RunManager(nil)
 
-	manager.StartScrapingJob(workflow)
+	job := manager.StartScrapingJob(workflow)
+	r.track(manager.Stop, nil, nil)
+	manager.Run()
+
+	if !exporter.WaitForJob(job.UUID, ExporterPendingFinishTimeout+ExporterPendingFinishCheckInterval) {
+		log.Error(fmt.Sprintf("Exporter did not finish exporting job %s in time", job.UUID))
+	}
+}
+
+func (r *Runner) ListDeadLetters(jobUUID string) ([]*DeadLetter, error) {
+	r.initLogging()
+
+	spiderBus := r.setupSpiderBus()
+
+	return spiderBus.ListDeadLetters(jobUUID)
+}
+
+// SendJobControl puts job control onto the bus for managers and workers to pick up.
+func (r *Runner) SendJobControl(jobUUID string, action string) error {
+	r.initLogging()
+
+	spiderBus := r.setupSpiderBus()
+
+	return spiderBus.Enqueue(NewJobControl(jobUUID, action))
+}
+
+// RedriveDeadLetters runs Manager that schedules tasks from dead letters of given job once
+// more and waits for them (and whatever they lead to) to finish. Dead letters whose tasks
+// finished successfully are removed afterwards and their number is returned. Tasks that fail
+// again keep their dead letters.
+func (r *Runner) RedriveDeadLetters(workflow *Workflow, jobUUID string) (int, error) {
+	r.initLogging()
+
+	spiderBus := r.setupSpiderBus()
+
+	deadLetters, err := spiderBus.ListDeadLetters(jobUUID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(deadLetters) == 0 {
+		return 0, nil
+	}
+
+	manager := NewManager(r.setupDeduplicator())
+	job := manager.ContinueScrapingJob(workflow, jobUUID)
+	manager.RedriveDeadLetters(deadLetters)
+
+	managerAdapter := NewSpiderBusAdapterForManager(spiderBus, manager)
+	managerAdapter.Start()
+
+	log.Info(fmt.Sprintf("Starting Manager %v to re-drive %d dead letters", manager, len(deadLetters)))
 	manager.Run()
+
+	// Making sure items and tasks coming out of re-driven tasks are on the bus before
+	// dead letters are gone.
+	managerAdapter.Stop()
+
+	nRedriven := 0
+
+	for _, deadLetterUUID := range job.RedrivenDeadLetterUUIDs {
+		err = spiderBus.RemoveDeadLetter(deadLetterUUID)
+		if err != nil {
+			log.Error(fmt.Sprintf("Failed to remove dead letter %s: %v", deadLetterUUID, err))
+			continue
+		}
+
+		nRedriven++
+	}
+
+	return nRedriven, nil
 }
@@ -1,7 +1,11 @@
 package spsw
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,3 +16,244 @@ func TestNewRunner(t *testing.T) {
 	assert.NotNil(t, runner)
 	assert.Equal(t, "localhost:1337", runner.BackendAddr)
 }
+
+func TestRunnerSetupSpiderBusInMemory(t *testing.T) {
+	runner := NewRunner(BackendAddrInMemory)
+
+	spiderBus1 := runner.setupSpiderBus()
+	spiderBus2 := runner.setupSpiderBus()
+
+	_, ok := spiderBus1.Backend.(*InMemorySpiderBusBackend)
+	assert.True(t, ok)
+	assert.True(t, spiderBus1.Backend == spiderBus2.Backend)
+
+	deduplicator1 := runner.setupDeduplicator()
+	deduplicator2 := runner.setupDeduplicator()
+
+	_, ok = deduplicator1.Backend.(*InMemoryDeduplicatorBackend)
+	assert.True(t, ok)
+	assert.True(t, deduplicator1.Backend == deduplicator2.Backend)
+}
+
+func TestRunnerSetupSpiderBusSQLite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	runner := NewRunner(BackendAddrSQLitePrefix + dir + "/bus.db")
+
+	spiderBus := runner.setupSpiderBus()
+
+	spiderBusBackend, ok := spiderBus.Backend.(*SQLiteSpiderBusBackend)
+	assert.True(t, ok)
+	assert.Equal(t, dir+"/bus.db", spiderBusBackend.dbPath)
+
+	deduplicator := runner.setupDeduplicator()
+
+	_, ok = deduplicator.Backend.(*SQLiteDeduplicatorBackend)
+	assert.True(t, ok)
+}
+
+func TestRunnerSetupSpiderBusRedis(t *testing.T) {
+	runner := NewRunner("127.0.0.1:6379")
+
+	spiderBus := runner.setupSpiderBus()
+
+	_, ok := spiderBus.Backend.(*RedisSpiderBusBackend)
+	assert.True(t, ok)
+}
+
+func TestRunnerRunSingleNodeInMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	taskTempl := NewTaskTemplate("Launch", true)
+
+	taskTempl.AddActionTemplate(NewActionTemplate("ConstName", "ConstAction",
+		map[string]interface{}{"c": "Faust"}))
+	taskTempl.AddActionTemplate(NewActionTemplate("JoinFields", "FieldJoinAction",
+		map[string]interface{}{"inputNames": []string{"name"}, "itemName": "person"}))
+
+	taskTempl.ConnectActionTemplates("ConstName", ConstActionOutput, "JoinFields", "name")
+	taskTempl.ConnectOutputToActionTemplate("JoinFields", FieldJoinActionOutputItem, "items")
+
+	workflow := NewWorkflow("testWorkflow", "v0.0.1")
+	workflow.AddTaskTemplate(taskTempl)
+
+	runner := NewRunner(BackendAddrInMemory)
+	runner.RunSingleNode(1, dir, workflow)
+
+	var csvStr string
+
+	for i := 0; i < 50; i++ {
+		matches, _ := filepath.Glob(dir + "/*.csv")
+		if len(matches) == 1 {
+			buf, _ := ioutil.ReadFile(matches[0])
+			csvStr = string(buf)
+
+			if csvStr == "name\nFaust\n" {
+				break
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	assert.Equal(t, "name\nFaust\n", csvStr)
+
+	// Single node run only returns once export is finalized.
+	matches, _ := filepath.Glob(dir + "/*.manifest.json")
+	assert.Equal(t, 1, len(matches))
+}
+
+func TestRunnerServeManagerInMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	runner := NewRunner(BackendAddrInMemory)
+
+	master := runner.RunMaster("127.0.0.1:0")
+	runner.ServeManager()
+	runner.RunWorkers(1)
+	runner.RunExporter(dir)
+
+	job, err := master.CreateJob(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		job, _ = master.GetJob(job.UUID)
+		if job.Status == JobStatusFinished {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	assert.Equal(t, JobStatusFinished, job.Status)
+	assert.Equal(t, 1, job.Stats.NFinishedTasks)
+	assert.Equal(t, 1, job.Stats.NScheduledTasks)
+	assert.NotEqual(t, "", job.ManagerUUID)
+	assert.Equal(t, 1, len(master.ListManagers()))
+}
+
+func TestRunnerSendJobControl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	runner := NewRunner(BackendAddrSQLitePrefix + dir + "/bus.db")
+
+	assert.Nil(t, runner.SendJobControl("job1", JobControlActionPause))
+
+	jobControls, _, err := runner.setupSpiderBus().ReceiveJobControls("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobControls))
+	assert.Equal(t, "job1", jobControls[0].JobUUID)
+	assert.Equal(t, JobControlActionPause, jobControls[0].Action)
+}
+
+func TestRunnerResumeManagerInMemory(t *testing.T) {
+	runner := NewRunner(BackendAddrInMemory)
+
+	_, err := runner.ResumeManager("no-such-job")
+	assert.Equal(t, ErrManagerCheckpointNotFound, err)
+
+	checkpoint := NewManagerCheckpoint("manager0", newTestCheckpointedJob())
+	assert.Nil(t, runner.setupSpiderBus().Enqueue(checkpoint))
+
+	manager, err := runner.ResumeManager(checkpoint.JobUUID)
+	assert.Nil(t, err)
+	assert.NotNil(t, manager.GetJob(checkpoint.JobUUID))
+}
+
+func TestRunnerShutdownInMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	runner := NewRunner(BackendAddrInMemory)
+
+	master := runner.RunMaster("127.0.0.1:0")
+	runner.ServeManager()
+	runner.RunWorkers(2)
+	runner.RunExporter(dir)
+
+	job, err := master.CreateJob(newTestMasterWorkflow())
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		job, _ = master.GetJob(job.UUID)
+		if job.Status == JobStatusFinished {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	assert.Equal(t, JobStatusFinished, job.Status)
+
+	assert.True(t, runner.Shutdown(5*time.Second))
+
+	// Shutting down again is fine.
+	assert.True(t, runner.Shutdown(time.Second))
+}
+
+func TestRunnerRedriveDeadLettersInMemory(t *testing.T) {
+	okTaskTempl := NewTaskTemplate("Succeed", false)
+	okTaskTempl.AddActionTemplate(NewActionTemplate("ConstName", "ConstAction",
+		map[string]interface{}{"c": "Faust"}))
+	okTaskTempl.ConnectOutputToActionTemplate("ConstName", ConstActionOutput, "name")
+
+	// URLJoinAction cannot take int as relative URL, so it fails each time it runs.
+	failingTaskTempl := NewTaskTemplate("Fail", false)
+	failingTaskTempl.AddActionTemplate(NewActionTemplate("ConstPage", "ConstAction",
+		map[string]interface{}{"c": 42}))
+	failingTaskTempl.AddActionTemplate(NewActionTemplate("JoinURL", "URLJoinAction",
+		map[string]interface{}{"baseURL": "http://books.toscrape.com/"}))
+	failingTaskTempl.ConnectActionTemplates("ConstPage", ConstActionOutput, "JoinURL",
+		URLJoinActionInputRelativeURL)
+	failingTaskTempl.ConnectOutputToActionTemplate("JoinURL", URLJoinActionOutputAbsoluteURL, "url")
+
+	workflow := NewWorkflow("testWorkflow", "v0.0.1")
+	workflow.AddTaskTemplate(NewTaskTemplate("Launch", true))
+	workflow.AddTaskTemplate(okTaskTempl)
+	workflow.AddTaskTemplate(failingTaskTempl)
+
+	jobUUID := "0D5F2C3A-8E4B-4F1A-9C6D-7B2E1A3F4C5D"
+
+	runner := NewRunner(BackendAddrInMemory)
+	spiderBus := runner.setupSpiderBus()
+
+	var deadLetters []*DeadLetter
+
+	for _, taskTempl := range []*TaskTemplate{okTaskTempl, failingTaskTempl} {
+		promise := NewTaskPromise(taskTempl.TaskName, workflow.Name, jobUUID, map[string]*DataChunk{})
+		scheduledTask := NewScheduledTask(promise, taskTempl, workflow.Name, workflow.Version, jobUUID)
+		deadLetter := NewDeadLetter(scheduledTask, "connection refused")
+
+		assert.Nil(t, spiderBus.Enqueue(deadLetter))
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	runner.RunWorkers(1)
+	defer runner.Shutdown(time.Second)
+
+	n, err := runner.RedriveDeadLetters(workflow, jobUUID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	// Only dead letter of task that failed again is left, and no new one was added for it.
+	remaining, err := runner.ListDeadLetters(jobUUID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(remaining))
+	if len(remaining) == 1 {
+		assert.Equal(t, deadLetters[1].UUID, remaining[0].UUID)
+	}
+}
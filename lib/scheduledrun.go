@@ -0,0 +1,71 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of scheduled runs are exposed through Master API, hence short lowercase values.
+const ScheduledRunOutcomeStarted = "started"
+const ScheduledRunOutcomeSkipped = "skipped"
+const ScheduledRunOutcomeQueued = "queued"
+const ScheduledRunOutcomeFailed = "failed"
+
+// ScheduledRun records what JobScheduler did when schedule was due. Queued run is
+// updated once it's started. If previous run was cancelled to make room for this one,
+// CancelledJobUUID tells which job it was.
+type ScheduledRun struct {
+	UUID             string
+	ScheduleName     string
+	DueAt            time.Time
+	Outcome          string
+	JobUUID          string
+	CancelledJobUUID string
+	Message          string
+	StartedAt        time.Time
+	CreatedAt        time.Time
+}
+
+func NewScheduledRun(scheduleName string, dueAt time.Time) *ScheduledRun {
+	return &ScheduledRun{
+		UUID:         uuid.New().String(),
+		ScheduleName: scheduleName,
+		DueAt:        dueAt,
+		CreatedAt:    time.Now(),
+	}
+}
+
+func NewScheduledRunFromJSON(raw []byte) *ScheduledRun {
+	run := &ScheduledRun{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(run)
+	if err != nil {
+		return nil
+	}
+
+	return run
+}
+
+func (sr *ScheduledRun) String() string {
+	return fmt.Sprintf("<ScheduledRun %s ScheduleName: %s, DueAt: %v, Outcome: %s, JobUUID: %s, Message: %s>",
+		sr.UUID, sr.ScheduleName, sr.DueAt, sr.Outcome, sr.JobUUID, sr.Message)
+}
+
+func (sr *ScheduledRun) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(sr)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
@@ -0,0 +1,40 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewScheduledRun(t *testing.T) {
+	dueAt := time.Date(2021, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	run := NewScheduledRun("daily", dueAt)
+
+	assert.NotNil(t, run)
+	assert.Equal(t, 36, len(run.UUID))
+	assert.Equal(t, "daily", run.ScheduleName)
+	assert.Equal(t, dueAt, run.DueAt)
+	assert.Equal(t, "", run.Outcome)
+	assert.False(t, run.CreatedAt.IsZero())
+}
+
+func TestScheduledRunJSONAndBack(t *testing.T) {
+	run := NewScheduledRun("daily", time.Date(2021, 1, 2, 3, 0, 0, 0, time.UTC))
+	run.Outcome = ScheduledRunOutcomeStarted
+	run.JobUUID = "job2"
+	run.CancelledJobUUID = "job1"
+
+	gotRun := NewScheduledRunFromJSON(run.EncodeToJSON())
+
+	assert.NotNil(t, gotRun)
+	assert.Equal(t, run.UUID, gotRun.UUID)
+	assert.Equal(t, "daily", gotRun.ScheduleName)
+	assert.True(t, run.DueAt.Equal(gotRun.DueAt))
+	assert.Equal(t, ScheduledRunOutcomeStarted, gotRun.Outcome)
+	assert.Equal(t, "job2", gotRun.JobUUID)
+	assert.Equal(t, "job1", gotRun.CancelledJobUUID)
+
+	assert.Nil(t, NewScheduledRunFromJSON([]byte("{")))
+}
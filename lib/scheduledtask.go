@@ -6,10 +6,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+// Scheduled tasks with higher priority are handed out to workers first. Tasks of the same
+// priority are handed out in the order they were scheduled.
+const TaskPriorityLowest = 0
+const TaskPriorityHighest = 4
+
+func IsValidTaskPriority(priority int) bool {
+	return priority >= TaskPriorityLowest && priority <= TaskPriorityHighest
+}
+
+// clampTaskPriority brings priority within allowed range, e.g. for priorities coming
+// from older messages or from promises that were not validated.
+func clampTaskPriority(priority int) int {
+	if priority < TaskPriorityLowest {
+		return TaskPriorityLowest
+	}
+
+	if priority > TaskPriorityHighest {
+		return TaskPriorityHighest
+	}
+
+	return priority
+}
+
 type ScheduledTask struct {
 	UUID            string
 	Promise         TaskPromise
@@ -17,9 +42,20 @@ type ScheduledTask struct {
 	WorkflowName    string
 	WorkflowVersion string
 	JobUUID         string
+	Attempt         int
+	Priority        int
+	Depth           int
+	ParentTaskUUID  string
 }
 
+// NewScheduledTask makes a scheduled task with priority of the template, unless promise
+// overrides it.
 func NewScheduledTask(promise *TaskPromise, template *TaskTemplate, workflowName string, workflowVersion string, jobUUID string) *ScheduledTask {
+	priority := template.Priority
+	if promise.Priority != nil {
+		priority = *promise.Priority
+	}
+
 	return &ScheduledTask{
 		UUID:            uuid.New().String(),
 		Promise:         *promise,
@@ -27,6 +63,10 @@ func NewScheduledTask(promise *TaskPromise, template *TaskTemplate, workflowName
 		WorkflowName:    workflowName,
 		WorkflowVersion: workflowVersion,
 		JobUUID:         jobUUID,
+		Attempt:         1,
+		Priority:        clampTaskPriority(priority),
+		Depth:           promise.Depth,
+		ParentTaskUUID:  promise.ParentTaskUUID,
 	}
 }
 
@@ -44,6 +84,28 @@ func NewScheduledTaskFromJSON(raw []byte) *ScheduledTask {
 	return scheduledTask
 }
 
+// NewAttempt makes a copy of scheduled task for trying it once more.
+func (st *ScheduledTask) NewAttempt() *ScheduledTask {
+	return &ScheduledTask{
+		UUID:            uuid.New().String(),
+		Promise:         st.Promise,
+		Template:        st.Template,
+		WorkflowName:    st.WorkflowName,
+		WorkflowVersion: st.WorkflowVersion,
+		JobUUID:         st.JobUUID,
+		Attempt:         st.Attempt + 1,
+		Priority:        st.Priority,
+		Depth:           st.Depth,
+		ParentTaskUUID:  st.ParentTaskUUID,
+	}
+}
+
+// LabelSelector returns labels required by the task, formatted by FormatLabels. It is empty
+// if task can run on any worker.
+func (st *ScheduledTask) LabelSelector() string {
+	return FormatLabels(st.Template.RequiredLabels)
+}
+
 func (st *ScheduledTask) Hash() []byte {
 	h := sha256.New()
 
@@ -57,9 +119,54 @@ func (st *ScheduledTask) Hash() []byte {
 	return h.Sum(nil)
 }
 
+// TargetHost returns name of the host that task is going to make HTTP request to, or empty
+// string if that cannot be told upfront. URL is taken from task input that is piped into
+// HTTPAction, if any, and from HTTPAction constructor parameters otherwise. URLs that task
+// builds from its inputs with other actions (e.g. URLJoinAction) are not known until
+// it runs, so such tasks are not held to host policies.
+func (st *ScheduledTask) TargetHost() string {
+	for _, actionTempl := range st.Template.ActionTemplates {
+		if actionTempl.StructName != "HTTPAction" {
+			continue
+		}
+
+		urlStr := ""
+
+		if baseURL, ok := actionTempl.ConstructorParams["baseURL"]; ok {
+			urlStr = baseURL.StringValue
+		}
+
+		for _, dpt := range st.Template.DataPipeTemplates {
+			if dpt.DestActionName != actionTempl.Name || dpt.DestInputName != HTTPActionInputBaseURL ||
+				dpt.TaskInputName == "" {
+				continue
+			}
+
+			chunk := st.Promise.InputDataChunksByInputName[dpt.TaskInputName]
+			if chunk != nil && chunk.PayloadValue != nil && chunk.PayloadValue.StringValue != "" {
+				urlStr = chunk.PayloadValue.StringValue
+			}
+		}
+
+		if urlStr == "" {
+			continue
+		}
+
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			continue
+		}
+
+		return strings.ToLower(u.Hostname())
+	}
+
+	return ""
+}
+
 func (st *ScheduledTask) String() string {
-	return fmt.Sprintf("<ScheduledTask %s Promise: %v Template: %v, WorkflowName: %s, WorkflowVersion: %s, JobUUID: %s>",
-		st.UUID, &st.Promise, &st.Template, st.WorkflowName, st.WorkflowVersion, st.JobUUID)
+	return fmt.Sprintf("<ScheduledTask %s Promise: %v Template: %v, WorkflowName: %s, WorkflowVersion: %s, JobUUID: %s, Attempt: %d, Priority: %d, Depth: %d>",
+		st.UUID, &st.Promise, &st.Template, st.WorkflowName, st.WorkflowVersion, st.JobUUID, st.Attempt, st.Priority,
+		st.Depth)
 }
 
 func (st *ScheduledTask) EncodeToJSON() []byte {
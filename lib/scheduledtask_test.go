@@ -23,3 +23,87 @@ func TestNewScheduledTask(t *testing.T) {
 	assert.Equal(t, workflowVersion, scheduledTask.WorkflowVersion)
 	assert.Equal(t, jobUUID, scheduledTask.JobUUID)
 }
+
+func TestScheduledTaskNewAttempt(t *testing.T) {
+	taskPromise := &TaskPromise{UUID: "D412D565-B2A8-4BE3-B3CB-B37008FDA099"}
+	taskTemplate := &TaskTemplate{TaskName: "testTask"}
+
+	scheduledTask := NewScheduledTask(taskPromise, taskTemplate, "testWorkflow", "2.0",
+		"5369DD61-E98E-465E-9619-4641D06728FB")
+
+	assert.Equal(t, 1, scheduledTask.Attempt)
+
+	newAttempt := scheduledTask.NewAttempt()
+
+	assert.NotEqual(t, scheduledTask.UUID, newAttempt.UUID)
+	assert.Equal(t, 2, newAttempt.Attempt)
+	assert.Equal(t, scheduledTask.Promise, newAttempt.Promise)
+	assert.Equal(t, scheduledTask.Template, newAttempt.Template)
+	assert.Equal(t, scheduledTask.WorkflowName, newAttempt.WorkflowName)
+	assert.Equal(t, scheduledTask.WorkflowVersion, newAttempt.WorkflowVersion)
+	assert.Equal(t, scheduledTask.JobUUID, newAttempt.JobUUID)
+	assert.Equal(t, scheduledTask.Hash(), newAttempt.Hash())
+}
+
+func TestScheduledTaskTargetHost(t *testing.T) {
+	taskTempl := NewTaskTemplate("GetPage", false)
+	taskTempl.AddActionTemplate(NewActionTemplate("HTTP1", "HTTPAction", map[string]interface{}{
+		"baseURL": "https://Books.ToScrape.com:8080/catalogue/",
+		"method":  "GET",
+	}))
+
+	promise := NewTaskPromise("GetPage", "WF0", "job1", map[string]*DataChunk{})
+
+	scheduledTask := NewScheduledTask(promise, taskTempl, "WF0", "v1", "job1")
+	assert.Equal(t, "books.toscrape.com", scheduledTask.TargetHost())
+
+	// URL coming from task input takes precedence over the one in constructor params.
+	taskTempl.ConnectInputToActionTemplate("url", "HTTP1", HTTPActionInputBaseURL)
+
+	urlChunk, _ := NewDataChunk("http://quotes.toscrape.com/page/2/")
+	promise = NewTaskPromise("GetPage", "WF0", "job1", map[string]*DataChunk{"url": urlChunk})
+
+	scheduledTask = NewScheduledTask(promise, taskTempl, "WF0", "v1", "job1")
+	assert.Equal(t, "quotes.toscrape.com", scheduledTask.TargetHost())
+
+	scheduledTask = NewScheduledTask(promise, NewTaskTemplate("GetPage", false), "WF0", "v1", "job1")
+	assert.Equal(t, "", scheduledTask.TargetHost())
+}
+
+func TestScheduledTaskPriority(t *testing.T) {
+	taskPromise := &TaskPromise{UUID: "D412D565-B2A8-4BE3-B3CB-B37008FDA099"}
+	taskTemplate := &TaskTemplate{TaskName: "testTask", Priority: 2}
+
+	scheduledTask := NewScheduledTask(taskPromise, taskTemplate, "testWorkflow", "2.0", "job1")
+	assert.Equal(t, 2, scheduledTask.Priority)
+
+	priority := 4
+	taskPromise.Priority = &priority
+
+	scheduledTask = NewScheduledTask(taskPromise, taskTemplate, "testWorkflow", "2.0", "job1")
+	assert.Equal(t, 4, scheduledTask.Priority)
+	assert.Equal(t, 4, scheduledTask.NewAttempt().Priority)
+
+	priority = 10
+
+	scheduledTask = NewScheduledTask(taskPromise, taskTemplate, "testWorkflow", "2.0", "job1")
+	assert.Equal(t, TaskPriorityHighest, scheduledTask.Priority)
+
+	gotScheduledTask := NewScheduledTaskFromJSON(scheduledTask.EncodeToJSON())
+	assert.Equal(t, TaskPriorityHighest, gotScheduledTask.Priority)
+}
+
+func TestScheduledTaskDepth(t *testing.T) {
+	taskPromise := &TaskPromise{UUID: "D412D565-B2A8-4BE3-B3CB-B37008FDA099", Depth: 3,
+		ParentTaskUUID: "0F5C1D76-2A53-4B5E-9A3A-0B8D0F7C1E11"}
+
+	scheduledTask := NewScheduledTask(taskPromise, &TaskTemplate{TaskName: "testTask"}, "testWorkflow", "2.0", "job1")
+
+	assert.Equal(t, 3, scheduledTask.Depth)
+	assert.Equal(t, taskPromise.ParentTaskUUID, scheduledTask.ParentTaskUUID)
+
+	newAttempt := scheduledTask.NewAttempt()
+
+	assert.Equal(t, 3, newAttempt.Depth)
+	assert.Equal(t, taskPromise.ParentTaskUUID, newAttempt.ParentTaskUUID)
+}
@@ -3,10 +3,16 @@ package spsw
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// Defaults for how long a scheduled task may stay unacknowledged before being redelivered,
+// and how many times it will be delivered before being considered failed.
+const SpiderBusDefaultVisibilityTimeout = 5 * time.Minute
+const SpiderBusDefaultMaxDeliveries = 3
+
 type SpiderBus struct {
 	UUID    string
 	Backend SpiderBusBackend
@@ -35,6 +41,34 @@ func (sb *SpiderBus) Enqueue(x interface{}) error {
 		return sb.Backend.SendItem(item)
 	}
 
+	if deadLetter, okDeadLetter := x.(*DeadLetter); okDeadLetter {
+		return sb.Backend.SendDeadLetter(deadLetter)
+	}
+
+	if job, okJob := x.(*Job); okJob {
+		return sb.Backend.SendJob(job)
+	}
+
+	if report, okReport := x.(*ManagerReport); okReport {
+		return sb.Backend.SendManagerReport(report)
+	}
+
+	if checkpoint, okCheckpoint := x.(*ManagerCheckpoint); okCheckpoint {
+		return sb.Backend.SaveManagerCheckpoint(checkpoint)
+	}
+
+	if jobFinished, okJobFinished := x.(*JobFinished); okJobFinished {
+		return sb.Backend.SendJobFinished(jobFinished)
+	}
+
+	if jobControl, okJobControl := x.(*JobControl); okJobControl {
+		return sb.Backend.SendJobControl(jobControl)
+	}
+
+	if heartbeat, okHeartbeat := x.(*WorkerHeartbeat); okHeartbeat {
+		return sb.Backend.SaveWorkerHeartbeat(heartbeat)
+	}
+
 	return errors.New(fmt.Sprintf("SpiderBus.Enqueue: argument not recognised: %v", x))
 }
 
@@ -42,6 +76,9 @@ const SpiderBusEntryTypeScheduledTask = "SpiderBusEntryTypeScheduledTask"
 const SpiderBusEntryTypeTaskPromise = "SpiderBusEntryTypeTaskPromise"
 const SpiderBusEntryTypeTaskResult = "SpiderBusEntryTypeTaskResult"
 const SpiderBusEntryTypeItem = "SpiderBusEntryTypeItem"
+const SpiderBusEntryTypeJob = "SpiderBusEntryTypeJob"
+const SpiderBusEntryTypeManagerReport = "SpiderBusEntryTypeManagerReport"
+const SpiderBusEntryTypeJobFinished = "SpiderBusEntryTypeJobFinished"
 
 func (sb *SpiderBus) Dequeue(entryType string) (interface{}, error) {
 	if sb.Backend == nil {
@@ -49,7 +86,7 @@ func (sb *SpiderBus) Dequeue(entryType string) (interface{}, error) {
 	}
 
 	if entryType == SpiderBusEntryTypeScheduledTask {
-		return sb.Backend.ReceiveScheduledTask(), nil
+		return sb.Backend.ReceiveScheduledTask(nil), nil
 	}
 
 	if entryType == SpiderBusEntryTypeTaskPromise {
@@ -64,5 +101,93 @@ func (sb *SpiderBus) Dequeue(entryType string) (interface{}, error) {
 		return sb.Backend.ReceiveTaskResult(), nil
 	}
 
+	if entryType == SpiderBusEntryTypeJob {
+		return sb.Backend.ReceiveJob(), nil
+	}
+
+	if entryType == SpiderBusEntryTypeManagerReport {
+		return sb.Backend.ReceiveManagerReport(), nil
+	}
+
+	if entryType == SpiderBusEntryTypeJobFinished {
+		return sb.Backend.ReceiveJobFinished(), nil
+	}
+
 	return nil, errors.New(fmt.Sprintf("SpiderBus.Dequeue: unrecognised entryType: %s", entryType))
 }
+
+// DequeueScheduledTask receives scheduled task that worker with given labels may run. Unlike
+// Dequeue(SpiderBusEntryTypeScheduledTask), which only gets tasks that don't require any
+// labels, it also gets tasks requiring some or all of worker labels.
+func (sb *SpiderBus) DequeueScheduledTask(workerLabels map[string]string) (*ScheduledTask, error) {
+	if sb.Backend == nil {
+		return nil, errors.New("SpiderBus has no backend assigned")
+	}
+
+	return sb.Backend.ReceiveScheduledTask(workerLabels), nil
+}
+
+// AckScheduledTask tells the backend that scheduled task was done and result for it has been
+// sent, so it does not need to be redelivered.
+func (sb *SpiderBus) AckScheduledTask(scheduledTaskUUID string) error {
+	if sb.Backend == nil {
+		return errors.New("SpiderBus has no backend assigned")
+	}
+
+	return sb.Backend.AckScheduledTask(scheduledTaskUUID)
+}
+
+// ListDeadLetters returns scheduled tasks that ran out of attempts for given job, or for all
+// jobs if jobUUID is empty. Dead letters stay on the bus until removed.
+func (sb *SpiderBus) ListDeadLetters(jobUUID string) ([]*DeadLetter, error) {
+	if sb.Backend == nil {
+		return nil, errors.New("SpiderBus has no backend assigned")
+	}
+
+	return sb.Backend.ListDeadLetters(jobUUID)
+}
+
+func (sb *SpiderBus) RemoveDeadLetter(deadLetterUUID string) error {
+	if sb.Backend == nil {
+		return errors.New("SpiderBus has no backend assigned")
+	}
+
+	return sb.Backend.RemoveDeadLetter(deadLetterUUID)
+}
+
+// ReceiveJobControls returns job controls sent after given cursor, along with cursor to
+// pass next time. Empty cursor means from the very beginning. As job controls are
+// broadcast, each consumer keeps track of its own cursor.
+func (sb *SpiderBus) ReceiveJobControls(cursor string) ([]*JobControl, string, error) {
+	if sb.Backend == nil {
+		return nil, cursor, errors.New("SpiderBus has no backend assigned")
+	}
+
+	return sb.Backend.ReceiveJobControls(cursor)
+}
+
+// LoadManagerCheckpoint returns the latest checkpoint Manager has made for given job.
+func (sb *SpiderBus) LoadManagerCheckpoint(jobUUID string) (*ManagerCheckpoint, error) {
+	if sb.Backend == nil {
+		return nil, errors.New("SpiderBus has no backend assigned")
+	}
+
+	return sb.Backend.LoadManagerCheckpoint(jobUUID)
+}
+
+// ListWorkerHeartbeats returns the latest heartbeat of each worker that has sent one.
+func (sb *SpiderBus) ListWorkerHeartbeats() ([]*WorkerHeartbeat, error) {
+	if sb.Backend == nil {
+		return nil, errors.New("SpiderBus has no backend assigned")
+	}
+
+	return sb.Backend.ListWorkerHeartbeats()
+}
+
+// Close releases whatever backend holds on to, e.g. connection to Redis. Bus must not be
+// used afterwards.
+func (sb *SpiderBus) Close() {
+	if closer, ok := sb.Backend.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
@@ -28,16 +28,15 @@ func (tb *TestSpiderBusBackend) SendScheduledTask(scheduledTask *ScheduledTask)
 	return nil
 }
 
-func (tb *TestSpiderBusBackend) ReceiveScheduledTask() *ScheduledTask {
-	if len(tb.ScheduledTasks) == 0 {
-		return nil
+func (tb *TestSpiderBusBackend) ReceiveScheduledTask(workerLabels map[string]string) *ScheduledTask {
+	for i, scheduledTask := range tb.ScheduledTasks {
+		if LabelsMatch(workerLabels, scheduledTask.Template.RequiredLabels) {
+			tb.ScheduledTasks = append(tb.ScheduledTasks[:i], tb.ScheduledTasks[i+1:]...)
+			return scheduledTask
+		}
 	}
 
-	var scheduledTask *ScheduledTask
-
-	scheduledTask, tb.ScheduledTasks = tb.ScheduledTasks[0], tb.ScheduledTasks[1:]
-
-	return scheduledTask
+	return nil
 }
 
 func (tb *TestSpiderBusBackend) SendTaskPromise(taskPromise *TaskPromise) error {
@@ -110,6 +109,28 @@ func TestSpiderBusEnqueueDequeueScheduledTask(t *testing.T) {
 	assert.Equal(t, scheduledTask, gotScheduledTask)
 }
 
+func TestSpiderBusDequeueScheduledTaskByLabels(t *testing.T) {
+	testBackend := NewTestSpiderBusBackend()
+
+	spiderBus := NewSpiderBus()
+	spiderBus.Backend = testBackend
+
+	scheduledTask := &ScheduledTask{
+		UUID:     "0B1C36D4-2F7E-4E5A-9C8B-57D1E0A3F6B2",
+		Template: TaskTemplate{RequiredLabels: map[string]string{"proxy": "residential"}},
+	}
+
+	assert.Nil(t, spiderBus.Enqueue(scheduledTask))
+
+	gotScheduledTask, err := spiderBus.Dequeue(SpiderBusEntryTypeScheduledTask)
+	assert.Nil(t, err)
+	assert.Nil(t, gotScheduledTask)
+
+	gotScheduledTask, err = spiderBus.DequeueScheduledTask(map[string]string{"proxy": "residential"})
+	assert.Nil(t, err)
+	assert.Equal(t, scheduledTask, gotScheduledTask)
+}
+
 func TestSpiderBusEnqueueDequeueTaskPromise(t *testing.T) {
 	testBackend := NewTestSpiderBusBackend()
 
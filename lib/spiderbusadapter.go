@@ -2,148 +2,433 @@ package spsw
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
+// Heartbeats are only read from the bus this often, as workers send them rarely anyway.
+const SpiderBusAdapterWorkerHeartbeatsPollInterval = WorkerHeartbeatInterval
+
 type SpiderBusAdapter struct {
-	UUID              string
-	Bus               *SpiderBus
-	ScheduledTasksIn  chan *ScheduledTask
-	ScheduledTasksOut chan *ScheduledTask
-	TaskPromisesIn    chan *TaskPromise
-	TaskPromisesOut   chan *TaskPromise
-	TaskResultsIn     chan *TaskResult
-	TaskResultsOut    chan *TaskResult
-	ItemsIn           chan *Item
-	ItemsOut          chan *Item
+	UUID                string
+	Bus                 *SpiderBus
+	ScheduledTasksIn    chan *ScheduledTask
+	ScheduledTasksOut   chan *ScheduledTask
+	TaskPromisesIn      chan *TaskPromise
+	TaskPromisesOut     chan *TaskPromise
+	TaskResultsIn       chan *TaskResult
+	TaskResultsOut      chan *TaskResult
+	ItemsIn             chan *Item
+	ItemsOut            chan *Item
+	DeadLettersIn       chan *DeadLetter
+	JobsIn              chan *Job
+	JobsOut             chan *Job
+	ManagerReportsIn    chan *ManagerReport
+	ManagerReportsOut   chan *ManagerReport
+	JobsFinishedIn      chan *JobFinished
+	JobsFinishedOut     chan *JobFinished
+	CheckpointsIn       chan *ManagerCheckpoint
+	JobControlsIn       chan *JobControl
+	JobControlsOut      chan *JobControl
+	WorkerHeartbeatsIn  chan *WorkerHeartbeat
+	WorkerHeartbeatsOut chan *WorkerHeartbeat
+
+	// Scheduled tasks are only received if they can run on worker having these labels.
+	WorkerLabels map[string]string
+
+	stopReceivingOnce sync.Once
+	stopReceiving     chan struct{}
+	receivers         sync.WaitGroup
+	stopSendingOnce   sync.Once
+	stopSending       chan struct{}
+	senders           sync.WaitGroup
 }
 
 func NewSpiderBusAdapterForWorker(sb *SpiderBus, w *Worker) *SpiderBusAdapter {
 	return &SpiderBusAdapter{
-		UUID:              uuid.New().String(),
-		Bus:               sb,
-		ScheduledTasksOut: w.ScheduledTasksIn,
-		TaskPromisesIn:    w.TaskPromisesOut,
-		TaskResultsIn:     w.TaskResultsOut,
+		UUID:               uuid.New().String(),
+		Bus:                sb,
+		ScheduledTasksOut:  w.ScheduledTasksIn,
+		TaskPromisesIn:     w.TaskPromisesOut,
+		TaskResultsIn:      w.TaskResultsOut,
+		JobControlsOut:     w.JobControlsIn,
+		WorkerHeartbeatsIn: w.HeartbeatsOut,
+		WorkerLabels:       w.Labels,
 	}
 }
 
 func NewSpiderBusAdapterForExporter(sb *SpiderBus, e *Exporter) *SpiderBusAdapter {
 	return &SpiderBusAdapter{
-		UUID:     uuid.New().String(),
-		Bus:      sb,
-		ItemsOut: e.ItemsIn,
+		UUID:            uuid.New().String(),
+		Bus:             sb,
+		ItemsOut:        e.ItemsIn,
+		JobsFinishedOut: e.JobsFinishedIn,
 	}
 }
 
 func NewSpiderBusAdapterForManager(sb *SpiderBus, m *Manager) *SpiderBusAdapter {
 	return &SpiderBusAdapter{
-		UUID:             uuid.New().String(),
-		Bus:              sb,
-		TaskPromisesOut:  m.TaskPromisesIn,
-		TaskResultsOut:   m.TaskResultsIn,
-		ScheduledTasksIn: m.ScheduledTasksOut,
-		ItemsIn:          m.ItemsOut,
+		UUID:                uuid.New().String(),
+		Bus:                 sb,
+		TaskPromisesOut:     m.TaskPromisesIn,
+		TaskResultsOut:      m.TaskResultsIn,
+		ScheduledTasksIn:    m.ScheduledTasksOut,
+		ItemsIn:             m.ItemsOut,
+		DeadLettersIn:       m.DeadLettersOut,
+		ManagerReportsIn:    m.ManagerReportsOut,
+		JobsFinishedIn:      m.JobsFinishedOut,
+		CheckpointsIn:       m.CheckpointsOut,
+		JobControlsOut:      m.JobControlsIn,
+		WorkerHeartbeatsOut: m.WorkerHeartbeatsIn,
+	}
+}
+
+func NewSpiderBusAdapterForMaster(sb *SpiderBus, m *Master) *SpiderBusAdapter {
+	return &SpiderBusAdapter{
+		UUID:                uuid.New().String(),
+		Bus:                 sb,
+		JobsIn:              m.JobsOut,
+		JobControlsIn:       m.JobControlsOut,
+		ManagerReportsOut:   m.ManagerReportsIn,
+		WorkerHeartbeatsOut: m.WorkerHeartbeatsIn,
+	}
+}
+
+// receiveFromBus keeps running given function, which takes a message off the bus and passes
+// it on, until StopReceiving is called.
+func (sba *SpiderBusAdapter) receiveFromBus(receiveOne func()) {
+	sba.receivers.Add(1)
+
+	go func() {
+		defer sba.receivers.Done()
+
+		for {
+			select {
+			case <-sba.stopReceiving:
+				return
+			default:
+			}
+
+			receiveOne()
+		}
+	}()
+}
+
+// sleep waits for given duration, unless StopReceiving is called in the meantime.
+func (sba *SpiderBusAdapter) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-sba.stopReceiving:
+	}
+}
+
+// sendToBus passes messages from given channel to sendOne until Stop is called. Messages
+// that have already been handed to the channel by then are still passed on.
+func (sba *SpiderBusAdapter) sendToBus(ch interface{}, sendOne func(x interface{})) {
+	sba.senders.Add(1)
+
+	go func() {
+		defer sba.senders.Done()
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sba.stopSending)},
+		}
+
+		for {
+			chosen, x, ok := reflect.Select(cases)
+			if chosen == 1 {
+				break
+			}
+
+			if !ok {
+				return
+			}
+
+			sendOne(x.Interface())
+		}
+
+		for {
+			x, ok := cases[0].Chan.TryRecv()
+			if !ok {
+				return
+			}
+
+			sendOne(x.Interface())
+		}
+	}()
+}
+
+// passOn hands message taken off the bus over to given channel. If StopReceiving is called
+// while nobody reads the channel (e.g. because its reader has already returned), message is
+// dropped instead, so that stopping never blocks on a reader that is gone.
+func (sba *SpiderBusAdapter) passOn(ch interface{}, x interface{}) {
+	chValue := reflect.ValueOf(ch)
+	xValue := reflect.ValueOf(x)
+
+	if chValue.TrySend(xValue) {
+		return
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: chValue, Send: xValue},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sba.stopReceiving)},
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == 1 {
+		log.Warn(fmt.Sprintf("SpiderBusAdapter %s dropping %v, as nobody takes it anymore", sba.UUID, x))
+	}
+}
+
+func (sba *SpiderBusAdapter) enqueue(x interface{}) {
+	err := sba.Bus.Enqueue(x)
+	if err != nil {
+		log.Error(fmt.Sprintf("SpiderBusAdapter %s failed to enqueue %v: %v", sba.UUID, x, err))
 	}
 }
 
 func (sba *SpiderBusAdapter) Start() {
 	log.Info(fmt.Sprintf("SpiderBusAdapter %s starting run loops", sba.UUID))
 
+	sba.stopReceiving = make(chan struct{})
+	sba.stopSending = make(chan struct{})
+
 	if sba.ScheduledTasksIn != nil {
-		go func() {
-			for scheduledTask := range sba.ScheduledTasksIn {
-				if scheduledTask != nil {
-					sba.Bus.Enqueue(scheduledTask)
-				}
+		sba.sendToBus(sba.ScheduledTasksIn, func(x interface{}) {
+			if scheduledTask := x.(*ScheduledTask); scheduledTask != nil {
+				sba.enqueue(scheduledTask)
 			}
-		}()
+		})
 	}
 
 	if sba.ScheduledTasksOut != nil {
-		go func() {
-			for {
-				scheduledTask, err := sba.Bus.Dequeue(SpiderBusEntryTypeScheduledTask)
-
-				if scheduledTask == nil || err != nil {
-					time.Sleep(1)
-					continue
-				}
+		sba.receiveFromBus(func() {
+			scheduledTask, err := sba.Bus.DequeueScheduledTask(sba.WorkerLabels)
 
-				sba.ScheduledTasksOut <- scheduledTask.(*ScheduledTask)
+			if scheduledTask == nil || err != nil {
+				time.Sleep(1)
+				return
 			}
-		}()
+
+			sba.passOn(sba.ScheduledTasksOut, scheduledTask)
+		})
 	}
 
 	if sba.TaskPromisesIn != nil {
-		go func() {
-			for taskPromise := range sba.TaskPromisesIn {
-				sba.Bus.Enqueue(taskPromise)
-			}
-		}()
+		sba.sendToBus(sba.TaskPromisesIn, sba.enqueue)
 	}
 
 	if sba.TaskPromisesOut != nil {
-		go func() {
-			for {
-				taskPromise, err := sba.Bus.Dequeue(SpiderBusEntryTypeTaskPromise)
+		sba.receiveFromBus(func() {
+			x, err := sba.Bus.Dequeue(SpiderBusEntryTypeTaskPromise)
 
-				if taskPromise == nil || err != nil {
-					time.Sleep(10 * time.Second)
-					continue
-				}
-
-				sba.TaskPromisesOut <- taskPromise.(*TaskPromise)
+			// Bus gives typed nil (e.g. (*TaskPromise)(nil)) if there's nothing to dequeue.
+			taskPromise, ok := x.(*TaskPromise)
+			if !ok || taskPromise == nil || err != nil {
+				sba.sleep(10 * time.Second)
+				return
 			}
-		}()
+
+			sba.passOn(sba.TaskPromisesOut, taskPromise)
+		})
 	}
 
 	if sba.TaskResultsIn != nil {
-		go func() {
-			for taskResult := range sba.TaskResultsIn {
-				sba.Bus.Enqueue(taskResult)
+		sba.sendToBus(sba.TaskResultsIn, func(x interface{}) {
+			taskResult := x.(*TaskResult)
+
+			err := sba.Bus.Enqueue(taskResult)
+			if err != nil {
+				// Leaving scheduled task unacknowledged so that it would be redelivered.
+				log.Error(fmt.Sprintf("SpiderBusAdapter %s failed to enqueue task result %v: %v", sba.UUID,
+					taskResult, err))
+				return
 			}
-		}()
+
+			err = sba.Bus.AckScheduledTask(taskResult.ScheduledTaskUUID)
+			if err != nil {
+				log.Error(fmt.Sprintf("SpiderBusAdapter %s failed to acknowledge scheduled task %s: %v", sba.UUID,
+					taskResult.ScheduledTaskUUID, err))
+			}
+		})
 	}
 
 	if sba.TaskResultsOut != nil {
-		go func() {
-			for {
-				taskResult, err := sba.Bus.Dequeue(SpiderBusEntryTypeTaskResult)
-
-				if taskResult == nil || err != nil {
-					time.Sleep(1)
-					continue
-				}
+		sba.receiveFromBus(func() {
+			x, err := sba.Bus.Dequeue(SpiderBusEntryTypeTaskResult)
 
-				sba.TaskResultsOut <- taskResult.(*TaskResult)
+			taskResult, ok := x.(*TaskResult)
+			if !ok || taskResult == nil || err != nil {
+				time.Sleep(1)
+				return
 			}
-		}()
+
+			sba.passOn(sba.TaskResultsOut, taskResult)
+		})
 	}
 
 	if sba.ItemsIn != nil {
-		go func() {
-			for item := range sba.ItemsIn {
-				sba.Bus.Enqueue(item)
-			}
-		}()
+		sba.sendToBus(sba.ItemsIn, sba.enqueue)
+	}
+
+	if sba.DeadLettersIn != nil {
+		sba.sendToBus(sba.DeadLettersIn, sba.enqueue)
 	}
 
 	if sba.ItemsOut != nil {
-		go func() {
-			for {
-				item, err := sba.Bus.Dequeue(SpiderBusEntryTypeItem)
+		sba.receiveFromBus(func() {
+			x, err := sba.Bus.Dequeue(SpiderBusEntryTypeItem)
+
+			item, ok := x.(*Item)
+			if !ok || item == nil || err != nil {
+				time.Sleep(1)
+				return
+			}
+
+			sba.passOn(sba.ItemsOut, item)
+		})
+	}
+
+	if sba.JobsIn != nil {
+		sba.sendToBus(sba.JobsIn, sba.enqueue)
+	}
+
+	if sba.JobsOut != nil {
+		sba.receiveFromBus(func() {
+			x, err := sba.Bus.Dequeue(SpiderBusEntryTypeJob)
+
+			job, ok := x.(*Job)
+			if !ok || job == nil || err != nil {
+				time.Sleep(1)
+				return
+			}
+
+			sba.passOn(sba.JobsOut, job)
+		})
+	}
+
+	if sba.ManagerReportsIn != nil {
+		sba.sendToBus(sba.ManagerReportsIn, sba.enqueue)
+	}
+
+	if sba.ManagerReportsOut != nil {
+		sba.receiveFromBus(func() {
+			x, err := sba.Bus.Dequeue(SpiderBusEntryTypeManagerReport)
+
+			report, ok := x.(*ManagerReport)
+			if !ok || report == nil || err != nil {
+				time.Sleep(1)
+				return
+			}
+
+			sba.passOn(sba.ManagerReportsOut, report)
+		})
+	}
+
+	if sba.JobsFinishedIn != nil {
+		sba.sendToBus(sba.JobsFinishedIn, sba.enqueue)
+	}
+
+	if sba.JobsFinishedOut != nil {
+		sba.receiveFromBus(func() {
+			x, err := sba.Bus.Dequeue(SpiderBusEntryTypeJobFinished)
+
+			jobFinished, ok := x.(*JobFinished)
+			if !ok || jobFinished == nil || err != nil {
+				time.Sleep(1)
+				return
+			}
+
+			sba.passOn(sba.JobsFinishedOut, jobFinished)
+		})
+	}
 
-				if item == nil || err != nil {
-					time.Sleep(1)
-					continue
-				}
+	if sba.CheckpointsIn != nil {
+		sba.sendToBus(sba.CheckpointsIn, sba.enqueue)
+	}
+
+	if sba.JobControlsIn != nil {
+		sba.sendToBus(sba.JobControlsIn, sba.enqueue)
+	}
+
+	if sba.JobControlsOut != nil {
+		// Job controls are broadcast, so each adapter keeps its own position.
+		cursor := ""
+
+		sba.receiveFromBus(func() {
+			jobControls, newCursor, err := sba.Bus.ReceiveJobControls(cursor)
+			if err != nil {
+				log.Error(fmt.Sprintf("SpiderBusAdapter %s failed to receive job controls: %v", sba.UUID, err))
+				sba.sleep(1 * time.Second)
+				return
+			}
+
+			cursor = newCursor
+
+			for _, jobControl := range jobControls {
+				sba.passOn(sba.JobControlsOut, jobControl)
+			}
+		})
+	}
+
+	if sba.WorkerHeartbeatsIn != nil {
+		sba.sendToBus(sba.WorkerHeartbeatsIn, sba.enqueue)
+	}
+
+	if sba.WorkerHeartbeatsOut != nil {
+		sba.receiveFromBus(func() {
+			heartbeats, err := sba.Bus.ListWorkerHeartbeats()
+			if err != nil {
+				log.Error(fmt.Sprintf("SpiderBusAdapter %s failed to list worker heartbeats: %v", sba.UUID, err))
+			}
 
-				sba.ItemsOut <- item.(*Item)
+			for _, heartbeat := range heartbeats {
+				sba.passOn(sba.WorkerHeartbeatsOut, heartbeat)
 			}
-		}()
+
+			sba.sleep(SpiderBusAdapterWorkerHeartbeatsPollInterval)
+		})
 	}
 }
+
+// StopReceiving stops taking messages off the bus, and waits until messages already taken
+// are passed on. Hence, whoever reads them should keep doing so until StopReceiving returns,
+// as messages that nobody takes by then are dropped.
+func (sba *SpiderBusAdapter) StopReceiving() {
+	if sba.stopReceiving == nil {
+		return
+	}
+
+	sba.stopReceivingOnce.Do(func() {
+		log.Info(fmt.Sprintf("SpiderBusAdapter %s stopping to receive from bus", sba.UUID))
+		close(sba.stopReceiving)
+	})
+
+	sba.receivers.Wait()
+}
+
+// Stop stops all the run loops, once messages that were handed to the adapter are put
+// onto the bus. To avoid losing messages, whoever adapter sends messages for should be
+// stopped before.
+func (sba *SpiderBusAdapter) Stop() {
+	if sba.stopSending == nil {
+		return
+	}
+
+	sba.StopReceiving()
+
+	sba.stopSendingOnce.Do(func() {
+		log.Info(fmt.Sprintf("SpiderBusAdapter %s stopping", sba.UUID))
+		close(sba.stopSending)
+	})
+
+	sba.senders.Wait()
+}
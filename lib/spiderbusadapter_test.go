@@ -2,6 +2,7 @@ package spsw
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,6 +18,7 @@ func TestNewSpiderBusAdapterForWorker(t *testing.T) {
 	assert.Equal(t, spiderBus, adapter.Bus)
 	assert.Equal(t, worker.ScheduledTasksIn, adapter.ScheduledTasksOut)
 	assert.Equal(t, worker.TaskPromisesOut, adapter.TaskPromisesIn)
+	assert.Equal(t, worker.JobControlsIn, adapter.JobControlsOut)
 	assert.Nil(t, adapter.ScheduledTasksIn)
 	assert.Nil(t, adapter.TaskPromisesOut)
 	assert.Nil(t, adapter.ItemsOut)
@@ -32,6 +34,7 @@ func TestNewSpiderBusAdapterForExporter(t *testing.T) {
 
 	assert.Equal(t, spiderBus, adapter.Bus)
 	assert.Equal(t, exporter.ItemsIn, adapter.ItemsOut)
+	assert.Equal(t, exporter.JobsFinishedIn, adapter.JobsFinishedOut)
 	assert.Nil(t, adapter.ScheduledTasksIn)
 	assert.Nil(t, adapter.ScheduledTasksOut)
 	assert.Nil(t, adapter.TaskPromisesIn)
@@ -54,8 +57,117 @@ func TestNewSpiderBusAdapterForManager(t *testing.T) {
 	assert.Equal(t, manager.TaskResultsIn, adapter.TaskResultsOut)
 	assert.Equal(t, manager.ScheduledTasksOut, adapter.ScheduledTasksIn)
 	assert.Equal(t, manager.ItemsOut, adapter.ItemsIn)
+	assert.Equal(t, manager.DeadLettersOut, adapter.DeadLettersIn)
+	assert.Equal(t, manager.ManagerReportsOut, adapter.ManagerReportsIn)
+	assert.Equal(t, manager.JobsFinishedOut, adapter.JobsFinishedIn)
+	assert.Equal(t, manager.CheckpointsOut, adapter.CheckpointsIn)
+	assert.Equal(t, manager.JobControlsIn, adapter.JobControlsOut)
+	assert.Nil(t, adapter.JobsOut)
 	assert.Nil(t, adapter.TaskPromisesIn)
 	assert.Nil(t, adapter.ScheduledTasksOut)
 	assert.Nil(t, adapter.TaskResultsIn)
 	assert.Nil(t, adapter.ItemsOut)
 }
+
+func TestNewSpiderBusAdapterForMaster(t *testing.T) {
+	spiderBus := NewSpiderBus()
+	master := NewMaster()
+
+	adapter := NewSpiderBusAdapterForMaster(spiderBus, master)
+
+	assert.NotNil(t, adapter)
+
+	assert.Equal(t, spiderBus, adapter.Bus)
+	assert.Equal(t, master.JobsOut, adapter.JobsIn)
+	assert.Equal(t, master.JobControlsOut, adapter.JobControlsIn)
+	assert.Equal(t, master.ManagerReportsIn, adapter.ManagerReportsOut)
+	assert.Nil(t, adapter.JobsOut)
+	assert.Nil(t, adapter.ScheduledTasksIn)
+	assert.Nil(t, adapter.TaskResultsOut)
+}
+
+func TestSpiderBusAdapterStopPassesOnHandedOverMessages(t *testing.T) {
+	spiderBus := NewSpiderBus()
+	spiderBus.Backend = NewInMemorySpiderBusBackend()
+
+	manager := NewManager(nil)
+
+	adapter := NewSpiderBusAdapterForManager(spiderBus, manager)
+	adapter.Start()
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "job1")
+
+	manager.ScheduledTasksOut <- scheduledTask
+
+	adapter.Stop()
+
+	gotTask, err := spiderBus.DequeueScheduledTask(nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, gotTask)
+	assert.Equal(t, scheduledTask.UUID, gotTask.UUID)
+
+	// Stopping again is fine.
+	adapter.Stop()
+}
+
+func TestSpiderBusAdapterStopReceiving(t *testing.T) {
+	spiderBus := NewSpiderBus()
+	spiderBus.Backend = NewInMemorySpiderBusBackend()
+
+	worker := NewWorker()
+
+	adapter := NewSpiderBusAdapterForWorker(spiderBus, worker)
+	adapter.Start()
+	adapter.StopReceiving()
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "job1")
+
+	assert.Nil(t, spiderBus.Enqueue(scheduledTask))
+
+	select {
+	case <-worker.ScheduledTasksIn:
+		t.Fatal("Adapter took scheduled task off the bus after it stopped receiving")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	adapter.Stop()
+
+	// Task is left for other workers.
+	gotTask, err := spiderBus.DequeueScheduledTask(nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, gotTask)
+	assert.Equal(t, scheduledTask.UUID, gotTask.UUID)
+}
+
+func TestSpiderBusAdapterStopWithoutReader(t *testing.T) {
+	spiderBus := NewSpiderBus()
+	spiderBus.Backend = NewInMemorySpiderBusBackend()
+
+	// Manager is never run, as if its Run has already returned, so nobody reads what
+	// adapter takes off the bus.
+	manager := NewManager(nil)
+
+	taskResult := NewTaskResult("job1", "", "", true, nil)
+	assert.Nil(t, spiderBus.Enqueue(taskResult))
+
+	adapter := NewSpiderBusAdapterForManager(spiderBus, manager)
+	adapter.Start()
+
+	// Giving adapter time to take task result off the bus.
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+
+	go func() {
+		adapter.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adapter did not stop while nobody was reading from it")
+	}
+}
@@ -3,7 +3,9 @@ package spsw
 type SpiderBusBackend interface {
 	IsScheduledTaskDuplicated(scheduledTask *ScheduledTask, jobUUID string) bool
 	SendScheduledTask(scheduledTask *ScheduledTask) error
-	ReceiveScheduledTask() *ScheduledTask
+	// ReceiveScheduledTask only hands out tasks whose RequiredLabels match given worker labels.
+	ReceiveScheduledTask(workerLabels map[string]string) *ScheduledTask
+	AckScheduledTask(scheduledTaskUUID string) error
 	IsTaskPromiseDuplicated(taskPromise *TaskPromise, jobUUID string) bool
 	SendTaskPromise(taskPromise *TaskPromise) error
 	ReceiveTaskPromise() *TaskPromise
@@ -12,6 +14,21 @@ type SpiderBusBackend interface {
 	ReceiveItem() *Item
 	SendTaskResult(taskResult *TaskResult) error
 	ReceiveTaskResult() *TaskResult
+	SendDeadLetter(deadLetter *DeadLetter) error
+	ListDeadLetters(jobUUID string) ([]*DeadLetter, error)
+	RemoveDeadLetter(deadLetterUUID string) error
+	SendJob(job *Job) error
+	ReceiveJob() *Job
+	SendManagerReport(report *ManagerReport) error
+	ReceiveManagerReport() *ManagerReport
+	SendJobFinished(jobFinished *JobFinished) error
+	ReceiveJobFinished() *JobFinished
+	SaveManagerCheckpoint(checkpoint *ManagerCheckpoint) error
+	LoadManagerCheckpoint(jobUUID string) (*ManagerCheckpoint, error)
+	SendJobControl(jobControl *JobControl) error
+	ReceiveJobControls(cursor string) ([]*JobControl, string, error)
+	SaveWorkerHeartbeat(heartbeat *WorkerHeartbeat) error
+	ListWorkerHeartbeats() ([]*WorkerHeartbeat, error)
 }
 
 type AbstractSpiderBusBackend struct {
@@ -0,0 +1,70 @@
+package spsw
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type SQLiteDeduplicatorBackend struct {
+	AbstractDeduplicatorBackend
+	UUID string
+
+	dbPath string
+	db     *sql.DB
+}
+
+func NewSQLiteDeduplicatorBackend(dbPath string) (*SQLiteDeduplicatorBackend, error) {
+	db, err := openSQLiteDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS scheduled_task_hashes (
+		job_uuid TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		PRIMARY KEY (job_uuid, hash)
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteDeduplicatorBackend{
+		UUID:   uuid.New().String(),
+		dbPath: dbPath,
+		db:     db,
+	}, nil
+}
+
+func (sdb *SQLiteDeduplicatorBackend) IsScheduledTaskDuplicated(scheduledTask *ScheduledTask) bool {
+	hashStr := fmt.Sprintf("%v", scheduledTask.Hash())
+
+	var n int
+
+	row := sdb.db.QueryRow("SELECT COUNT(*) FROM scheduled_task_hashes WHERE job_uuid = ? AND hash = ?",
+		scheduledTask.JobUUID, hashStr)
+
+	err := row.Scan(&n)
+	if err != nil {
+		log.Error(fmt.Sprintf("Looking up scheduled task hash failed with error: %v", err))
+		return false
+	}
+
+	return n > 0
+}
+
+func (sdb *SQLiteDeduplicatorBackend) NoteScheduledTask(scheduledTask *ScheduledTask) error {
+	hashStr := fmt.Sprintf("%v", scheduledTask.Hash())
+
+	_, err := sdb.db.Exec("INSERT OR IGNORE INTO scheduled_task_hashes (job_uuid, hash) VALUES (?, ?)",
+		scheduledTask.JobUUID, hashStr)
+
+	return err
+}
+
+func (sdb *SQLiteDeduplicatorBackend) Close() {
+	sdb.db.Close()
+}
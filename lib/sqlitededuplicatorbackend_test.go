@@ -0,0 +1,34 @@
+package spsw
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteDeduplicatorBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteDeduplicatorBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	promise := NewTaskPromise("Task1", "WF0", "2E1F0E2D-0D83-4A6B-A3E1-DB1C4D1BF9B8", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", promise.JobUUID)
+
+	assert.False(t, backend.IsScheduledTaskDuplicated(scheduledTask))
+
+	err = backend.NoteScheduledTask(scheduledTask)
+	assert.Nil(t, err)
+
+	err = backend.NoteScheduledTask(scheduledTask)
+	assert.Nil(t, err)
+
+	assert.True(t, backend.IsScheduledTaskDuplicated(scheduledTask))
+}
@@ -0,0 +1,238 @@
+package spsw
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SQLiteMasterStoreBackend struct {
+	AbstractMasterStoreBackend
+	UUID string
+
+	dbPath string
+	db     *sql.DB
+}
+
+func NewSQLiteMasterStoreBackend(dbPath string) (*SQLiteMasterStoreBackend, error) {
+	db, err := openSQLiteDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS workflows (
+			name TEXT NOT NULL,
+			version TEXT NOT NULL,
+			raw BLOB NOT NULL,
+			updated_at INTEGER NOT NULL,
+			PRIMARY KEY (name, version)
+		)`,
+		`CREATE TABLE IF NOT EXISTS jobs (
+			uuid TEXT PRIMARY KEY,
+			workflow_name TEXT NOT NULL,
+			workflow_version TEXT NOT NULL,
+			status TEXT NOT NULL,
+			raw BLOB NOT NULL,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS scheduled_runs (
+			uuid TEXT PRIMARY KEY,
+			schedule_name TEXT NOT NULL,
+			raw BLOB NOT NULL,
+			due_at INTEGER NOT NULL
+		)`,
+	} {
+		_, err = db.Exec(stmt)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &SQLiteMasterStoreBackend{
+		UUID:   uuid.New().String(),
+		dbPath: dbPath,
+		db:     db,
+	}, nil
+}
+
+func (smsb *SQLiteMasterStoreBackend) String() string {
+	return fmt.Sprintf("<SQLiteMasterStoreBackend %s DBPath: %s>", smsb.UUID, smsb.dbPath)
+}
+
+func (smsb *SQLiteMasterStoreBackend) SaveWorkflow(workflow *Workflow) error {
+	raw, err := json.Marshal(workflow)
+	if err != nil {
+		return err
+	}
+
+	_, err = smsb.db.Exec("INSERT OR REPLACE INTO workflows (name, version, raw, updated_at) VALUES (?, ?, ?, ?)",
+		workflow.Name, workflow.Version, raw, time.Now().UnixNano())
+
+	return err
+}
+
+func (smsb *SQLiteMasterStoreBackend) LoadWorkflow(name string, version string) (*Workflow, error) {
+	var raw []byte
+
+	row := smsb.db.QueryRow("SELECT raw FROM workflows WHERE name = ? AND version = ?", name, version)
+
+	err := row.Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrWorkflowNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	workflow := &Workflow{}
+
+	err = json.Unmarshal(raw, workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	return workflow, nil
+}
+
+// encodeJobRecord encodes job without its workflow, which is stored separately.
+func encodeJobRecord(job *Job) []byte {
+	jobCopy := *job
+	jobCopy.Workflow = nil
+
+	return jobCopy.EncodeToJSON()
+}
+
+func (smsb *SQLiteMasterStoreBackend) CreateJob(job *Job) error {
+	_, err := smsb.db.Exec(`INSERT INTO jobs (uuid, workflow_name, workflow_version, status, raw, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, job.UUID, job.WorkflowName, job.WorkflowVersion, job.Status,
+		encodeJobRecord(job), job.CreatedAt.UnixNano(), job.UpdatedAt.UnixNano())
+
+	return err
+}
+
+func (smsb *SQLiteMasterStoreBackend) UpdateJob(job *Job) error {
+	res, err := smsb.db.Exec("UPDATE jobs SET status = ?, raw = ?, updated_at = ? WHERE uuid = ?", job.Status,
+		encodeJobRecord(job), job.UpdatedAt.UnixNano(), job.UUID)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+func (smsb *SQLiteMasterStoreBackend) GetJob(jobUUID string) (*Job, error) {
+	var raw []byte
+
+	row := smsb.db.QueryRow("SELECT raw FROM jobs WHERE uuid = ?", jobUUID)
+
+	err := row.Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	job := NewJobFromJSON(raw)
+	if job == nil {
+		return nil, fmt.Errorf("Bad record for job %s", jobUUID)
+	}
+
+	return job, nil
+}
+
+func (smsb *SQLiteMasterStoreBackend) ListJobs(filter *JobFilter) ([]*Job, error) {
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if filter != nil {
+		if filter.Status != "" {
+			conditions = append(conditions, "status = ?")
+			args = append(args, filter.Status)
+		}
+
+		if filter.WorkflowName != "" {
+			conditions = append(conditions, "workflow_name = ?")
+			args = append(args, filter.WorkflowName)
+		}
+
+		if filter.WorkflowVersion != "" {
+			conditions = append(conditions, "workflow_version = ?")
+			args = append(args, filter.WorkflowVersion)
+		}
+	}
+
+	rows, err := smsb.db.Query(fmt.Sprintf("SELECT raw FROM jobs WHERE %s ORDER BY created_at, rowid",
+		strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []*Job{}
+
+	for rows.Next() {
+		var raw []byte
+
+		err = rows.Scan(&raw)
+		if err != nil {
+			return nil, err
+		}
+
+		job := NewJobFromJSON(raw)
+		if job != nil {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, rows.Err()
+}
+
+func (smsb *SQLiteMasterStoreBackend) SaveScheduledRun(run *ScheduledRun) error {
+	_, err := smsb.db.Exec(`INSERT OR REPLACE INTO scheduled_runs (uuid, schedule_name, raw, due_at)
+		VALUES (?, ?, ?, ?)`, run.UUID, run.ScheduleName, run.EncodeToJSON(), run.DueAt.UnixNano())
+
+	return err
+}
+
+func (smsb *SQLiteMasterStoreBackend) ListScheduledRuns(scheduleName string) ([]*ScheduledRun, error) {
+	rows, err := smsb.db.Query(`SELECT raw FROM scheduled_runs WHERE ? = '' OR schedule_name = ?
+		ORDER BY due_at, rowid`, scheduleName, scheduleName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	runs := []*ScheduledRun{}
+
+	for rows.Next() {
+		var raw []byte
+
+		err = rows.Scan(&raw)
+		if err != nil {
+			return nil, err
+		}
+
+		run := NewScheduledRunFromJSON(raw)
+		if run != nil {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, rows.Err()
+}
+
+func (smsb *SQLiteMasterStoreBackend) Close() {
+	smsb.db.Close()
+}
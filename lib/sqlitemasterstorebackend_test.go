@@ -0,0 +1,40 @@
+package spsw
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSQLiteMasterStoreBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteMasterStoreBackend(dir + "/master.db")
+	assert.Nil(t, err)
+	assert.NotNil(t, backend)
+	assert.Equal(t, dir+"/master.db", backend.dbPath)
+
+	defer backend.Close()
+
+	_, err = os.Stat(dir + "/master.db")
+	assert.Nil(t, err)
+}
+
+func TestSQLiteMasterStoreBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteMasterStoreBackend(dir + "/master.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	testMasterStoreBackend(t, backend)
+}
@@ -0,0 +1,673 @@
+package spsw
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+// SQLiteSpiderBusBackend keeps SpiderBus messages in tables of SQLite database file,
+// so that they survive restarts. Messages are claimed by a single consumer before
+// being handed out, which allows multiple processes on the same host to share
+// a database file.
+//
+// Like with Redis, scheduled tasks stay claimed until acknowledged. Claims older than
+// VisibilityTimeout expire, letting another consumer pick up the task, until it has been
+// delivered MaxDeliveries times. Messages with higher priority are claimed first. Scheduled
+// tasks requiring labels are only claimed by consumers having them.
+type SQLiteSpiderBusBackend struct {
+	SpiderBusBackend
+	UUID string
+
+	VisibilityTimeout time.Duration
+	MaxDeliveries     int64
+
+	dbPath     string
+	db         *sql.DB
+	consumerId string
+
+	unackedMutex     sync.Mutex
+	unackedIDsByUUID map[string]int64
+}
+
+const SQLiteTableNameItems = "items"
+const SQLiteTableNameTaskPromises = "task_promises"
+const SQLiteTableNameScheduledTasks = "scheduled_tasks"
+const SQLiteTableNameTaskResults = "task_results"
+const SQLiteTableNameDeadLetters = "dead_letters"
+const SQLiteTableNameJobs = "jobs"
+const SQLiteTableNameManagerReports = "manager_reports"
+const SQLiteTableNameJobsFinished = "jobs_finished"
+const SQLiteTableNameJobControls = "job_controls"
+const SQLiteTableNameManagerCheckpoints = "manager_checkpoints"
+const SQLiteTableNameWorkerHeartbeats = "worker_heartbeats"
+
+const SQLiteSpiderBusBackendReceiveTimeout = 1 * time.Second
+const SQLiteSpiderBusBackendPollInterval = 100 * time.Millisecond
+
+// openSQLiteDB opens database file in a way that is safe for concurrent use by
+// several processes: WAL journal, waiting on locks and taking write lock when
+// transaction begins.
+func openSQLiteDB(dbPath string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", dbPath)
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func NewSQLiteSpiderBusBackend(dbPath string) (*SQLiteSpiderBusBackend, error) {
+	db, err := openSQLiteDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tableName := range []string{SQLiteTableNameItems, SQLiteTableNameTaskPromises,
+		SQLiteTableNameScheduledTasks, SQLiteTableNameTaskResults, SQLiteTableNameJobs,
+		SQLiteTableNameManagerReports, SQLiteTableNameJobsFinished} {
+		_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			raw BLOB NOT NULL,
+			created_at INTEGER NOT NULL,
+			claimed_by TEXT,
+			claimed_at INTEGER,
+			deliveries INTEGER NOT NULL DEFAULT 0,
+			priority INTEGER NOT NULL DEFAULT 0,
+			required_labels TEXT NOT NULL DEFAULT ''
+		)`, tableName))
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		err = addSQLiteColumnIfMissing(db, tableName, "priority", "INTEGER NOT NULL DEFAULT 0")
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		err = addSQLiteColumnIfMissing(db, tableName, "required_labels", "TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uuid TEXT NOT NULL UNIQUE,
+		job_uuid TEXT NOT NULL,
+		raw BLOB NOT NULL,
+		created_at INTEGER NOT NULL
+	)`, SQLiteTableNameDeadLetters))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		raw BLOB NOT NULL,
+		created_at INTEGER NOT NULL
+	)`, SQLiteTableNameJobControls))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		job_uuid TEXT PRIMARY KEY,
+		raw BLOB NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`, SQLiteTableNameManagerCheckpoints))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		worker_uuid TEXT PRIMARY KEY,
+		raw BLOB NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`, SQLiteTableNameWorkerHeartbeats))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	consumerId := uuid.New().String()
+
+	return &SQLiteSpiderBusBackend{
+		UUID:              consumerId,
+		VisibilityTimeout: SpiderBusDefaultVisibilityTimeout,
+		MaxDeliveries:     SpiderBusDefaultMaxDeliveries,
+		dbPath:            dbPath,
+		db:                db,
+		consumerId:        consumerId,
+		unackedIDsByUUID:  map[string]int64{},
+	}, nil
+}
+
+// addSQLiteColumnIfMissing brings tables made by older versions up to date.
+func addSQLiteColumnIfMissing(db *sql.DB, tableName string, columnName string, columnDef string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name string
+		var colType string
+		var notNull int
+		var defaultValue sql.NullString
+		var pk int
+
+		err = rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk)
+		if err != nil {
+			return err
+		}
+
+		if name == columnName {
+			return nil
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, columnName, columnDef))
+
+	return err
+}
+
+func (ssbb *SQLiteSpiderBusBackend) String() string {
+	return fmt.Sprintf("<SQLiteSpiderBusBackend %s DBPath: %s>", ssbb.UUID, ssbb.dbPath)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) writeRawMessageToTable(tableName string, raw []byte) error {
+	return ssbb.writeRawMessageToTableWithPriority(tableName, raw, 0, "")
+}
+
+// writeRawMessageToTableWithPriority inserts message that is claimed before messages of
+// lower priority, and only by consumers whose labels match labelSelector.
+func (ssbb *SQLiteSpiderBusBackend) writeRawMessageToTableWithPriority(tableName string, raw []byte, priority int,
+	labelSelector string) error {
+	_, err := ssbb.db.Exec(fmt.Sprintf("INSERT INTO %s (raw, created_at, priority, required_labels) VALUES (?, ?, ?, ?)",
+		tableName), raw, time.Now().UnixNano(), priority, labelSelector)
+
+	if err != nil {
+		log.Error(fmt.Sprintf("Inserting into %s failed with error: %v", tableName, err))
+	}
+
+	return err
+}
+
+// matchingLabelSelectors returns label selectors of claimable messages in the table that
+// given labels match.
+func matchingLabelSelectors(tx *sql.Tx, tableName string, labels map[string]string,
+	expiredBefore int64) ([]string, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT DISTINCT required_labels FROM %s WHERE claimed_by IS NULL OR claimed_at < ?",
+		tableName), expiredBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	selectors := []string{}
+
+	for rows.Next() {
+		var selector string
+
+		err = rows.Scan(&selector)
+		if err != nil {
+			return nil, err
+		}
+
+		if labelSelectorMatches(labels, selector) {
+			selectors = append(selectors, selector)
+		}
+	}
+
+	return selectors, rows.Err()
+}
+
+// claimRawMessage marks the oldest unclaimed message of highest priority in the table that
+// given labels match as claimed by this consumer. Messages with claims older than
+// VisibilityTimeout are also eligible. Returns nil message if there's nothing to claim.
+func (ssbb *SQLiteSpiderBusBackend) claimRawMessage(tableName string, labels map[string]string) (int64, int64, []byte, error) {
+	tx, err := ssbb.db.Begin()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	defer tx.Rollback()
+
+	var id int64
+	var deliveries int64
+	var raw []byte
+
+	now := time.Now()
+	expiredBefore := now.Add(-ssbb.VisibilityTimeout).UnixNano()
+
+	selectors, err := matchingLabelSelectors(tx, tableName, labels, expiredBefore)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	if len(selectors) == 0 {
+		return 0, 0, nil, nil
+	}
+
+	args := []interface{}{expiredBefore}
+	for _, selector := range selectors {
+		args = append(args, selector)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(selectors)), ", ")
+
+	row := tx.QueryRow(fmt.Sprintf("SELECT id, deliveries, raw FROM %s WHERE (claimed_by IS NULL OR claimed_at < ?) AND required_labels IN (%s) ORDER BY priority DESC, id LIMIT 1",
+		tableName, placeholders), args...)
+
+	err = row.Scan(&id, &deliveries, &raw)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil, nil
+	} else if err != nil {
+		return 0, 0, nil, err
+	}
+
+	deliveries++
+
+	_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET claimed_by = ?, claimed_at = ?, deliveries = ? WHERE id = ?", tableName),
+		ssbb.consumerId, now.UnixNano(), deliveries, id)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return id, deliveries, raw, nil
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ackMessage(tableName string, id int64) error {
+	res, err := ssbb.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ? AND claimed_by = ?", tableName),
+		id, ssbb.consumerId)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Message %d in %s is no longer claimed by consumer %s", id, tableName, ssbb.consumerId)
+	}
+
+	return nil
+}
+
+// readMessageFromTable waits up to SQLiteSpiderBusBackendReceiveTimeout for a message that
+// given labels match to claim.
+func (ssbb *SQLiteSpiderBusBackend) readMessageFromTable(tableName string, labels map[string]string) (int64, int64, []byte, error) {
+	deadline := time.Now().Add(SQLiteSpiderBusBackendReceiveTimeout)
+
+	for {
+		id, deliveries, raw, err := ssbb.claimRawMessage(tableName, labels)
+		if err != nil {
+			log.Error(fmt.Sprintf("Claiming message from %s failed with error: %v", tableName, err))
+			return 0, 0, nil, err
+		}
+
+		if raw != nil {
+			return id, deliveries, raw, nil
+		}
+
+		if time.Now().After(deadline) {
+			return 0, 0, nil, nil
+		}
+
+		time.Sleep(SQLiteSpiderBusBackendPollInterval)
+	}
+}
+
+func (ssbb *SQLiteSpiderBusBackend) readRawMessageFromTable(tableName string) ([]byte, error) {
+	id, _, raw, err := ssbb.readMessageFromTable(tableName, nil)
+	if raw == nil || err != nil {
+		return nil, err
+	}
+
+	err = ssbb.ackMessage(tableName, id)
+	if err != nil {
+		log.Error(fmt.Sprintf("Acking message %d in %s failed with error: %v", id, tableName, err))
+	}
+
+	return raw, nil
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendScheduledTask(scheduledTask *ScheduledTask) error {
+	return ssbb.writeRawMessageToTableWithPriority(SQLiteTableNameScheduledTasks, scheduledTask.EncodeToJSON(),
+		scheduledTask.Priority, scheduledTask.LabelSelector())
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ReceiveScheduledTask(workerLabels map[string]string) *ScheduledTask {
+	for {
+		id, deliveries, raw, err := ssbb.readMessageFromTable(SQLiteTableNameScheduledTasks, workerLabels)
+		if raw == nil || err != nil {
+			return nil
+		}
+
+		scheduledTask := NewScheduledTaskFromJSON(raw)
+		if scheduledTask == nil {
+			ssbb.ackMessage(SQLiteTableNameScheduledTasks, id)
+			continue
+		}
+
+		if deliveries > ssbb.MaxDeliveries {
+			log.Error(fmt.Sprintf("Giving up on scheduled task %s after %d deliveries", scheduledTask.UUID,
+				deliveries-1))
+
+			taskResult := NewTaskResult(scheduledTask.JobUUID, "", scheduledTask.UUID, false,
+				fmt.Errorf("Scheduled task was delivered %d times without being completed", deliveries-1))
+
+			err = ssbb.SendTaskResult(taskResult)
+			if err == nil {
+				ssbb.ackMessage(SQLiteTableNameScheduledTasks, id)
+			}
+
+			continue
+		}
+
+		ssbb.unackedMutex.Lock()
+		ssbb.unackedIDsByUUID[scheduledTask.UUID] = id
+		ssbb.unackedMutex.Unlock()
+
+		return scheduledTask
+	}
+}
+
+func (ssbb *SQLiteSpiderBusBackend) AckScheduledTask(scheduledTaskUUID string) error {
+	ssbb.unackedMutex.Lock()
+	id, ok := ssbb.unackedIDsByUUID[scheduledTaskUUID]
+	delete(ssbb.unackedIDsByUUID, scheduledTaskUUID)
+	ssbb.unackedMutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("No unacknowledged scheduled task with UUID %s", scheduledTaskUUID)
+	}
+
+	return ssbb.ackMessage(SQLiteTableNameScheduledTasks, id)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendTaskPromise(taskPromise *TaskPromise) error {
+	return ssbb.writeRawMessageToTable(SQLiteTableNameTaskPromises, taskPromise.EncodeToJSON())
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ReceiveTaskPromise() *TaskPromise {
+	raw, err := ssbb.readRawMessageFromTable(SQLiteTableNameTaskPromises)
+	if raw == nil || err != nil {
+		return nil
+	}
+
+	return NewTaskPromiseFromJSON(raw)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendItem(item *Item) error {
+	return ssbb.writeRawMessageToTable(SQLiteTableNameItems, item.EncodeToJSON())
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ReceiveItem() *Item {
+	raw, err := ssbb.readRawMessageFromTable(SQLiteTableNameItems)
+	if raw == nil || err != nil {
+		return nil
+	}
+
+	return NewItemFromJSON(raw)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendTaskResult(taskResult *TaskResult) error {
+	return ssbb.writeRawMessageToTable(SQLiteTableNameTaskResults, taskResult.EncodeToJSON())
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ReceiveTaskResult() *TaskResult {
+	raw, err := ssbb.readRawMessageFromTable(SQLiteTableNameTaskResults)
+	if raw == nil || err != nil {
+		return nil
+	}
+
+	return NewTaskResultFromJSON(raw)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendDeadLetter(deadLetter *DeadLetter) error {
+	_, err := ssbb.db.Exec(fmt.Sprintf("INSERT INTO %s (uuid, job_uuid, raw, created_at) VALUES (?, ?, ?, ?)",
+		SQLiteTableNameDeadLetters), deadLetter.UUID, deadLetter.ScheduledTask.JobUUID, deadLetter.EncodeToJSON(),
+		deadLetter.CreatedAt.UnixNano())
+
+	return err
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ListDeadLetters(jobUUID string) ([]*DeadLetter, error) {
+	rows, err := ssbb.db.Query(fmt.Sprintf("SELECT raw FROM %s WHERE ? = '' OR job_uuid = ? ORDER BY id",
+		SQLiteTableNameDeadLetters), jobUUID, jobUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deadLetters := []*DeadLetter{}
+
+	for rows.Next() {
+		var raw []byte
+
+		err = rows.Scan(&raw)
+		if err != nil {
+			return nil, err
+		}
+
+		deadLetter := NewDeadLetterFromJSON(raw)
+		if deadLetter != nil {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+
+	return deadLetters, rows.Err()
+}
+
+func (ssbb *SQLiteSpiderBusBackend) RemoveDeadLetter(deadLetterUUID string) error {
+	res, err := ssbb.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE uuid = ?", SQLiteTableNameDeadLetters), deadLetterUUID)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Dead letter %s not found", deadLetterUUID)
+	}
+
+	return nil
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendJob(job *Job) error {
+	return ssbb.writeRawMessageToTable(SQLiteTableNameJobs, job.EncodeToJSON())
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ReceiveJob() *Job {
+	raw, err := ssbb.readRawMessageFromTable(SQLiteTableNameJobs)
+	if raw == nil || err != nil {
+		return nil
+	}
+
+	return NewJobFromJSON(raw)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendManagerReport(report *ManagerReport) error {
+	return ssbb.writeRawMessageToTable(SQLiteTableNameManagerReports, report.EncodeToJSON())
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ReceiveManagerReport() *ManagerReport {
+	raw, err := ssbb.readRawMessageFromTable(SQLiteTableNameManagerReports)
+	if raw == nil || err != nil {
+		return nil
+	}
+
+	return NewManagerReportFromJSON(raw)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SaveManagerCheckpoint(checkpoint *ManagerCheckpoint) error {
+	_, err := ssbb.db.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (job_uuid, raw, updated_at) VALUES (?, ?, ?)",
+		SQLiteTableNameManagerCheckpoints), checkpoint.JobUUID, checkpoint.EncodeToJSON(), time.Now().UnixNano())
+
+	return err
+}
+
+func (ssbb *SQLiteSpiderBusBackend) LoadManagerCheckpoint(jobUUID string) (*ManagerCheckpoint, error) {
+	var raw []byte
+
+	err := ssbb.db.QueryRow(fmt.Sprintf("SELECT raw FROM %s WHERE job_uuid = ?", SQLiteTableNameManagerCheckpoints),
+		jobUUID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrManagerCheckpointNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return NewManagerCheckpointFromJSON(raw), nil
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SaveWorkerHeartbeat(heartbeat *WorkerHeartbeat) error {
+	_, err := ssbb.db.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (worker_uuid, raw, updated_at) VALUES (?, ?, ?)",
+		SQLiteTableNameWorkerHeartbeats), heartbeat.WorkerUUID, heartbeat.EncodeToJSON(), time.Now().UnixNano())
+
+	return err
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ListWorkerHeartbeats() ([]*WorkerHeartbeat, error) {
+	rows, err := ssbb.db.Query(fmt.Sprintf("SELECT raw FROM %s", SQLiteTableNameWorkerHeartbeats))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	heartbeats := []*WorkerHeartbeat{}
+
+	for rows.Next() {
+		var raw []byte
+
+		err = rows.Scan(&raw)
+		if err != nil {
+			return nil, err
+		}
+
+		heartbeat := NewWorkerHeartbeatFromJSON(raw)
+		if heartbeat != nil {
+			heartbeats = append(heartbeats, heartbeat)
+		}
+	}
+
+	return heartbeats, rows.Err()
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendJobFinished(jobFinished *JobFinished) error {
+	return ssbb.writeRawMessageToTable(SQLiteTableNameJobsFinished, jobFinished.EncodeToJSON())
+}
+
+func (ssbb *SQLiteSpiderBusBackend) ReceiveJobFinished() *JobFinished {
+	raw, err := ssbb.readRawMessageFromTable(SQLiteTableNameJobsFinished)
+	if raw == nil || err != nil {
+		return nil
+	}
+
+	return NewJobFinishedFromJSON(raw)
+}
+
+func (ssbb *SQLiteSpiderBusBackend) SendJobControl(jobControl *JobControl) error {
+	_, err := ssbb.db.Exec(fmt.Sprintf("INSERT INTO %s (raw, created_at) VALUES (?, ?)", SQLiteTableNameJobControls),
+		jobControl.EncodeToJSON(), time.Now().UnixNano())
+
+	return err
+}
+
+func (ssbb *SQLiteSpiderBusBackend) readJobControls(lastID int64) ([]*JobControl, int64, error) {
+	rows, err := ssbb.db.Query(fmt.Sprintf("SELECT id, raw FROM %s WHERE id > ? ORDER BY id",
+		SQLiteTableNameJobControls), lastID)
+	if err != nil {
+		return nil, lastID, err
+	}
+
+	defer rows.Close()
+
+	jobControls := []*JobControl{}
+
+	for rows.Next() {
+		var raw []byte
+
+		err = rows.Scan(&lastID, &raw)
+		if err != nil {
+			return nil, lastID, err
+		}
+
+		jobControl := NewJobControlFromJSON(raw)
+		if jobControl != nil {
+			jobControls = append(jobControls, jobControl)
+		}
+	}
+
+	return jobControls, lastID, rows.Err()
+}
+
+// ReceiveJobControls uses ID of the last job control row seen as cursor. Job control rows
+// are never deleted, so that every consumer gets to read all of them.
+func (ssbb *SQLiteSpiderBusBackend) ReceiveJobControls(cursor string) ([]*JobControl, string, error) {
+	var lastID int64
+
+	if cursor != "" {
+		var err error
+
+		lastID, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return nil, cursor, fmt.Errorf("Bad job control cursor %s: %v", cursor, err)
+		}
+	}
+
+	deadline := time.Now().Add(SQLiteSpiderBusBackendReceiveTimeout)
+
+	for {
+		jobControls, newLastID, err := ssbb.readJobControls(lastID)
+		if err != nil {
+			return nil, cursor, err
+		}
+
+		if len(jobControls) > 0 || time.Now().After(deadline) {
+			return jobControls, strconv.FormatInt(newLastID, 10), nil
+		}
+
+		time.Sleep(SQLiteSpiderBusBackendPollInterval)
+	}
+}
+
+func (ssbb *SQLiteSpiderBusBackend) Close() {
+	ssbb.db.Close()
+}
@@ -0,0 +1,471 @@
+package spsw
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSQLiteSpiderBusBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+	assert.NotNil(t, backend)
+	assert.NotNil(t, backend.db)
+	assert.Equal(t, dir+"/bus.db", backend.dbPath)
+
+	defer backend.Close()
+
+	_, err = os.Stat(dir + "/bus.db")
+	assert.Nil(t, err)
+}
+
+func TestSQLiteSpiderBusBackendSendReceive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	jobUUID := "E2B8A4C1-7A7D-4C53-8D9E-3C1F22B5A6D0"
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", jobUUID)
+	item := NewItem("person", "WF0", jobUUID, "")
+	item.SetField("name", "Faust")
+	taskResult := NewTaskResult(jobUUID, "", scheduledTask.UUID, true, nil)
+
+	assert.Nil(t, backend.SendScheduledTask(scheduledTask))
+	assert.Nil(t, backend.SendTaskPromise(promise))
+	assert.Nil(t, backend.SendItem(item))
+	assert.Nil(t, backend.SendTaskResult(taskResult))
+
+	gotScheduledTask := backend.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, scheduledTask.UUID, gotScheduledTask.UUID)
+
+	gotPromise := backend.ReceiveTaskPromise()
+	assert.NotNil(t, gotPromise)
+	assert.Equal(t, promise.UUID, gotPromise.UUID)
+
+	gotItem := backend.ReceiveItem()
+	assert.NotNil(t, gotItem)
+	assert.Equal(t, "Faust", gotItem.Fields["name"].StringValue)
+
+	gotTaskResult := backend.ReceiveTaskResult()
+	assert.NotNil(t, gotTaskResult)
+	assert.Equal(t, taskResult.UUID, gotTaskResult.UUID)
+}
+
+func TestSQLiteSpiderBusBackendScheduledTaskPriority(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	jobUUID := "E2B8A4C1-7A7D-4C53-8D9E-3C1F22B5A6D0"
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	listTemplate := NewTaskTemplate("ListPage", false)
+	detailTemplate := NewTaskTemplate("DetailPage", false)
+	detailTemplate.Priority = 3
+
+	listTask := NewScheduledTask(promise, listTemplate, "WF0", "v1", jobUUID)
+	detailTask := NewScheduledTask(promise, detailTemplate, "WF0", "v1", jobUUID)
+
+	assert.Nil(t, backend.SendScheduledTask(listTask))
+	assert.Nil(t, backend.SendScheduledTask(detailTask))
+
+	gotScheduledTask := backend.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, detailTask.UUID, gotScheduledTask.UUID)
+
+	gotScheduledTask = backend.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, listTask.UUID, gotScheduledTask.UUID)
+}
+
+func TestSQLiteSpiderBusBackendScheduledTaskLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	jobUUID := "E2B8A4C1-7A7D-4C53-8D9E-3C1F22B5A6D0"
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	anyTemplate := NewTaskTemplate("ListPage", false)
+	euTemplate := NewTaskTemplate("DetailPage", false)
+	euTemplate.RequiredLabels = map[string]string{"region": "eu", "proxy": "residential"}
+	euTemplate.Priority = 3
+
+	euTask := NewScheduledTask(promise, euTemplate, "WF0", "v1", jobUUID)
+	anyTask := NewScheduledTask(promise, anyTemplate, "WF0", "v1", jobUUID)
+
+	assert.Nil(t, backend.SendScheduledTask(euTask))
+	assert.Nil(t, backend.SendScheduledTask(anyTask))
+
+	gotScheduledTask := backend.ReceiveScheduledTask(map[string]string{"region": "eu"})
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, anyTask.UUID, gotScheduledTask.UUID)
+
+	_, _, raw, err := backend.claimRawMessage(SQLiteTableNameScheduledTasks, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, raw)
+
+	gotScheduledTask = backend.ReceiveScheduledTask(map[string]string{"region": "eu", "proxy": "residential",
+		"memory": "large"})
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, euTask.UUID, gotScheduledTask.UUID)
+}
+
+func TestSQLiteSpiderBusBackendAddsPriorityColumn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	db, err := openSQLiteDB(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	_, err = db.Exec(`CREATE TABLE scheduled_tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		raw BLOB NOT NULL,
+		created_at INTEGER NOT NULL,
+		claimed_by TEXT,
+		claimed_at INTEGER,
+		deliveries INTEGER NOT NULL DEFAULT 0
+	)`)
+	assert.Nil(t, err)
+
+	db.Close()
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "job1")
+
+	assert.Nil(t, backend.SendScheduledTask(scheduledTask))
+
+	gotScheduledTask := backend.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, scheduledTask.UUID, gotScheduledTask.UUID)
+}
+
+func TestSQLiteSpiderBusBackendSharedDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend1, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	backend2, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend2.Close()
+
+	item1 := NewItem("person", "WF0", "", "")
+	item2 := NewItem("person", "WF0", "", "")
+
+	assert.Nil(t, backend1.SendItem(item1))
+	assert.Nil(t, backend1.SendItem(item2))
+
+	gotItem1 := backend1.ReceiveItem()
+	gotItem2 := backend2.ReceiveItem()
+
+	assert.NotNil(t, gotItem1)
+	assert.NotNil(t, gotItem2)
+	assert.Equal(t, item1.UUID, gotItem1.UUID)
+	assert.Equal(t, item2.UUID, gotItem2.UUID)
+
+	// Messages should survive backend going away.
+	item3 := NewItem("person", "WF0", "", "")
+	assert.Nil(t, backend1.SendItem(item3))
+	backend1.Close()
+
+	backend3, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend3.Close()
+
+	gotItem3 := backend3.ReceiveItem()
+	assert.NotNil(t, gotItem3)
+	assert.Equal(t, item3.UUID, gotItem3.UUID)
+}
+
+func TestSQLiteSpiderBusBackendRedelivery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend1, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend1.Close()
+
+	backend2, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend2.Close()
+
+	for _, backend := range []*SQLiteSpiderBusBackend{backend1, backend2} {
+		backend.VisibilityTimeout = 50 * time.Millisecond
+		backend.MaxDeliveries = 2
+	}
+
+	jobUUID := "E2B8A4C1-7A7D-4C53-8D9E-3C1F22B5A6D0"
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", jobUUID)
+
+	assert.Nil(t, backend1.SendScheduledTask(scheduledTask))
+
+	// First delivery - never acknowledged, as if worker crashed.
+	gotScheduledTask := backend1.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, scheduledTask.UUID, gotScheduledTask.UUID)
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Second delivery to another consumer after claim expired.
+	gotScheduledTask = backend2.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+	assert.Equal(t, scheduledTask.UUID, gotScheduledTask.UUID)
+
+	// Stale consumer can no longer acknowledge it.
+	assert.NotNil(t, backend1.AckScheduledTask(scheduledTask.UUID))
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Third time around task is given up on.
+	gotScheduledTask = backend1.ReceiveScheduledTask(nil)
+	assert.Nil(t, gotScheduledTask)
+
+	taskResult := backend1.ReceiveTaskResult()
+	assert.NotNil(t, taskResult)
+	assert.False(t, taskResult.Succeeded)
+	assert.Equal(t, scheduledTask.UUID, taskResult.ScheduledTaskUUID)
+	assert.Equal(t, jobUUID, taskResult.JobUUID)
+}
+
+func TestSQLiteSpiderBusBackendAckScheduledTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	backend.VisibilityTimeout = 50 * time.Millisecond
+
+	promise := NewTaskPromise("Task1", "WF0", "", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "")
+
+	assert.Nil(t, backend.SendScheduledTask(scheduledTask))
+
+	gotScheduledTask := backend.ReceiveScheduledTask(nil)
+	assert.NotNil(t, gotScheduledTask)
+
+	assert.Nil(t, backend.AckScheduledTask(scheduledTask.UUID))
+	assert.NotNil(t, backend.AckScheduledTask(scheduledTask.UUID))
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, _, raw, err := backend.claimRawMessage(SQLiteTableNameScheduledTasks, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, raw)
+}
+
+func TestSQLiteSpiderBusBackendDeadLetters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	deadLetter1 := NewDeadLetter(NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "job1"),
+		"connection refused")
+	deadLetter2 := NewDeadLetter(NewScheduledTask(promise, NewTaskTemplate("Task1", false), "WF0", "v1", "job2"),
+		"connection refused")
+
+	assert.Nil(t, backend.SendDeadLetter(deadLetter1))
+	assert.Nil(t, backend.SendDeadLetter(deadLetter2))
+
+	deadLetters, err := backend.ListDeadLetters("")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(deadLetters))
+
+	deadLetters, err = backend.ListDeadLetters("job1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deadLetters))
+	assert.Equal(t, deadLetter1.UUID, deadLetters[0].UUID)
+
+	assert.Nil(t, backend.RemoveDeadLetter(deadLetter1.UUID))
+	assert.NotNil(t, backend.RemoveDeadLetter(deadLetter1.UUID))
+
+	deadLetters, err = backend.ListDeadLetters("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deadLetters))
+	assert.Equal(t, deadLetter2.UUID, deadLetters[0].UUID)
+}
+
+func TestSQLiteSpiderBusBackendJobsAndReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend.Close()
+
+	job := NewJob(&Workflow{Name: "WF0", Version: "v1"})
+	report := NewManagerReport("manager1", job.UUID, JobStatusRunning, JobStats{NPendingTasks: 1})
+
+	assert.Nil(t, backend.SendJob(job))
+	assert.Nil(t, backend.SendManagerReport(report))
+
+	gotJob := backend.ReceiveJob()
+	assert.NotNil(t, gotJob)
+	assert.Equal(t, job.UUID, gotJob.UUID)
+
+	gotReport := backend.ReceiveManagerReport()
+	assert.NotNil(t, gotReport)
+	assert.Equal(t, report.UUID, gotReport.UUID)
+
+	jobFinished := NewJobFinished(job.UUID, JobStatusFinished, JobStats{NFinishedTasks: 1}, 3)
+
+	assert.Nil(t, backend.SendJobFinished(jobFinished))
+
+	gotJobFinished := backend.ReceiveJobFinished()
+	assert.NotNil(t, gotJobFinished)
+	assert.Equal(t, jobFinished.UUID, gotJobFinished.UUID)
+	assert.Equal(t, 3, gotJobFinished.NItems)
+
+	_, err = backend.LoadManagerCheckpoint(job.UUID)
+	assert.Equal(t, ErrManagerCheckpointNotFound, err)
+
+	managerJob := NewManagerJob(job.Workflow, job.UUID)
+
+	assert.Nil(t, backend.SaveManagerCheckpoint(NewManagerCheckpoint("manager1", managerJob)))
+
+	managerJob.NFinishedTasks = 2
+	checkpoint := NewManagerCheckpoint("manager1", managerJob)
+
+	assert.Nil(t, backend.SaveManagerCheckpoint(checkpoint))
+
+	// Only the latest checkpoint is kept.
+	gotCheckpoint, err := backend.LoadManagerCheckpoint(job.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, checkpoint.UUID, gotCheckpoint.UUID)
+	assert.Equal(t, 2, gotCheckpoint.NFinishedTasks)
+}
+
+func TestSQLiteSpiderBusBackendJobControls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend1, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend1.Close()
+
+	backend2, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend2.Close()
+
+	jobControl1 := NewJobControl("job1", JobControlActionCancel)
+	jobControl2 := NewJobControl("job2", JobControlActionCancel)
+
+	assert.Nil(t, backend1.SendJobControl(jobControl1))
+
+	jobControls, cursor, err := backend1.ReceiveJobControls("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobControls))
+	assert.Equal(t, jobControl1.UUID, jobControls[0].UUID)
+
+	assert.Nil(t, backend1.SendJobControl(jobControl2))
+
+	jobControls, _, err = backend1.ReceiveJobControls(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobControls))
+	assert.Equal(t, jobControl2.UUID, jobControls[0].UUID)
+
+	// Other consumers see all job controls too.
+	jobControls, _, err = backend2.ReceiveJobControls("")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(jobControls))
+}
+
+func TestSQLiteSpiderBusBackendWorkerHeartbeats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsw_test_")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	backend1, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend1.Close()
+
+	backend2, err := NewSQLiteSpiderBusBackend(dir + "/bus.db")
+	assert.Nil(t, err)
+
+	defer backend2.Close()
+
+	heartbeats, err := backend2.ListWorkerHeartbeats()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(heartbeats))
+
+	assert.Nil(t, backend1.SaveWorkerHeartbeat(NewWorkerHeartbeat("worker1", "host1")))
+
+	heartbeat := NewWorkerHeartbeat("worker1", "host1")
+	heartbeat.ScheduledTaskUUID = "task1"
+	assert.Nil(t, backend1.SaveWorkerHeartbeat(heartbeat))
+
+	// Only the latest heartbeat of each worker is kept, and it's visible to all processes.
+	heartbeats, err = backend2.ListWorkerHeartbeats()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(heartbeats))
+	assert.Equal(t, heartbeat.UUID, heartbeats[0].UUID)
+	assert.Equal(t, "task1", heartbeats[0].ScheduledTaskUUID)
+}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -33,25 +34,27 @@ func NewStringCutAction(from string, to string) *StringCutAction {
 	}
 }
 
-func NewStringCutActionFromTemplate(actionTempl *ActionTemplate) Action {
-	var from string
-	var to string
+func NewStringCutActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("StringCutAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
 
-	from = actionTempl.ConstructorParams["from"].StringValue
-	to = actionTempl.ConstructorParams["to"].StringValue
+	from := params["from"].StringValue
+	to := params["to"].StringValue
 
 	action := NewStringCutAction(from, to)
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (sca *StringCutAction) String() string {
 	return fmt.Sprintf("<StringCutAction %s Name: %s, From: %s, To: %s>", sca.UUID, sca.Name, sca.From, sca.To)
 }
 
-func (sca *StringCutAction) Run() error {
+func (sca *StringCutAction) Run(ctx context.Context) error {
 	if sca.Inputs[StringCutActionInputStr] == nil {
 		return errors.New("Input not connected")
 	}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,7 +24,10 @@ func TestNewStringCutActionFromTemplate(t *testing.T) {
 		ConstructorParams: constructorParams,
 	}
 
-	action, ok := NewStringCutActionFromTemplate(actionTempl).(*StringCutAction)
+	gotAction, err := NewStringCutActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action, ok := gotAction.(*StringCutAction)
 	assert.True(t, ok)
 
 	assert.NotNil(t, action)
@@ -48,7 +52,7 @@ func TestStringCutActionRun(t *testing.T) {
 	err = action.AddOutput(StringCutActionOutputStr, strOut)
 	assert.Nil(t, err)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotStr, ok := action.Outputs[StringCutActionOutputStr][0].Remove().(string)
@@ -70,7 +74,7 @@ func TestStringCutActionRun2(t *testing.T) {
 	action.AddInput(StringCutActionInputStr, inDP)
 	action.AddOutput(StringCutActionOutputStr, outDP)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotStrings, ok := action.Outputs[StringCutActionOutputStr][0].Remove().([]string)
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -40,27 +41,25 @@ func NewStringMapUpdateAction(overrideKey string) *StringMapUpdateAction {
 	}
 }
 
-func NewStringMapUpdateActionFromTemplate(actionTempl *ActionTemplate) Action {
-	action := NewStringMapUpdateAction("")
-
-	action.Name = actionTempl.Name
-
-	if _, ok := actionTempl.ConstructorParams["overrideKey"]; ok {
-		action.OverrideKey = actionTempl.ConstructorParams["overrideKey"].StringValue
+func NewStringMapUpdateActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("StringMapUpdateAction", actionTempl)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, ok := actionTempl.ConstructorParams["itemName"]; ok {
-		action.ItemName = actionTempl.ConstructorParams["itemName"].StringValue
-	}
+	action := NewStringMapUpdateAction(params["overrideKey"].StringValue)
+
+	action.Name = actionTempl.Name
+	action.ItemName = params["itemName"].StringValue
 
-	return action
+	return action, nil
 }
 
 func (smua *StringMapUpdateAction) String() string {
 	return fmt.Sprintf("<StringMapUpdateAction %s Name: %s OverrideKey: %s>", smua.UUID, smua.Name, smua.OverrideKey)
 }
 
-func (smua *StringMapUpdateAction) Run() error {
+func (smua *StringMapUpdateAction) Run(ctx context.Context) error {
 	if smua.OverrideKey == "" {
 		if smua.Inputs[StringMapUpdateActionInputOld] == nil || smua.Inputs[StringMapUpdateActionInputNew] == nil {
 			return errors.New("Both inputs must be connected")
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -36,7 +37,7 @@ func TestStringMapUpdateActionRun(t *testing.T) {
 	action.AddInput(StringMapUpdateActionInputNew, newCookiesIn)
 	action.AddOutput(StringMapUpdateActionOutputUpdated, updatedCookiesOut)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 
 	assert.Nil(t, err)
 
@@ -73,7 +74,7 @@ func TestStringMapUpdateActionRunWithOverride(t *testing.T) {
 	action.AddInput(StringMapUpdateActionInputOld, inDP2)
 	action.AddOutput(StringMapUpdateActionOutputUpdated, outDP)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 
 	assert.Nil(t, err)
 
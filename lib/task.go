@@ -1,13 +1,18 @@
 package spsw
 
 import (
+	"context"
 	"fmt"
+	"runtime/debug"
 	"time"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
+// Task runs its actions one at a time in topological order. If MaxConcurrency is more
+// than one, up to that many actions run at once, each as soon as all the actions it gets
+// data from are done.
 type Task struct {
 	UUID              string
 	Name              string
@@ -15,11 +20,14 @@ type Task struct {
 	WorkflowName      string
 	JobUUID           string
 	ScheduledTaskUUID string
+	MaxConcurrency    int
 
 	Inputs    map[string][]*DataPipe
 	Outputs   map[string]*DataPipe
 	Actions   []Action
 	DataPipes []*DataPipe
+
+	scheduledTask *ScheduledTask
 }
 
 func NewTask(name string, workflowName string, jobUUID string) *Task {
@@ -90,13 +98,18 @@ func (t *Task) addDataPipeFromTemplate(dataPipeTemplate *DataPipeTemplate, nameT
 	}
 }
 
-func NewTaskFromTemplate(taskTempl *TaskTemplate, workflowName string, jobUUID string) *Task {
+func NewTaskFromTemplate(taskTempl *TaskTemplate, workflowName string, jobUUID string) (*Task, error) {
 	task := NewTask(taskTempl.TaskName, workflowName, jobUUID)
+	task.MaxConcurrency = taskTempl.MaxConcurrency
 
 	nameToAction := map[string]Action{}
 
 	for _, actionTempl := range taskTempl.ActionTemplates {
-		newAction := NewActionFromTemplate(&actionTempl)
+		newAction, err := NewActionFromTemplate(&actionTempl)
+		if err != nil {
+			return nil, fmt.Errorf("Task %s: %v", taskTempl.TaskName, err)
+		}
+
 		task.Actions = append(task.Actions, newAction)
 		nameToAction[actionTempl.Name] = newAction
 	}
@@ -105,7 +118,7 @@ func NewTaskFromTemplate(taskTempl *TaskTemplate, workflowName string, jobUUID s
 		task.addDataPipeFromTemplate(&dataPipeTemplate, nameToAction)
 	}
 
-	return task
+	return task, nil
 }
 
 func (t *Task) populateTaskInputsFromPromise(promise *TaskPromise) {
@@ -116,34 +129,42 @@ func (t *Task) populateTaskInputsFromPromise(promise *TaskPromise) {
 		}
 
 		for _, inDP := range inputs {
-			inDP.Queue = append(inDP.Queue, chunk)
+			inDP.addChunk(chunk)
 		}
 	}
 }
 
-func NewTaskFromPromise(promise *TaskPromise, workflow *Workflow) *Task {
+func NewTaskFromPromise(promise *TaskPromise, workflow *Workflow) (*Task, error) {
 	taskTempl := workflow.FindTaskTemplate(promise.TaskName)
 
 	if taskTempl == nil {
-		return nil
+		return nil, fmt.Errorf("Task template %s not found in workflow %s", promise.TaskName, workflow.Name)
+	}
+
+	task, err := NewTaskFromTemplate(taskTempl, workflow.Name, promise.JobUUID)
+	if err != nil {
+		return nil, err
 	}
 
-	task := NewTaskFromTemplate(taskTempl, workflow.Name, promise.JobUUID)
 	task.populateTaskInputsFromPromise(promise)
 
 	task.JobUUID = promise.JobUUID
 
-	return task
+	return task, nil
 }
 
-func NewTaskFromScheduledTask(scheduledTask *ScheduledTask) *Task {
-	task := NewTaskFromTemplate(&scheduledTask.Template, scheduledTask.WorkflowName, scheduledTask.JobUUID)
+func NewTaskFromScheduledTask(scheduledTask *ScheduledTask) (*Task, error) {
+	task, err := NewTaskFromTemplate(&scheduledTask.Template, scheduledTask.WorkflowName, scheduledTask.JobUUID)
+	if err != nil {
+		return nil, err
+	}
 
 	task.ScheduledTaskUUID = scheduledTask.UUID
+	task.scheduledTask = scheduledTask
 
 	task.populateTaskInputsFromPromise(&scheduledTask.Promise)
 
-	return task
+	return task, nil
 }
 
 func (t *Task) AddInput(name string, action Action, actionInputName string, dataPipe *DataPipe) {
@@ -215,18 +236,296 @@ func (t *Task) sortActionsTopologically() []Action {
 	return order
 }
 
-func (t *Task) Run() error {
-	order := t.sortActionsTopologically()
+// actionPipes are the data pipes that action gets data from and sends data to.
+type actionPipes struct {
+	inputs  []*DataPipe
+	outputs []*DataPipe
+}
+
+func (t *Task) pipesByAction() map[string]*actionPipes {
+	pipesByAction := map[string]*actionPipes{}
+
+	pipesOf := func(action Action) *actionPipes {
+		pipes := pipesByAction[action.GetUniqueID()]
+		if pipes == nil {
+			pipes = &actionPipes{}
+			pipesByAction[action.GetUniqueID()] = pipes
+		}
+
+		return pipes
+	}
+
+	for _, dp := range t.DataPipes {
+		if dp.ToAction != nil {
+			pipes := pipesOf(dp.ToAction)
+			pipes.inputs = append(pipes.inputs, dp)
+		}
+
+		if dp.FromAction != nil {
+			pipes := pipesOf(dp.FromAction)
+			pipes.outputs = append(pipes.outputs, dp)
+		}
+	}
+
+	return pipesByAction
+}
+
+// runActionOnAllChunks runs the action once, and then again for as long as any of its
+// inputs that got more than one chunk has chunks left, so that lists can be streamed
+// through actions one element at a time. Input that got a single chunk hands it to each
+// run, e.g. base URL that is joined with each of relative URLs. Action is not run at all
+// if stream it gets (e.g. from XPathAction with streamResults) turned out empty. Outputs of
+// action fed by streams are streamed too. Action is not run again if it took no chunks on
+// its last run. Once action is done, its outputs are closed. Returns error only if it's not
+// allowed for the action to fail.
+func runActionOnAllChunks(ctx context.Context, action Action, pipes *actionPipes) error {
+	if pipes == nil {
+		pipes = &actionPipes{}
+	}
+
+	defer func() {
+		for _, outDP := range pipes.outputs {
+			outDP.Close()
+		}
+	}()
+
+	streamed := []*DataPipe{}
+	broadcast := map[*DataPipe]*DataChunk{}
+	fedByStream := false
+	emptyStream := false
+
+	for _, inDP := range pipes.inputs {
+		n := inDP.Len()
+
+		if n > 1 || inDP.IsStreamed() {
+			fedByStream = true
+		}
+
+		switch {
+		case n > 1:
+			streamed = append(streamed, inDP)
+		case n == 1:
+			broadcast[inDP] = inDP.peekChunk()
+		case inDP.IsStreamed() && inDP.IsDone():
+			emptyStream = true
+		}
+	}
+
+	if fedByStream {
+		for _, outDP := range pipes.outputs {
+			outDP.MarkStreamed()
+		}
+	}
+
+	if emptyStream {
+		log.Debug(fmt.Sprintf("Not running action %v, as stream it gets is empty", action))
+		return nil
+	}
+
+	nStreamedChunks := func() int {
+		n := 0
+
+		for _, inDP := range streamed {
+			n += inDP.Len()
+		}
+
+		return n
+	}
+
+	nChunksLeft := nStreamedChunks()
+
+	for nRuns := 0; ; nRuns++ {
+		if nRuns > 0 {
+			nChunksBefore := nChunksLeft
+			nChunksLeft = nStreamedChunks()
+
+			if nChunksLeft == 0 {
+				return nil
+			}
 
+			// Action that took nothing on its last run (e.g. TaskPromiseAction refraining)
+			// would do the same again, so the rest of the stream is left alone.
+			if nChunksLeft == nChunksBefore {
+				log.Debug(fmt.Sprintf("Action %v took no chunks on its last run, leaving %d of them",
+					action, nChunksLeft))
+				return nil
+			}
+
+			err := ctx.Err()
+			if err != nil {
+				return err
+			}
+
+			for inDP, chunk := range broadcast {
+				if inDP.Len() == 0 {
+					inDP.requeueChunk(chunk)
+				}
+			}
+		}
+
+		err := action.Run(ctx)
+		if err != nil {
+			if !action.IsFailureAllowed() {
+				return err
+			}
+
+			log.Warn(fmt.Sprintf("Action %v failed with error: %v", action, err))
+		}
+	}
+}
+
+func (t *Task) runActionsSequentially(ctx context.Context, order []Action,
+	pipesByAction map[string]*actionPipes) error {
 	for _, action := range order {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
 		log.Info(fmt.Sprintf("Running action: %v", action))
-		err := action.Run()
-		if err != nil && !action.IsFailureAllowed() {
+
+		err = runActionOnAllChunks(ctx, action, pipesByAction[action.GetUniqueID()])
+		if err != nil {
 			log.Error(fmt.Sprintf("Action failed with error: %v", err))
 			return err
 		}
 	}
 
+	return nil
+}
+
+type actionOutcome struct {
+	action Action
+	err    error
+}
+
+// runAction runs the action, turning panic into TaskPanicError as it would otherwise take
+// down the whole process.
+func runAction(ctx context.Context, action Action, pipes *actionPipes) (outcome actionOutcome) {
+	outcome.action = action
+
+	defer func() {
+		if r := recover(); r != nil {
+			outcome.err = &TaskPanicError{Value: r, Stack: string(debug.Stack())}
+		}
+	}()
+
+	outcome.err = runActionOnAllChunks(ctx, action, pipes)
+
+	return outcome
+}
+
+// runActionsConcurrently starts each action once all its preceding actions are done,
+// keeping up to MaxConcurrency of them running. Once an action fails, no more actions are
+// started and the ones still running are cancelled and waited for.
+func (t *Task) runActionsConcurrently(ctx context.Context, order []Action,
+	pipesByAction map[string]*actionPipes) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	nWaitingFor := map[string]int{}
+	dependents := map[string][]Action{}
+	ready := []Action{}
+
+	for _, action := range order {
+		seen := map[string]bool{}
+
+		for _, preceding := range action.GetPrecedingActions() {
+			precedingID := preceding.GetUniqueID()
+			if seen[precedingID] {
+				continue
+			}
+
+			seen[precedingID] = true
+			nWaitingFor[action.GetUniqueID()]++
+			dependents[precedingID] = append(dependents[precedingID], action)
+		}
+
+		if nWaitingFor[action.GetUniqueID()] == 0 {
+			ready = append(ready, action)
+		}
+	}
+
+	outcomes := make(chan actionOutcome, len(order))
+	nRunning := 0
+
+	var firstErr error
+
+	for len(ready) > 0 || nRunning > 0 {
+		for len(ready) > 0 && nRunning < t.MaxConcurrency && firstErr == nil && ctx.Err() == nil {
+			action := ready[0]
+			ready = ready[1:]
+
+			log.Info(fmt.Sprintf("Running action: %v", action))
+
+			nRunning++
+
+			go func() {
+				outcomes <- runAction(ctx, action, pipesByAction[action.GetUniqueID()])
+			}()
+		}
+
+		if nRunning == 0 {
+			break
+		}
+
+		outcome := <-outcomes
+		nRunning--
+
+		if outcome.err != nil {
+			log.Error(fmt.Sprintf("Action failed with error: %v", outcome.err))
+
+			if firstErr == nil {
+				firstErr = outcome.err
+				cancel()
+			}
+
+			continue
+		}
+
+		for _, dependent := range dependents[outcome.action.GetUniqueID()] {
+			nWaitingFor[dependent.GetUniqueID()]--
+
+			if nWaitingFor[dependent.GetUniqueID()] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+// Run runs actions of the task until all of them are done or given context is cancelled.
+// Actions get the context along with task metadata. Inputs of the task are closed, as
+// nothing more can be added to them once task is running.
+func (t *Task) Run(ctx context.Context) error {
+	ctx = ContextWithTaskMetadata(ctx, NewTaskMetadata(t))
+
+	for _, inputs := range t.Inputs {
+		for _, inDP := range inputs {
+			inDP.Close()
+		}
+	}
+
+	order := t.sortActionsTopologically()
+	pipesByAction := t.pipesByAction()
+
+	var err error
+
+	if t.MaxConcurrency > 1 {
+		err = t.runActionsConcurrently(ctx, order, pipesByAction)
+	} else {
+		err = t.runActionsSequentially(ctx, order, pipesByAction)
+	}
+
+	if err != nil {
+		return err
+	}
+
 	for _, outDP := range t.Outputs {
 		for _, chunk := range outDP.Queue {
 			if chunk.Type == DataChunkTypeItem {
@@ -247,7 +546,7 @@ func (t *Task) Run() error {
 	return nil
 }
 
-func (t *Task) RunWithInputs(inputs map[string]interface{}) (error, map[string]interface{}) {
+func (t *Task) RunWithInputs(ctx context.Context, inputs map[string]interface{}) (error, map[string]interface{}) {
 	for key, value := range inputs {
 		chunk, err := NewDataChunk(value)
 		if err != nil {
@@ -260,11 +559,11 @@ func (t *Task) RunWithInputs(inputs map[string]interface{}) (error, map[string]i
 		}
 
 		for _, inDP := range inputs {
-			inDP.Queue = append(inDP.Queue, chunk)
+			inDP.addChunk(chunk)
 		}
 	}
 
-	err := t.Run()
+	err := t.Run(ctx)
 	if err != nil {
 		return err, nil
 	}
@@ -1,7 +1,11 @@
 package spsw
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -205,7 +209,8 @@ func TestNewTaskFromTemplate(t *testing.T) {
 	}
 	jobUUID := "44ECE4B0-A1C9-4DE2-A456-7862F2A5B6CA"
 
-	task := NewTaskFromTemplate(&workflow.TaskTemplates[0], workflow.Name, jobUUID)
+	task, err := NewTaskFromTemplate(&workflow.TaskTemplates[0], workflow.Name, jobUUID)
+	assert.Nil(t, err)
 
 	assert.NotNil(t, task)
 	assert.Equal(t, workflow.Name, task.WorkflowName)
@@ -352,7 +357,8 @@ func TestNewTaskFromPromise(t *testing.T) {
 		},
 	}
 
-	task := NewTaskFromPromise(promise, workflow)
+	task, err := NewTaskFromPromise(promise, workflow)
+	assert.Nil(t, err)
 
 	assert.NotNil(t, task)
 
@@ -385,3 +391,326 @@ func TestNewTaskUniqueUUID(t *testing.T) {
 
 	assert.NotEqual(t, task1.UUID, task2.UUID)
 }
+
+func TestTaskRunPassesContextToActions(t *testing.T) {
+	task := NewTask("Task1", "WF0", "job1")
+
+	var gotMetadata *TaskMetadata
+
+	action := newTestWorkerAction(func(ctx context.Context) error {
+		gotMetadata, _ = TaskMetadataFromContext(ctx)
+		return nil
+	})
+
+	task.AddAction(action)
+	task.AddOutput("out", action, testWorkerActionOutput, NewDataPipe())
+
+	err := task.Run(context.Background())
+	assert.Nil(t, err)
+	assert.NotNil(t, gotMetadata)
+	assert.Equal(t, "job1", gotMetadata.JobUUID)
+	assert.Equal(t, task.UUID, gotMetadata.TaskUUID)
+	assert.Equal(t, "WF0", gotMetadata.WorkflowName)
+
+	// Actions are not run once context is cancelled.
+	gotMetadata = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = task.Run(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, gotMetadata)
+}
+
+const testTaskActionInput1 = "testTaskActionInput1"
+const testTaskActionInput2 = "testTaskActionInput2"
+const testTaskActionOutput = "testTaskActionOutput"
+
+// testTaskAction joins strings it gets on its inputs with its own name and passes that on.
+type testTaskAction struct {
+	AbstractAction
+	run func(ctx context.Context) error
+}
+
+func newTestTaskAction(name string, run func(ctx context.Context) error) *testTaskAction {
+	return &testTaskAction{
+		AbstractAction: AbstractAction{
+			Name:               name,
+			AllowedInputNames:  []string{testTaskActionInput1, testTaskActionInput2},
+			AllowedOutputNames: []string{testTaskActionOutput},
+			Inputs:             map[string]*DataPipe{},
+			Outputs:            map[string][]*DataPipe{},
+			UUID:               name,
+		},
+		run: run,
+	}
+}
+
+func (tta *testTaskAction) Run(ctx context.Context) error {
+	if tta.run != nil {
+		err := tta.run(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	s := ""
+
+	for _, inputName := range []string{testTaskActionInput1, testTaskActionInput2} {
+		if inDP := tta.Inputs[inputName]; inDP != nil {
+			x, _ := inDP.Remove().(string)
+			s += x + "+"
+		}
+	}
+
+	for _, outDP := range tta.Outputs[testTaskActionOutput] {
+		outDP.Add(s + tta.Name)
+	}
+
+	return nil
+}
+
+// newTestFanOutTask makes task where first and second action run independently of each
+// other, and third joins what they produce.
+func newTestFanOutTask(run func(ctx context.Context) error) (*Task, *DataPipe) {
+	task := NewTask("Task1", "WF0", "job1")
+
+	first := newTestTaskAction("first", run)
+	second := newTestTaskAction("second", run)
+	third := newTestTaskAction("third", nil)
+
+	task.AddAction(first)
+	task.AddAction(second)
+	task.AddAction(third)
+
+	task.AddDataPipeBetweenActions(first, testTaskActionOutput, third, testTaskActionInput1)
+	task.AddDataPipeBetweenActions(second, testTaskActionOutput, third, testTaskActionInput2)
+
+	outDP := NewDataPipe()
+	task.AddOutput("out", third, testTaskActionOutput, outDP)
+
+	return task, outDP
+}
+
+func TestTaskRunActionsConcurrently(t *testing.T) {
+	for _, maxConcurrency := range []int{0, 1, 2} {
+		var mutex sync.Mutex
+		nRunning := 0
+		maxRunning := 0
+
+		task, outDP := newTestFanOutTask(func(ctx context.Context) error {
+			mutex.Lock()
+			nRunning++
+			if nRunning > maxRunning {
+				maxRunning = nRunning
+			}
+			mutex.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+
+			mutex.Lock()
+			nRunning--
+			mutex.Unlock()
+
+			return nil
+		})
+
+		task.MaxConcurrency = maxConcurrency
+
+		err := task.Run(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "first+second+third", outDP.Remove())
+
+		if maxConcurrency > 1 {
+			assert.Equal(t, maxConcurrency, maxRunning)
+		} else {
+			assert.Equal(t, 1, maxRunning)
+		}
+	}
+}
+
+func TestTaskRunActionsConcurrentlyFailure(t *testing.T) {
+	task, outDP := newTestFanOutTask(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	task.MaxConcurrency = 2
+
+	err := task.Run(context.Background())
+	assert.Equal(t, errors.New("connection refused"), err)
+
+	// Action depending on failed ones is not run.
+	assert.Equal(t, 0, outDP.Len())
+}
+
+func TestTaskRunActionsConcurrentlyCancelsOnFailure(t *testing.T) {
+	task := NewTask("Task1", "WF0", "job1")
+
+	failing := newTestTaskAction("failing", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	var cancelledErr error
+
+	waiting := newTestTaskAction("waiting", func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelledErr = ctx.Err()
+		return cancelledErr
+	})
+
+	task.AddAction(failing)
+	task.AddAction(waiting)
+
+	task.AddOutput("out1", failing, testTaskActionOutput, NewDataPipe())
+	task.AddOutput("out2", waiting, testTaskActionOutput, NewDataPipe())
+
+	task.MaxConcurrency = 2
+
+	err := task.Run(context.Background())
+	assert.Equal(t, errors.New("connection refused"), err)
+	assert.Equal(t, context.Canceled, cancelledErr)
+}
+
+func TestTaskRunActionsConcurrentlyPanic(t *testing.T) {
+	task, _ := newTestFanOutTask(func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	task.MaxConcurrency = 2
+
+	err := task.Run(context.Background())
+
+	panicErr, okPanic := err.(*TaskPanicError)
+	assert.True(t, okPanic)
+	assert.Equal(t, "boom", panicErr.Value)
+}
+
+func TestNewTaskFromTemplateMaxConcurrency(t *testing.T) {
+	taskTempl := NewTaskTemplate("Task1", true)
+	taskTempl.MaxConcurrency = 4
+
+	task, err := NewTaskFromTemplate(taskTempl, "WF0", "job1")
+	assert.Nil(t, err)
+
+	assert.Equal(t, 4, task.MaxConcurrency)
+}
+
+func TestTaskRunStreamsChunksThroughActions(t *testing.T) {
+	for _, maxConcurrency := range []int{0, 2} {
+		task := NewTask("Task1", "WF0", "job1")
+
+		htmlIn := NewDataPipe()
+		urlsOut := NewDataPipe()
+
+		xpathAction := NewXPathAction("//a/@href", true)
+		xpathAction.StreamResults = true
+
+		baseURLAction := NewConstAction(NewValueFromString("https://example.org/books/"))
+		urlJoinAction := NewURLJoinAction("")
+
+		task.AddAction(xpathAction)
+		task.AddAction(baseURLAction)
+		task.AddAction(urlJoinAction)
+
+		task.AddInput("html", xpathAction, XPathActionInputHTMLStr, htmlIn)
+		task.AddDataPipeBetweenActions(xpathAction, XPathActionOutputStr, urlJoinAction,
+			URLJoinActionInputRelativeURL)
+		task.AddDataPipeBetweenActions(baseURLAction, ConstActionOutput, urlJoinAction, URLJoinActionInputBaseURL)
+		task.AddOutput("urls", urlJoinAction, URLJoinActionOutputAbsoluteURL, urlsOut)
+
+		task.MaxConcurrency = maxConcurrency
+
+		htmlIn.Add("<html><body><a href=\"1.html\">1</a><a href=\"2.html\">2</a><a href=\"/3.html\">3</a></body></html>")
+
+		err := task.Run(context.Background())
+		assert.Nil(t, err)
+
+		// URLJoinAction ran for each relative URL, getting the same base URL each time.
+		assert.Equal(t, 3, urlsOut.Len())
+		assert.Equal(t, "https://example.org/books/1.html", urlsOut.Remove())
+		assert.Equal(t, "https://example.org/books/2.html", urlsOut.Remove())
+		assert.Equal(t, "https://example.org/3.html", urlsOut.Remove())
+
+		assert.True(t, urlsOut.IsDone())
+		assert.True(t, htmlIn.IsDone())
+	}
+}
+
+func TestTaskRunStopsWhenActionTakesNoChunks(t *testing.T) {
+	task := NewTask("Task1", "WF0", "job1")
+
+	pagesIn := NewDataPipe()
+	promisesOut := NewDataPipe()
+
+	refrainAction := NewConstAction(NewValueFromBool(true))
+	promiseAction := NewTaskPromiseAction([]string{"page"}, "Task2", "job1", nil)
+
+	task.AddAction(refrainAction)
+	task.AddAction(promiseAction)
+
+	task.AddInput("pages", promiseAction, "page", pagesIn)
+	task.AddDataPipeBetweenActions(refrainAction, ConstActionOutput, promiseAction, TaskPromiseActionInputRefrain)
+	task.AddOutput("promises", promiseAction, TaskPromiseActionOutputPromise, promisesOut)
+
+	pagesIn.Add("1")
+	pagesIn.Add("2")
+	pagesIn.Add("3")
+
+	// Refraining TaskPromiseAction returns without taking the page.
+	done := make(chan error)
+	go func() {
+		done <- task.Run(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Task kept running action that took no chunks")
+	}
+
+	assert.Equal(t, 0, promisesOut.Len())
+}
+
+func TestTaskRunSkipsActionsOnEmptyStream(t *testing.T) {
+	for _, maxConcurrency := range []int{0, 2} {
+		task := NewTask("Task1", "WF0", "job1")
+
+		htmlIn := NewDataPipe()
+		urlsOut := NewDataPipe()
+
+		xpathAction := NewXPathAction("//a/@href", true)
+		xpathAction.StreamResults = true
+
+		baseURLAction := NewConstAction(NewValueFromString("https://example.org/books/"))
+		urlJoinAction := NewURLJoinAction("")
+
+		// Actions further downstream don't run either.
+		secondURLJoinAction := NewURLJoinAction("https://example.org/")
+
+		task.AddAction(xpathAction)
+		task.AddAction(baseURLAction)
+		task.AddAction(urlJoinAction)
+		task.AddAction(secondURLJoinAction)
+
+		task.AddInput("html", xpathAction, XPathActionInputHTMLStr, htmlIn)
+		task.AddDataPipeBetweenActions(xpathAction, XPathActionOutputStr, urlJoinAction,
+			URLJoinActionInputRelativeURL)
+		task.AddDataPipeBetweenActions(baseURLAction, ConstActionOutput, urlJoinAction, URLJoinActionInputBaseURL)
+		task.AddDataPipeBetweenActions(urlJoinAction, URLJoinActionOutputAbsoluteURL, secondURLJoinAction,
+			URLJoinActionInputRelativeURL)
+		task.AddOutput("urls", secondURLJoinAction, URLJoinActionOutputAbsoluteURL, urlsOut)
+
+		task.MaxConcurrency = maxConcurrency
+
+		htmlIn.Add("<html><body><p>No links here</p></body></html>")
+
+		// URLJoinAction would fail with "Cannot get relative URL" if it ran.
+		err := task.Run(context.Background())
+		assert.Nil(t, err)
+
+		assert.Equal(t, 0, urlsOut.Len())
+		assert.True(t, urlsOut.IsDone())
+	}
+}
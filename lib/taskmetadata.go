@@ -0,0 +1,44 @@
+package spsw
+
+import (
+	"context"
+	"fmt"
+)
+
+type taskMetadataKey struct{}
+
+// TaskMetadata tells actions which task they are running for, e.g. to tag log
+// messages or outgoing requests.
+type TaskMetadata struct {
+	JobUUID           string
+	TaskUUID          string
+	TaskName          string
+	ScheduledTaskUUID string
+	WorkflowName      string
+}
+
+func NewTaskMetadata(task *Task) *TaskMetadata {
+	return &TaskMetadata{
+		JobUUID:           task.JobUUID,
+		TaskUUID:          task.UUID,
+		TaskName:          task.Name,
+		ScheduledTaskUUID: task.ScheduledTaskUUID,
+		WorkflowName:      task.WorkflowName,
+	}
+}
+
+func (tm *TaskMetadata) String() string {
+	return fmt.Sprintf("<TaskMetadata JobUUID: %s, TaskUUID: %s, TaskName: %s, ScheduledTaskUUID: %s, WorkflowName: %s>",
+		tm.JobUUID, tm.TaskUUID, tm.TaskName, tm.ScheduledTaskUUID, tm.WorkflowName)
+}
+
+// ContextWithTaskMetadata returns copy of given context that carries task metadata.
+func ContextWithTaskMetadata(ctx context.Context, metadata *TaskMetadata) context.Context {
+	return context.WithValue(ctx, taskMetadataKey{}, metadata)
+}
+
+// TaskMetadataFromContext returns task metadata carried by given context, if any.
+func TaskMetadataFromContext(ctx context.Context) (*TaskMetadata, bool) {
+	metadata, ok := ctx.Value(taskMetadataKey{}).(*TaskMetadata)
+	return metadata, ok
+}
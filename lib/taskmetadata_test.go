@@ -0,0 +1,28 @@
+package spsw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskMetadataContext(t *testing.T) {
+	_, ok := TaskMetadataFromContext(context.Background())
+	assert.False(t, ok)
+
+	task := NewTask("Task1", "WF0", "job1")
+	task.ScheduledTaskUUID = "scheduledTask1"
+
+	metadata := NewTaskMetadata(task)
+
+	ctx := ContextWithTaskMetadata(context.Background(), metadata)
+
+	gotMetadata, ok := TaskMetadataFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "job1", gotMetadata.JobUUID)
+	assert.Equal(t, task.UUID, gotMetadata.TaskUUID)
+	assert.Equal(t, "Task1", gotMetadata.TaskName)
+	assert.Equal(t, "scheduledTask1", gotMetadata.ScheduledTaskUUID)
+	assert.Equal(t, "WF0", gotMetadata.WorkflowName)
+}
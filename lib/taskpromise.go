@@ -19,6 +19,14 @@ type TaskPromise struct {
 	JobUUID                    string
 	InputDataChunksByInputName map[string]*DataChunk
 	CreatedAt                  time.Time
+
+	// Priority overrides priority of task template, if set.
+	Priority *int
+
+	// Depth counts how many tasks it took to get here from the initial task. Manager sets
+	// it along with ParentTaskUUID (UUID of scheduled task that made the promise).
+	Depth          int
+	ParentTaskUUID string
 }
 
 func NewTaskPromise(taskName string, workflowName string, jobUUID string, inputDataChunksByInputName map[string]*DataChunk) *TaskPromise {
@@ -98,6 +106,9 @@ func (tp *TaskPromise) splayOff() *TaskPromise {
 		JobUUID:                    tp.JobUUID,
 		InputDataChunksByInputName: map[string]*DataChunk{},
 		CreatedAt:                  time.Now(),
+		Priority:                   tp.Priority,
+		Depth:                      tp.Depth,
+		ParentTaskUUID:             tp.ParentTaskUUID,
 	}
 
 	for name, chunk := range tp.InputDataChunksByInputName {
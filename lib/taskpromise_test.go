@@ -103,6 +103,28 @@ func TestTaskPromiseSplay(t *testing.T) {
 	assert.Equal(t, "3", gotPromises[2].InputDataChunksByInputName["param2"].PayloadValue.StringValue)
 }
 
+func TestTaskPromiseSplayKeepsPriority(t *testing.T) {
+	priority := 3
+
+	promise := &TaskPromise{
+		TaskName:     "HTTP1",
+		WorkflowName: "testWorkflow",
+		JobUUID:      "97F34D30-7355-4C82-9480-A3B9CD086824",
+		InputDataChunksByInputName: map[string]*DataChunk{
+			"param1": NewDataChunk_(NewValueFromStrings([]string{"1", "2"})),
+		},
+		Priority: &priority,
+	}
+
+	gotPromises := promise.Splay()
+
+	assert.Equal(t, 2, len(gotPromises))
+
+	for _, newPromise := range gotPromises {
+		assert.Equal(t, &priority, newPromise.Priority)
+	}
+}
+
 func TestTaskPromiseSplay2(t *testing.T) {
 	taskName := "HTTP2"
 	workflowName := "testWorkflow"
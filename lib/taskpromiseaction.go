@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -15,6 +16,9 @@ type TaskPromiseAction struct {
 	WorkflowName  string
 	JobUUID       string
 	RequireFields []string
+
+	// Priority is passed on to the promises, overriding priority of the task template.
+	Priority *int
 }
 
 const TaskPromiseActionInputRefrain = "TaskPromiseActionInputRefrain"
@@ -41,20 +45,26 @@ func NewTaskPromiseAction(inputNames []string, taskName string, jobUUID string,
 	}
 }
 
-func NewTaskPromiseActionFromTemplate(actionTempl *ActionTemplate) Action {
-	var inputNames []string
-	var taskName string
-	var requireFields []string
+func NewTaskPromiseActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("TaskPromiseAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
 
-	inputNames = actionTempl.ConstructorParams["inputNames"].StringsValue
-	taskName = actionTempl.ConstructorParams["taskName"].StringValue
-	requireFields = actionTempl.ConstructorParams["requireFields"].StringsValue
+	inputNames := params["inputNames"].StringsValue
+	taskName := params["taskName"].StringValue
+	requireFields := params["requireFields"].StringsValue
 
 	action := NewTaskPromiseAction(inputNames, taskName, "", requireFields)
 
+	if priorityValue, ok := params["priority"]; ok {
+		priority := priorityValue.IntValue
+		action.Priority = &priority
+	}
+
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (tpa *TaskPromiseAction) String() string {
@@ -62,7 +72,7 @@ func (tpa *TaskPromiseAction) String() string {
 		tpa.UUID, tpa.Name, tpa.AllowedInputNames, tpa.TaskName, tpa.WorkflowName, tpa.JobUUID, tpa.RequireFields)
 }
 
-func (tpa *TaskPromiseAction) Run() error {
+func (tpa *TaskPromiseAction) Run(ctx context.Context) error {
 	inputDataChunksByInputName := map[string]*DataChunk{}
 
 	if tpa.Inputs[TaskPromiseActionInputRefrain] != nil {
@@ -79,7 +89,7 @@ func (tpa *TaskPromiseAction) Run() error {
 			continue
 		}
 
-		if len(input.Queue) > 0 {
+		if input.Len() > 0 {
 			x := input.Remove()
 			newChunk, _ := NewDataChunk(x)
 			inputDataChunksByInputName[name] = newChunk
@@ -101,6 +111,7 @@ func (tpa *TaskPromiseAction) Run() error {
 	}
 
 	promise := NewTaskPromise(tpa.TaskName, tpa.WorkflowName, tpa.JobUUID, inputDataChunksByInputName)
+	promise.Priority = tpa.Priority
 
 	for _, output := range tpa.Outputs[TaskPromiseActionOutputPromise] {
 		output.Add(promise)
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,7 +25,10 @@ func TestNewTaskPromiseActionFromTemplate(t *testing.T) {
 		},
 	}
 
-	action, ok := NewTaskPromiseActionFromTemplate(actionTempl).(*TaskPromiseAction)
+	gotAction, err := NewTaskPromiseActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action, ok := gotAction.(*TaskPromiseAction)
 	assert.True(t, ok)
 
 	expectInputNames := []string{"page", "session", TaskPromiseActionInputRefrain}
@@ -33,6 +37,17 @@ func TestNewTaskPromiseActionFromTemplate(t *testing.T) {
 	assert.Equal(t, taskName, action.TaskName)
 	assert.Equal(t, expectInputNames, action.AllowedInputNames)
 	assert.Equal(t, []string{TaskPromiseActionOutputPromise}, action.AllowedOutputNames)
+	assert.Nil(t, action.Priority)
+
+	actionTempl.ConstructorParams["priority"] = Value{ValueType: ValueTypeInt, IntValue: 3}
+
+	gotAction, err = NewTaskPromiseActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action = gotAction.(*TaskPromiseAction)
+
+	assert.NotNil(t, action.Priority)
+	assert.Equal(t, 3, *action.Priority)
 }
 
 func TestTaskPromiseActionRun(t *testing.T) {
@@ -43,23 +58,24 @@ func TestTaskPromiseActionRun(t *testing.T) {
 		StructName: "TaskPromiseAction",
 		ConstructorParams: map[string]Value{
 			"inputNames": Value{
-				ValueType:    ValueTypeString,
+				ValueType:    ValueTypeStrings,
 				StringsValue: []string{"page", "session"},
 			},
 			"taskName": Value{
-				ValueType:   ValueTypeStrings,
+				ValueType:   ValueTypeString,
 				StringValue: taskName,
 			},
 		},
 	}
 
-	action := NewTaskPromiseActionFromTemplate(actionTempl)
+	action, err := NewTaskPromiseActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
 
 	pageIn := NewDataPipe()
 	sessionIn := NewDataPipe()
 	promiseOut := NewDataPipe()
 
-	err := action.AddInput("page", pageIn)
+	err = action.AddInput("page", pageIn)
 	assert.Nil(t, err)
 
 	err = action.AddInput("session", sessionIn)
@@ -74,7 +90,7 @@ func TestTaskPromiseActionRun(t *testing.T) {
 	pageIn.Add(page)
 	sessionIn.Add(session)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	promise, ok := promiseOut.Remove().(*TaskPromise)
@@ -84,6 +100,40 @@ func TestTaskPromiseActionRun(t *testing.T) {
 	assert.Equal(t, session, promise.InputDataChunksByInputName["session"].PayloadValue.StringValue)
 }
 
+func TestTaskPromiseActionRunNullInput(t *testing.T) {
+	actionTempl := &ActionTemplate{
+		Name:       "testAction",
+		StructName: "TaskPromiseAction",
+		ConstructorParams: map[string]Value{
+			"inputNames": *NewValueFromStrings([]string{"price"}),
+			"taskName":   *NewValueFromString("HTTP2"),
+		},
+	}
+
+	action, err := NewTaskPromiseActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	priceIn := NewDataPipe()
+	promiseOut := NewDataPipe()
+
+	assert.Nil(t, action.AddInput("price", priceIn))
+	assert.Nil(t, action.AddOutput(TaskPromiseActionOutputPromise, promiseOut))
+
+	priceIn.Add(NewNullValue())
+
+	err = action.Run(context.Background())
+	assert.Nil(t, err)
+
+	promise, ok := promiseOut.Remove().(*TaskPromise)
+	assert.True(t, ok)
+
+	chunk := promise.InputDataChunksByInputName["price"]
+	assert.NotNil(t, chunk)
+	if chunk != nil {
+		assert.Equal(t, ValueTypeNull, chunk.PayloadValue.ValueType)
+	}
+}
+
 func TestTaskPromiseActionRunRequireFields(t *testing.T) {
 	taskName := "HTTP3"
 
@@ -106,13 +156,14 @@ func TestTaskPromiseActionRunRequireFields(t *testing.T) {
 		},
 	}
 
-	action := NewTaskPromiseActionFromTemplate(actionTempl)
+	action, err := NewTaskPromiseActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
 
 	pageIn := NewDataPipe()
 	sessionIn := NewDataPipe()
 	promiseOut := NewDataPipe()
 
-	err := action.AddInput("page", pageIn)
+	err = action.AddInput("page", pageIn)
 	assert.Nil(t, err)
 
 	err = action.AddInput("session", sessionIn)
@@ -126,14 +177,14 @@ func TestTaskPromiseActionRunRequireFields(t *testing.T) {
 
 	pageIn.Add(page)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, 0, len(promiseOut.Queue))
 
 	pageIn.Add(page)
 	sessionIn.Add(session)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	promise, ok := promiseOut.Remove().(*TaskPromise)
@@ -15,18 +15,28 @@ type TaskResult struct {
 	TaskUUID          string
 	ScheduledTaskUUID string
 	Succeeded         bool
-	Error             error
+	Error             string
 	OutputDataChunks  map[string][]*DataChunk
+
+	// ScheduledTask the result is for, so that manager resuming the job can take over
+	// tasks scheduled after its checkpoint.
+	ScheduledTask *ScheduledTask `json:",omitempty"`
 }
 
 func NewTaskResult(jobUUID string, taskUUID string, scheduledTaskUUID string, succeeded bool, err error) *TaskResult {
+	// Error is kept as string, as error values do not survive JSON round trip.
+	errStr := ""
+	if err != nil {
+		errStr = err.Error()
+	}
+
 	return &TaskResult{
 		UUID:              uuid.New().String(),
 		JobUUID:           jobUUID,
 		TaskUUID:          taskUUID,
 		ScheduledTaskUUID: scheduledTaskUUID,
 		Succeeded:         succeeded,
-		Error:             err,
+		Error:             errStr,
 		OutputDataChunks:  map[string][]*DataChunk{},
 	}
 }
@@ -21,7 +21,7 @@ func TestNewTaskResult(t *testing.T) {
 	assert.Equal(t, taskUUID, taskResult.TaskUUID)
 	assert.Equal(t, scheduledTaskUUID, taskResult.ScheduledTaskUUID)
 	assert.Equal(t, succeeded, taskResult.Succeeded)
-	assert.Equal(t, err, taskResult.Error)
+	assert.Equal(t, err.Error(), taskResult.Error)
 
 	assert.NotNil(t, taskResult.OutputDataChunks)
 	assert.Equal(t, 0, len(taskResult.OutputDataChunks))
@@ -64,3 +64,13 @@ func TestTaskResultAddOutputTaskPromise(t *testing.T) {
 	assert.Equal(t, "DoIt", taskResult.OutputDataChunks["promises"][0].PayloadPromise.TaskName)
 	assert.Equal(t, "DoItAgain", taskResult.OutputDataChunks["promises"][1].PayloadPromise.TaskName)
 }
+
+func TestTaskResultJSONRoundTripKeepsError(t *testing.T) {
+	taskResult := NewTaskResult("A0", "B1", "C2", false, errors.New("Test error"))
+
+	gotTaskResult := NewTaskResultFromJSON(taskResult.EncodeToJSON())
+
+	assert.NotNil(t, gotTaskResult)
+	assert.False(t, gotTaskResult.Succeeded)
+	assert.Equal(t, "Test error", gotTaskResult.Error)
+}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -37,21 +38,26 @@ func NewURLJoinAction(baseURL string) *URLJoinAction {
 	}
 }
 
-func NewURLJoinActionFromTemplate(actionTempl *ActionTemplate) Action {
-	baseURL := actionTempl.ConstructorParams["baseURL"].StringValue
+func NewURLJoinActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("URLJoinAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := params["baseURL"].StringValue
 
 	action := NewURLJoinAction(baseURL)
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (uja *URLJoinAction) String() string {
 	return fmt.Sprintf("<URLJoinAction %s Name: %s, BaseURL: %s>", uja.UUID, uja.Name, uja.BaseURL)
 }
 
-func (uja *URLJoinAction) Run() error {
+func (uja *URLJoinAction) Run(ctx context.Context) error {
 	var baseURL *url.URL
 	var absoluteURL *url.URL
 	var err error
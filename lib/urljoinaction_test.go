@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,7 +24,7 @@ func TestURLJoinActionRun(t *testing.T) {
 
 	inDP.Add(relativeURLStr)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 
 	gotAbsoluteURLStr, ok := outDP.Remove().(string)
 	assert.True(t, ok)
@@ -54,7 +55,7 @@ func TestURLJoinActionBaseWithRelative(t *testing.T) {
 	err = action.AddOutput(URLJoinActionOutputAbsoluteURL, absoluteOut)
 	assert.Nil(t, err)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotAbsoluteURLStr, ok := absoluteOut.Remove().(string)
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -40,12 +41,17 @@ func NewURLParseAction() *URLParseAction {
 	}
 }
 
-func NewURLParseActionFromTemplate(actionTempl *ActionTemplate) Action {
+func NewURLParseActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	_, err := constructorParamsWithDefaults("URLParseAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
 	action := NewURLParseAction()
 
 	action.Name = actionTempl.Name
 
-	return action
+	return action, nil
 }
 
 func (upa *URLParseAction) String() string {
@@ -62,7 +68,7 @@ func (upa *URLParseAction) fixParams(params url.Values) map[string][]string {
 	return newParams
 }
 
-func (upa *URLParseAction) Run() error {
+func (upa *URLParseAction) Run(ctx context.Context) error {
 	if upa.Inputs[URLParseActionInputURL] == nil {
 		return errors.New("Input not connected")
 	}
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -38,7 +39,7 @@ func TestURLParseActionRun(t *testing.T) {
 	err = action.AddOutput(URLParseActionOutputParams, paramsOut)
 	assert.Nil(t, err)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotScheme, ok1 := schemeOut.Remove().(string)
@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -34,17 +35,22 @@ func NewUTF8DecodeAction() *UTF8DecodeAction {
 	}
 }
 
-func NewUTF8DecodeActionFromTemplate(actionTempl *ActionTemplate) Action {
+func NewUTF8DecodeActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	_, err := constructorParamsWithDefaults("UTF8DecodeAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
 	action := NewUTF8DecodeAction()
 	action.Name = actionTempl.Name
-	return action
+	return action, nil
 }
 
 func (ua *UTF8DecodeAction) String() string {
 	return fmt.Sprintf("<UTF8DecodeAction %s Name: %s>", ua.UUID, ua.Name)
 }
 
-func (ua *UTF8DecodeAction) Run() error {
+func (ua *UTF8DecodeAction) Run(ctx context.Context) error {
 	if ua.Inputs[UTF8DecodeActionInputBytes] == nil {
 		return errors.New("Input not connected")
 	}
@@ -94,17 +100,22 @@ func NewUTF8EncodeAction() *UTF8EncodeAction {
 	}
 }
 
-func NewUTF8EncodeActionFromTemplate(actionTempl *ActionTemplate) Action {
+func NewUTF8EncodeActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	_, err := constructorParamsWithDefaults("UTF8EncodeAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
+
 	action := NewUTF8EncodeAction()
 	action.Name = actionTempl.Name
-	return action
+	return action, nil
 }
 
 func (ua *UTF8EncodeAction) String() string {
 	return fmt.Sprintf("<UTF8EncodeAction %s Name: %s>", ua.UUID, ua.Name)
 }
 
-func (ua *UTF8EncodeAction) Run() error {
+func (ua *UTF8EncodeAction) Run(ctx context.Context) error {
 	if ua.Inputs[UTF8EncodeActionInputStr] == nil {
 		return errors.New("Input not connected")
 	}
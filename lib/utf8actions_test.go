@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -20,7 +21,7 @@ func TestUTF8EncodeActionRun(t *testing.T) {
 	utf8EncodeAction.AddInput(UTF8EncodeActionInputStr, dataPipeIn)
 	utf8EncodeAction.AddOutput(UTF8EncodeActionOutputBytes, dataPipeOut)
 
-	err := utf8EncodeAction.Run()
+	err := utf8EncodeAction.Run(context.Background())
 	assert.Nil(t, err)
 
 	binData, ok := dataPipeOut.Remove().([]byte)
@@ -49,7 +50,7 @@ func TestUTF8DecodeActionMultipleOutputs(t *testing.T) {
 
 	input.Add(b)
 
-	err = action.Run()
+	err = action.Run(context.Background())
 	assert.Nil(t, err)
 
 	s1, ok1 := output1.Remove().(string)
@@ -64,19 +65,19 @@ func TestUTF8DecodeActionMultipleOutputs(t *testing.T) {
 func TestUTF8DecodeActionRunErrors(t *testing.T) {
 	action := NewUTF8DecodeAction()
 
-	err := action.Run()
+	err := action.Run(context.Background())
 
 	assert.Equal(t, errors.New("Input not connected"), err)
 
 	action.AddInput(UTF8DecodeActionInputBytes, NewDataPipe())
 
-	err = action.Run()
+	err = action.Run(context.Background())
 
 	assert.Equal(t, errors.New("Output not connected"), err)
 
 	action.AddOutput(UTF8DecodeActionOutputStr, NewDataPipe())
 
-	err = action.Run()
+	err = action.Run(context.Background())
 
 	assert.Equal(t, errors.New("Failed to get binary data"), err)
 }
@@ -4,6 +4,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"net/http"
+	"sort"
+	"time"
 )
 
 const ValueTypeInt = "ValueTypeInt"
@@ -14,6 +16,11 @@ const ValueTypeMapStringToString = "ValueTypeStringToString"
 const ValueTypeMapStringToStrings = "ValueTypeStringToStrings"
 const ValueTypeBytes = "ValueTypeBytes"
 const ValueTypeHTTPHeaders = "ValueTypeHTTPHeaders"
+const ValueTypeFloat = "ValueTypeFloat"
+const ValueTypeNull = "ValueTypeNull"
+const ValueTypeTime = "ValueTypeTime"
+const ValueTypeList = "ValueTypeList"
+const ValueTypeMap = "ValueTypeMap"
 
 type Value struct {
 	ValueType               string              `yaml:"ValueType"`
@@ -25,6 +32,10 @@ type Value struct {
 	MapStringToStringsValue map[string][]string `yaml:"MapStringToStringsValue,omitempty"`
 	BytesValue              []byte              `yaml:"BytesValue,omitempty"`
 	HTTPHeadersValue        http.Header         `yaml:"HTTPHeadersValue,omitempty"`
+	FloatValue              float64             `yaml:"FloatValue,omitempty"`
+	TimeValue               time.Time           `yaml:"TimeValue,omitempty"`
+	ListValue               []*Value            `yaml:"ListValue,omitempty"`
+	MapValue                map[string]*Value   `yaml:"MapValue,omitempty"`
 }
 
 func NewValueFromInt(i int) *Value {
@@ -82,7 +93,79 @@ func NewValueFromHTTPHeaders(h http.Header) *Value {
 	}
 }
 
+func NewValueFromFloat(f float64) *Value {
+	return &Value{
+		ValueType:  ValueTypeFloat,
+		FloatValue: f,
+	}
+}
+
+func NewNullValue() *Value {
+	return &Value{
+		ValueType: ValueTypeNull,
+	}
+}
+
+func NewValueFromTime(t time.Time) *Value {
+	return &Value{
+		ValueType: ValueTypeTime,
+		TimeValue: t,
+	}
+}
+
+func NewValueFromList(l []*Value) *Value {
+	return &Value{
+		ValueType: ValueTypeList,
+		ListValue: l,
+	}
+}
+
+func NewValueFromMap(m map[string]*Value) *Value {
+	return &Value{
+		ValueType: ValueTypeMap,
+		MapValue:  m,
+	}
+}
+
+// newValueFromInterfaces makes list Value out of generic slice (e.g. decoded JSON array).
+// It returns nil if any of the elements has unsupported type.
+func newValueFromInterfaces(l []interface{}) *Value {
+	list := []*Value{}
+
+	for _, x := range l {
+		value := NewValue(x)
+		if value == nil {
+			return nil
+		}
+
+		list = append(list, value)
+	}
+
+	return NewValueFromList(list)
+}
+
+// newValueFromInterfaceMap makes map Value out of generic map (e.g. decoded JSON object).
+// It returns nil if any of the values has unsupported type.
+func newValueFromInterfaceMap(m map[string]interface{}) *Value {
+	mapValue := map[string]*Value{}
+
+	for key, x := range m {
+		value := NewValue(x)
+		if value == nil {
+			return nil
+		}
+
+		mapValue[key] = value
+	}
+
+	return NewValueFromMap(mapValue)
+}
+
 func NewValue(x interface{}) *Value {
+	if x == nil {
+		return NewNullValue()
+	}
+
 	if i, okInt := x.(int); okInt {
 		return NewValueFromInt(i)
 	} else if b, okBool := x.(bool); okBool {
@@ -99,6 +182,24 @@ func NewValue(x interface{}) *Value {
 		return NewValueFromBytes(by)
 	} else if h, okHeaders := x.(http.Header); okHeaders {
 		return NewValueFromHTTPHeaders(h)
+	} else if i64, okInt64 := x.(int64); okInt64 {
+		return NewValueFromInt(int(i64))
+	} else if f, okFloat := x.(float64); okFloat {
+		return NewValueFromFloat(f)
+	} else if f32, okFloat32 := x.(float32); okFloat32 {
+		return NewValueFromFloat(float64(f32))
+	} else if t, okTime := x.(time.Time); okTime {
+		return NewValueFromTime(t)
+	} else if l, okList := x.([]*Value); okList {
+		return NewValueFromList(l)
+	} else if m, okMap := x.(map[string]*Value); okMap {
+		return NewValueFromMap(m)
+	} else if li, okInterfaces := x.([]interface{}); okInterfaces {
+		return newValueFromInterfaces(li)
+	} else if mi, okInterfaceMap := x.(map[string]interface{}); okInterfaceMap {
+		return newValueFromInterfaceMap(mi)
+	} else if v, okValue := x.(*Value); okValue {
+		return v
 	}
 
 	return nil
@@ -108,7 +209,31 @@ func (value *Value) Hash() []byte {
 	h := sha256.New()
 
 	h.Write([]byte(value.ValueType))
-	h.Write([]byte(fmt.Sprintf("%v", value.GetUnderlyingValue())))
+
+	if value.ValueType == ValueTypeTime {
+		// %v of time.Time includes location and monotonic clock reading, which would make
+		// the same instant hash differently.
+		h.Write([]byte(value.TimeValue.UTC().Format(time.RFC3339Nano)))
+	} else if value.ValueType == ValueTypeList {
+		for _, element := range value.ListValue {
+			h.Write(element.Hash())
+		}
+	} else if value.ValueType == ValueTypeMap {
+		keys := []string{}
+
+		for key := range value.MapValue {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			h.Write([]byte(key))
+			h.Write(value.MapValue[key].Hash())
+		}
+	} else {
+		h.Write([]byte(fmt.Sprintf("%v", value.GetUnderlyingValue())))
+	}
 
 	return h.Sum(nil)
 }
@@ -134,7 +259,28 @@ func (value *Value) GetUnderlyingValue() interface{} {
 		return value.BytesValue
 	} else if value.ValueType == ValueTypeHTTPHeaders {
 		return value.HTTPHeadersValue
+	} else if value.ValueType == ValueTypeFloat {
+		return value.FloatValue
+	} else if value.ValueType == ValueTypeTime {
+		return value.TimeValue
+	} else if value.ValueType == ValueTypeList {
+		list := []interface{}{}
+
+		for _, element := range value.ListValue {
+			list = append(list, element.GetUnderlyingValue())
+		}
+
+		return list
+	} else if value.ValueType == ValueTypeMap {
+		m := map[string]interface{}{}
+
+		for key, element := range value.MapValue {
+			m[key] = element.GetUnderlyingValue()
+		}
+
+		return m
 	}
 
+	// This includes ValueTypeNull.
 	return nil
 }
@@ -1,10 +1,13 @@
 package spsw
 
 import (
+	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 func TestNewValueFromInt(t *testing.T) {
@@ -42,7 +45,17 @@ func TestNewValue(t *testing.T) {
 		{map[string][]string{"x": []string{"1", "2"}}, &Value{ValueType: ValueTypeMapStringToStrings, MapStringToStringsValue: map[string][]string{"x": []string{"1", "2"}}}},
 		{[]byte("\xde\xea\xbe\xef"), &Value{ValueType: ValueTypeBytes, BytesValue: []byte("\xde\xea\xbe\xef")}},
 		{http.Header{}, &Value{ValueType: ValueTypeHTTPHeaders, HTTPHeadersValue: http.Header{}}},
-		{4.2, nil},
+		{4.2, &Value{ValueType: ValueTypeFloat, FloatValue: 4.2}},
+		{int64(7), &Value{ValueType: ValueTypeInt, IntValue: 7}},
+		{nil, &Value{ValueType: ValueTypeNull}},
+		{time.Unix(1600000000, 0), &Value{ValueType: ValueTypeTime, TimeValue: time.Unix(1600000000, 0)}},
+		{[]interface{}{1.5, "a"}, &Value{ValueType: ValueTypeList, ListValue: []*Value{NewValueFromFloat(1.5), NewValueFromString("a")}}},
+		{map[string]interface{}{"price": 9.99, "tags": []interface{}{"x"}}, &Value{ValueType: ValueTypeMap, MapValue: map[string]*Value{
+			"price": NewValueFromFloat(9.99),
+			"tags":  NewValueFromList([]*Value{NewValueFromString("x")}),
+		}}},
+		{[]interface{}{struct{}{}}, nil},
+		{struct{}{}, nil},
 	}
 
 	for _, entry := range table {
@@ -51,3 +64,59 @@ func TestNewValue(t *testing.T) {
 	}
 
 }
+
+func TestValueGetUnderlyingValueNested(t *testing.T) {
+	value := NewValueFromMap(map[string]*Value{
+		"price":   NewValueFromFloat(9.99),
+		"missing": NewNullValue(),
+		"sizes":   NewValueFromList([]*Value{NewValueFromInt(1), NewValueFromInt(2)}),
+	})
+
+	expected := map[string]interface{}{
+		"price":   9.99,
+		"missing": nil,
+		"sizes":   []interface{}{1, 2},
+	}
+
+	assert.Equal(t, expected, value.GetUnderlyingValue())
+}
+
+func TestValueHash(t *testing.T) {
+	t1 := time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)
+	t2 := t1.In(time.FixedZone("EEST", 3*60*60))
+
+	assert.Equal(t, NewValueFromTime(t1).Hash(), NewValueFromTime(t2).Hash())
+	assert.NotEqual(t, NewValueFromTime(t1).Hash(), NewValueFromTime(t1.Add(time.Second)).Hash())
+
+	m1 := NewValue(map[string]interface{}{"a": 1, "b": []interface{}{1.5, nil}})
+	m2 := NewValue(map[string]interface{}{"b": []interface{}{1.5, nil}, "a": 1})
+	m3 := NewValue(map[string]interface{}{"a": 1, "b": []interface{}{nil, 1.5}})
+
+	assert.Equal(t, m1.Hash(), m2.Hash())
+	assert.NotEqual(t, m1.Hash(), m3.Hash())
+
+	assert.NotEqual(t, NewValueFromInt(1).Hash(), NewValueFromFloat(1).Hash())
+}
+
+func TestValueEncodingE2E(t *testing.T) {
+	value := NewValueFromMap(map[string]*Value{
+		"price":     NewValueFromFloat(9.99),
+		"missing":   NewNullValue(),
+		"scrapedAt": NewValueFromTime(time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)),
+		"sizes":     NewValueFromList([]*Value{NewValueFromInt(1), NewValueFromString("XL")}),
+	})
+
+	raw, err := json.Marshal(value)
+	assert.Nil(t, err)
+
+	gotValue := &Value{}
+	assert.Nil(t, json.Unmarshal(raw, gotValue))
+	assert.Equal(t, value, gotValue)
+
+	rawYAML, err := yaml.Marshal(value)
+	assert.Nil(t, err)
+
+	gotValue = &Value{}
+	assert.Nil(t, yaml.Unmarshal(rawYAML, gotValue))
+	assert.Equal(t, value, gotValue)
+}
@@ -1,27 +1,65 @@
 package spsw
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
+const WorkerHeartbeatInterval = 5 * time.Second
+
+// Worker runs scheduled tasks one at a time. While running, it sends out heartbeat every
+// HeartbeatInterval. Heartbeats are not queued up: if previous one has not been taken
+// yet, new one is dropped. Worker only gets scheduled tasks whose RequiredLabels match its
+// Labels. Worker stops once it gets a signal on Done, but not before finishing the task
+// it's running.
 type Worker struct {
-	UUID             string
-	ScheduledTasksIn chan *ScheduledTask
-	TaskPromisesOut  chan *TaskPromise
-	TaskResultsOut   chan *TaskResult
-	Done             chan interface{}
+	UUID              string
+	Host              string
+	Labels            map[string]string
+	ScheduledTasksIn  chan *ScheduledTask
+	TaskPromisesOut   chan *TaskPromise
+	TaskResultsOut    chan *TaskResult
+	JobControlsIn     chan *JobControl
+	HeartbeatsOut     chan *WorkerHeartbeat
+	HeartbeatInterval time.Duration
+	Done              chan interface{}
+
+	cancelledJobUUIDs jobUUIDSet
+	stopOnce          sync.Once
+	stopped           chan struct{}
+
+	statsMutex           sync.Mutex
+	startedAt            time.Time
+	currentScheduledTask *ScheduledTask
+	nFinishedTasks       int
+	nFailedTasks         int
+	nSkippedTasks        int
 }
 
 func NewWorker() *Worker {
+	host, _ := os.Hostname()
+
 	return &Worker{
-		UUID:             uuid.New().String(),
-		ScheduledTasksIn: make(chan *ScheduledTask),
-		TaskPromisesOut:  make(chan *TaskPromise),
-		TaskResultsOut:   make(chan *TaskResult),
-		Done:             make(chan interface{}),
+		UUID:              uuid.New().String(),
+		Host:              host,
+		Labels:            map[string]string{},
+		ScheduledTasksIn:  make(chan *ScheduledTask),
+		TaskPromisesOut:   make(chan *TaskPromise),
+		TaskResultsOut:    make(chan *TaskResult),
+		JobControlsIn:     make(chan *JobControl),
+		HeartbeatsOut:     make(chan *WorkerHeartbeat, 1),
+		HeartbeatInterval: WorkerHeartbeatInterval,
+		Done:              make(chan interface{}),
+		cancelledJobUUIDs: jobUUIDSet{},
+		stopped:           make(chan struct{}),
+		startedAt:         time.Now(),
 	}
 }
 
@@ -29,37 +67,87 @@ func (w *Worker) String() string {
 	return fmt.Sprintf("<Worker %s>", w.UUID)
 }
 
-func (w *Worker) executeTask(task *Task) error {
-	err := task.Run()
+// TaskPanicError is what task fails with if one of its actions panics. Stack trace is
+// kept in the error, so that it makes it into TaskResult and dead letter.
+type TaskPanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (tpe *TaskPanicError) Error() string {
+	return fmt.Sprintf("Task panicked: %v\n%s", tpe.Value, tpe.Stack)
+}
+
+// runTaskRecovering runs the task, turning panic into TaskPanicError.
+func runTaskRecovering(ctx context.Context, task *Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &TaskPanicError{Value: r, Stack: string(debug.Stack())}
+		}
+	}()
+
+	return task.Run(ctx)
+}
+
+// executeTask runs the task and hands over its result. If timeout is non-zero and task
+// does not finish in time, it is cancelled and reported as failed. Actions that do not
+// heed cancellation are left to finish in the background, but their outcome is ignored.
+func (w *Worker) executeTask(task *Task, timeout time.Duration) error {
+	ctx := context.Background()
+	cancel := func() {}
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- runTaskRecovering(ctx, task)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("Task %s timed out after %v", task.Name, timeout)
+		}
+	case <-ctx.Done():
+		err = fmt.Errorf("Task %s timed out after %v", task.Name, timeout)
+	}
+
 	if err != nil {
 		log.Error(fmt.Sprintf("Task %v failed with error: %v", task, err))
 
 		taskResult := NewTaskResult(task.JobUUID, task.UUID, task.ScheduledTaskUUID, false, err)
+		taskResult.ScheduledTask = task.scheduledTask
 		w.TaskResultsOut <- taskResult
 
 		return err
 	}
 
 	taskResult := NewTaskResult(task.JobUUID, task.UUID, task.ScheduledTaskUUID, true, nil)
+	taskResult.ScheduledTask = task.scheduledTask
 
 	nPromises := 0
 
 	for outputName, outDP := range task.Outputs {
-		if len(outDP.Queue) == 0 {
-			continue
-		}
-
-		x := outDP.Remove()
+		for outDP.Len() > 0 {
+			x := outDP.Remove()
 
-		if item, okItem := x.(*Item); okItem {
-			taskResult.AddOutputItem(outputName, item)
-		}
+			if item, okItem := x.(*Item); okItem {
+				taskResult.AddOutputItem(outputName, item)
+			}
 
-		if promise, okPromise := x.(*TaskPromise); okPromise {
-			w.TaskPromisesOut <- promise
-			nPromises++
+			if promise, okPromise := x.(*TaskPromise); okPromise {
+				w.TaskPromisesOut <- promise
+				nPromises++
 
-			taskResult.AddOutputTaskPromise(outputName, promise)
+				taskResult.AddOutputTaskPromise(outputName, promise)
+			}
 		}
 	}
 
@@ -68,10 +156,116 @@ func (w *Worker) executeTask(task *Task) error {
 	return nil
 }
 
+// Heartbeat returns snapshot of what the worker is doing.
+func (w *Worker) Heartbeat() *WorkerHeartbeat {
+	w.statsMutex.Lock()
+	defer w.statsMutex.Unlock()
+
+	heartbeat := NewWorkerHeartbeat(w.UUID, w.Host)
+
+	heartbeat.Labels = w.Labels
+	heartbeat.StartedAt = w.startedAt
+	heartbeat.Uptime = heartbeat.CreatedAt.Sub(w.startedAt)
+	heartbeat.NFinishedTasks = w.nFinishedTasks
+	heartbeat.NFailedTasks = w.nFailedTasks
+	heartbeat.NSkippedTasks = w.nSkippedTasks
+
+	if w.currentScheduledTask != nil {
+		heartbeat.ScheduledTaskUUID = w.currentScheduledTask.UUID
+		heartbeat.JobUUID = w.currentScheduledTask.JobUUID
+	}
+
+	return heartbeat
+}
+
+func (w *Worker) sendHeartbeat() {
+	select {
+	case w.HeartbeatsOut <- w.Heartbeat():
+	default:
+	}
+}
+
+func (w *Worker) sendHeartbeats(stop chan struct{}) {
+	ticker := time.NewTicker(w.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.sendHeartbeat()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (w *Worker) runScheduledTask(scheduledTask *ScheduledTask) {
+	w.statsMutex.Lock()
+	w.currentScheduledTask = scheduledTask
+	w.statsMutex.Unlock()
+
+	// Heartbeat goes out right away, so that it's known which worker took the task.
+	w.sendHeartbeat()
+
+	task, err := NewTaskFromScheduledTask(scheduledTask)
+	if err != nil {
+		log.Error(fmt.Sprintf("Worker %s failed to create task from scheduled task %s: %v", w.UUID,
+			scheduledTask.UUID, err))
+
+		taskResult := NewTaskResult(scheduledTask.JobUUID, "", scheduledTask.UUID, false, err)
+		taskResult.ScheduledTask = scheduledTask
+		w.TaskResultsOut <- taskResult
+	} else {
+		log.Info(fmt.Sprintf("Worker %s running task %v", w.UUID, task))
+
+		err = w.executeTask(task, scheduledTask.Template.Timeout)
+	}
+
+	w.statsMutex.Lock()
+	w.currentScheduledTask = nil
+
+	if err != nil {
+		w.nFailedTasks++
+	} else {
+		w.nFinishedTasks++
+	}
+
+	w.statsMutex.Unlock()
+}
+
+// skipScheduledTask reports scheduled task of cancelled job as failed without running it,
+// so that it is acknowledged and does not get redelivered.
+func (w *Worker) skipScheduledTask(scheduledTask *ScheduledTask) {
+	log.Info(fmt.Sprintf("Worker %s skipping scheduled task %s of cancelled job %s", w.UUID, scheduledTask.UUID,
+		scheduledTask.JobUUID))
+
+	err := fmt.Errorf("Job %s was cancelled", scheduledTask.JobUUID)
+	w.TaskResultsOut <- NewTaskResult(scheduledTask.JobUUID, "", scheduledTask.UUID, false, err)
+
+	w.statsMutex.Lock()
+	w.nSkippedTasks++
+	w.statsMutex.Unlock()
+}
+
 func (w *Worker) Run() error {
 	log.Info(fmt.Sprintf("Starting runloop for worker %s", w.UUID))
 
+	defer close(w.stopped)
+
+	stopHeartbeats := make(chan struct{})
+	defer close(stopHeartbeats)
+
+	w.sendHeartbeat()
+	go w.sendHeartbeats(stopHeartbeats)
+
 	for {
+		// Not taking on another task if asked to stop in the meantime.
+		select {
+		case <-w.Done:
+			return nil
+		default:
+		}
+
 		select {
 		case scheduledTask := <-w.ScheduledTasksIn:
 			if scheduledTask == nil {
@@ -80,12 +274,32 @@ func (w *Worker) Run() error {
 
 			log.Info(fmt.Sprintf("Worker %s got scheduled task %v", w.UUID, scheduledTask))
 
-			task := NewTaskFromScheduledTask(scheduledTask)
-			log.Info(fmt.Sprintf("Worker %s running task %v", w.UUID, task))
+			if w.cancelledJobUUIDs.contains(scheduledTask.JobUUID) {
+				w.skipScheduledTask(scheduledTask)
+				continue
+			}
 
-			w.executeTask(task)
+			w.runScheduledTask(scheduledTask)
+		case jobControl := <-w.JobControlsIn:
+			// Cancelled jobs are only remembered for JobControlRetention, by then their
+			// tasks should be long gone from the bus.
+			if jobControl != nil && jobControl.Action == JobControlActionCancel && !jobControl.isStale() {
+				w.cancelledJobUUIDs.prune()
+				w.cancelledJobUUIDs.add(jobControl.JobUUID, jobControl.CreatedAt)
+			}
 		case <-w.Done:
 			return nil
 		}
 	}
 }
+
+// Stop tells Worker to stop and waits until task it's running, if any, is finished and
+// its result is handed over. It must only be called while Run is going.
+func (w *Worker) Stop() {
+	w.stopOnce.Do(func() {
+		log.Info(fmt.Sprintf("Stopping worker %s", w.UUID))
+		close(w.Done)
+	})
+
+	<-w.stopped
+}
@@ -1,7 +1,9 @@
 package spsw
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -38,7 +40,309 @@ func TestWorkerExecuteTaskNoError(t *testing.T) {
 
 	worker := NewWorker()
 
-	gotErr := worker.executeTask(testTask)
+	gotErr := worker.executeTask(testTask, 0)
 
 	assert.Nil(t, gotErr)
 }
+
+func TestWorkerSkipsTasksOfCancelledJobs(t *testing.T) {
+	worker := NewWorker()
+
+	go worker.Run()
+	defer func() {
+		worker.Done <- true
+	}()
+
+	jobUUID := "6F1E2D3C-4B5A-4968-8776-A5B4C3D2E1F0"
+
+	worker.JobControlsIn <- NewJobControl(jobUUID, JobControlActionCancel)
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", jobUUID)
+
+	worker.ScheduledTasksIn <- scheduledTask
+
+	taskResult := <-worker.TaskResultsOut
+	assert.Equal(t, jobUUID, taskResult.JobUUID)
+	assert.Equal(t, scheduledTask.UUID, taskResult.ScheduledTaskUUID)
+	assert.False(t, taskResult.Succeeded)
+	assert.Contains(t, taskResult.Error, "cancelled")
+}
+
+func TestWorkerIgnoresStaleJobControls(t *testing.T) {
+	worker := NewWorker()
+
+	go worker.Run()
+	defer worker.Stop()
+
+	jobUUID := "0A1B2C3D-4E5F-4061-8273-94A5B6C7D8E9"
+
+	// Cancel sent long ago (e.g. replayed from the start of the bus) does not stick.
+	jobControl := NewJobControl(jobUUID, JobControlActionCancel)
+	jobControl.CreatedAt = time.Now().Add(-2 * JobControlRetention)
+
+	worker.JobControlsIn <- jobControl
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", jobUUID)
+
+	worker.ScheduledTasksIn <- scheduledTask
+
+	taskResult := <-worker.TaskResultsOut
+	assert.Equal(t, scheduledTask.UUID, taskResult.ScheduledTaskUUID)
+	assert.True(t, taskResult.Succeeded)
+}
+
+func TestWorkerFailsTaskWithBadTemplate(t *testing.T) {
+	worker := NewWorker()
+
+	go worker.Run()
+	defer worker.Stop()
+
+	jobUUID := "2B3C4D5E-6F70-4182-93A4-B5C6D7E8F901"
+
+	taskTempl := NewTaskTemplate("Task1", true)
+	taskTempl.AddActionTemplate(NewActionTemplate("HTTP1", "HTTPAction", map[string]interface{}{
+		"baseUrl": "http://example.com",
+	}))
+
+	promise := NewTaskPromise("Task1", "WF0", jobUUID, map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, taskTempl, "WF0", "v1", jobUUID)
+
+	worker.ScheduledTasksIn <- scheduledTask
+
+	taskResult := <-worker.TaskResultsOut
+	assert.Equal(t, scheduledTask.UUID, taskResult.ScheduledTaskUUID)
+	assert.False(t, taskResult.Succeeded)
+	assert.Contains(t, taskResult.Error, "Unknown constructor param baseUrl")
+}
+
+func TestWorkerSendsHeartbeats(t *testing.T) {
+	worker := NewWorker()
+	worker.Host = "host1"
+	worker.HeartbeatInterval = time.Hour
+
+	go worker.Run()
+	defer func() {
+		worker.Done <- true
+	}()
+
+	heartbeat := <-worker.HeartbeatsOut
+	assert.Equal(t, worker.UUID, heartbeat.WorkerUUID)
+	assert.Equal(t, "host1", heartbeat.Host)
+	assert.Equal(t, "", heartbeat.ScheduledTaskUUID)
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", "job1")
+
+	worker.ScheduledTasksIn <- scheduledTask
+
+	// Heartbeat tells which task the worker has taken.
+	heartbeat = <-worker.HeartbeatsOut
+	assert.Equal(t, scheduledTask.UUID, heartbeat.ScheduledTaskUUID)
+	assert.Equal(t, "job1", heartbeat.JobUUID)
+
+	taskResult := <-worker.TaskResultsOut
+	assert.True(t, taskResult.Succeeded)
+
+	worker.JobControlsIn <- NewJobControl("job1", JobControlActionCancel)
+	worker.ScheduledTasksIn <- NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", "job1")
+	<-worker.TaskResultsOut
+
+	// Worker takes next message only once it's done with the task.
+	worker.JobControlsIn <- NewJobControl("job2", JobControlActionCancel)
+
+	heartbeat = worker.Heartbeat()
+	assert.Equal(t, "", heartbeat.ScheduledTaskUUID)
+	assert.Equal(t, 1, heartbeat.NFinishedTasks)
+	assert.Equal(t, 0, heartbeat.NFailedTasks)
+	assert.Equal(t, 1, heartbeat.NSkippedTasks)
+	assert.True(t, heartbeat.Uptime > 0)
+}
+
+func TestWorkerStopWaitsForRunningTask(t *testing.T) {
+	worker := NewWorker()
+	worker.HeartbeatInterval = time.Hour
+
+	go worker.Run()
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, NewTaskTemplate("Task1", true), "WF0", "v1", "job1")
+
+	worker.ScheduledTasksIn <- scheduledTask
+
+	stopped := make(chan struct{})
+	go func() {
+		worker.Stop()
+		close(stopped)
+	}()
+
+	// Worker is stuck handing over task result, so it cannot be stopped yet.
+	select {
+	case <-stopped:
+		t.Fatal("Worker stopped before handing over task result")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	taskResult := <-worker.TaskResultsOut
+	assert.Equal(t, scheduledTask.UUID, taskResult.ScheduledTaskUUID)
+
+	<-stopped
+
+	// Stopping again is fine.
+	worker.Stop()
+}
+
+const testWorkerActionOutput = "testWorkerActionOutput"
+
+type testWorkerAction struct {
+	AbstractAction
+	run func(ctx context.Context) error
+}
+
+func newTestWorkerAction(run func(ctx context.Context) error) *testWorkerAction {
+	return &testWorkerAction{
+		AbstractAction: AbstractAction{
+			AllowedOutputNames: []string{testWorkerActionOutput},
+			Inputs:             map[string]*DataPipe{},
+			Outputs:            map[string][]*DataPipe{},
+			UUID:               "testWorkerAction",
+		},
+		run: run,
+	}
+}
+
+func (twa *testWorkerAction) Run(ctx context.Context) error {
+	return twa.run(ctx)
+}
+
+func newTestWorkerTask(run func(ctx context.Context) error) *Task {
+	task := NewTask("Task1", "WF0", "job1")
+
+	action := newTestWorkerAction(run)
+
+	task.AddAction(action)
+	task.AddOutput("out", action, testWorkerActionOutput, NewDataPipe())
+
+	return task
+}
+
+func TestWorkerExecuteTaskRecoversPanic(t *testing.T) {
+	worker := NewWorker()
+
+	task := newTestWorkerTask(func(ctx context.Context) error {
+		var m map[string]string
+		m["boom"] = "boom"
+		return nil
+	})
+
+	errs := make(chan error)
+	go func() {
+		errs <- worker.executeTask(task, 0)
+	}()
+
+	taskResult := <-worker.TaskResultsOut
+	assert.False(t, taskResult.Succeeded)
+	assert.Contains(t, taskResult.Error, "Task panicked: assignment to entry in nil map")
+	assert.Contains(t, taskResult.Error, "worker_test.go")
+
+	err := <-errs
+	panicErr, okPanic := err.(*TaskPanicError)
+	assert.True(t, okPanic)
+	assert.NotEqual(t, "", panicErr.Stack)
+}
+
+func TestWorkerExecuteTaskTimeout(t *testing.T) {
+	worker := NewWorker()
+
+	task := newTestWorkerTask(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	errs := make(chan error)
+	go func() {
+		errs <- worker.executeTask(task, 50*time.Millisecond)
+	}()
+
+	taskResult := <-worker.TaskResultsOut
+	assert.False(t, taskResult.Succeeded)
+	assert.Equal(t, "Task Task1 timed out after 50ms", taskResult.Error)
+	assert.NotNil(t, <-errs)
+}
+
+func TestWorkerExecuteTaskTimeoutNotHeeded(t *testing.T) {
+	worker := NewWorker()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// Action ignores cancellation, but worker does not wait for it.
+	task := newTestWorkerTask(func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	errs := make(chan error)
+	go func() {
+		errs <- worker.executeTask(task, 50*time.Millisecond)
+	}()
+
+	taskResult := <-worker.TaskResultsOut
+	assert.False(t, taskResult.Succeeded)
+	assert.Equal(t, "Task Task1 timed out after 50ms", taskResult.Error)
+	assert.NotNil(t, <-errs)
+}
+
+func TestWorkerRunsTaskWithTemplateTimeout(t *testing.T) {
+	worker := NewWorker()
+	worker.HeartbeatInterval = time.Hour
+
+	go worker.Run()
+	defer worker.Stop()
+
+	taskTempl := NewTaskTemplate("Task1", true)
+	taskTempl.Timeout = time.Minute
+
+	promise := NewTaskPromise("Task1", "WF0", "job1", map[string]*DataChunk{})
+	scheduledTask := NewScheduledTask(promise, taskTempl, "WF0", "v1", "job1")
+
+	worker.ScheduledTasksIn <- scheduledTask
+
+	taskResult := <-worker.TaskResultsOut
+	assert.True(t, taskResult.Succeeded)
+	assert.Equal(t, scheduledTask.UUID, taskResult.ScheduledTaskUUID)
+}
+
+func TestWorkerExecuteTaskSendsAllOutputChunks(t *testing.T) {
+	worker := NewWorker()
+
+	task := NewTask("Task1", "WF0", "job1")
+
+	outDP := NewDataPipe()
+
+	action := newTestWorkerAction(func(ctx context.Context) error {
+		for _, name := range []string{"item1", "item2", "item3"} {
+			outDP.AddItem(NewItem(name, "", "", ""))
+		}
+
+		return nil
+	})
+
+	task.AddAction(action)
+	task.AddOutput("items", action, testWorkerActionOutput, outDP)
+
+	errs := make(chan error)
+	go func() {
+		errs <- worker.executeTask(task, 0)
+	}()
+
+	taskResult := <-worker.TaskResultsOut
+	assert.True(t, taskResult.Succeeded)
+	assert.Nil(t, <-errs)
+
+	chunks := taskResult.OutputDataChunks["items"]
+	assert.Equal(t, 3, len(chunks))
+	assert.Equal(t, "item1", chunks[0].PayloadItem.Name)
+	assert.Equal(t, "item3", chunks[2].PayloadItem.Name)
+}
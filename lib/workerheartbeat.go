@@ -0,0 +1,69 @@
+package spsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkerHeartbeat is periodically sent by Worker to tell it's alive and what it's doing.
+// Only the latest heartbeat of each worker is kept on the bus. Empty ScheduledTaskUUID
+// means the worker is idle.
+type WorkerHeartbeat struct {
+	UUID              string
+	WorkerUUID        string
+	Host              string
+	Labels            map[string]string `json:",omitempty"`
+	ScheduledTaskUUID string
+	JobUUID           string
+	StartedAt         time.Time
+	Uptime            time.Duration
+	NFinishedTasks    int
+	NFailedTasks      int
+	NSkippedTasks     int
+	CreatedAt         time.Time
+}
+
+func NewWorkerHeartbeat(workerUUID string, host string) *WorkerHeartbeat {
+	return &WorkerHeartbeat{
+		UUID:       uuid.New().String(),
+		WorkerUUID: workerUUID,
+		Host:       host,
+		CreatedAt:  time.Now(),
+	}
+}
+
+func NewWorkerHeartbeatFromJSON(raw []byte) *WorkerHeartbeat {
+	heartbeat := &WorkerHeartbeat{}
+
+	buffer := bytes.NewBuffer(raw)
+	decoder := json.NewDecoder(buffer)
+
+	err := decoder.Decode(heartbeat)
+	if err != nil {
+		return nil
+	}
+
+	return heartbeat
+}
+
+func (wh *WorkerHeartbeat) String() string {
+	return fmt.Sprintf("<WorkerHeartbeat %s WorkerUUID: %s, Host: %s, ScheduledTaskUUID: %s, Uptime: %v, "+
+		"NFinishedTasks: %d, NFailedTasks: %d>", wh.UUID, wh.WorkerUUID, wh.Host, wh.ScheduledTaskUUID, wh.Uptime,
+		wh.NFinishedTasks, wh.NFailedTasks)
+}
+
+func (wh *WorkerHeartbeat) EncodeToJSON() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buffer)
+
+	encoder.Encode(wh)
+
+	bytes, _ := ioutil.ReadAll(buffer)
+
+	return bytes
+}
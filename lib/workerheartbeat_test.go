@@ -0,0 +1,43 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWorkerHeartbeat(t *testing.T) {
+	heartbeat := NewWorkerHeartbeat("worker1", "host1")
+
+	assert.NotNil(t, heartbeat)
+	assert.Equal(t, 36, len(heartbeat.UUID))
+	assert.Equal(t, "worker1", heartbeat.WorkerUUID)
+	assert.Equal(t, "host1", heartbeat.Host)
+	assert.Equal(t, "", heartbeat.ScheduledTaskUUID)
+	assert.False(t, heartbeat.CreatedAt.IsZero())
+}
+
+func TestWorkerHeartbeatJSONAndBack(t *testing.T) {
+	heartbeat := NewWorkerHeartbeat("worker1", "host1")
+	heartbeat.ScheduledTaskUUID = "task1"
+	heartbeat.JobUUID = "job1"
+	heartbeat.Uptime = time.Minute
+	heartbeat.NFinishedTasks = 3
+	heartbeat.NFailedTasks = 1
+	heartbeat.Labels = map[string]string{"region": "eu"}
+
+	gotHeartbeat := NewWorkerHeartbeatFromJSON(heartbeat.EncodeToJSON())
+
+	assert.NotNil(t, gotHeartbeat)
+	assert.Equal(t, heartbeat.UUID, gotHeartbeat.UUID)
+	assert.Equal(t, "worker1", gotHeartbeat.WorkerUUID)
+	assert.Equal(t, "task1", gotHeartbeat.ScheduledTaskUUID)
+	assert.Equal(t, "job1", gotHeartbeat.JobUUID)
+	assert.Equal(t, time.Minute, gotHeartbeat.Uptime)
+	assert.Equal(t, 3, gotHeartbeat.NFinishedTasks)
+	assert.Equal(t, 1, gotHeartbeat.NFailedTasks)
+	assert.Equal(t, map[string]string{"region": "eu"}, gotHeartbeat.Labels)
+
+	assert.Nil(t, NewWorkerHeartbeatFromJSON([]byte("{")))
+}
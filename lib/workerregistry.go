@@ -0,0 +1,131 @@
+package spsw
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Worker is considered gone if no new heartbeat has been seen from it for this long.
+const WorkerRegistryDefaultStaleAfter = 6 * WorkerHeartbeatInterval
+
+// WorkerStatus is the latest heartbeat of a worker along with when it was received.
+type WorkerStatus struct {
+	Heartbeat  *WorkerHeartbeat
+	LastSeenAt time.Time
+	Stale      bool
+}
+
+type workerRegistryEntry struct {
+	heartbeat  *WorkerHeartbeat
+	lastSeenAt time.Time
+	staleNoted bool
+}
+
+// WorkerRegistry keeps track of workers by their heartbeats. Heartbeats are timed by when
+// registry received them, so that clocks of worker hosts don't matter. Same heartbeat
+// may be received more than once.
+type WorkerRegistry struct {
+	UUID       string
+	StaleAfter time.Duration
+
+	mutex   sync.Mutex
+	workers map[string]*workerRegistryEntry
+}
+
+func NewWorkerRegistry() *WorkerRegistry {
+	return &WorkerRegistry{
+		UUID:       uuid.New().String(),
+		StaleAfter: WorkerRegistryDefaultStaleAfter,
+		workers:    map[string]*workerRegistryEntry{},
+	}
+}
+
+func (wr *WorkerRegistry) String() string {
+	return fmt.Sprintf("<WorkerRegistry %s StaleAfter: %v>", wr.UUID, wr.StaleAfter)
+}
+
+func (wr *WorkerRegistry) Update(heartbeat *WorkerHeartbeat) {
+	wr.updateAt(heartbeat, time.Now())
+}
+
+func (wr *WorkerRegistry) updateAt(heartbeat *WorkerHeartbeat, now time.Time) {
+	if heartbeat == nil {
+		return
+	}
+
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	entry, found := wr.workers[heartbeat.WorkerUUID]
+	if found && entry.heartbeat.UUID == heartbeat.UUID {
+		return
+	}
+
+	wr.workers[heartbeat.WorkerUUID] = &workerRegistryEntry{
+		heartbeat:  heartbeat,
+		lastSeenAt: now,
+	}
+}
+
+func (wr *WorkerRegistry) isStale(entry *workerRegistryEntry, now time.Time) bool {
+	return now.Sub(entry.lastSeenAt) >= wr.StaleAfter
+}
+
+// ListWorkers returns status of every worker that has been seen, by host and worker UUID.
+func (wr *WorkerRegistry) ListWorkers() []*WorkerStatus {
+	return wr.listWorkersAt(time.Now())
+}
+
+func (wr *WorkerRegistry) listWorkersAt(now time.Time) []*WorkerStatus {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	statuses := []*WorkerStatus{}
+
+	for _, entry := range wr.workers {
+		statuses = append(statuses, &WorkerStatus{
+			Heartbeat:  entry.heartbeat,
+			LastSeenAt: entry.lastSeenAt,
+			Stale:      wr.isStale(entry, now),
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Heartbeat.Host != statuses[j].Heartbeat.Host {
+			return statuses[i].Heartbeat.Host < statuses[j].Heartbeat.Host
+		}
+
+		return statuses[i].Heartbeat.WorkerUUID < statuses[j].Heartbeat.WorkerUUID
+	})
+
+	return statuses
+}
+
+// TakeNewlyStaleWorkers returns the last heartbeats of workers that have gone stale since
+// previous call. Worker that comes back with a new heartbeat may be returned again
+// should it go stale once more.
+func (wr *WorkerRegistry) TakeNewlyStaleWorkers() []*WorkerHeartbeat {
+	return wr.takeNewlyStaleWorkersAt(time.Now())
+}
+
+func (wr *WorkerRegistry) takeNewlyStaleWorkersAt(now time.Time) []*WorkerHeartbeat {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	heartbeats := []*WorkerHeartbeat{}
+
+	for _, entry := range wr.workers {
+		if entry.staleNoted || !wr.isStale(entry, now) {
+			continue
+		}
+
+		entry.staleNoted = true
+		heartbeats = append(heartbeats, entry.heartbeat)
+	}
+
+	return heartbeats
+}
@@ -0,0 +1,70 @@
+package spsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWorkerRegistry(t *testing.T) {
+	registry := NewWorkerRegistry()
+
+	assert.NotNil(t, registry)
+	assert.Equal(t, 36, len(registry.UUID))
+	assert.Equal(t, WorkerRegistryDefaultStaleAfter, registry.StaleAfter)
+	assert.Equal(t, 0, len(registry.ListWorkers()))
+}
+
+func TestWorkerRegistryListWorkers(t *testing.T) {
+	registry := NewWorkerRegistry()
+	registry.StaleAfter = time.Minute
+
+	now := time.Now()
+
+	registry.updateAt(NewWorkerHeartbeat("worker2", "hostB"), now)
+	registry.updateAt(NewWorkerHeartbeat("worker1", "hostB"), now)
+	registry.updateAt(NewWorkerHeartbeat("worker3", "hostA"), now.Add(time.Minute))
+	registry.updateAt(nil, now)
+
+	statuses := registry.listWorkersAt(now.Add(90 * time.Second))
+	assert.Equal(t, 3, len(statuses))
+
+	assert.Equal(t, "worker3", statuses[0].Heartbeat.WorkerUUID)
+	assert.False(t, statuses[0].Stale)
+	assert.Equal(t, "worker1", statuses[1].Heartbeat.WorkerUUID)
+	assert.True(t, statuses[1].Stale)
+	assert.Equal(t, "worker2", statuses[2].Heartbeat.WorkerUUID)
+	assert.True(t, statuses[2].Stale)
+}
+
+func TestWorkerRegistryTakeNewlyStaleWorkers(t *testing.T) {
+	registry := NewWorkerRegistry()
+	registry.StaleAfter = time.Minute
+
+	now := time.Now()
+
+	heartbeat := NewWorkerHeartbeat("worker1", "host1")
+	heartbeat.ScheduledTaskUUID = "task1"
+
+	registry.updateAt(heartbeat, now)
+	registry.updateAt(NewWorkerHeartbeat("worker2", "host1"), now)
+
+	assert.Equal(t, 0, len(registry.takeNewlyStaleWorkersAt(now.Add(30*time.Second))))
+
+	// Same heartbeat seen again does not count as a sign of life.
+	registry.updateAt(heartbeat, now.Add(30*time.Second))
+	registry.updateAt(NewWorkerHeartbeat("worker2", "host1"), now.Add(30*time.Second))
+
+	staleHeartbeats := registry.takeNewlyStaleWorkersAt(now.Add(time.Minute))
+	assert.Equal(t, 1, len(staleHeartbeats))
+	assert.Equal(t, "task1", staleHeartbeats[0].ScheduledTaskUUID)
+
+	// Stale worker is reported once, until it comes back and goes stale again.
+	assert.Equal(t, 0, len(registry.takeNewlyStaleWorkersAt(now.Add(time.Minute))))
+
+	registry.updateAt(NewWorkerHeartbeat("worker1", "host1"), now.Add(2*time.Minute))
+
+	staleHeartbeats = registry.takeNewlyStaleWorkersAt(now.Add(4 * time.Minute))
+	assert.Equal(t, 2, len(staleHeartbeats))
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v3"
 )
@@ -55,6 +57,11 @@ type TaskTemplate struct {
 	Initial           bool               `yaml:"Initial"`
 	ActionTemplates   []ActionTemplate   `yaml:"ActionTemplates"`
 	DataPipeTemplates []DataPipeTemplate `yaml:"DataPipeTemplates"`
+	RetryPolicy       *RetryPolicy       `yaml:"RetryPolicy,omitempty"`
+	Priority          int                `yaml:"Priority,omitempty"`
+	RequiredLabels    map[string]string  `yaml:"RequiredLabels,omitempty"`
+	Timeout           time.Duration      `yaml:"Timeout,omitempty"`
+	MaxConcurrency    int                `yaml:"MaxConcurrency,omitempty"`
 }
 
 func NewTaskTemplate(taskName string, initial bool) *TaskTemplate {
@@ -203,6 +210,8 @@ type Workflow struct {
 	Name          string         `yaml:"Name"`
 	Version       string         `yaml:"Version"`
 	TaskTemplates []TaskTemplate `yaml:"TaskTemplates"`
+	HostPolicies  []HostPolicy   `yaml:"HostPolicies,omitempty"`
+	Limits        *JobLimits     `yaml:"Limits,omitempty"`
 }
 
 func NewWorkflow(name string, version string) *Workflow {
@@ -311,11 +320,27 @@ func (w *Workflow) validateActionStructNames() error {
 	return nil
 }
 
+func (w *Workflow) validateConstructorParams() error {
+	for _, tt := range w.TaskTemplates {
+		for _, actionTempl := range tt.ActionTemplates {
+			err := ValidateConstructorParams(&actionTempl)
+			if err != nil {
+				return fmt.Errorf("Action %s of task %s: %v", actionTempl.Name, tt.TaskName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (w *Workflow) validateActionConnectedness() error {
 	// XXX: We're instantiating Task because we don't know upfront what allowed inputs/outputs will be for each action
 	// Perhaps there'a better way. We could make global tables for allowed input/output names.
 	for _, tt := range w.TaskTemplates {
-		task := NewTaskFromTemplate(&tt, "", "")
+		task, err := NewTaskFromTemplate(&tt, "", "")
+		if err != nil {
+			return err
+		}
 
 		sortedActions := task.sortActionsTopologically()
 
@@ -329,7 +354,10 @@ func (w *Workflow) validateActionConnectedness() error {
 
 func (w *Workflow) validateDataPipeConnectedness() error {
 	for _, tt := range w.TaskTemplates {
-		task := NewTaskFromTemplate(&tt, "", "")
+		task, err := NewTaskFromTemplate(&tt, "", "")
+		if err != nil {
+			return err
+		}
 
 		for _, dp := range task.DataPipes {
 			hasFromAction := (dp.FromAction != nil)
@@ -437,6 +465,176 @@ func (w *Workflow) validateInputOutputNames() error {
 	return nil
 }
 
+// outputValueTypes returns ValueTypes that given output of action may send out, or nil
+// if it's not known.
+func outputValueTypes(actionTempl *ActionTemplate, outputName string) []string {
+	if actionTempl.StructName == "ConstAction" {
+		c, found := actionTempl.ConstructorParams["c"]
+		if !found {
+			return nil
+		}
+
+		return []string{c.ValueType}
+	}
+
+	return OutputValueTypeTable[actionTempl.StructName][outputName]
+}
+
+func valueTypesOverlap(valueTypes1 []string, valueTypes2 []string) bool {
+	for _, valueType1 := range valueTypes1 {
+		for _, valueType2 := range valueTypes2 {
+			if valueType1 == valueType2 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateDataPipeTypes checks that each data pipe between two actions connects output to
+// input that takes at least some of what it sends out. Ports of unknown type are not checked.
+func (w *Workflow) validateDataPipeTypes() error {
+	for _, tt := range w.TaskTemplates {
+		actionTemplByName := map[string]*ActionTemplate{}
+
+		for i := range tt.ActionTemplates {
+			actionTemplByName[tt.ActionTemplates[i].Name] = &tt.ActionTemplates[i]
+		}
+
+		for _, dpt := range tt.DataPipeTemplates {
+			sourceActionTempl := actionTemplByName[dpt.SourceActionName]
+			destActionTempl := actionTemplByName[dpt.DestActionName]
+
+			if sourceActionTempl == nil || destActionTempl == nil {
+				continue
+			}
+
+			sourceTypes := outputValueTypes(sourceActionTempl, dpt.SourceOutputName)
+			destTypes := InputValueTypeTable[destActionTempl.StructName][dpt.DestInputName]
+
+			if len(sourceTypes) == 0 || len(destTypes) == 0 {
+				continue
+			}
+
+			if !valueTypesOverlap(sourceTypes, destTypes) {
+				return fmt.Errorf("Type mismatch in task %s: output %s of action %s sends out %s, but input %s of action %s takes %s",
+					tt.TaskName, dpt.SourceOutputName, dpt.SourceActionName, strings.Join(sourceTypes, "/"),
+					dpt.DestInputName, dpt.DestActionName, strings.Join(destTypes, "/"))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) validateRetryPolicies() error {
+	for _, tt := range w.TaskTemplates {
+		if tt.RetryPolicy == nil {
+			continue
+		}
+
+		err := tt.RetryPolicy.Validate()
+		if err != nil {
+			return fmt.Errorf("Bad RetryPolicy for task %s: %v", tt.TaskName, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) validateTaskPriorities() error {
+	for _, tt := range w.TaskTemplates {
+		if !IsValidTaskPriority(tt.Priority) {
+			return fmt.Errorf("Priority of task %s must be between %d and %d, got %d", tt.TaskName,
+				TaskPriorityLowest, TaskPriorityHighest, tt.Priority)
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) validateRequiredLabels() error {
+	for _, tt := range w.TaskTemplates {
+		err := ValidateLabels(tt.RequiredLabels)
+		if err != nil {
+			return fmt.Errorf("Bad RequiredLabels for task %s: %v", tt.TaskName, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) validateTaskTimeouts() error {
+	for _, tt := range w.TaskTemplates {
+		if tt.Timeout < 0 {
+			return fmt.Errorf("Timeout of task %s must not be negative, got %v", tt.TaskName, tt.Timeout)
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) validateTaskConcurrency() error {
+	for _, tt := range w.TaskTemplates {
+		if tt.MaxConcurrency < 0 {
+			return fmt.Errorf("MaxConcurrency of task %s must not be negative, got %d", tt.TaskName,
+				tt.MaxConcurrency)
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) validateLimits() error {
+	if w.Limits == nil {
+		return nil
+	}
+
+	err := w.Limits.Validate()
+	if err != nil {
+		return fmt.Errorf("Bad Limits: %v", err)
+	}
+
+	return nil
+}
+
+// GetLimits returns job limits of the workflow, which are all zero (i.e. no limits) if
+// workflow does not set any.
+func (w *Workflow) GetLimits() *JobLimits {
+	if w.Limits == nil {
+		return NewJobLimits()
+	}
+
+	return w.Limits
+}
+
+func (w *Workflow) validateHostPolicies() error {
+	seenHosts := map[string]bool{}
+
+	for _, hp := range w.HostPolicies {
+		err := hp.Validate()
+		if err != nil {
+			return fmt.Errorf("Bad HostPolicy for host %s: %v", hp.Host, err)
+		}
+
+		host := strings.ToLower(hp.Host)
+		if seenHosts[host] {
+			return fmt.Errorf("More than one HostPolicy for host %s", hp.Host)
+		}
+
+		seenHosts[host] = true
+	}
+
+	return nil
+}
+
+// FindHostPolicy returns policy for given host, falling back to policy for any host.
+// Returns nil if there is neither.
+func (w *Workflow) FindHostPolicy(host string) *HostPolicy {
+	return findHostPolicy(w.HostPolicies, host)
+}
+
 func (w *Workflow) GetInitialTaskTemplate() *TaskTemplate {
 	var initialTaskTempl *TaskTemplate
 	initialTaskTempl = nil
@@ -473,6 +671,16 @@ func (w *Workflow) Validate() (bool, error) {
 		return false, err
 	}
 
+	err = w.validateConstructorParams()
+	if err != nil {
+		return false, err
+	}
+
+	err = w.validateDataPipeTypes()
+	if err != nil {
+		return false, err
+	}
+
 	err = w.validateActionConnectedness()
 	if err != nil {
 		return false, err
@@ -483,5 +691,40 @@ func (w *Workflow) Validate() (bool, error) {
 		return false, err
 	}
 
+	err = w.validateRetryPolicies()
+	if err != nil {
+		return false, err
+	}
+
+	err = w.validateHostPolicies()
+	if err != nil {
+		return false, err
+	}
+
+	err = w.validateTaskPriorities()
+	if err != nil {
+		return false, err
+	}
+
+	err = w.validateRequiredLabels()
+	if err != nil {
+		return false, err
+	}
+
+	err = w.validateTaskTimeouts()
+	if err != nil {
+		return false, err
+	}
+
+	err = w.validateTaskConcurrency()
+	if err != nil {
+		return false, err
+	}
+
+	err = w.validateLimits()
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
@@ -3,6 +3,7 @@ package spsw
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -269,6 +270,109 @@ func TestWorkflowValidateActionStructNames(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestWorkflowValidateConstructorParams(t *testing.T) {
+	workflow := &Workflow{
+		Name:    "testWorkflow",
+		Version: "v0.0.0.0.1",
+		TaskTemplates: []TaskTemplate{
+			TaskTemplate{
+				TaskName: "GetHTML",
+				Initial:  true,
+				ActionTemplates: []ActionTemplate{
+					*NewActionTemplate("HTTP1", "HTTPAction", map[string]interface{}{
+						"baseUrl": "http://example.com",
+					}),
+				},
+			},
+		},
+	}
+
+	err := workflow.validateConstructorParams()
+	assert.NotNil(t, err)
+	assert.Equal(t, "Action HTTP1 of task GetHTML: Unknown constructor param baseUrl for HTTPAction", err.Error())
+
+	ok, err2 := workflow.Validate()
+	assert.False(t, ok)
+	assert.Equal(t, err, err2)
+
+	workflow.TaskTemplates[0].ActionTemplates[0] = *NewActionTemplate("HTTP1", "HTTPAction", map[string]interface{}{
+		"baseURL": "http://example.com",
+	})
+
+	err = workflow.validateConstructorParams()
+	assert.Nil(t, err)
+}
+
+func TestWorkflowValidateDataPipeTypes(t *testing.T) {
+	workflow := &Workflow{
+		Name:    "testWorkflow",
+		Version: "v0.0.0.0.1",
+		TaskTemplates: []TaskTemplate{
+			TaskTemplate{
+				TaskName: "GetHTML",
+				Initial:  true,
+				ActionTemplates: []ActionTemplate{
+					ActionTemplate{
+						Name:       "HTTP1",
+						StructName: "HTTPAction",
+					},
+					ActionTemplate{
+						Name:       "XPath1",
+						StructName: "XPathAction",
+						ConstructorParams: map[string]Value{
+							"xpath": Value{ValueType: ValueTypeString, StringValue: "//title"},
+						},
+					},
+				},
+				DataPipeTemplates: []DataPipeTemplate{
+					DataPipeTemplate{
+						SourceActionName: "HTTP1",
+						SourceOutputName: HTTPActionOutputStatusCode,
+						DestActionName:   "XPath1",
+						DestInputName:    XPathActionInputHTMLStr,
+					},
+				},
+			},
+		},
+	}
+
+	err := workflow.validateDataPipeTypes()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "GetHTML")
+	assert.Contains(t, err.Error(), "HTTP1")
+	assert.Contains(t, err.Error(), HTTPActionOutputStatusCode)
+	assert.Contains(t, err.Error(), "XPath1")
+	assert.Contains(t, err.Error(), XPathActionInputHTMLStr)
+
+	ok, err2 := workflow.Validate()
+	assert.False(t, ok)
+	assert.Equal(t, err, err2)
+
+	workflow.TaskTemplates[0].DataPipeTemplates[0].SourceOutputName = HTTPActionOutputBody
+	workflow.TaskTemplates[0].DataPipeTemplates[0].DestInputName = XPathActionInputHTMLBytes
+
+	err = workflow.validateDataPipeTypes()
+	assert.Nil(t, err)
+
+	// ConstAction sends out value of type given in the template.
+	workflow.TaskTemplates[0].ActionTemplates[0] = ActionTemplate{
+		Name:       "HTTP1",
+		StructName: "ConstAction",
+		ConstructorParams: map[string]Value{
+			"c": Value{ValueType: ValueTypeString, StringValue: "<html></html>"},
+		},
+	}
+	workflow.TaskTemplates[0].DataPipeTemplates[0].SourceOutputName = ConstActionOutput
+
+	err = workflow.validateDataPipeTypes()
+	assert.NotNil(t, err)
+
+	workflow.TaskTemplates[0].DataPipeTemplates[0].DestInputName = XPathActionInputHTMLStr
+
+	err = workflow.validateDataPipeTypes()
+	assert.Nil(t, err)
+}
+
 func TestWorkflowValidateActionConnectedness(t *testing.T) {
 	workflow := &Workflow{
 		Name:    "testWorkflow",
@@ -649,3 +753,152 @@ func TestDisconnectOutput(t *testing.T) {
 	err = tt.DisconnectOutput("out4")
 	assert.Equal(t, errors.New("Not found"), err)
 }
+
+func TestWorkflowValidateRetryPolicies(t *testing.T) {
+	taskTempl := NewTaskTemplate("GetHTML", true)
+
+	workflow := &Workflow{
+		Name:          "testWorkflow1",
+		Version:       "v0.0.0.0.1",
+		TaskTemplates: []TaskTemplate{*taskTempl},
+	}
+
+	assert.Nil(t, workflow.validateRetryPolicies())
+
+	workflow.TaskTemplates[0].RetryPolicy = NewRetryPolicy(3, time.Second)
+	assert.Nil(t, workflow.validateRetryPolicies())
+
+	workflow.TaskTemplates[0].RetryPolicy = NewRetryPolicy(0, time.Second)
+	assert.NotNil(t, workflow.validateRetryPolicies())
+}
+
+func TestWorkflowValidateHostPolicies(t *testing.T) {
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+
+	assert.Nil(t, workflow.validateHostPolicies())
+
+	workflow.HostPolicies = []HostPolicy{
+		*NewHostPolicy("books.toscrape.com", 1.0, 2, 0),
+		*NewHostPolicy(HostPolicyAnyHost, 5.0, 0, 0),
+	}
+	assert.Nil(t, workflow.validateHostPolicies())
+
+	workflow.HostPolicies = append(workflow.HostPolicies, *NewHostPolicy("Books.ToScrape.com", 2.0, 0, 0))
+	assert.NotNil(t, workflow.validateHostPolicies())
+
+	workflow.HostPolicies = []HostPolicy{*NewHostPolicy("books.toscrape.com", -1.0, 0, 0)}
+	assert.NotNil(t, workflow.validateHostPolicies())
+}
+
+func TestWorkflowFindHostPolicy(t *testing.T) {
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+
+	assert.Nil(t, workflow.FindHostPolicy("books.toscrape.com"))
+
+	workflow.HostPolicies = []HostPolicy{*NewHostPolicy("books.toscrape.com", 1.0, 2, 0)}
+
+	assert.Equal(t, &workflow.HostPolicies[0], workflow.FindHostPolicy("books.toscrape.com"))
+	assert.Nil(t, workflow.FindHostPolicy("quotes.toscrape.com"))
+}
+
+func TestWorkflowHostPoliciesYAMLAndBack(t *testing.T) {
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+	workflow.HostPolicies = []HostPolicy{*NewHostPolicy("books.toscrape.com", 0.5, 2, time.Second)}
+
+	yamlStr := workflow.ToYAML()
+
+	assert.Contains(t, yamlStr, "HostPolicies:")
+
+	gotWorkflow := NewWorkflowFromYAML(yamlStr)
+
+	assert.Equal(t, workflow.HostPolicies, gotWorkflow.HostPolicies)
+}
+
+func TestWorkflowValidateTaskPriorities(t *testing.T) {
+	taskTempl := NewTaskTemplate("GetHTML", true)
+
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+	workflow.AddTaskTemplate(taskTempl)
+
+	assert.Nil(t, workflow.validateTaskPriorities())
+
+	workflow.TaskTemplates[0].Priority = TaskPriorityHighest
+	assert.Nil(t, workflow.validateTaskPriorities())
+
+	workflow.TaskTemplates[0].Priority = TaskPriorityHighest + 1
+	assert.NotNil(t, workflow.validateTaskPriorities())
+
+	workflow.TaskTemplates[0].Priority = -1
+	assert.NotNil(t, workflow.validateTaskPriorities())
+}
+
+func TestWorkflowLimits(t *testing.T) {
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+
+	assert.Nil(t, workflow.validateLimits())
+	assert.Equal(t, NewJobLimits(), workflow.GetLimits())
+
+	workflow.Limits = &JobLimits{MaxDepth: 3}
+	assert.Nil(t, workflow.validateLimits())
+	assert.Equal(t, workflow.Limits, workflow.GetLimits())
+
+	gotWorkflow := NewWorkflowFromYAML(workflow.ToYAML())
+	assert.Equal(t, workflow.Limits, gotWorkflow.Limits)
+
+	workflow.Limits.MaxItems = -1
+	assert.NotNil(t, workflow.validateLimits())
+}
+
+func TestWorkflowValidateRequiredLabels(t *testing.T) {
+	taskTempl := NewTaskTemplate("GetHTML", true)
+
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+	workflow.AddTaskTemplate(taskTempl)
+
+	assert.Nil(t, workflow.validateRequiredLabels())
+
+	workflow.TaskTemplates[0].RequiredLabels = map[string]string{"region": "eu"}
+	assert.Nil(t, workflow.validateRequiredLabels())
+
+	gotWorkflow := NewWorkflowFromYAML(workflow.ToYAML())
+	assert.Equal(t, workflow.TaskTemplates[0].RequiredLabels, gotWorkflow.TaskTemplates[0].RequiredLabels)
+
+	workflow.TaskTemplates[0].RequiredLabels = map[string]string{"region": "eu,us"}
+	assert.NotNil(t, workflow.validateRequiredLabels())
+}
+
+func TestWorkflowValidateTaskTimeouts(t *testing.T) {
+	taskTempl := NewTaskTemplate("GetHTML", true)
+
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+	workflow.AddTaskTemplate(taskTempl)
+
+	assert.Nil(t, workflow.validateTaskTimeouts())
+
+	workflow.TaskTemplates[0].Timeout = 30 * time.Second
+	assert.Nil(t, workflow.validateTaskTimeouts())
+
+	gotWorkflow := NewWorkflowFromYAML(workflow.ToYAML())
+	assert.Equal(t, 30*time.Second, gotWorkflow.TaskTemplates[0].Timeout)
+
+	workflow.TaskTemplates[0].Timeout = -time.Second
+	assert.NotNil(t, workflow.validateTaskTimeouts())
+}
+
+func TestWorkflowValidateTaskConcurrency(t *testing.T) {
+	taskTempl := NewTaskTemplate("GetHTML", true)
+
+	workflow := NewWorkflow("testWorkflow1", "v0.0.0.0.1")
+	workflow.AddTaskTemplate(taskTempl)
+
+	assert.Nil(t, workflow.validateTaskConcurrency())
+
+	workflow.TaskTemplates[0].MaxConcurrency = 4
+	assert.Nil(t, workflow.validateTaskConcurrency())
+
+	gotWorkflow := NewWorkflowFromYAML(workflow.ToYAML())
+	assert.Equal(t, 4, gotWorkflow.TaskTemplates[0].MaxConcurrency)
+
+	workflow.TaskTemplates[0].MaxConcurrency = -1
+	assert.NotNil(t, workflow.validateTaskConcurrency())
+}
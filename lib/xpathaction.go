@@ -2,6 +2,7 @@ package spsw
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"golang.org/x/net/html" // XXX
@@ -16,10 +17,14 @@ const XPathActionInputHTMLStr = "XPathActionInputHTMLStr"
 const XPathActionInputHTMLBytes = "XPathActionInputHTMLBytes"
 const XPathActionOutputStr = "XPathActionOutputStr"
 
+// XPathAction extracts what XPath matches in HTML. If ExpectMany is set, all the matches
+// are sent out as a single []string chunk, or one chunk per match if StreamResults is set
+// too, so that actions downstream run once for each of them.
 type XPathAction struct {
 	AbstractAction
 	XPath           string
 	StripWhitespace bool
+	StreamResults   bool
 }
 
 func NewXPathAction(xpath string, expectMany bool) *XPathAction {
@@ -45,22 +50,22 @@ func NewXPathAction(xpath string, expectMany bool) *XPathAction {
 	}
 }
 
-func NewXPathActionFromTemplate(actionTempl *ActionTemplate) Action {
-	var xpath string
-	var expectMany bool
+func NewXPathActionFromTemplate(actionTempl *ActionTemplate) (Action, error) {
+	params, err := constructorParamsWithDefaults("XPathAction", actionTempl)
+	if err != nil {
+		return nil, err
+	}
 
-	xpath = actionTempl.ConstructorParams["xpath"].StringValue
-	expectMany = actionTempl.ConstructorParams["expectMany"].BoolValue
+	xpath := params["xpath"].StringValue
+	expectMany := params["expectMany"].BoolValue
 
 	action := NewXPathAction(xpath, expectMany)
 
 	action.Name = actionTempl.Name
+	action.StripWhitespace = params["stripWhitespace"].BoolValue
+	action.StreamResults = params["streamResults"].BoolValue
 
-	if _, ok := actionTempl.ConstructorParams["stripWhitespace"]; ok {
-		action.StripWhitespace = actionTempl.ConstructorParams["stripWhitespace"].BoolValue
-	}
-
-	return action
+	return action, nil
 }
 
 // https://stackoverflow.com/a/38855264
@@ -87,7 +92,7 @@ func (xa *XPathAction) String() string {
 	return fmt.Sprintf("<XPathAction %s Name: %s, XPath: %s>", xa.UUID, xa.Name, xa.XPath)
 }
 
-func (xa *XPathAction) Run() error {
+func (xa *XPathAction) Run(ctx context.Context) error {
 	if xa.Inputs[XPathActionInputHTMLStr] == nil && xa.Inputs[XPathActionInputHTMLBytes] == nil {
 		return errors.New("Input not connected")
 	}
@@ -172,7 +177,16 @@ func (xa *XPathAction) Run() error {
 		}
 
 		for _, outDP := range xa.Outputs[XPathActionOutputStr] {
-			outDP.Add(results)
+			if !xa.StreamResults {
+				outDP.Add(results)
+				continue
+			}
+
+			outDP.MarkStreamed()
+
+			for _, result := range results {
+				outDP.Add(result)
+			}
 		}
 	}
 
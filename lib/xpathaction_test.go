@@ -1,6 +1,7 @@
 package spsw
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -26,7 +27,10 @@ func TestNewXPathActionFromTemplate(t *testing.T) {
 		ConstructorParams: constructorParams,
 	}
 
-	action, ok := NewXPathActionFromTemplate(actionTempl).(*XPathAction)
+	gotAction, err := NewXPathActionFromTemplate(actionTempl)
+	assert.Nil(t, err)
+
+	action, ok := gotAction.(*XPathAction)
 	assert.True(t, ok)
 
 	assert.NotNil(t, action)
@@ -47,7 +51,7 @@ func TestXPathActionRunBasic(t *testing.T) {
 	xpathAction.AddInput(XPathActionInputHTMLStr, dataPipeIn)
 	xpathAction.AddOutput(XPathActionOutputStr, dataPipeOut)
 
-	err := xpathAction.Run()
+	err := xpathAction.Run(context.Background())
 	assert.Nil(t, err)
 
 	resultStr, ok := dataPipeOut.Remove().(string)
@@ -69,7 +73,7 @@ func TestXPathActionRunMultipleResults(t *testing.T) {
 	xpathAction.AddInput(XPathActionInputHTMLStr, dataPipeIn)
 	xpathAction.AddOutput(XPathActionOutputStr, dataPipeOut)
 
-	err := xpathAction.Run()
+	err := xpathAction.Run(context.Background())
 	assert.Nil(t, err)
 
 	resultValue, ok := dataPipeOut.Remove().([]string)
@@ -94,7 +98,7 @@ func TestXPathActionBadInput(t *testing.T) {
 	xpathAction.AddInput(XPathActionInputHTMLStr, dataPipeIn)
 	xpathAction.AddOutput(XPathActionOutputStr, dataPipeOut)
 
-	xpathAction.Run() // Must not crash.
+	xpathAction.Run(context.Background()) // Must not crash.
 }
 
 func TestXPathActionBadXPath(t *testing.T) {
@@ -111,7 +115,7 @@ func TestXPathActionBadXPath(t *testing.T) {
 	xpathAction.AddInput(XPathActionInputHTMLStr, dataPipeIn)
 	xpathAction.AddOutput(XPathActionOutputStr, dataPipeOut)
 
-	err := xpathAction.Run() // Must not crash.
+	err := xpathAction.Run(context.Background()) // Must not crash.
 	assert.NotNil(t, err)
 }
 
@@ -128,7 +132,7 @@ func TestXPathActionAttrib(t *testing.T) {
 	xpathAction.AddInput(XPathActionInputHTMLBytes, dataPipeIn)
 	xpathAction.AddOutput(XPathActionOutputStr, dataPipeOut)
 
-	err := xpathAction.Run()
+	err := xpathAction.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotResult, ok := dataPipeOut.Remove().(string)
@@ -150,7 +154,7 @@ func TestXPathActionRunHTMLUnescape(t *testing.T) {
 	action.AddInput(XPathActionInputHTMLStr, inDP)
 	action.AddOutput(XPathActionOutputStr, outDP)
 
-	err := action.Run()
+	err := action.Run(context.Background())
 	assert.Nil(t, err)
 
 	gotResult, ok := outDP.Remove().(string)
@@ -158,3 +162,26 @@ func TestXPathActionRunHTMLUnescape(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "/WebResource.axd?d=pynGkmcFUV13He1Qd6_TZMf3uKkrnZDqWIncPpA2JyCKNI3abPgg4VFK3aIP8IptHTidNt0q28y-r61APewz1A2&t=637729441680000000", gotResult)
 }
+
+func TestXPathActionRunStreamResults(t *testing.T) {
+	inputStr := "<html><body><a href=\"/1\">1</a><a href=\"/2\">2</a><a href=\"/3\">3</a></body></html>"
+
+	dataPipeIn := NewDataPipe()
+	dataPipeOut := NewDataPipe()
+
+	dataPipeIn.Add(inputStr)
+
+	xpathAction := NewXPathAction("//a/@href", true)
+	xpathAction.StreamResults = true
+
+	xpathAction.AddInput(XPathActionInputHTMLStr, dataPipeIn)
+	xpathAction.AddOutput(XPathActionOutputStr, dataPipeOut)
+
+	err := xpathAction.Run(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, 3, dataPipeOut.Len())
+	assert.Equal(t, "/1", dataPipeOut.Remove())
+	assert.Equal(t, "/2", dataPipeOut.Remove())
+	assert.Equal(t, "/3", dataPipeOut.Remove())
+}
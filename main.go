@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime/pprof"
 	"strconv"
+	"syscall"
 	"time"
 
 	spsw "github.com/spiderswarm/spiderswarm/lib"
@@ -20,14 +22,46 @@ func printUsage() {
 	fmt.Println("Run in single mode mode:")
 	fmt.Println("  spiderswarm singlenode <backendAddr> <yamlFilePath> [--validate-only]")
 	fmt.Println("")
+	fmt.Println("Use mem:// as backendAddr in single node mode to run without Redis.")
+	fmt.Println("Use sqlite://<dbFilePath> as backendAddr to keep messages in SQLite database file.")
+	fmt.Println("")
+	fmt.Println("Environment variables:")
+	fmt.Println("  SPSW_LOGLEVEL - log level (default: debug)")
+	fmt.Println("  SPSW_VISIBILITY_TIMEOUT - how long a task may run before it's redelivered (e.g. 10m)")
+	fmt.Println("  SPSW_MAX_DELIVERIES - how many times a task is delivered before it's considered failed")
+	fmt.Println("  SPSW_SHUTDOWN_TIMEOUT - how long to wait for running tasks on SIGTERM or SIGINT (default: 25s)")
+	fmt.Println("")
 	fmt.Println("Run as worker with given number of worker goroutines:")
-	fmt.Println("  spiderswarm worker <n> <backendAddr>")
+	fmt.Println("  spiderswarm worker <n> <backendAddr> [--labels <key=value,...>]")
+	fmt.Println("")
+	fmt.Println("Use --labels to run tasks whose RequiredLabels are all among given labels.")
+	fmt.Println("")
+	fmt.Println("Run as manager, either for a single workflow or taking jobs submitted to master:")
+	fmt.Println("  spiderswarm manager <backendAddr> [yamlFilePath]")
 	fmt.Println("")
-	fmt.Println("Run as manager:")
-	fmt.Println("  spiderswarm manager <backendAddr> <yamlFilePath>")
+	fmt.Println("Run as manager resuming job from its latest checkpoint, e.g. after a crash:")
+	fmt.Println("  spiderswarm manager <backendAddr> --resume <jobUUID>")
+	fmt.Println("")
+	fmt.Println("Run as master serving job management API:")
+	fmt.Println("  spiderswarm master <listenAddr> <backendAddr> [storeAddr] [--schedules <yamlFilePath>]")
+	fmt.Println("")
+	fmt.Println("Use sqlite://<dbFilePath> or file://<dirPath> as storeAddr to keep job history.")
+	fmt.Println("Use --schedules to start jobs on cron expressions or fixed intervals from given file.")
+	fmt.Println("")
+	fmt.Println("Manage jobs through master API (see `spiderswarm client` for details):")
+	fmt.Println("  spiderswarm client [--master <addr>] [--json] jobs|managers|workers|schedules|workflows ...")
 	fmt.Println("")
 	fmt.Println("Run as exporter:")
 	fmt.Println("  spiderswarm exporter <outputDir> <backendAddr>")
+	fmt.Println("")
+	fmt.Println("List tasks that ran out of attempts, optionally for given job only:")
+	fmt.Println("  spiderswarm deadletters <backendAddr> [jobUUID]")
+	fmt.Println("")
+	fmt.Println("Re-drive dead letters of given job (run workers separately):")
+	fmt.Println("  spiderswarm redrive <backendAddr> <yamlFilePath> <jobUUID>")
+	fmt.Println("")
+	fmt.Println("Pause, resume or cancel job directly over the bus, without master:")
+	fmt.Println("  spiderswarm jobcontrol <backendAddr> pause|resume|cancel <jobUUID>")
 }
 
 func getWorkflow(filePath string) *spsw.Workflow {
@@ -48,6 +82,25 @@ func getWorkflow(filePath string) *spsw.Workflow {
 	return workflow
 }
 
+// shutdownOnSignal waits for SIGTERM or SIGINT and then shuts down whatever runner has
+// started. Another signal makes the process exit right away. Returns false if shutdown
+// did not finish in time.
+func shutdownOnSignal(runner *spsw.Runner, timeout time.Duration) bool {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	sig := <-signals
+	log.Info(fmt.Sprintf("Got %v, shutting down", sig))
+
+	go func() {
+		sig := <-signals
+		log.Warn(fmt.Sprintf("Got %v again, exiting without waiting for shutdown", sig))
+		os.Exit(1)
+	}()
+
+	return runner.Shutdown(timeout)
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
@@ -70,6 +123,35 @@ func main() {
 
 	runner := &spsw.Runner{}
 
+	if visibilityTimeoutStr := os.Getenv("SPSW_VISIBILITY_TIMEOUT"); visibilityTimeoutStr != "" {
+		visibilityTimeout, err := time.ParseDuration(visibilityTimeoutStr)
+		if err != nil {
+			log.Fatal("could not parse SPSW_VISIBILITY_TIMEOUT: ", err)
+		}
+
+		runner.VisibilityTimeout = visibilityTimeout
+	}
+
+	if maxDeliveriesStr := os.Getenv("SPSW_MAX_DELIVERIES"); maxDeliveriesStr != "" {
+		maxDeliveries, err := strconv.ParseInt(maxDeliveriesStr, 10, 64)
+		if err != nil {
+			log.Fatal("could not parse SPSW_MAX_DELIVERIES: ", err)
+		}
+
+		runner.MaxDeliveries = maxDeliveries
+	}
+
+	shutdownTimeout := spsw.RunnerDefaultShutdownTimeout
+
+	if shutdownTimeoutStr := os.Getenv("SPSW_SHUTDOWN_TIMEOUT"); shutdownTimeoutStr != "" {
+		var err error
+
+		shutdownTimeout, err = time.ParseDuration(shutdownTimeoutStr)
+		if err != nil {
+			log.Fatal("could not parse SPSW_SHUTDOWN_TIMEOUT: ", err)
+		}
+	}
+
 	switch os.Args[1] {
 	case "singlenode":
 		if len(os.Args) != 4 && len(os.Args) != 5 {
@@ -92,28 +174,80 @@ func main() {
 		}
 
 		runner.BackendAddr = backendAddr
+
+		go func() {
+			if !shutdownOnSignal(runner, shutdownTimeout) {
+				os.Exit(1)
+			}
+
+			os.Exit(0)
+		}()
+
 		runner.RunSingleNode(4, ".", workflow)
 		time.Sleep(1 * time.Second)
 	case "worker":
-		if len(os.Args) != 4 {
+		args := os.Args[2:]
+
+		if len(args) == 4 && args[2] == "--labels" {
+			labels, err := spsw.ParseLabels(args[3])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			runner.WorkerLabels = labels
+			args = args[:2]
+		}
+
+		if len(args) != 2 {
 			printUsage()
 			os.Exit(0)
 		}
 
-		n, _ := strconv.Atoi(os.Args[2])
-		backendAddr := os.Args[3]
+		n, _ := strconv.Atoi(args[0])
+		backendAddr := args[1]
 		runner.BackendAddr = backendAddr
 		runner.RunWorkers(n)
-		for {
-			select {}
+		if !shutdownOnSignal(runner, shutdownTimeout) {
+			os.Exit(1)
 		}
 	case "manager":
-		if len(os.Args) != 4 {
+		if len(os.Args) != 3 && len(os.Args) != 4 && len(os.Args) != 5 {
 			printUsage()
 			os.Exit(0)
 		}
 
 		backendAddr := os.Args[2]
+		runner.BackendAddr = backendAddr
+
+		if len(os.Args) == 5 {
+			if os.Args[3] != "--resume" {
+				printUsage()
+				os.Exit(0)
+			}
+
+			_, err := runner.ResumeManager(os.Args[4])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if !shutdownOnSignal(runner, shutdownTimeout) {
+				os.Exit(1)
+			}
+
+			return
+		}
+
+		if len(os.Args) == 3 {
+			runner.ServeManager()
+			if !shutdownOnSignal(runner, shutdownTimeout) {
+				os.Exit(1)
+			}
+
+			return
+		}
+
 		yamlFilePath := os.Args[3]
 		workflow := getWorkflow(yamlFilePath)
 
@@ -123,10 +257,34 @@ func main() {
 			os.Exit(1)
 		}
 
-		runner.BackendAddr = backendAddr
 		runner.RunManager(workflow)
-		for {
-			select {}
+		if !shutdownOnSignal(runner, shutdownTimeout) {
+			os.Exit(1)
+		}
+	case "master":
+		args := os.Args[2:]
+
+		if len(args) >= 2 && args[len(args)-2] == "--schedules" {
+			runner.SchedulesFilePath = args[len(args)-1]
+			args = args[:len(args)-2]
+		}
+
+		if len(args) != 2 && len(args) != 3 {
+			printUsage()
+			os.Exit(0)
+		}
+
+		listenAddr := args[0]
+		backendAddr := args[1]
+		runner.BackendAddr = backendAddr
+
+		if len(args) == 3 {
+			runner.MasterStoreAddr = args[2]
+		}
+
+		runner.RunMaster(listenAddr)
+		if !shutdownOnSignal(runner, shutdownTimeout) {
+			os.Exit(1)
 		}
 	case "exporter":
 		if len(os.Args) != 4 {
@@ -138,12 +296,75 @@ func main() {
 		backendAddr := os.Args[3]
 		runner.BackendAddr = backendAddr
 		runner.RunExporter(outputDir)
-		for {
-			select {}
+		if !shutdownOnSignal(runner, shutdownTimeout) {
+			os.Exit(1)
+		}
+	case "deadletters":
+		if len(os.Args) != 3 && len(os.Args) != 4 {
+			printUsage()
+			os.Exit(0)
+		}
+
+		jobUUID := ""
+		if len(os.Args) == 4 {
+			jobUUID = os.Args[3]
+		}
+
+		runner.BackendAddr = os.Args[2]
+		deadLetters, err := runner.ListDeadLetters(jobUUID)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, deadLetter := range deadLetters {
+			fmt.Println(deadLetter)
+		}
+	case "redrive":
+		if len(os.Args) != 5 {
+			printUsage()
+			os.Exit(0)
+		}
+
+		backendAddr := os.Args[2]
+		yamlFilePath := os.Args[3]
+		jobUUID := os.Args[4]
+		workflow := getWorkflow(yamlFilePath)
+
+		success, err := workflow.Validate()
+		if !success {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		runner.BackendAddr = backendAddr
+		n, err := runner.RedriveDeadLetters(workflow, jobUUID)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Re-drove %d dead letters\n", n)
+	case "jobcontrol":
+		if len(os.Args) != 5 {
+			printUsage()
+			os.Exit(0)
+		}
+
+		action, found := spsw.JobControlActionsByName[os.Args[3]]
+		if !found {
+			printUsage()
+			os.Exit(1)
+		}
+
+		runner.BackendAddr = os.Args[2]
+		err := runner.SendJobControl(os.Args[4], action)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
 	case "client":
-		// TODO: client for REST API
-		log.Error("client part not implemented yet")
+		os.Exit(runClient(os.Args[2:]))
 	default:
 		printUsage()
 	}